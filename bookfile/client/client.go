@@ -1,38 +1,205 @@
 package client
 
 import (
-	"github.com/nats-io/nats.go/jetstream"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
 
-	"github.com/lunn06/library/bookfile/internal/app/repository"
-	"github.com/lunn06/library/bookfile/internal/app/service"
-	"github.com/lunn06/library/bookfile/internal/infrastructure/db/js"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+	"github.com/lunn06/library/bookfile/internal/api/transfer"
+	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
 )
 
+const defaultTimeout = 30 * time.Second
+
 func New(config Config) (*Client, error) {
-	jstream, err := js.NewJetStream(config.URL)
+	conn, err := nats.Connect(config.URL)
 	if err != nil {
 		return nil, err
 	}
 
-	objStore, err := js.NewObjectStore(jstream)
-	if err != nil {
-		return nil, err
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
 	}
 
-	repo := repository.NewJsBookRepo(objStore)
-	bookService := service.NewBookService(repo)
-
 	return &Client{
-		BookService: bookService,
-		jstream:     jstream,
+		conn:      conn,
+		timeout:   timeout,
+		chunkSize: config.ChunkSize,
 	}, nil
 }
 
+// Client talks to the bookfile service over NATS, files are transferred
+// in chunks as they are read.
 type Client struct {
-	*service.BookService
-	jstream jetstream.JetStream
+	conn      *nats.Conn
+	timeout   time.Duration
+	chunkSize int
+}
+
+func (c *Client) Info(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
+	return c.info(ctx, &transferpb.InfoRequest{BookUuid: bookUUID.String()})
+}
+
+func (c *Client) FindByDigest(ctx context.Context, digest string) (domain.Book, error) {
+	return c.info(ctx, &transferpb.InfoRequest{Digest: digest})
+}
+
+func (c *Client) info(ctx context.Context, req *transferpb.InfoRequest) (domain.Book, error) {
+	var resp transferpb.InfoResponse
+	if err := c.request(ctx, "bookfile.info", req, &resp); err != nil {
+		return domain.Book{}, err
+	}
+	if err := statusError(resp.StatusCode); err != nil {
+		return domain.Book{}, err
+	}
+
+	return transfer.BookFromProto(resp.Book)
+}
+
+// Get returns the book with its file, which must be closed
+// unless read to the end.
+func (c *Client) Get(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
+	return c.get(ctx, &transferpb.GetRequest{BookUuid: bookUUID.String()})
+}
+
+func (c *Client) GetRange(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	offset uint64,
+	length uint64,
+) (domain.Book, error) {
+	return c.get(ctx, &transferpb.GetRequest{
+		BookUuid: bookUUID.String(),
+		Range: &transferpb.Range{
+			Offset: offset,
+			Length: length,
+		},
+	})
+}
+
+func (c *Client) get(ctx context.Context, req *transferpb.GetRequest) (domain.Book, error) {
+	var resp transferpb.StreamResponse
+	if err := c.request(ctx, "bookfile.get", req, &resp); err != nil {
+		return domain.Book{}, err
+	}
+	if err := statusError(resp.StatusCode); err != nil {
+		return domain.Book{}, err
+	}
+
+	book, err := transfer.BookFromProto(resp.Book)
+	if err != nil {
+		return domain.Book{}, err
+	}
+	book.FileReadCloser = transfer.NewReader(c.conn, resp.Subject, c.timeout)
+
+	return book, nil
+}
+
+func (c *Client) Cover(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	size string,
+	mimeType string,
+) (domain.Cover, error) {
+	var resp transferpb.StreamResponse
+	req := transferpb.CoverRequest{
+		BookUuid: bookUUID.String(),
+		Size:     size,
+		MimeType: mimeType,
+	}
+	if err := c.request(ctx, "bookfile.cover", &req, &resp); err != nil {
+		return domain.Cover{}, err
+	}
+	if err := statusError(resp.StatusCode); err != nil {
+		return domain.Cover{}, err
+	}
+
+	cover, err := transfer.CoverFromProto(resp.Cover)
+	if err != nil {
+		return domain.Cover{}, err
+	}
+	cover.FileReadCloser = transfer.NewReader(c.conn, resp.Subject, c.timeout)
+
+	return cover, nil
+}
+
+// Create uploads the book and closes r, errors of reading r are returned as is.
+func (c *Client) Create(ctx context.Context, fileName string, r io.ReadCloser) (domain.Book, error) {
+	if r == nil {
+		return domain.Book{}, errors.New("buffer is nil")
+	}
+	defer r.Close()
+
+	var opened transferpb.StreamResponse
+	if err := c.request(ctx, "bookfile.put", &transferpb.PutRequest{FileName: fileName}, &opened); err != nil {
+		return domain.Book{}, err
+	}
+	if err := statusError(opened.StatusCode); err != nil {
+		return domain.Book{}, err
+	}
+
+	resp, err := transfer.Send(c.conn, opened.Subject, r, c.chunkSize, c.timeout)
+	if err != nil {
+		return domain.Book{}, err
+	}
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		return domain.Book{}, servicerrors.ErrInvalidFormat{FileName: fileName}
+	}
+	if err = statusError(resp.StatusCode); err != nil {
+		return domain.Book{}, err
+	}
+
+	return transfer.BookFromProto(resp.Book)
+}
+
+func (c *Client) Delete(ctx context.Context, bookUUID uuid.UUID) error {
+	var resp transferpb.DeleteResponse
+	err := c.request(ctx, "bookfile.delete", &transferpb.DeleteRequest{BookUuid: bookUUID.String()}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return statusError(resp.StatusCode)
 }
 
 func (c *Client) Close() error {
-	return c.jstream.Conn().Drain()
+	return c.conn.Drain()
+}
+
+func (c *Client) request(ctx context.Context, subject string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	msg, err := c.conn.RequestWithContext(ctx, subject, data)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(msg.Data, resp)
+}
+
+func statusError(code int32) error {
+	switch code {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return servicerrors.ErrResourceNotFound{Inner: errors.New("bookfile responded with 404")}
+	default:
+		return fmt.Errorf("bookfile responded with %d", code)
+	}
 }
@@ -1,5 +1,9 @@
 package client
 
+import "time"
+
 type Config struct {
-	URL string `default:"nats://localhost:4222"`
+	URL       string        `default:"nats://localhost:4222"`
+	Timeout   time.Duration `default:"30s"`
+	ChunkSize int           `default:"524288" split_words:"true"`
 }
@@ -0,0 +1,11 @@
+package client
+
+import (
+	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+)
+
+var (
+	IsErrResourceNotFound = servicerrors.IsErrResourceNotFound
+	IsErrInvalidFormat    = servicerrors.IsErrInvalidFormat
+	IsErrUploadConflict   = servicerrors.IsErrUploadConflict
+)
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+	"github.com/lunn06/library/bookfile/internal/api/transfer"
+	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+// Upload is the state of an upload made with the client.
+type Upload = domain.Upload
+
+// CreateUpload starts an upload of length bytes to be sent in parts
+// with AppendUpload.
+func (c *Client) CreateUpload(ctx context.Context, fileName string, length uint64) (domain.Upload, error) {
+	var resp transferpb.UploadResponse
+	req := transferpb.CreateUploadRequest{
+		FileName: fileName,
+		Length:   length,
+	}
+	if err := c.request(ctx, "bookfile.upload.create", &req, &resp); err != nil {
+		return domain.Upload{}, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return domain.Upload{}, statusError(resp.StatusCode)
+	}
+
+	return transfer.UploadFromProto(resp.Upload)
+}
+
+func (c *Client) Upload(ctx context.Context, uploadID uuid.UUID) (domain.Upload, error) {
+	var resp transferpb.UploadResponse
+	if err := c.request(ctx, "bookfile.upload.info", &transferpb.UploadRequest{UploadId: uploadID.String()}, &resp); err != nil {
+		return domain.Upload{}, err
+	}
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		return domain.Upload{}, servicerrors.ErrInvalidFormat{}
+	}
+	if err := statusError(resp.StatusCode); err != nil {
+		return domain.Upload{}, err
+	}
+
+	return transfer.UploadFromProto(resp.Upload)
+}
+
+// AppendUpload sends the part of the upload starting at offset. Once the
+// last part is there the upload is assembled into a book in the background,
+// Upload tells when it is done.
+func (c *Client) AppendUpload(
+	ctx context.Context,
+	uploadID uuid.UUID,
+	offset uint64,
+	r io.Reader,
+) (domain.Upload, error) {
+	var opened transferpb.StreamResponse
+	req := transferpb.AppendRequest{
+		UploadId: uploadID.String(),
+		Offset:   offset,
+	}
+	if err := c.request(ctx, "bookfile.upload.append", &req, &opened); err != nil {
+		return domain.Upload{}, err
+	}
+	if err := statusError(opened.StatusCode); err != nil {
+		return domain.Upload{}, err
+	}
+
+	resp, err := transfer.Send(c.conn, opened.Subject, r, c.chunkSize, c.timeout)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		return domain.Upload{}, servicerrors.ErrUploadConflict{Offset: resp.GetUpload().GetOffset()}
+	case http.StatusUnprocessableEntity:
+		return domain.Upload{}, servicerrors.ErrInvalidFormat{}
+	}
+	if err = statusError(resp.StatusCode); err != nil {
+		return domain.Upload{}, err
+	}
+
+	return transfer.UploadFromProto(resp.Upload)
+}
+
+func (c *Client) DeleteUpload(ctx context.Context, uploadID uuid.UUID) error {
+	var resp transferpb.DeleteResponse
+	err := c.request(ctx, "bookfile.upload.delete", &transferpb.UploadRequest{UploadId: uploadID.String()}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return statusError(resp.StatusCode)
+}
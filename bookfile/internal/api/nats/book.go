@@ -0,0 +1,274 @@
+package nats
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+	"github.com/lunn06/library/bookfile/internal/api/transfer"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+	"github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+func RegisterBookConsumer(conn *nats.Conn, cons *BookConsumer) error {
+	mws := []middleware.Middleware{
+		middleware.Recover(),
+		middleware.Logger(slog.Default()),
+	}
+	for subj, handler := range map[string]nats.MsgHandler{
+		"bookfile.info":   cons.Info,
+		"bookfile.get":    cons.Get,
+		"bookfile.cover":  cons.Cover,
+		"bookfile.put":    cons.Put,
+		"bookfile.delete": cons.Delete,
+	} {
+		_, err := conn.QueueSubscribe(subj, queueGroup, middleware.With(handler, mws...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewBookConsumer(conn *nats.Conn, service *service.BookService, cfg Config) *BookConsumer {
+	return &BookConsumer{
+		conn:     conn,
+		service:  service,
+		transfer: cfg.Transfer,
+	}
+}
+
+type BookConsumer struct {
+	conn     *nats.Conn
+	service  *service.BookService
+	transfer transfer.Config
+}
+
+// Info looks a book up by uuid or, when it is set, by content digest.
+func (bc BookConsumer) Info(msg *nats.Msg) {
+	var (
+		req  transferpb.InfoRequest
+		resp transferpb.InfoResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on book info", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	var book domain.Book
+	if req.Digest != "" {
+		book, err = bc.service.FindByDigest(context.Background(), req.Digest)
+	} else {
+		var bookUUID uuid.UUID
+		if bookUUID, err = uuid.Parse(req.BookUuid); err != nil {
+			resp.StatusCode = http.StatusUnprocessableEntity
+			return
+		}
+		book, err = bc.service.Info(context.Background(), bookUUID)
+	}
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Book = transfer.BookToProto(book)
+	resp.StatusCode = http.StatusOK
+}
+
+// Get opens a download of the book file or of the requested range of it.
+func (bc BookConsumer) Get(msg *nats.Msg) {
+	var (
+		req  transferpb.GetRequest
+		resp transferpb.StreamResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on book get", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	bookUUID, err := uuid.Parse(req.BookUuid)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	var book domain.Book
+	if rng := req.GetRange(); rng != nil {
+		book, err = bc.service.GetRange(context.Background(), bookUUID, rng.Offset, rng.Length)
+	} else {
+		book, err = bc.service.Get(context.Background(), bookUUID)
+	}
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Subject, err = transfer.Serve(bc.conn, book.FileReadCloser, bc.transfer)
+	if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Book = transfer.BookToProto(book)
+	resp.StatusCode = http.StatusOK
+}
+
+func (bc BookConsumer) Cover(msg *nats.Msg) {
+	var (
+		req  transferpb.CoverRequest
+		resp transferpb.StreamResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on book cover", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	bookUUID, err := uuid.Parse(req.BookUuid)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	cover, err := bc.service.Cover(context.Background(), bookUUID, req.Size, req.MimeType)
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Subject, err = transfer.Serve(bc.conn, cover.FileReadCloser, bc.transfer)
+	if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Cover = transfer.CoverToProto(cover)
+	resp.StatusCode = http.StatusOK
+}
+
+// Put opens an upload, the book is stored as its chunks arrive.
+func (bc BookConsumer) Put(msg *nats.Msg) {
+	var (
+		req  transferpb.PutRequest
+		resp transferpb.StreamResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on book put", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	resp.Subject, err = transfer.Receive(bc.conn, bc.transfer, func(r io.ReadCloser) *transferpb.StreamResponse {
+		return bc.create(req.FileName, r)
+	})
+	if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (bc BookConsumer) create(fileName string, r io.ReadCloser) *transferpb.StreamResponse {
+	book, err := bc.service.Create(context.Background(), fileName, r)
+	if errors.IsErrInvalidFormat(err) {
+		return &transferpb.StreamResponse{StatusCode: http.StatusUnprocessableEntity}
+	} else if err != nil {
+		slog.Error("Error on book create", "err", err)
+		return &transferpb.StreamResponse{StatusCode: http.StatusInternalServerError}
+	}
+
+	return &transferpb.StreamResponse{
+		Book:       transfer.BookToProto(book),
+		StatusCode: http.StatusOK,
+	}
+}
+
+func (bc BookConsumer) Delete(msg *nats.Msg) {
+	var (
+		req  transferpb.DeleteRequest
+		resp transferpb.DeleteResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on book delete", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	bookUUID, err := uuid.Parse(req.BookUuid)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = bc.service.Delete(context.Background(), bookUUID)
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
@@ -0,0 +1,51 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	bookpb "github.com/lunn06/library/bookfile/internal/api/proto/book"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+)
+
+const bookInfoTimeout = 10 * time.Second
+
+var _ service.BookURLChecker = (*BookInfoClient)(nil)
+
+func NewBookInfoClient(conn *nats.Conn) *BookInfoClient {
+	return &BookInfoClient{conn: conn}
+}
+
+// BookInfoClient asks bookinfo about catalogue books.
+type BookInfoClient struct {
+	conn *nats.Conn
+}
+
+func (bc *BookInfoClient) MissingBookURLs(ctx context.Context, bookURLs []string) ([]string, error) {
+	data, err := proto.Marshal(&bookpb.ExistsRequest{BookUrls: bookURLs})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bookInfoTimeout)
+	defer cancel()
+	msg, err := bc.conn.RequestWithContext(ctx, "book.exists", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp bookpb.ExistsResponse
+	if err = proto.Unmarshal(msg.Data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("book.exists responded with status %d", resp.StatusCode)
+	}
+
+	return resp.MissingBookUrls, nil
+}
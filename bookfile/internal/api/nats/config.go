@@ -0,0 +1,8 @@
+package nats
+
+import "github.com/lunn06/library/bookfile/internal/api/transfer"
+
+type Config struct {
+	URL      string `default:"nats://127.0.0.1:4222"`
+	Transfer transfer.Config
+}
@@ -0,0 +1,85 @@
+package nats
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lunn06/library/bookinfo/pkg/events"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/fx"
+	"google.golang.org/protobuf/proto"
+
+	eventspb "github.com/lunn06/library/bookfile/internal/api/proto/events"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const (
+	bookEventsDurable = "bookfile"
+	retryDelay        = 5 * time.Second
+)
+
+// RegisterBookEventsConsumer subscribes to book.deleted with a durable
+// consumer, so events published while the service is down are not lost.
+func RegisterBookEventsConsumer(lifecycle fx.Lifecycle, js jetstream.JetStream, cons *BookEventsConsumer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := events.EnsureStream(ctx, js)
+	if err != nil {
+		return err
+	}
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       bookEventsDurable,
+		FilterSubject: events.BookDeleted,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	consumeCtx, err := consumer.Consume(cons.BookDeleted)
+	if err != nil {
+		return err
+	}
+	lifecycle.Append(fx.StopHook(consumeCtx.Stop))
+
+	return nil
+}
+
+func NewBookEventsConsumer(service *service.BookService) *BookEventsConsumer {
+	return &BookEventsConsumer{
+		service: service,
+	}
+}
+
+type BookEventsConsumer struct {
+	service *service.BookService
+}
+
+// BookDeleted releases the file of the deleted book, books referring to
+// files stored elsewhere are skipped.
+func (bc BookEventsConsumer) BookDeleted(msg jetstream.Msg) {
+	var event eventspb.BookDeleted
+	if err := proto.Unmarshal(msg.Data(), &event); err != nil {
+		slog.Error("Malformed book.deleted event", "err", err)
+		_ = msg.Term()
+		return
+	}
+
+	bookUUID, ok := domain.ParseBookURL(event.BookUrl)
+	if !ok {
+		_ = msg.Ack()
+		return
+	}
+
+	if err := bc.service.Release(context.Background(), event.BookId, bookUUID); err != nil {
+		slog.Error("Error on releasing file of deleted book", "bookID", event.BookId, "err", err)
+		_ = msg.NakWithDelay(retryDelay)
+		return
+	}
+
+	slog.Info("Released file of deleted book", "bookID", event.BookId, "bookUUID", bookUUID)
+	_ = msg.Ack()
+}
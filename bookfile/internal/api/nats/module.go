@@ -0,0 +1,25 @@
+package nats
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/bookfile/internal/app/service"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		NewConnection,
+		NewBookConsumer,
+		NewUploadConsumer,
+		NewBookEventsConsumer,
+		fx.Annotate(
+			NewBookInfoClient,
+			fx.As(new(service.BookURLChecker)),
+		),
+	),
+	fx.Invoke(
+		RegisterBookConsumer,
+		RegisterUploadConsumer,
+		RegisterBookEventsConsumer,
+	),
+)
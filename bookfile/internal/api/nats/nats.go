@@ -0,0 +1,34 @@
+package nats
+
+import (
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+)
+
+// queueGroup spreads the requests among the replicas of bookfile, so that
+// each request is handled once.
+const queueGroup = "bookfile"
+
+func NewConnection(cfg Config) (*nats.Conn, error) {
+	return nats.Connect(cfg.URL)
+}
+
+func NewResponder(msg *nats.Msg) Responder {
+	return Responder{msg: msg}
+}
+
+type Responder struct {
+	msg *nats.Msg
+}
+
+func (r Responder) Respond(resp proto.Message) {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		slog.Error("Failed to marshal response", "err", err)
+		return
+	}
+
+	_ = r.msg.Respond(data)
+}
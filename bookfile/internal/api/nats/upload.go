@@ -0,0 +1,222 @@
+package nats
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+	"github.com/lunn06/library/bookfile/internal/api/transfer"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+	"github.com/lunn06/library/bookfile/internal/app/service/errors"
+)
+
+func RegisterUploadConsumer(conn *nats.Conn, cons *UploadConsumer) error {
+	mws := []middleware.Middleware{
+		middleware.Recover(),
+		middleware.Logger(slog.Default()),
+	}
+	for subj, handler := range map[string]nats.MsgHandler{
+		"bookfile.upload.create": cons.Create,
+		"bookfile.upload.info":   cons.Info,
+		"bookfile.upload.append": cons.Append,
+		"bookfile.upload.delete": cons.Delete,
+	} {
+		_, err := conn.QueueSubscribe(subj, queueGroup, middleware.With(handler, mws...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewUploadConsumer(conn *nats.Conn, service *service.UploadService, cfg Config) *UploadConsumer {
+	return &UploadConsumer{
+		conn:     conn,
+		service:  service,
+		transfer: cfg.Transfer,
+	}
+}
+
+type UploadConsumer struct {
+	conn     *nats.Conn
+	service  *service.UploadService
+	transfer transfer.Config
+}
+
+func (uc UploadConsumer) Create(msg *nats.Msg) {
+	var (
+		req  transferpb.CreateUploadRequest
+		resp transferpb.UploadResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on upload create", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	upload, err := uc.service.Create(context.Background(), req.FileName, req.Length)
+	if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Upload = transfer.UploadToProto(upload)
+	resp.StatusCode = http.StatusCreated
+}
+
+func (uc UploadConsumer) Info(msg *nats.Msg) {
+	var (
+		req  transferpb.UploadRequest
+		resp transferpb.UploadResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on upload info", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	uploadID, err := uuid.Parse(req.UploadId)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	upload, err := uc.service.Get(context.Background(), uploadID)
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrInvalidFormat(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Upload = transfer.UploadToProto(upload)
+	resp.StatusCode = http.StatusOK
+}
+
+// Append opens a transfer of the part starting at the requested offset.
+func (uc UploadConsumer) Append(msg *nats.Msg) {
+	var (
+		req  transferpb.AppendRequest
+		resp transferpb.StreamResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on upload append", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	uploadID, err := uuid.Parse(req.UploadId)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	resp.Subject, err = transfer.Receive(uc.conn, uc.transfer, func(r io.ReadCloser) *transferpb.StreamResponse {
+		return uc.append(uploadID, req.Offset, r)
+	})
+	if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (uc UploadConsumer) append(uploadID uuid.UUID, offset uint64, r io.ReadCloser) *transferpb.StreamResponse {
+	upload, err := uc.service.Append(context.Background(), uploadID, offset, r)
+	var conflict errors.ErrUploadConflict
+	switch {
+	case errors.IsErrResourceNotFound(err):
+		return &transferpb.StreamResponse{StatusCode: http.StatusNotFound}
+	case stderrors.As(err, &conflict):
+		return &transferpb.StreamResponse{
+			StatusCode: http.StatusConflict,
+			Upload:     &transferpb.UploadInfo{UploadId: uploadID.String(), Offset: conflict.Offset},
+		}
+	case errors.IsErrInvalidFormat(err):
+		return &transferpb.StreamResponse{StatusCode: http.StatusUnprocessableEntity}
+	case err != nil:
+		slog.Error("Error on upload append", "err", err)
+		return &transferpb.StreamResponse{StatusCode: http.StatusInternalServerError}
+	}
+
+	return &transferpb.StreamResponse{
+		Upload:     transfer.UploadToProto(upload),
+		StatusCode: http.StatusOK,
+	}
+}
+
+// Delete terminates the upload and drops the parts stored so far.
+func (uc UploadConsumer) Delete(msg *nats.Msg) {
+	var (
+		req  transferpb.UploadRequest
+		resp transferpb.DeleteResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on upload delete", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	uploadID, err := uuid.Parse(req.UploadId)
+	if err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = uc.service.Delete(context.Background(), uploadID)
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
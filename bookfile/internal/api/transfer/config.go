@@ -0,0 +1,23 @@
+package transfer
+
+import "time"
+
+const (
+	DefaultChunkSize   = 512 * 1024
+	DefaultIdleTimeout = 30 * time.Second
+)
+
+type Config struct {
+	ChunkSize   int           `default:"524288" split_words:"true"`
+	IdleTimeout time.Duration `default:"30s" split_words:"true"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	return c
+}
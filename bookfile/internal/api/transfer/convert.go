@@ -0,0 +1,122 @@
+package transfer
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+func BookToProto(book domain.Book) *transferpb.BookInfo {
+	return &transferpb.BookInfo{
+		BookUuid:    book.UUID.String(),
+		FileName:    book.FileName,
+		Format:      string(book.Format),
+		Size:        book.Size,
+		Digest:      book.Digest,
+		ModTime:     book.ModTime.Unix(),
+		Title:       book.Metadata.Title,
+		Creators:    book.Metadata.Creators,
+		Language:    book.Metadata.Language,
+		Publisher:   book.Metadata.Publisher,
+		Isbn:        book.Metadata.ISBN,
+		Description: book.Metadata.Description,
+		HasCover:    book.Metadata.HasCover,
+	}
+}
+
+func BookFromProto(info *transferpb.BookInfo) (domain.Book, error) {
+	bookUUID, err := uuid.Parse(info.GetBookUuid())
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	return domain.Book{
+		UUID:     bookUUID,
+		FileName: info.GetFileName(),
+		Format:   domain.Format(info.GetFormat()),
+		Size:     info.GetSize(),
+		Digest:   info.GetDigest(),
+		ModTime:  time.Unix(info.GetModTime(), 0),
+		Metadata: domain.Metadata{
+			Title:       info.GetTitle(),
+			Creators:    info.GetCreators(),
+			Language:    info.GetLanguage(),
+			Publisher:   info.GetPublisher(),
+			ISBN:        info.GetIsbn(),
+			Description: info.GetDescription(),
+			HasCover:    info.GetHasCover(),
+		},
+	}, nil
+}
+
+func CoverToProto(cover domain.Cover) *transferpb.CoverInfo {
+	return &transferpb.CoverInfo{
+		BookUuid: cover.BookUUID.String(),
+		Size:     string(cover.Size),
+		MimeType: cover.MIMEType,
+		Length:   cover.Length,
+		Digest:   cover.Digest,
+		ModTime:  cover.ModTime.Unix(),
+	}
+}
+
+func CoverFromProto(info *transferpb.CoverInfo) (domain.Cover, error) {
+	bookUUID, err := uuid.Parse(info.GetBookUuid())
+	if err != nil {
+		return domain.Cover{}, err
+	}
+
+	return domain.Cover{
+		BookUUID: bookUUID,
+		Size:     domain.CoverSize(info.GetSize()),
+		MIMEType: info.GetMimeType(),
+		Length:   info.GetLength(),
+		Digest:   info.GetDigest(),
+		ModTime:  time.Unix(info.GetModTime(), 0),
+	}, nil
+}
+
+func UploadToProto(upload domain.Upload) *transferpb.UploadInfo {
+	info := &transferpb.UploadInfo{
+		UploadId:   upload.ID.String(),
+		FileName:   upload.FileName,
+		Length:     upload.Length,
+		Offset:     upload.Offset,
+		Assembled:  upload.Assembled,
+		Assembling: upload.Assembling,
+		ExpiresAt:  upload.ExpiresAt.Unix(),
+	}
+	if upload.BookUUID != uuid.Nil {
+		info.BookUuid = upload.BookUUID.String()
+	}
+
+	return info
+}
+
+func UploadFromProto(info *transferpb.UploadInfo) (domain.Upload, error) {
+	uploadID, err := uuid.Parse(info.GetUploadId())
+	if err != nil {
+		return domain.Upload{}, err
+	}
+
+	var bookUUID uuid.UUID
+	if info.GetBookUuid() != "" {
+		if bookUUID, err = uuid.Parse(info.GetBookUuid()); err != nil {
+			return domain.Upload{}, err
+		}
+	}
+
+	return domain.Upload{
+		ID:         uploadID,
+		FileName:   info.GetFileName(),
+		Length:     info.GetLength(),
+		Offset:     info.GetOffset(),
+		BookUUID:   bookUUID,
+		Assembled:  info.GetAssembled(),
+		Assembling: info.GetAssembling(),
+		ExpiresAt:  time.Unix(info.GetExpiresAt(), 0),
+	}, nil
+}
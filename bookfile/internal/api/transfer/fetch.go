@@ -0,0 +1,132 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+)
+
+var errClosed = errors.New("transfer closed")
+
+// NewReader downloads the file served on subject, chunk by chunk as it is read.
+func NewReader(conn *nats.Conn, subject string, timeout time.Duration) *Reader {
+	return &Reader{
+		conn:    conn,
+		subject: subject,
+		timeout: timeout,
+	}
+}
+
+type Reader struct {
+	conn    *nats.Conn
+	subject string
+	timeout time.Duration
+	buf     []byte
+	eof     bool
+	err     error
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 && r.err == nil {
+		r.err = r.next()
+	}
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *Reader) next() error {
+	if r.eof {
+		return io.EOF
+	}
+
+	var chunk transferpb.Chunk
+	if err := request(r.conn, r.subject, &transferpb.Chunk{}, &chunk, r.timeout); err != nil {
+		return err
+	}
+	if chunk.StatusCode != http.StatusOK {
+		return fmt.Errorf("transfer failed with status %d", chunk.StatusCode)
+	}
+
+	r.buf, r.eof = chunk.Data, chunk.Eof
+	return nil
+}
+
+// Close lets the sender know the rest of the file isn't needed.
+func (r *Reader) Close() error {
+	if r.eof || r.err != nil {
+		r.err = errClosed
+		return nil
+	}
+	r.err = errClosed
+
+	return cancel(r.conn, r.subject)
+}
+
+// Send uploads r to subject and returns the answer to the last chunk,
+// the transfer is canceled if reading r fails.
+func Send(
+	conn *nats.Conn,
+	subject string,
+	r io.Reader,
+	chunkSize int,
+	timeout time.Duration,
+) (*transferpb.StreamResponse, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		eof := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !eof {
+			return nil, errors.Join(err, cancel(conn, subject))
+		}
+
+		var resp transferpb.StreamResponse
+		chunk := transferpb.Chunk{Data: buf[:n], Eof: eof}
+		if err = request(conn, subject, &chunk, &resp, timeout); err != nil {
+			return nil, err
+		}
+		// the receiver may be done before the last chunk, e.g. once the
+		// file turned out to be of an unsupported format
+		if eof || resp.StatusCode != http.StatusContinue {
+			return &resp, nil
+		}
+	}
+}
+
+func cancel(conn *nats.Conn, subject string) error {
+	data, err := proto.Marshal(&transferpb.Chunk{Cancel: true})
+	if err != nil {
+		return err
+	}
+
+	return conn.Publish(subject, data)
+}
+
+func request(conn *nats.Conn, subject string, req, resp proto.Message, timeout time.Duration) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	msg, err := conn.Request(subject, data, timeout)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(msg.Data, resp)
+}
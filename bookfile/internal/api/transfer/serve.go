@@ -0,0 +1,209 @@
+// Package transfer moves book files over NATS in chunks small enough for
+// a single message.
+//
+// A transfer is opened by a regular request, its reply carries a subject
+// private to the transfer. The side receiving the file then requests
+// chunks from that subject, or the side sending it requests with chunks,
+// until one of them is marked as the last. A transfer the peer abandons is
+// dropped after the idle timeout.
+package transfer
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	transferpb "github.com/lunn06/library/bookfile/internal/api/proto/transfer"
+)
+
+var (
+	errCanceled = errors.New("transfer canceled")
+	errIdle     = errors.New("transfer timed out")
+)
+
+// Serve lets r be downloaded from the returned subject, r is closed once
+// the transfer ends.
+func Serve(conn *nats.Conn, r io.ReadCloser, cfg Config) (string, error) {
+	cfg = cfg.withDefaults()
+	s := &sender{
+		r:   r,
+		buf: make([]byte, cfg.ChunkSize),
+	}
+
+	subject := nats.NewInbox()
+	sub, err := conn.Subscribe(subject, s.handle)
+	if err != nil {
+		return "", errors.Join(err, r.Close())
+	}
+	s.sub = sub
+	s.idle = newIdleTimer(cfg.IdleTimeout, s.stop)
+
+	return subject, nil
+}
+
+type sender struct {
+	mu   sync.Mutex
+	r    io.ReadCloser
+	buf  []byte
+	sub  *nats.Subscription
+	idle *idleTimer
+	done bool
+}
+
+func (s *sender) handle(msg *nats.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+
+	var req transferpb.Chunk
+	if err := proto.Unmarshal(msg.Data, &req); err != nil || req.Cancel {
+		s.close()
+		return
+	}
+	s.idle.reset()
+
+	n, err := io.ReadFull(s.r, s.buf)
+	chunk := transferpb.Chunk{
+		Data:       s.buf[:n],
+		StatusCode: http.StatusOK,
+	}
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		chunk.Eof = true
+	case err != nil:
+		slog.Error("Failed to read book file chunk", "err", err)
+		chunk.Data = nil
+		chunk.StatusCode = http.StatusInternalServerError
+	}
+
+	respond(msg, &chunk)
+	if chunk.Eof || err != nil {
+		s.close()
+	}
+}
+
+func (s *sender) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+}
+
+func (s *sender) close() {
+	if s.done {
+		return
+	}
+	s.done = true
+	s.idle.stop()
+	_ = s.sub.Unsubscribe()
+	_ = s.r.Close()
+}
+
+// Receive lets a file be uploaded to the returned subject. consume is run
+// with the uploaded content as soon as the transfer opens and the last
+// chunk is answered with what it returns, every other one with 100 Continue.
+func Receive(
+	conn *nats.Conn,
+	cfg Config,
+	consume func(r io.ReadCloser) *transferpb.StreamResponse,
+) (string, error) {
+	cfg = cfg.withDefaults()
+	pr, pw := io.Pipe()
+	rc := &receiver{
+		pw:     pw,
+		result: make(chan *transferpb.StreamResponse, 1),
+	}
+
+	subject := nats.NewInbox()
+	sub, err := conn.Subscribe(subject, rc.handle)
+	if err != nil {
+		return "", err
+	}
+	rc.sub = sub
+	rc.idle = newIdleTimer(cfg.IdleTimeout, func() { rc.stop(errIdle) })
+
+	go func() {
+		resp := consume(pr)
+		// unblocks the handler when consume gave up before the last chunk
+		_ = pr.CloseWithError(io.ErrClosedPipe)
+		rc.result <- resp
+	}()
+
+	return subject, nil
+}
+
+type receiver struct {
+	pw     *io.PipeWriter
+	result chan *transferpb.StreamResponse
+	sub    *nats.Subscription
+	idle   *idleTimer
+	once   sync.Once
+}
+
+// handle is never run concurrently, NATS calls handlers of a subscription
+// one at a time.
+func (rc *receiver) handle(msg *nats.Msg) {
+	var chunk transferpb.Chunk
+	if err := proto.Unmarshal(msg.Data, &chunk); err != nil || chunk.Cancel {
+		rc.stop(errCanceled)
+		return
+	}
+	rc.idle.reset()
+
+	_, err := rc.pw.Write(chunk.Data)
+	if err == nil && !chunk.Eof {
+		respond(msg, &transferpb.StreamResponse{StatusCode: http.StatusContinue})
+		return
+	}
+	if err == nil {
+		_ = rc.pw.Close()
+	}
+
+	respond(msg, <-rc.result)
+	rc.stop(nil)
+}
+
+func (rc *receiver) stop(err error) {
+	rc.once.Do(func() {
+		rc.idle.stop()
+		_ = rc.sub.Unsubscribe()
+		_ = rc.pw.CloseWithError(err)
+	})
+}
+
+func respond(msg *nats.Msg, resp proto.Message) {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		slog.Error("Failed to marshal response", "err", err)
+		return
+	}
+
+	_ = msg.Respond(data)
+}
+
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimer(timeout time.Duration, f func()) *idleTimer {
+	return &idleTimer{
+		timeout: timeout,
+		timer:   time.AfterFunc(timeout, f),
+	}
+}
+
+func (t *idleTimer) reset() {
+	t.timer.Reset(t.timeout)
+}
+
+func (t *idleTimer) stop() {
+	t.timer.Stop()
+}
@@ -0,0 +1,313 @@
+package metadata
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const (
+	zipLocalHeaderSig = 0x04034b50
+	zipDescriptorSig  = 0x08074b50
+	zipLocalHeaderLen = 30
+	zipDescriptorLen  = 12
+	zipFlagDescriptor = 0x8
+	zipMethodStore    = 0
+	zipMethodDeflate  = 8
+
+	maxEntryLen         = 10 << 20
+	maxCoverCandidates  = 4
+	packageDocExtension = ".opf"
+)
+
+var (
+	errNoPackageDoc  = errors.New("epub: package document not found")
+	errEntryTooLarge = errors.New("epub: entry is too large")
+
+	imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+)
+
+// opfPackage is the part of the OPF package document we care about,
+// elements are matched by local name so both EPUB 2 and 3 fit.
+type opfPackage struct {
+	Metadata struct {
+		Titles       []string `xml:"title"`
+		Creators     []string `xml:"creator"`
+		Languages    []string `xml:"language"`
+		Publishers   []string `xml:"publisher"`
+		Descriptions []string `xml:"description"`
+		Identifiers  []struct {
+			Scheme string `xml:"scheme,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"identifier"`
+		Metas []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"item"`
+	} `xml:"manifest"`
+}
+
+// extractEPUB walks the archive once. The cover is named by the package
+// document, images looking like a cover are kept in case they come first.
+func extractEPUB(r io.Reader) (Result, error) {
+	var (
+		zr         = zipStream{br: bufio.NewReader(r)}
+		pkg        *opfPackage
+		coverPath  string
+		candidates = make(map[string][]byte)
+	)
+	for {
+		name, content, err := zr.next(func(name string) bool {
+			switch {
+			case pkg == nil && strings.HasSuffix(strings.ToLower(name), packageDocExtension):
+				return true
+			case pkg == nil:
+				return isImage(name) &&
+					strings.Contains(strings.ToLower(path.Base(name)), "cover") &&
+					len(candidates) < maxCoverCandidates
+			default:
+				return name == coverPath
+			}
+		})
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		if content == nil {
+			continue
+		}
+
+		if pkg != nil {
+			// the cover named by the package document
+			return Result{Metadata: pkg.toDomain(), Cover: content}, nil
+		}
+		if !strings.HasSuffix(strings.ToLower(name), packageDocExtension) {
+			candidates[name] = content
+			continue
+		}
+
+		pkg = &opfPackage{}
+		if err = xml.Unmarshal(content, pkg); err != nil {
+			return Result{}, err
+		}
+		coverPath = pkg.coverPath(name)
+		if cover, ok := candidates[coverPath]; ok {
+			return Result{Metadata: pkg.toDomain(), Cover: cover}, nil
+		}
+	}
+	if pkg == nil {
+		return Result{}, errNoPackageDoc
+	}
+
+	res := Result{Metadata: pkg.toDomain()}
+	if names := slices.Sorted(maps.Keys(candidates)); len(names) > 0 {
+		res.Cover = candidates[names[0]]
+	}
+	return res, nil
+}
+
+func (pkg *opfPackage) toDomain() domain.Metadata {
+	md := domain.Metadata{
+		Title:       first(pkg.Metadata.Titles),
+		Creators:    pkg.Metadata.Creators,
+		Language:    first(pkg.Metadata.Languages),
+		Publisher:   first(pkg.Metadata.Publishers),
+		Description: first(pkg.Metadata.Descriptions),
+	}
+	for _, id := range pkg.Metadata.Identifiers {
+		if id.Scheme == "" || strings.EqualFold(id.Scheme, "isbn") {
+			md.ISBN = firstISBN([]string{md.ISBN, id.Value})
+		}
+	}
+
+	return md
+}
+
+// coverPath finds the cover image the EPUB 3 way, then the EPUB 2 one.
+func (pkg *opfPackage) coverPath(docPath string) string {
+	var coverID string
+	for _, meta := range pkg.Metadata.Metas {
+		if meta.Name == "cover" {
+			coverID = meta.Content
+		}
+	}
+
+	var href string
+	for _, item := range pkg.Manifest.Items {
+		if slices.Contains(strings.Fields(item.Properties), "cover-image") {
+			href = item.Href
+			break
+		}
+		if item.ID == coverID && strings.HasPrefix(item.MediaType, "image/") {
+			href = item.Href
+		}
+	}
+	if href == "" {
+		return ""
+	}
+
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(docPath), href)
+}
+
+func isImage(name string) bool {
+	return slices.Contains(imageExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// zipStream walks the local headers of a zip archive, so the central
+// directory at its end is never needed.
+type zipStream struct {
+	br *bufio.Reader
+}
+
+// next reads the following entry, its content is only returned when keep
+// says so. io.EOF is returned once the central directory is reached.
+func (z *zipStream) next(keep func(name string) bool) (string, []byte, error) {
+	var hdr [zipLocalHeaderLen]byte
+	if _, err := io.ReadFull(z.br, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", nil, io.EOF
+		}
+		return "", nil, err
+	}
+	if binary.LittleEndian.Uint32(hdr[0:4]) != zipLocalHeaderSig {
+		return "", nil, io.EOF
+	}
+
+	flags := binary.LittleEndian.Uint16(hdr[6:8])
+	method := binary.LittleEndian.Uint16(hdr[8:10])
+	size := int64(binary.LittleEndian.Uint32(hdr[18:22]))
+	nameLen := int(binary.LittleEndian.Uint16(hdr[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(hdr[28:30]))
+
+	rawName := make([]byte, nameLen)
+	if _, err := io.ReadFull(z.br, rawName); err != nil {
+		return "", nil, err
+	}
+	if _, err := z.br.Discard(extraLen); err != nil {
+		return "", nil, err
+	}
+
+	name := string(rawName)
+	wanted := keep(name)
+	hasDescriptor := flags&zipFlagDescriptor != 0
+
+	var (
+		content []byte
+		err     error
+	)
+	switch {
+	case method == zipMethodStore && hasDescriptor:
+		content, err = z.readUntilDescriptor(wanted)
+	case method == zipMethodDeflate && hasDescriptor:
+		// the deflate stream knows its own end
+		content, err = readEntry(flate.NewReader(z.br), wanted)
+		if err == nil {
+			err = z.skipDescriptor()
+		}
+	case method == zipMethodStore:
+		content, err = readEntry(io.LimitReader(z.br, size), wanted)
+	case method == zipMethodDeflate:
+		lr := io.LimitReader(z.br, size)
+		if wanted {
+			content, err = readEntry(flate.NewReader(lr), wanted)
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, lr)
+		}
+	default:
+		return "", nil, errors.New("epub: unsupported compression method")
+	}
+	if errors.Is(err, errEntryTooLarge) {
+		// an oversized entry is skipped, not kept
+		return name, nil, nil
+	}
+
+	return name, content, err
+}
+
+func readEntry(r io.Reader, keep bool) ([]byte, error) {
+	if !keep {
+		_, err := io.Copy(io.Discard, r)
+		return nil, err
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, maxEntryLen+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxEntryLen {
+		_, err = io.Copy(io.Discard, r)
+		return nil, errors.Join(errEntryTooLarge, err)
+	}
+	return content, nil
+}
+
+// readUntilDescriptor reads a stored entry of unknown size, its end is
+// only marked by the data descriptor signature.
+func (z *zipStream) readUntilDescriptor(keep bool) ([]byte, error) {
+	var (
+		content []byte
+		window  uint32
+	)
+	for {
+		b, err := z.br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		window = window>>8 | uint32(b)<<24
+		if keep && len(content) <= maxEntryLen+4 {
+			content = append(content, b)
+		}
+
+		if window == zipDescriptorSig {
+			if _, err = z.br.Discard(zipDescriptorLen); err != nil {
+				return nil, err
+			}
+			if !keep {
+				return nil, nil
+			}
+			if len(content) > maxEntryLen+4 {
+				return nil, errEntryTooLarge
+			}
+			return content[:len(content)-4], nil
+		}
+	}
+}
+
+func (z *zipStream) skipDescriptor() error {
+	sig, err := z.br.Peek(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(sig) == zipDescriptorSig {
+		if _, err = z.br.Discard(len(sig)); err != nil {
+			return err
+		}
+	}
+
+	_, err = z.br.Discard(zipDescriptorLen)
+	return err
+}
@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const testOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:identifier id="uid">urn:uuid:0b8b4c9e-7d0f-4a57-9a4b-0f6f8f1d2c3a</dc:identifier>
+    <dc:identifier opf:scheme="ISBN">978-0-14-044793-4</dc:identifier>
+    <dc:title>The Brothers Karamazov</dc:title>
+    <dc:creator opf:role="aut">Fyodor Dostoevsky</dc:creator>
+    <dc:creator opf:role="trl">David McDuff</dc:creator>
+    <dc:language>en</dc:language>
+    <dc:publisher>Penguin</dc:publisher>
+    <dc:description>A passionate philosophical novel.</dc:description>
+    <meta name="cover" content="front"/>
+  </metadata>
+  <manifest>
+    <item id="front" href="images/front%20page.jpg" media-type="image/jpeg"/>
+    <item id="text" href="text.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`
+
+func TestExtractEPUB(t *testing.T) {
+	for name, method := range map[string]uint16{
+		"deflated": zip.Deflate,
+		"stored":   zip.Store,
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := Extract(domain.FormatEPUB, bytes.NewReader(testEPUB(t, method)))
+			require.NoError(t, err)
+
+			assert.Equal(t, testCover, res.Cover)
+			assert.Equal(t, domain.Metadata{
+				Title:       "The Brothers Karamazov",
+				Creators:    []string{"Fyodor Dostoevsky", "David McDuff"},
+				Language:    "en",
+				Publisher:   "Penguin",
+				ISBN:        "9780140447934",
+				Description: "A passionate philosophical novel.",
+			}, res.Metadata)
+		})
+	}
+}
+
+func TestExtractEPUBWithoutPackageDoc(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("mimetype")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	_, err = Extract(domain.FormatEPUB, &buf)
+	assert.ErrorIs(t, err, errNoPackageDoc)
+}
+
+func TestExtractEPUBCoverBeforePackageDoc(t *testing.T) {
+	opf := `<package><metadata><title>T</title></metadata><manifest>
+<item id="c" href="cover.jpg" media-type="image/jpeg" properties="cover-image"/>
+</manifest></package>`
+
+	res, err := Extract(domain.FormatEPUB, bytes.NewReader(buildEPUB(t, zip.Deflate, [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"OEBPS/cover.jpg", string(testCover)},
+		{"OEBPS/content.opf", opf},
+	})))
+	require.NoError(t, err)
+
+	assert.Equal(t, "T", res.Metadata.Title)
+	assert.Equal(t, testCover, res.Cover)
+}
+
+var testCover = bytes.Repeat([]byte{0xff, 0xd8, 'P', 'K', 3, 4}, 1024)
+
+func testEPUB(t *testing.T, method uint16) []byte {
+	return buildEPUB(t, method, [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`},
+		{"OEBPS/cover.jpg", "not the cover the package document names"},
+		{"OEBPS/content.opf", testOPF},
+		{"OEBPS/text.xhtml", "<html/>"},
+		{"OEBPS/images/front page.jpg", string(testCover)},
+	})
+}
+
+func buildEPUB(t *testing.T, method uint16, entries [][2]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, entry := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entry[0], Method: method})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(entry[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const maxDescriptionLen = 2048
+
+type Result struct {
+	Metadata domain.Metadata
+	// Cover is the encoded cover image, if the book has one.
+	Cover []byte
+}
+
+// Extract reads the metadata and the cover embedded in a book of the given
+// format. Formats without a known metadata layout yield an empty result.
+//
+// The book is read sequentially, so Extract may be fed a stream which
+// is being uploaded at the same time.
+func Extract(format domain.Format, r io.Reader) (Result, error) {
+	var (
+		res Result
+		err error
+	)
+	switch format {
+	case domain.FormatEPUB:
+		res, err = extractEPUB(r)
+	case domain.FormatPDF:
+		res, err = extractPDF(r)
+	default:
+		return Result{}, nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	res.Metadata = normalize(res.Metadata)
+	return res, nil
+}
+
+func normalize(md domain.Metadata) domain.Metadata {
+	creators := make([]string, 0, len(md.Creators))
+	for _, creator := range md.Creators {
+		creator = clean(creator)
+		if creator != "" && !slices.Contains(creators, creator) {
+			creators = append(creators, creator)
+		}
+	}
+
+	return domain.Metadata{
+		Title:       clean(md.Title),
+		Creators:    creators,
+		Language:    clean(md.Language),
+		Publisher:   clean(md.Publisher),
+		ISBN:        normalizeISBN(md.ISBN),
+		Description: truncate(clean(md.Description), maxDescriptionLen),
+	}
+}
+
+func clean(s string) string {
+	return strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// normalizeISBN strips prefixes and separators and drops values
+// that cannot be an ISBN-10 or ISBN-13.
+func normalizeISBN(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "URN:")
+	s = strings.TrimPrefix(s, "ISBN")
+	s = strings.TrimLeft(s, ":- ")
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+
+	switch {
+	case len(s) == 13 && isDigits(s):
+		return s
+	case len(s) == 10 && isDigits(s[:9]) && (isDigits(s[9:]) || s[9] == 'X'):
+		return s
+	default:
+		return ""
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstISBN(values []string) string {
+	for _, v := range values {
+		if isbn := normalizeISBN(v); isbn != "" {
+			return isbn
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+func TestExtractUnsupportedFormat(t *testing.T) {
+	res, err := Extract(domain.FormatText, strings.NewReader("just text"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Result{}, res)
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	for in, want := range map[string]string{
+		"urn:isbn:978-5-17-090630-7": "9785170906307",
+		"ISBN: 0-306-40615-2":        "0306406152",
+		"0-8044-2957-x":              "080442957X",
+		"urn:uuid:1f0a6d1e":          "",
+		"12345":                      "",
+	} {
+		assert.Equal(t, want, normalizeISBN(in), in)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	md := normalize(domain.Metadata{
+		Title:       "  War \n and   Peace ",
+		Creators:    []string{"Leo Tolstoy", " ", "Leo  Tolstoy"},
+		Description: strings.Repeat("a", maxDescriptionLen+10),
+	})
+
+	assert.Equal(t, "War and Peace", md.Title)
+	assert.Equal(t, []string{"Leo Tolstoy"}, md.Creators)
+	assert.Len(t, md.Description, maxDescriptionLen)
+}
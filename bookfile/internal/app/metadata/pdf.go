@@ -0,0 +1,312 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const (
+	maxPDFObjectLen = 4 << 20
+	minCoverSide    = 150
+)
+
+var (
+	pdfEndObj    = []byte("endobj")
+	pdfObjHeader = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfInfoRef   = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfInfoKey   = regexp.MustCompile(`/(Title|Author|Subject)\s*([(<])`)
+	xmpPacket    = regexp.MustCompile(`(?s)<x:xmpmeta.*?</x:xmpmeta>`)
+	pdfImage     = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	pdfDCTFilter = regexp.MustCompile(`/Filter\s*\[?\s*/DCTDecode\b`)
+	pdfWidth     = regexp.MustCompile(`/Width\s+(\d+)`)
+	pdfHeight    = regexp.MustCompile(`/Height\s+(\d+)`)
+	pdfStream    = regexp.MustCompile(`stream\r?\n`)
+	jpegMagic    = []byte{0xff, 0xd8, 0xff}
+)
+
+// xmpMeta is the Dublin Core subset of an XMP packet.
+type xmpMeta struct {
+	Descriptions []struct {
+		Titles       []string `xml:"title>Alt>li"`
+		Creators     []string `xml:"creator>Seq>li"`
+		Languages    []string `xml:"language>Bag>li"`
+		Publishers   []string `xml:"publisher>Bag>li"`
+		Descriptions []string `xml:"description>Alt>li"`
+		Identifiers  []string `xml:"identifier"`
+		ISBNs        []string `xml:"isbn"`
+	} `xml:"RDF>Description"`
+}
+
+// extractPDF scans the file object by object. The trailer is only known
+// at the end, so every dictionary which may be the Info one is kept until
+// then. Objects packed in compressed object streams are not looked into.
+//
+// Pages are not rendered, the first large enough JPEG image stands for
+// the cover, which is what scanned books look like.
+func extractPDF(r io.Reader) (Result, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 2*maxPDFObjectLen)
+	scanner.Split(splitPDFObjects)
+
+	var (
+		infos   = make(map[string]domain.Metadata)
+		infoRef string
+		xmp     domain.Metadata
+		cover   []byte
+	)
+	for scanner.Scan() {
+		obj := scanner.Bytes()
+
+		// the trailer or a cross-reference stream, the last one wins
+		if m := pdfInfoRef.FindAllSubmatch(obj, -1); m != nil {
+			infoRef = string(m[len(m)-1][1])
+		}
+
+		header := pdfObjHeader.FindSubmatchIndex(obj)
+		if header == nil {
+			continue
+		}
+		num, body := string(obj[header[2]:header[3]]), obj[header[1]:]
+
+		if pdfImage.Match(body) {
+			if cover == nil {
+				cover = parseJPEGImage(body)
+			}
+			continue
+		}
+		if packet := xmpPacket.Find(body); packet != nil && xmp.IsZero() {
+			xmp = parseXMP(packet)
+			continue
+		}
+		if info := parseInfo(body); !info.IsZero() {
+			infos[num] = info
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Metadata: merge(xmp, infos[infoRef]),
+		Cover:    cover,
+	}, nil
+}
+
+// parseJPEGImage returns the data of an image XObject stored as a plain
+// JPEG, other encodings would need decoding of their own.
+func parseJPEGImage(body []byte) []byte {
+	loc := pdfStream.FindIndex(body)
+	if loc == nil {
+		return nil
+	}
+	dict, data := body[:loc[0]], body[loc[1]:]
+
+	if !pdfDCTFilter.Match(dict) || pdfDimension(pdfWidth, dict) < minCoverSide || pdfDimension(pdfHeight, dict) < minCoverSide {
+		return nil
+	}
+	if end := bytes.LastIndex(data, []byte("endstream")); end >= 0 {
+		data = data[:end]
+	}
+	if !bytes.HasPrefix(data, jpegMagic) {
+		return nil
+	}
+
+	return bytes.Clone(data)
+}
+
+func pdfDimension(re *regexp.Regexp, dict []byte) int {
+	m := re.FindSubmatch(dict)
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(string(m[1]))
+	return v
+}
+
+// splitPDFObjects yields everything up to the next "endobj". Objects too
+// large to be a metadata one, like images or fonts, are skipped.
+func splitPDFObjects(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, pdfEndObj); i >= 0 {
+		return i + len(pdfEndObj), data[:i], nil
+	}
+	if atEOF {
+		if len(data) == 0 {
+			return 0, nil, nil
+		}
+		return len(data), data, nil
+	}
+	if len(data) >= maxPDFObjectLen {
+		// keep a possibly cut "endobj"
+		return len(data) - len(pdfEndObj), nil, nil
+	}
+
+	return 0, nil, nil
+}
+
+func parseInfo(body []byte) domain.Metadata {
+	// outline items have titles too, but always a parent
+	if bytes.Contains(body, []byte("/Parent")) {
+		return domain.Metadata{}
+	}
+
+	var md domain.Metadata
+	for _, m := range pdfInfoKey.FindAllSubmatchIndex(body, -1) {
+		value := parsePDFString(body[m[4]:])
+		switch string(body[m[2]:m[3]]) {
+		case "Title":
+			md.Title = value
+		case "Author":
+			md.Creators = []string{value}
+		case "Subject":
+			md.Description = value
+		}
+	}
+
+	return md
+}
+
+// parsePDFString decodes the literal or hexadecimal string s starts with.
+func parsePDFString(s []byte) string {
+	var raw []byte
+	switch s[0] {
+	case '(':
+		raw = parseLiteralString(s[1:])
+	case '<':
+		end := bytes.IndexByte(s, '>')
+		if end < 0 {
+			return ""
+		}
+		digits := bytes.Join(bytes.Fields(s[1:end]), nil)
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		raw = make([]byte, hex.DecodedLen(len(digits)))
+		if _, err := hex.Decode(raw, digits); err != nil {
+			return ""
+		}
+	}
+
+	return decodePDFText(raw)
+}
+
+func parseLiteralString(s []byte) []byte {
+	var (
+		out   []byte
+		depth int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+		case '\\':
+			i++
+			if i == len(s) {
+				return out
+			}
+			switch e := s[i]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// line continuation
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(string(s[i:j]), 8, 8)
+				c, i = byte(v), j-1
+			default:
+				c = e
+			}
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// decodePDFText handles UTF-16BE text strings, anything else is taken
+// as PDFDocEncoding which matches Latin-1 for printable characters.
+func decodePDFText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func parseXMP(packet []byte) domain.Metadata {
+	var meta xmpMeta
+	if err := xml.Unmarshal(packet, &meta); err != nil {
+		return domain.Metadata{}
+	}
+
+	var md domain.Metadata
+	for _, d := range meta.Descriptions {
+		md = merge(md, domain.Metadata{
+			Title:       first(d.Titles),
+			Creators:    d.Creators,
+			Language:    first(d.Languages),
+			Publisher:   first(d.Publishers),
+			Description: first(d.Descriptions),
+			ISBN:        firstISBN(append(d.ISBNs, d.Identifiers...)),
+		})
+	}
+
+	return md
+}
+
+// merge fills the fields missing in md from fallback.
+func merge(md, fallback domain.Metadata) domain.Metadata {
+	if md.Title == "" {
+		md.Title = fallback.Title
+	}
+	if len(md.Creators) == 0 {
+		md.Creators = fallback.Creators
+	}
+	if md.Language == "" {
+		md.Language = fallback.Language
+	}
+	if md.Publisher == "" {
+		md.Publisher = fallback.Publisher
+	}
+	if md.ISBN == "" {
+		md.ISBN = fallback.ISBN
+	}
+	if md.Description == "" {
+		md.Description = fallback.Description
+	}
+
+	return md
+}
@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Anna Karenina</rdf:li></rdf:Alt></dc:title>
+<dc:creator><rdf:Seq><rdf:li>Leo Tolstoy</rdf:li></rdf:Seq></dc:creator>
+<dc:language><rdf:Bag><rdf:li>ru</rdf:li></rdf:Bag></dc:language>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:prism="http://prismstandard.org/namespaces/basic/2.0/">
+<prism:isbn>978-5-389-01006-7</prism:isbn>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`
+
+func TestExtractPDF(t *testing.T) {
+	pdf := strings.Join([]string{
+		"%PDF-1.7",
+		"1 0 obj << /Type /Catalog /Pages 2 0 R /Metadata 5 0 R /Outlines 3 0 R >> endobj",
+		"3 0 obj << /Title (Chapter 1) /Parent 4 0 R >> endobj",
+		"6 0 obj << /Width 10 /Length 5000000 >> stream\n" + strings.Repeat("x", 5_000_000) + "\nendstream endobj",
+		"9 0 obj << /Type /XObject /Subtype /Image /Width 16 /Height 16 /Filter /DCTDecode >> stream\n\xff\xd8\xfflogo\nendstream endobj",
+		"10 0 obj << /Type /XObject /Subtype /Image /Width 600 /Height 900 /Filter [/DCTDecode] /Length 9 >> stream\r\n\xff\xd8\xffcover\nendstream endobj",
+		"11 0 obj << /Type /XObject /Subtype /Image /Width 600 /Height 900 /Filter /DCTDecode >> stream\n\xff\xd8\xffpage2\nendstream endobj",
+		"5 0 obj << /Type /Metadata /Subtype /XML >> stream\n" + testXMP + "\nendstream endobj",
+		"7 0 obj << /Title (Old title) /Author (Nobody) >> endobj",
+		"8 0 obj << /Title <FEFF0410043D043D0430> /Author (Lev \\(Leo\\) Tolstoy) /Subject (Love\\040and society) >> endobj",
+		"trailer << /Root 1 0 R /Info 7 0 R >>",
+		"trailer << /Root 1 0 R /Info 8 0 R /Prev 100 >>",
+		"%%EOF",
+	}, "\n")
+
+	res, err := Extract(domain.FormatPDF, bytes.NewReader([]byte(pdf)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("\xff\xd8\xffcover\n"), res.Cover)
+	assert.Equal(t, domain.Metadata{
+		Title:       "Anna Karenina",
+		Creators:    []string{"Leo Tolstoy"},
+		Language:    "ru",
+		ISBN:        "9785389010067",
+		Description: "Love and society",
+	}, res.Metadata)
+}
+
+func TestExtractPDFInfoOnly(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj << /Title <416E6E61> /Author (Ren\\351) >> endobj\ntrailer << /Info 1 0 R >>"
+
+	res, err := Extract(domain.FormatPDF, strings.NewReader(pdf))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Anna", res.Metadata.Title)
+	assert.Equal(t, []string{"René"}, res.Metadata.Creators)
+	assert.Nil(t, res.Cover)
+}
+
+func TestParseLiteralString(t *testing.T) {
+	assert.Equal(t, "a (nested) b\n", string(parseLiteralString([]byte(`a (nested) b\n) tail`))))
+	assert.Equal(t, "AB", string(parseLiteralString([]byte("A\\\nB)"))))
+}
@@ -0,0 +1,13 @@
+package app
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/bookfile/internal/app/repository"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+)
+
+var Module = fx.Module("app",
+	repository.Module,
+	service.Module,
+)
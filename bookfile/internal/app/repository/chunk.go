@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	objStreamTmpl = "OBJ_%s"
+	objChunksTmpl = "$O.%s.C.%s"
+
+	// chunkWait bounds the wait for a chunk, the chunks of an object
+	// replaced or deleted meanwhile never come.
+	chunkWait = 10 * time.Second
+)
+
+var _ io.ReadCloser = (*chunkReader)(nil)
+
+// newChunkReader reads length bytes of the object starting at offset. Only
+// the chunk holding offset and the ones after it are fetched with payloads.
+func newChunkReader(
+	ctx context.Context,
+	js jetstream.JetStream,
+	info *jetstream.ObjectInfo,
+	offset, length uint64,
+) (*chunkReader, error) {
+	chunkSize := uint64(info.Opts.ChunkSize)
+	stream := fmt.Sprintf(objStreamTmpl, info.Bucket)
+	subj := fmt.Sprintf(objChunksTmpl, info.Bucket, info.NUID)
+
+	cfg := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subj},
+	}
+	if chunk := offset / chunkSize; chunk > 0 {
+		seq, err := chunkSeq(ctx, js, stream, subj, chunk)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = seq
+	}
+
+	cons, err := js.OrderedConsumer(ctx, stream, cfg)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := cons.Messages()
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkReader{
+		ctx:       ctx,
+		msgs:      msgs,
+		skip:      offset % chunkSize,
+		remaining: length,
+	}, nil
+}
+
+// chunkSeq finds the stream sequence of the chunk with the given index
+// without pulling payloads of the chunks before it.
+func chunkSeq(ctx context.Context, js jetstream.JetStream, stream, subj string, chunk uint64) (uint64, error) {
+	cons, err := js.OrderedConsumer(ctx, stream, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subj},
+		HeadersOnly:    true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	msgs, err := cons.Messages()
+	if err != nil {
+		return 0, err
+	}
+	defer msgs.Stop()
+
+	for i := uint64(0); ; i++ {
+		msg, err := nextMsg(ctx, msgs)
+		if err != nil {
+			return 0, err
+		}
+		if i < chunk {
+			continue
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return 0, err
+		}
+		return meta.Sequence.Stream, nil
+	}
+}
+
+// nextMsg waits for the next message of msgs until ctx is done or
+// chunkWait passes, msgs is stopped then.
+func nextMsg(ctx context.Context, msgs jetstream.MessagesContext) (jetstream.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, chunkWait)
+	defer cancel()
+	defer context.AfterFunc(ctx, msgs.Stop)()
+
+	msg, err := msgs.Next()
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("waiting for a chunk: %w", ctx.Err())
+	}
+
+	return msg, err
+}
+
+type chunkReader struct {
+	ctx       context.Context
+	msgs      jetstream.MessagesContext
+	chunk     []byte
+	skip      uint64
+	remaining uint64
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	if cr.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	for len(cr.chunk) == 0 {
+		msg, err := nextMsg(cr.ctx, cr.msgs)
+		if err != nil {
+			return 0, err
+		}
+
+		chunk := msg.Data()
+		skip := min(cr.skip, uint64(len(chunk)))
+		cr.chunk, cr.skip = chunk[skip:], cr.skip-skip
+	}
+
+	n := copy(p[:min(uint64(len(p)), cr.remaining)], cr.chunk)
+	cr.chunk = cr.chunk[n:]
+	cr.remaining -= uint64(n)
+
+	return n, nil
+}
+
+func (cr *chunkReader) Close() error {
+	cr.msgs.Stop()
+	return nil
+}
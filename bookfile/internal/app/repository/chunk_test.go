@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+// stalledMsgs never delivers a message, like the chunks of a deleted object.
+type stalledMsgs struct {
+	once    sync.Once
+	stopped chan struct{}
+}
+
+func (m *stalledMsgs) Next() (jetstream.Msg, error) {
+	<-m.stopped
+	return nil, jetstream.ErrMsgIteratorClosed
+}
+
+func (m *stalledMsgs) Stop() {
+	m.once.Do(func() { close(m.stopped) })
+}
+
+func (m *stalledMsgs) Drain() {
+	m.Stop()
+}
+
+func TestChunkReaderStalled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cr := &chunkReader{
+		ctx:       ctx,
+		msgs:      &stalledMsgs{stopped: make(chan struct{})},
+		remaining: 1,
+	}
+	cancel()
+
+	_, err := cr.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, cr.Close())
+}
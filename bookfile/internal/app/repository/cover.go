@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+var _ CoverRepo = (*JsCoverRepo)(nil)
+
+var coverExtensions = map[string]string{
+	domain.MIMETypeJPEG: "jpg",
+	domain.MIMETypeWebP: "webp",
+}
+
+func NewJsCoverRepo(store jetstream.ObjectStore) *JsCoverRepo {
+	return &JsCoverRepo{
+		ObjectStore: store,
+	}
+}
+
+type JsCoverRepo struct {
+	jetstream.ObjectStore
+}
+
+func (jcr *JsCoverRepo) Get(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	size domain.CoverSize,
+	mimeType string,
+) (domain.Cover, error) {
+	obj, err := jcr.ObjectStore.Get(ctx, coverName(bookUUID, size, mimeType))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			return domain.Cover{}, repoerrors.ErrNotFound{Inner: err}
+		}
+		return domain.Cover{}, err
+	}
+
+	objInfo, err := obj.Info()
+	if err != nil {
+		return domain.Cover{}, err
+	}
+
+	return domain.Cover{
+		BookUUID: bookUUID,
+		Size:     size,
+		MIMEType: mimeType,
+		Length:   objInfo.Size,
+		Digest:   digestToHex(objInfo.Digest),
+		ModTime:  objInfo.ModTime,
+		FileReadCloser: ioutils.NewReadCloserWrapper(
+			bufio.NewReader(obj),
+			obj.Close,
+		),
+	}, nil
+}
+
+func (jcr *JsCoverRepo) Put(ctx context.Context, cover domain.Cover) error {
+	meta := jetstream.ObjectMeta{
+		Name: coverName(cover.BookUUID, cover.Size, cover.MIMEType),
+		Metadata: map[string]string{
+			mimeTypeKey: cover.MIMEType,
+		},
+	}
+
+	_, err := jcr.ObjectStore.Put(ctx, meta, cover.FileReadCloser)
+	return errors.Join(
+		err,
+		cover.FileReadCloser.Close(),
+	)
+}
+
+// Delete removes every thumbnail of the book.
+func (jcr *JsCoverRepo) Delete(ctx context.Context, bookUUID uuid.UUID) error {
+	var errs []error
+	for _, size := range domain.CoverSizes {
+		for _, mimeType := range domain.CoverMIMETypes {
+			err := jcr.ObjectStore.Delete(ctx, coverName(bookUUID, size, mimeType))
+			if err != nil && !errors.Is(err, jetstream.ErrObjectNotFound) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func coverName(bookUUID uuid.UUID, size domain.CoverSize, mimeType string) string {
+	return fmt.Sprintf("%s/%s.%s", bookUUID, size, coverExtensions[mimeType])
+}
@@ -31,3 +31,52 @@ func (err ErrNotFound) Is(target error) bool {
 	_, ok := target.(ErrNotFound)
 	return ok
 }
+
+func IsErrInvalidRange(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrInvalidRange{})
+}
+
+type ErrInvalidRange struct {
+	Offset uint64
+	Size   uint64
+}
+
+func (err ErrInvalidRange) Error() string {
+	return fmt.Sprintf("invalid range: offset %d is beyond size %d", err.Offset, err.Size)
+}
+
+func (err ErrInvalidRange) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrInvalidRange)
+	return ok
+}
+
+func IsErrConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrConflict{})
+}
+
+// ErrConflict is returned when a change is no longer valid for the
+// current state of what it changes.
+type ErrConflict struct {
+	Reason string
+}
+
+func (err ErrConflict) Error() string {
+	return fmt.Sprintf("conflict: %s", err.Reason)
+}
+
+func (err ErrConflict) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrConflict)
+	return ok
+}
@@ -15,3 +15,15 @@ func TestIsErrNotFound(t *testing.T) {
 		fmt.Errorf("wrapped: %w", ErrNotFound{Inner: errors.New("outer: not found")}),
 	))
 }
+
+func TestIsErrInvalidRange(t *testing.T) {
+	assert.True(t, IsErrInvalidRange(ErrInvalidRange{Offset: 10, Size: 5}))
+	assert.False(t, IsErrInvalidRange(ErrNotFound{Inner: errors.New("not found")}))
+	assert.True(t, IsErrInvalidRange(fmt.Errorf("wrapped: %w", ErrInvalidRange{})))
+}
+
+func TestIsErrConflict(t *testing.T) {
+	assert.True(t, IsErrConflict(ErrConflict{Reason: "offset moved"}))
+	assert.False(t, IsErrConflict(ErrNotFound{Inner: errors.New("not found")}))
+	assert.True(t, IsErrConflict(fmt.Errorf("wrapped: %w", ErrConflict{})))
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+)
+
+var _ EventRepo = (*KvEventRepo)(nil)
+
+func NewKvEventRepo(kv jetstream.KeyValue) *KvEventRepo {
+	return &KvEventRepo{
+		KeyValue: kv,
+	}
+}
+
+// KvEventRepo keeps a key per processed event, the bucket TTL drops the
+// keys once the events can no longer be redelivered.
+type KvEventRepo struct {
+	jetstream.KeyValue
+}
+
+func (ker *KvEventRepo) Claim(ctx context.Context, key string) error {
+	_, err := ker.Create(ctx, key, nil)
+	if errors.Is(err, jetstream.ErrKeyExists) {
+		return repoerrors.ErrConflict{Reason: "event " + key + " is processed already"}
+	}
+
+	return err
+}
+
+func (ker *KvEventRepo) Unclaim(ctx context.Context, key string) error {
+	return ker.Purge(ctx, key)
+}
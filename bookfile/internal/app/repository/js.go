@@ -3,7 +3,13 @@ package repository
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"maps"
+	"strconv"
+	"strings"
 
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/google/uuid"
@@ -13,18 +19,33 @@ import (
 	"github.com/lunn06/library/bookfile/internal/domain"
 )
 
-const fileNameKey = "fileName"
+const (
+	fileNameKey = "fileName"
+	mimeTypeKey = "mimeType"
+
+	titleKey       = "meta.title"
+	creatorsKey    = "meta.creators"
+	languageKey    = "meta.language"
+	publisherKey   = "meta.publisher"
+	isbnKey        = "meta.isbn"
+	descriptionKey = "meta.description"
+	coverKey       = "meta.cover"
+
+	objDigestPrefix = "SHA-256="
+)
 
 var _ BookRepo = (*JsBookRepo)(nil)
 
-func NewJsBookRepo(store jetstream.ObjectStore) *JsBookRepo {
+func NewJsBookRepo(js jetstream.JetStream, store jetstream.ObjectStore) *JsBookRepo {
 	return &JsBookRepo{
 		ObjectStore: store,
+		js:          js,
 	}
 }
 
 type JsBookRepo struct {
 	jetstream.ObjectStore
+	js jetstream.JetStream
 }
 
 func (jbr *JsBookRepo) Get(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
@@ -41,16 +62,54 @@ func (jbr *JsBookRepo) Get(ctx context.Context, bookUUID uuid.UUID) (domain.Book
 		return domain.Book{}, err
 	}
 
-	fileName := objInfo.Metadata[fileNameKey]
+	book := infoToDomain(bookUUID, objInfo)
+	book.FileReadCloser = ioutils.NewReadCloserWrapper(
+		bufio.NewReader(obj),
+		obj.Close,
+	)
 
-	return domain.Book{
-		UUID:     bookUUID,
-		FileName: fileName,
-		FileReadCloser: ioutils.NewReadCloserWrapper(
-			bufio.NewReader(obj),
-			obj.Close,
-		),
-	}, nil
+	return book, nil
+}
+
+func (jbr *JsBookRepo) GetInfo(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
+	objInfo, err := jbr.ObjectStore.GetInfo(ctx, bookUUID.String())
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			return domain.Book{}, repoerrors.ErrNotFound{Inner: err}
+		}
+		return domain.Book{}, err
+	}
+
+	return infoToDomain(bookUUID, objInfo), nil
+}
+
+func (jbr *JsBookRepo) GetRange(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	offset uint64,
+	length uint64,
+) (domain.Book, error) {
+	objInfo, err := jbr.ObjectStore.GetInfo(ctx, bookUUID.String())
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			return domain.Book{}, repoerrors.ErrNotFound{Inner: err}
+		}
+		return domain.Book{}, err
+	}
+	if offset >= objInfo.Size {
+		return domain.Book{}, repoerrors.ErrInvalidRange{Offset: offset, Size: objInfo.Size}
+	}
+	length = min(length, objInfo.Size-offset)
+
+	r, err := newChunkReader(ctx, jbr.js, objInfo, offset, length)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	book := infoToDomain(bookUUID, objInfo)
+	book.FileReadCloser = r
+
+	return book, nil
 }
 
 func (jbr *JsBookRepo) Put(ctx context.Context, book domain.Book) error {
@@ -58,6 +117,7 @@ func (jbr *JsBookRepo) Put(ctx context.Context, book domain.Book) error {
 		Name: book.UUID.String(),
 		Metadata: map[string]string{
 			fileNameKey: book.FileName,
+			mimeTypeKey: book.Format.MIMEType(),
 		},
 	}
 
@@ -68,6 +128,115 @@ func (jbr *JsBookRepo) Put(ctx context.Context, book domain.Book) error {
 	)
 }
 
+func (jbr *JsBookRepo) PutMetadata(ctx context.Context, bookUUID uuid.UUID, md domain.Metadata) error {
+	objInfo, err := jbr.ObjectStore.GetInfo(ctx, bookUUID.String())
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			return repoerrors.ErrNotFound{Inner: err}
+		}
+		return err
+	}
+
+	creators, err := json.Marshal(md.Creators)
+	if err != nil {
+		return err
+	}
+
+	meta := objInfo.ObjectMeta
+	meta.Metadata = maps.Clone(meta.Metadata)
+	if meta.Metadata == nil {
+		meta.Metadata = make(map[string]string)
+	}
+	meta.Metadata[titleKey] = md.Title
+	meta.Metadata[creatorsKey] = string(creators)
+	meta.Metadata[languageKey] = md.Language
+	meta.Metadata[publisherKey] = md.Publisher
+	meta.Metadata[isbnKey] = md.ISBN
+	meta.Metadata[descriptionKey] = md.Description
+	meta.Metadata[coverKey] = strconv.FormatBool(md.HasCover)
+
+	return jbr.ObjectStore.UpdateMeta(ctx, bookUUID.String(), meta)
+}
+
+func infoToDomain(bookUUID uuid.UUID, objInfo *jetstream.ObjectInfo) domain.Book {
+	fileName := objInfo.Metadata[fileNameKey]
+
+	// objects stored before format detection only carry the file name
+	format := domain.FormatFromMIMEType(objInfo.Metadata[mimeTypeKey])
+	if format == domain.FormatUnknown {
+		format = domain.FormatFromFileName(fileName)
+	}
+
+	return domain.Book{
+		UUID:     bookUUID,
+		FileName: fileName,
+		Format:   format,
+		Size:     objInfo.Size,
+		Digest:   digestToHex(objInfo.Digest),
+		ModTime:  objInfo.ModTime,
+		Metadata: metadataToDomain(objInfo.Metadata),
+	}
+}
+
+func metadataToDomain(meta map[string]string) domain.Metadata {
+	var creators []string
+	if raw := meta[creatorsKey]; raw != "" {
+		// a broken value only loses the creators suggestion
+		_ = json.Unmarshal([]byte(raw), &creators)
+	}
+
+	return domain.Metadata{
+		Title:       meta[titleKey],
+		Creators:    creators,
+		Language:    meta[languageKey],
+		Publisher:   meta[publisherKey],
+		ISBN:        meta[isbnKey],
+		Description: meta[descriptionKey],
+		HasCover:    meta[coverKey] == "true",
+	}
+}
+
+// digestToHex converts the object store "SHA-256=<base64url>" digest
+// to the hex form used across the service.
+func digestToHex(digest string) string {
+	sum, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(digest, objDigestPrefix))
+	if err != nil {
+		return digest
+	}
+	return hex.EncodeToString(sum)
+}
+
 func (jbr *JsBookRepo) Delete(ctx context.Context, bookUUID uuid.UUID) error {
-	return jbr.ObjectStore.Delete(ctx, bookUUID.String())
+	// the object store deletes a deleted object again without complaint
+	_, err := jbr.ObjectStore.GetInfo(ctx, bookUUID.String())
+	if err == nil {
+		err = jbr.ObjectStore.Delete(ctx, bookUUID.String())
+	}
+	if errors.Is(err, jetstream.ErrObjectNotFound) {
+		return repoerrors.ErrNotFound{Inner: err}
+	}
+
+	return err
+}
+
+func (jbr *JsBookRepo) List(ctx context.Context) ([]domain.Book, error) {
+	infos, err := jbr.ObjectStore.List(ctx)
+	if errors.Is(err, jetstream.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]domain.Book, 0, len(infos))
+	for _, info := range infos {
+		bookUUID, err := uuid.Parse(info.Name)
+		if err != nil {
+			// not a book
+			continue
+		}
+		books = append(books, infoToDomain(bookUUID, info))
+	}
+
+	return books, nil
 }
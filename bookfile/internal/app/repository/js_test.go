@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestToHex(t *testing.T) {
+	sum := sha256.Sum256([]byte("book"))
+
+	assert.Equal(t,
+		hex.EncodeToString(sum[:]),
+		digestToHex(objDigestPrefix+base64.URLEncoding.EncodeToString(sum[:])),
+	)
+	assert.Equal(t, "garbage!", digestToHex("garbage!"))
+}
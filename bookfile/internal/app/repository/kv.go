@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const (
+	digestKeyPrefix = "digest."
+	refsKeyPrefix   = "refs."
+	refKeyPrefix    = "ref."
+)
+
+var _ RefRepo = (*KvRefRepo)(nil)
+
+// refRecord counts the references to the book of its holder, the
+// references taken before they were named in Refs are released through
+// the holder.
+type refRecord struct {
+	Digest string     `json:"digest"`
+	Count  uint64     `json:"count"`
+	Refs   []refEntry `json:"refs,omitempty"`
+}
+
+type refEntry struct {
+	UUID     uuid.UUID `json:"uuid"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// unnamed reports whether references not in Refs are left.
+func (r refRecord) unnamed() bool {
+	return r.Count > uint64(len(r.Refs))
+}
+
+func NewKvRefRepo(kv jetstream.KeyValue) *KvRefRepo {
+	return &KvRefRepo{
+		KeyValue: kv,
+	}
+}
+
+// KvRefRepo keeps the holder of every digest, the references to the book of
+// each holder and, for the references other than the holder itself, the
+// holder they refer to.
+type KvRefRepo struct {
+	jetstream.KeyValue
+}
+
+func (kr *KvRefRepo) Acquire(ctx context.Context, digest string, ref uuid.UUID) (uuid.UUID, error) {
+	for {
+		entry, err := kr.Get(ctx, digestKeyPrefix+digest)
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			holder, err := kr.create(ctx, digest, ref)
+			if errors.Is(err, jetstream.ErrKeyExists) {
+				// lost the race to another upload of the same content
+				continue
+			}
+			return holder, err
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		holder, err := uuid.ParseBytes(entry.Value())
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		// the reference resolves before it is counted, so that a counted
+		// reference always does
+		if _, err = kr.Put(ctx, refKeyPrefix+ref.String(), []byte(holder.String())); err != nil {
+			return uuid.Nil, err
+		}
+		err = kr.modify(ctx, holder, func(record *refRecord) error {
+			record.Count++
+			record.Refs = append(record.Refs, refEntry{UUID: ref, Acquired: time.Now()})
+			return nil
+		})
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			// the holder has just been released, drop its stale digest entry
+			if err = kr.Delete(ctx, refKeyPrefix+ref.String()); err != nil {
+				return uuid.Nil, err
+			}
+			err = kr.Delete(ctx, digestKeyPrefix+digest, jetstream.LastRevision(entry.Revision()))
+			if err != nil && !isRevisionConflict(err) {
+				return uuid.Nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, errors.Join(err, kr.Delete(ctx, refKeyPrefix+ref.String()))
+		}
+
+		return holder, nil
+	}
+}
+
+func (kr *KvRefRepo) Release(ctx context.Context, ref uuid.UUID) (uuid.UUID, uint64, error) {
+	holder, err := kr.Resolve(ctx, ref)
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+
+	for {
+		entry, err := kr.Get(ctx, refsKeyPrefix+holder.String())
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			if holder != ref {
+				return uuid.Nil, 0, repoerrors.ErrNotFound{Inner: fmt.Errorf("book %s of reference %s", holder, ref)}
+			}
+			// books stored before deduplication are not referenced by anyone else
+			return holder, 0, nil
+		}
+		if err != nil {
+			return uuid.Nil, 0, err
+		}
+
+		var record refRecord
+		if err = json.Unmarshal(entry.Value(), &record); err != nil {
+			return uuid.Nil, 0, err
+		}
+		if i := slices.IndexFunc(record.Refs, isReference(ref)); i >= 0 {
+			record.Refs = slices.Delete(record.Refs, i, i+1)
+		} else if holder != ref || !record.unnamed() {
+			return uuid.Nil, 0, repoerrors.ErrNotFound{Inner: fmt.Errorf("reference %s is released", ref)}
+		}
+		record.Count--
+
+		if record.Count == 0 {
+			err = kr.Delete(ctx, refsKeyPrefix+holder.String(), jetstream.LastRevision(entry.Revision()))
+		} else {
+			err = kr.update(ctx, holder, record, entry.Revision())
+		}
+		if isRevisionConflict(err) {
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, 0, err
+		}
+
+		if holder != ref {
+			if err = kr.Delete(ctx, refKeyPrefix+ref.String()); err != nil {
+				return uuid.Nil, 0, err
+			}
+		}
+		if record.Count == 0 {
+			return holder, 0, kr.releaseDigest(ctx, record.Digest, holder)
+		}
+		return holder, record.Count, nil
+	}
+}
+
+func (kr *KvRefRepo) Resolve(ctx context.Context, ref uuid.UUID) (uuid.UUID, error) {
+	entry, err := kr.Get(ctx, refKeyPrefix+ref.String())
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return ref, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.ParseBytes(entry.Value())
+}
+
+func (kr *KvRefRepo) References(ctx context.Context, holder uuid.UUID) ([]domain.Reference, error) {
+	entry, err := kr.Get(ctx, refsKeyPrefix+holder.String())
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return []domain.Reference{{UUID: holder}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record refRecord
+	if err = json.Unmarshal(entry.Value(), &record); err != nil {
+		return nil, err
+	}
+	refs := make([]domain.Reference, 0, len(record.Refs)+1)
+	for _, ref := range record.Refs {
+		refs = append(refs, domain.Reference(ref))
+	}
+	if record.unnamed() && !slices.ContainsFunc(record.Refs, isReference(holder)) {
+		refs = append(refs, domain.Reference{UUID: holder})
+	}
+
+	return refs, nil
+}
+
+// releaseDigest deletes the digest entry of the book, unless a concurrent
+// upload of the same content has replaced it meanwhile.
+func (kr *KvRefRepo) releaseDigest(ctx context.Context, digest string, holder uuid.UUID) error {
+	entry, err := kr.Get(ctx, digestKeyPrefix+digest)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(entry.Value()) != holder.String() {
+		return nil
+	}
+
+	err = kr.Delete(ctx, digestKeyPrefix+digest, jetstream.LastRevision(entry.Revision()))
+	if isRevisionConflict(err) {
+		return nil
+	}
+	return err
+}
+
+func (kr *KvRefRepo) Lookup(ctx context.Context, digest string) (uuid.UUID, error) {
+	entry, err := kr.Get(ctx, digestKeyPrefix+digest)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return uuid.Nil, repoerrors.ErrNotFound{Inner: err}
+		}
+		return uuid.Nil, err
+	}
+
+	return uuid.ParseBytes(entry.Value())
+}
+
+func (kr *KvRefRepo) create(ctx context.Context, digest string, holder uuid.UUID) (uuid.UUID, error) {
+	value, err := json.Marshal(refRecord{
+		Digest: digest,
+		Count:  1,
+		Refs:   []refEntry{{UUID: holder, Acquired: time.Now()}},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, err = kr.Create(ctx, refsKeyPrefix+holder.String(), value); err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = kr.Create(ctx, digestKeyPrefix+digest, []byte(holder.String()))
+	if err != nil {
+		return uuid.Nil, errors.Join(err, kr.Delete(ctx, refsKeyPrefix+holder.String()))
+	}
+
+	return holder, nil
+}
+
+// modify applies change to the references of the holder, retrying when
+// they have been changed concurrently.
+func (kr *KvRefRepo) modify(ctx context.Context, holder uuid.UUID, change func(record *refRecord) error) error {
+	for {
+		entry, err := kr.Get(ctx, refsKeyPrefix+holder.String())
+		if err != nil {
+			return err
+		}
+
+		var record refRecord
+		if err = json.Unmarshal(entry.Value(), &record); err != nil {
+			return err
+		}
+		if err = change(&record); err != nil {
+			return err
+		}
+
+		err = kr.update(ctx, holder, record, entry.Revision())
+		if isRevisionConflict(err) {
+			continue
+		}
+		return err
+	}
+}
+
+func (kr *KvRefRepo) update(ctx context.Context, holder uuid.UUID, record refRecord, revision uint64) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = kr.Update(ctx, refsKeyPrefix+holder.String(), value, revision)
+	return err
+}
+
+func isReference(ref uuid.UUID) func(refEntry) bool {
+	return func(r refEntry) bool {
+		return r.UUID == ref
+	}
+}
+
+// isRevisionConflict reports a failed optimistic update, the key has been
+// changed since it was read.
+func isRevisionConflict(err error) bool {
+	return errors.Is(err, jetstream.ErrKeyExists)
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			NewJsBookRepo,
+			fx.ParamTags(``, `name:"books"`),
+			fx.As(new(BookRepo)),
+		),
+		fx.Annotate(
+			NewKvRefRepo,
+			fx.ParamTags(`name:"refs"`),
+			fx.As(new(RefRepo)),
+		),
+		fx.Annotate(
+			NewJsCoverRepo,
+			fx.ParamTags(`name:"covers"`),
+			fx.As(new(CoverRepo)),
+		),
+		fx.Annotate(
+			NewKvUploadRepo,
+			fx.ParamTags(`name:"uploads"`),
+			fx.As(new(UploadRepo)),
+		),
+		fx.Annotate(
+			NewJsPartRepo,
+			fx.ParamTags(`name:"parts"`),
+			fx.As(new(PartRepo)),
+		),
+		fx.Annotate(
+			NewKvEventRepo,
+			fx.ParamTags(`name:"events"`),
+			fx.As(new(EventRepo)),
+		),
+	),
+)
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -10,6 +12,74 @@ import (
 
 type BookRepo interface {
 	Get(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error)
+	GetInfo(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error)
+	GetRange(ctx context.Context, bookUUID uuid.UUID, offset uint64, length uint64) (domain.Book, error)
 	Put(ctx context.Context, book domain.Book) error
+	PutMetadata(ctx context.Context, bookUUID uuid.UUID, md domain.Metadata) error
 	Delete(ctx context.Context, bookUUID uuid.UUID) error
+	// List returns the info of every stored book.
+	List(ctx context.Context) ([]domain.Book, error)
+}
+
+// RefRepo indexes stored books by content digest and counts references to
+// them. Each reference has its own uuid, the one of the book storing the
+// content is its holder.
+type RefRepo interface {
+	// Acquire takes the reference ref to the book holding digest,
+	// registering ref as its holder when there is none yet, and returns
+	// the holder.
+	Acquire(ctx context.Context, digest string, ref uuid.UUID) (uuid.UUID, error)
+	// Release drops the reference and reports its holder and how many
+	// references are left. It fails with ErrNotFound if the reference has
+	// been released already.
+	Release(ctx context.Context, ref uuid.UUID) (uuid.UUID, uint64, error)
+	// Resolve returns the holder of the book the reference refers to.
+	Resolve(ctx context.Context, ref uuid.UUID) (uuid.UUID, error)
+	// References returns the references to the book of the holder.
+	References(ctx context.Context, holder uuid.UUID) ([]domain.Reference, error)
+	Lookup(ctx context.Context, digest string) (uuid.UUID, error)
+}
+
+type CoverRepo interface {
+	Get(ctx context.Context, bookUUID uuid.UUID, size domain.CoverSize, mimeType string) (domain.Cover, error)
+	Put(ctx context.Context, cover domain.Cover) error
+	Delete(ctx context.Context, bookUUID uuid.UUID) error
+}
+
+// UploadRepo keeps the state of uploads in progress.
+type UploadRepo interface {
+	Create(ctx context.Context, upload domain.Upload) error
+	Get(ctx context.Context, uploadID uuid.UUID) (domain.Upload, error)
+	// Advance records the part stored at offset, it fails with ErrConflict
+	// unless the upload is still at that offset.
+	Advance(ctx context.Context, uploadID uuid.UUID, offset uint64, part string, size uint64) (domain.Upload, error)
+	// StartAssembly marks the complete upload as being assembled, it fails
+	// with ErrConflict if the upload is assembled already or an assembly
+	// made progress within stall.
+	StartAssembly(ctx context.Context, uploadID uuid.UUID, stall time.Duration) (domain.Upload, error)
+	// Progress records how many bytes have been assembled.
+	Progress(ctx context.Context, uploadID uuid.UUID, assembled uint64) error
+	// Finish records the book the upload is assembled into, it fails with
+	// ErrConflict if that is done already.
+	Finish(ctx context.Context, uploadID uuid.UUID, bookUUID uuid.UUID) (domain.Upload, error)
+	// Reject records that the upload is not a book.
+	Reject(ctx context.Context, uploadID uuid.UUID) error
+	Delete(ctx context.Context, uploadID uuid.UUID) error
+}
+
+type PartRepo interface {
+	// List returns the names of the stored parts.
+	List(ctx context.Context) ([]string, error)
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	Put(ctx context.Context, name string, r io.Reader) (uint64, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// EventRepo remembers the processed events, so that redelivered ones can
+// be told apart.
+type EventRepo interface {
+	// Claim marks the event as processed, it fails with ErrConflict if
+	// the event is marked already.
+	Claim(ctx context.Context, key string) error
+	Unclaim(ctx context.Context, key string) error
 }
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+var (
+	_ UploadRepo = (*KvUploadRepo)(nil)
+	_ PartRepo   = (*JsPartRepo)(nil)
+)
+
+type uploadRecord struct {
+	FileName   string    `json:"fileName"`
+	Length     uint64    `json:"length"`
+	Offset     uint64    `json:"offset"`
+	Parts      []string  `json:"parts,omitempty"`
+	BookUUID   uuid.UUID `json:"bookUUID"`
+	Assembled  uint64    `json:"assembled,omitempty"`
+	Assembling bool      `json:"assembling,omitempty"`
+	Invalid    bool      `json:"invalid,omitempty"`
+}
+
+func NewKvUploadRepo(kv jetstream.KeyValue) *KvUploadRepo {
+	return &KvUploadRepo{
+		KeyValue: kv,
+	}
+}
+
+type KvUploadRepo struct {
+	jetstream.KeyValue
+}
+
+func (kur *KvUploadRepo) Create(ctx context.Context, upload domain.Upload) error {
+	value, err := json.Marshal(uploadRecord{
+		FileName: upload.FileName,
+		Length:   upload.Length,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = kur.KeyValue.Create(ctx, upload.ID.String(), value)
+	return err
+}
+
+func (kur *KvUploadRepo) Get(ctx context.Context, uploadID uuid.UUID) (domain.Upload, error) {
+	upload, _, err := kur.get(ctx, uploadID)
+	return upload, err
+}
+
+func (kur *KvUploadRepo) Advance(
+	ctx context.Context,
+	uploadID uuid.UUID,
+	offset uint64,
+	part string,
+	size uint64,
+) (domain.Upload, error) {
+	return kur.modify(ctx, uploadID, func(upload *domain.Upload) error {
+		if upload.Offset != offset {
+			return repoerrors.ErrConflict{
+				Reason: fmt.Sprintf("upload is at offset %d, not %d", upload.Offset, offset),
+			}
+		}
+		if upload.Offset+size > upload.Length {
+			return repoerrors.ErrConflict{
+				Reason: fmt.Sprintf("part of %d bytes exceeds upload length %d", size, upload.Length),
+			}
+		}
+
+		upload.Offset += size
+		upload.Parts = append(upload.Parts, part)
+		return nil
+	})
+}
+
+func (kur *KvUploadRepo) StartAssembly(
+	ctx context.Context,
+	uploadID uuid.UUID,
+	stall time.Duration,
+) (domain.Upload, error) {
+	return kur.modify(ctx, uploadID, func(upload *domain.Upload) error {
+		// the entry is updated with the progress of the assembly
+		updatedAt := upload.ExpiresAt.Add(-domain.UploadTTL)
+		switch {
+		case !upload.Complete():
+			return repoerrors.ErrConflict{Reason: "upload is not complete"}
+		case upload.BookUUID != uuid.Nil || upload.Invalid:
+			return repoerrors.ErrConflict{Reason: "upload is assembled already"}
+		case upload.Assembling && time.Since(updatedAt) < stall:
+			return repoerrors.ErrConflict{Reason: "upload is being assembled"}
+		}
+
+		upload.Assembling = true
+		upload.Assembled = 0
+		return nil
+	})
+}
+
+func (kur *KvUploadRepo) Progress(ctx context.Context, uploadID uuid.UUID, assembled uint64) error {
+	_, err := kur.modify(ctx, uploadID, func(upload *domain.Upload) error {
+		upload.Assembled = assembled
+		return nil
+	})
+	return err
+}
+
+func (kur *KvUploadRepo) Finish(ctx context.Context, uploadID uuid.UUID, bookUUID uuid.UUID) (domain.Upload, error) {
+	return kur.modify(ctx, uploadID, func(upload *domain.Upload) error {
+		if upload.BookUUID != uuid.Nil {
+			return repoerrors.ErrConflict{Reason: "upload is assembled already"}
+		}
+
+		upload.BookUUID = bookUUID
+		upload.Parts = nil
+		upload.Assembled = upload.Length
+		upload.Assembling = false
+		return nil
+	})
+}
+
+func (kur *KvUploadRepo) Reject(ctx context.Context, uploadID uuid.UUID) error {
+	_, err := kur.modify(ctx, uploadID, func(upload *domain.Upload) error {
+		upload.Parts = nil
+		upload.Assembling = false
+		upload.Invalid = true
+		return nil
+	})
+	return err
+}
+
+func (kur *KvUploadRepo) Delete(ctx context.Context, uploadID uuid.UUID) error {
+	return kur.KeyValue.Delete(ctx, uploadID.String())
+}
+
+// modify applies change to the current state of the upload, retrying
+// when the upload has been changed concurrently.
+func (kur *KvUploadRepo) modify(
+	ctx context.Context,
+	uploadID uuid.UUID,
+	change func(upload *domain.Upload) error,
+) (domain.Upload, error) {
+	for {
+		upload, revision, err := kur.get(ctx, uploadID)
+		if err != nil {
+			return domain.Upload{}, err
+		}
+		if err = change(&upload); err != nil {
+			return domain.Upload{}, err
+		}
+
+		value, err := json.Marshal(uploadRecord{
+			FileName:   upload.FileName,
+			Length:     upload.Length,
+			Offset:     upload.Offset,
+			Parts:      upload.Parts,
+			BookUUID:   upload.BookUUID,
+			Assembled:  upload.Assembled,
+			Assembling: upload.Assembling,
+			Invalid:    upload.Invalid,
+		})
+		if err != nil {
+			return domain.Upload{}, err
+		}
+
+		_, err = kur.Update(ctx, uploadID.String(), value, revision)
+		if isRevisionConflict(err) {
+			continue
+		}
+		if err != nil {
+			return domain.Upload{}, err
+		}
+
+		// the bucket TTL counts from the last update
+		upload.ExpiresAt = time.Now().Add(domain.UploadTTL)
+		return upload, nil
+	}
+}
+
+func (kur *KvUploadRepo) get(ctx context.Context, uploadID uuid.UUID) (domain.Upload, uint64, error) {
+	entry, err := kur.KeyValue.Get(ctx, uploadID.String())
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return domain.Upload{}, 0, repoerrors.ErrNotFound{Inner: err}
+		}
+		return domain.Upload{}, 0, err
+	}
+
+	var record uploadRecord
+	if err = json.Unmarshal(entry.Value(), &record); err != nil {
+		return domain.Upload{}, 0, err
+	}
+
+	return domain.Upload{
+		ID:         uploadID,
+		FileName:   record.FileName,
+		Length:     record.Length,
+		Offset:     record.Offset,
+		Parts:      record.Parts,
+		BookUUID:   record.BookUUID,
+		Assembled:  record.Assembled,
+		Assembling: record.Assembling,
+		Invalid:    record.Invalid,
+		ExpiresAt:  entry.Created().Add(domain.UploadTTL),
+	}, entry.Revision(), nil
+}
+
+func NewJsPartRepo(store jetstream.ObjectStore) *JsPartRepo {
+	return &JsPartRepo{
+		ObjectStore: store,
+	}
+}
+
+// JsPartRepo stores the parts of uploads until they are assembled.
+type JsPartRepo struct {
+	jetstream.ObjectStore
+}
+
+func (jpr *JsPartRepo) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	obj, err := jpr.ObjectStore.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			return nil, repoerrors.ErrNotFound{Inner: err}
+		}
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func (jpr *JsPartRepo) List(ctx context.Context) ([]string, error) {
+	infos, err := jpr.ObjectStore.List(ctx)
+	if errors.Is(err, jetstream.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+
+	return names, nil
+}
+
+func (jpr *JsPartRepo) Put(ctx context.Context, name string, r io.Reader) (uint64, error) {
+	info, err := jpr.ObjectStore.Put(ctx, jetstream.ObjectMeta{Name: name}, r)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size, nil
+}
+
+func (jpr *JsPartRepo) Delete(ctx context.Context, name string) error {
+	err := jpr.ObjectStore.Delete(ctx, name)
+	if errors.Is(err, jetstream.ErrObjectNotFound) {
+		return nil
+	}
+
+	return err
+}
@@ -1,71 +1,267 @@
 package service
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
-	"strings"
+	"slices"
+	"strconv"
 
+	"github.com/docker/docker/pkg/ioutils"
 	"github.com/google/uuid"
 
 	"github.com/lunn06/library/bookfile/internal/app/repository"
 	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
 	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/app/thumbnail"
 	"github.com/lunn06/library/bookfile/internal/domain"
 )
 
 // TODO: add servicerrors handling
 
-func NewBookService(repo repository.BookRepo) *BookService {
+func NewBookService(
+	repo repository.BookRepo,
+	refs repository.RefRepo,
+	covers repository.CoverRepo,
+	events repository.EventRepo,
+) *BookService {
 	return &BookService{
-		repo: repo,
+		repo:   repo,
+		refs:   refs,
+		covers: covers,
+		events: events,
 	}
 }
 
 type BookService struct {
-	repo repository.BookRepo
+	repo   repository.BookRepo
+	refs   repository.RefRepo
+	covers repository.CoverRepo
+	events repository.EventRepo
 }
 
-func (s *BookService) Create(ctx context.Context, fileName string, r io.ReadCloser) (uuid.UUID, error) {
+// Create stores the book unless a book with the same content exists already,
+// in which case the existing one is referenced instead. Either way the book
+// gets a uuid of its own, which is released by Delete.
+func (s *BookService) Create(ctx context.Context, fileName string, r io.ReadCloser) (domain.Book, error) {
+	if r == nil {
+		return domain.Book{}, errors.New("buffer is nil")
+	}
+
+	br := bufio.NewReaderSize(r, domain.SniffLen)
+	head, err := br.Peek(domain.SniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return domain.Book{}, err
+	}
+
+	format := domain.DetectFormat(head)
 	switch {
+	case format == domain.FormatUnknown:
+		return domain.Book{}, servicerrors.ErrInvalidFormat{FileName: fileName}
 	case fileName == "":
-		fileName = "unknown"
-	case !strings.HasSuffix(fileName, ".pdf"):
-		return uuid.Nil, errors.New("invalid file name")
-	}
-	if r == nil {
-		return uuid.Nil, errors.New("buffer is nil")
+		fileName = "unknown" + format.Extension()
+	case domain.FormatFromFileName(fileName) != format:
+		return domain.Book{}, servicerrors.ErrInvalidFormat{FileName: fileName, Detected: string(format)}
 	}
 
 	bookUUID, err := uuid.NewRandom()
 	if err != nil {
-		return uuid.Nil, err
+		return domain.Book{}, err
 	}
 
+	hash := sha256.New()
+	extraction := startExtraction(format)
 	book := domain.Book{
-		UUID:           bookUUID,
-		FileName:       fileName,
-		FileReadCloser: r,
+		UUID:     bookUUID,
+		FileName: fileName,
+		Format:   format,
+		FileReadCloser: ioutils.NewReadCloserWrapper(
+			io.TeeReader(br, io.MultiWriter(hash, extraction)),
+			r.Close,
+		),
+	}
+	err = s.repo.Put(ctx, book)
+	extracted, extractErr := extraction.Wait(err)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	holderUUID, err := s.refs.Acquire(ctx, hex.EncodeToString(hash.Sum(nil)), bookUUID)
+	if err != nil {
+		return domain.Book{}, errors.Join(err, s.repo.Delete(ctx, bookUUID))
+	}
+	if holderUUID != bookUUID {
+		if err = s.repo.Delete(ctx, bookUUID); err != nil {
+			return domain.Book{}, err
+		}
+	}
+
+	// metadata is only a suggestion, losing it doesn't fail the upload
+	if holderUUID == bookUUID && extractErr == nil {
+		md := extracted.Metadata
+		md.HasCover = extracted.Cover != nil && s.putCovers(ctx, bookUUID, extracted.Cover) == nil
+		if !md.IsZero() {
+			_ = s.repo.PutMetadata(ctx, bookUUID, md)
+		}
+	}
+
+	return s.Info(ctx, bookUUID)
+}
+
+func (s *BookService) putCovers(ctx context.Context, bookUUID uuid.UUID, image []byte) error {
+	covers, err := thumbnail.Generate(image)
+	if err != nil {
+		return err
 	}
-	if err = s.repo.Put(ctx, book); err != nil {
-		return uuid.Nil, err
+
+	for _, cover := range covers {
+		cover.BookUUID = bookUUID
+		if err = s.covers.Put(ctx, cover); err != nil {
+			return errors.Join(err, s.covers.Delete(ctx, bookUUID))
+		}
 	}
 
-	return bookUUID, nil
+	return nil
 }
 
 func (s *BookService) Get(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
-	book, err := s.repo.Get(ctx, bookUUID)
+	return s.read(ctx, bookUUID, s.repo.Get)
+}
+
+func (s *BookService) Info(ctx context.Context, bookUUID uuid.UUID) (domain.Book, error) {
+	return s.read(ctx, bookUUID, s.repo.GetInfo)
+}
+
+func (s *BookService) GetRange(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	offset uint64,
+	length uint64,
+) (domain.Book, error) {
+	return s.read(ctx, bookUUID, func(ctx context.Context, holder uuid.UUID) (domain.Book, error) {
+		return s.repo.GetRange(ctx, holder, offset, length)
+	})
+}
+
+// read reads the book the reference refers to, the book keeps the uuid
+// of the reference.
+func (s *BookService) read(
+	ctx context.Context,
+	ref uuid.UUID,
+	get func(ctx context.Context, holder uuid.UUID) (domain.Book, error),
+) (domain.Book, error) {
+	holder, err := s.refs.Resolve(ctx, ref)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	book, err := get(ctx, holder)
 	if repoerrors.IsErrNotFound(err) {
 		return domain.Book{}, servicerrors.ErrResourceNotFound{Inner: err}
 	}
+	if err != nil {
+		return domain.Book{}, err
+	}
 	if book.FileName == "" {
 		book.FileName = "unknown"
 	}
+	book.UUID = ref
 
-	return book, err
+	return book, nil
 }
 
+func (s *BookService) FindByDigest(ctx context.Context, digest string) (domain.Book, error) {
+	bookUUID, err := s.refs.Lookup(ctx, digest)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.Book{}, servicerrors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	return s.Info(ctx, bookUUID)
+}
+
+// Delete drops the reference to the book, the file itself is removed
+// with the last one. It fails with ErrResourceNotFound if the reference has
+// been dropped already.
 func (s *BookService) Delete(ctx context.Context, bookUUID uuid.UUID) error {
-	return s.repo.Delete(ctx, bookUUID)
+	holder, remaining, err := s.refs.Release(ctx, bookUUID)
+	if repoerrors.IsErrNotFound(err) {
+		return servicerrors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil || remaining > 0 {
+		return err
+	}
+
+	return s.remove(ctx, holder)
+}
+
+// Release drops the reference held by the catalogue book bookID once it
+// is deleted. It is done at most once per book, so that a redelivered
+// event does not drop a reference of another book with the same file.
+func (s *BookService) Release(ctx context.Context, bookID int64, bookUUID uuid.UUID) error {
+	key := "book-deleted." + strconv.FormatInt(bookID, 10)
+	err := s.events.Claim(ctx, key)
+	if repoerrors.IsErrConflict(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.Delete(ctx, bookUUID)
+	if servicerrors.IsErrResourceNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, s.events.Unclaim(ctx, key))
+	}
+
+	return nil
+}
+
+// References returns the references to the stored book.
+func (s *BookService) References(ctx context.Context, holder uuid.UUID) ([]domain.Reference, error) {
+	return s.refs.References(ctx, holder)
+}
+
+func (s *BookService) remove(ctx context.Context, bookUUID uuid.UUID) error {
+	coversErr := s.covers.Delete(ctx, bookUUID)
+	err := s.repo.Delete(ctx, bookUUID)
+	if repoerrors.IsErrNotFound(err) {
+		return servicerrors.ErrResourceNotFound{Inner: errors.Join(err, coversErr)}
+	}
+
+	return errors.Join(err, coversErr)
+}
+
+func (s *BookService) Cover(
+	ctx context.Context,
+	bookUUID uuid.UUID,
+	size string,
+	mimeType string,
+) (domain.Cover, error) {
+	coverSize := domain.CoverSize(size)
+	if coverSize.Width() == 0 || !slices.Contains(domain.CoverMIMETypes, mimeType) {
+		return domain.Cover{}, servicerrors.ErrResourceNotFound{
+			Inner: fmt.Errorf("no %s cover of size %q", mimeType, size),
+		}
+	}
+
+	holder, err := s.refs.Resolve(ctx, bookUUID)
+	if err != nil {
+		return domain.Cover{}, err
+	}
+
+	cover, err := s.covers.Get(ctx, holder, coverSize, mimeType)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.Cover{}, servicerrors.ErrResourceNotFound{Inner: err}
+	}
+
+	return cover, err
 }
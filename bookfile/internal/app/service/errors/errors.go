@@ -30,3 +30,55 @@ func (err ErrResourceNotFound) Is(target error) bool {
 	_, ok := target.(ErrResourceNotFound)
 	return ok
 }
+
+func IsErrInvalidFormat(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrInvalidFormat{})
+}
+
+type ErrInvalidFormat struct {
+	FileName string
+	Detected string
+}
+
+func (err ErrInvalidFormat) Error() string {
+	if err.Detected == "" {
+		return fmt.Sprintf("unsupported format of %q", err.FileName)
+	}
+	return fmt.Sprintf("extension of %q does not match detected format %s", err.FileName, err.Detected)
+}
+
+func (err ErrInvalidFormat) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrInvalidFormat)
+	return ok
+}
+
+func IsErrUploadConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrUploadConflict{})
+}
+
+// ErrUploadConflict is returned for a part sent at an offset
+// the upload is not at.
+type ErrUploadConflict struct {
+	Offset uint64
+}
+
+func (err ErrUploadConflict) Error() string {
+	return fmt.Sprintf("upload is at offset %d", err.Offset)
+}
+
+func (err ErrUploadConflict) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrUploadConflict)
+	return ok
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"io"
+
+	"github.com/lunn06/library/bookfile/internal/app/metadata"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+type extractResult struct {
+	res metadata.Result
+	err error
+}
+
+// extraction feeds the metadata extractor with a copy of the upload.
+// It never fails the writes, the extractor may stop reading early.
+type extraction struct {
+	pw   *io.PipeWriter
+	done chan extractResult
+}
+
+func startExtraction(format domain.Format) *extraction {
+	pr, pw := io.Pipe()
+	e := &extraction{
+		pw:   pw,
+		done: make(chan extractResult, 1),
+	}
+
+	go func() {
+		res, err := metadata.Extract(format, pr)
+		_ = pr.Close()
+		e.done <- extractResult{res: res, err: err}
+	}()
+
+	return e
+}
+
+func (e *extraction) Write(p []byte) (int, error) {
+	_, _ = e.pw.Write(p)
+	return len(p), nil
+}
+
+// Wait ends the copy and returns what the extractor found, err is handed
+// to the extractor if the upload itself failed.
+func (e *extraction) Wait(err error) (metadata.Result, error) {
+	_ = e.pw.CloseWithError(err)
+	res := <-e.done
+	return res.res, res.err
+}
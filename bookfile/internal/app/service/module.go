@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		NewBookService,
+		NewUploadService,
+		NewReconciler,
+	),
+	fx.Invoke(
+		func(lifecycle fx.Lifecycle, reconciler *Reconciler) {
+			runInBackground(lifecycle, reconciler.Run)
+		},
+		func(lifecycle fx.Lifecycle, uploads *UploadService) {
+			runInBackground(lifecycle, uploads.Run)
+		},
+	),
+)
+
+// runInBackground runs run from the start until the stop of the app.
+func runInBackground(lifecycle fx.Lifecycle, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lunn06/library/bookfile/internal/app/repository"
+	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+// BookURLChecker tells which of the book urls no catalogue book refers to.
+type BookURLChecker interface {
+	MissingBookURLs(ctx context.Context, bookURLs []string) ([]string, error)
+}
+
+type ReconcileConfig struct {
+	Interval time.Duration `default:"24h"`
+	// GracePeriod is how long a stored file may wait for a catalogue book
+	// to refer to it.
+	GracePeriod time.Duration `default:"24h" split_words:"true"`
+	BatchSize   int           `default:"100" split_words:"true"`
+}
+
+func NewReconciler(
+	repo repository.BookRepo,
+	books *BookService,
+	checker BookURLChecker,
+	cfg ReconcileConfig,
+) *Reconciler {
+	return &Reconciler{
+		repo:        repo,
+		books:       books,
+		checker:     checker,
+		interval:    cfg.Interval,
+		gracePeriod: cfg.GracePeriod,
+		batchSize:   cfg.BatchSize,
+	}
+}
+
+// Reconciler removes files of books deleted while the book.deleted event
+// could not reach the service, and files uploaded for books never created.
+type Reconciler struct {
+	repo        repository.BookRepo
+	books       *BookService
+	checker     BookURLChecker
+	interval    time.Duration
+	gracePeriod time.Duration
+	batchSize   int
+}
+
+// Run reconciles on start and then every interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := r.Reconcile(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to reconcile book files", "err", err)
+		}
+		if purged > 0 {
+			slog.Info("Released orphaned book file references", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile releases the references older than the grace period which no
+// catalogue book refers to and returns how many were released. A file is
+// removed with its last reference.
+func (r *Reconciler) Reconcile(ctx context.Context) (int, error) {
+	books, err := r.repo.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		purged   int
+		deadline = time.Now().Add(-r.gracePeriod)
+		bookURLs = make(map[string]uuid.UUID)
+	)
+	for _, book := range books {
+		refs, err := r.books.References(ctx, book.UUID)
+		if err != nil {
+			return 0, err
+		}
+		for _, ref := range refs {
+			acquired := ref.Acquired
+			if acquired.IsZero() {
+				acquired = book.ModTime
+			}
+			if acquired.Before(deadline) {
+				bookURLs[domain.BookPath(ref.UUID)] = ref.UUID
+			}
+		}
+	}
+
+	batch := make([]string, 0, r.batchSize)
+	flush := func() error {
+		missing, err := r.checker.MissingBookURLs(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, bookURL := range missing {
+			ref, ok := bookURLs[bookURL]
+			if !ok {
+				continue
+			}
+			err = r.books.Delete(ctx, ref)
+			if servicerrors.IsErrResourceNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			purged++
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for bookURL := range bookURLs {
+		batch = append(batch, bookURL)
+		if len(batch) == r.batchSize {
+			if err = flush(); err != nil {
+				return purged, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		err = flush()
+	}
+
+	return purged, err
+}
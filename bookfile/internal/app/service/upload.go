@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lunn06/library/bookfile/internal/app/repository"
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+const (
+	// assemblyStall is how long an assembly may make no progress before it
+	// is taken for interrupted, by a restart say, and started over.
+	assemblyStall = time.Minute
+	// progressInterval is how often an assembly records its progress.
+	progressInterval = 10 * time.Second
+	// sweepInterval is how often the parts of expired uploads are deleted.
+	sweepInterval = time.Hour
+)
+
+func NewUploadService(
+	uploads repository.UploadRepo,
+	parts repository.PartRepo,
+	books *BookService,
+) *UploadService {
+	return &UploadService{
+		uploads: uploads,
+		parts:   parts,
+		books:   books,
+	}
+}
+
+// UploadService receives book files in parts and assembles them into
+// books in the background once all of them are there.
+type UploadService struct {
+	uploads repository.UploadRepo
+	parts   repository.PartRepo
+	books   *BookService
+}
+
+func (s *UploadService) Create(ctx context.Context, fileName string, length uint64) (domain.Upload, error) {
+	uploadID, err := uuid.NewRandom()
+	if err != nil {
+		return domain.Upload{}, err
+	}
+
+	upload := domain.Upload{
+		ID:       uploadID,
+		FileName: fileName,
+		Length:   length,
+	}
+	if err = s.uploads.Create(ctx, upload); err != nil {
+		return domain.Upload{}, err
+	}
+
+	return s.get(ctx, uploadID)
+}
+
+// Get returns the state of the upload, resuming its assembly if that has
+// been interrupted. It fails with ErrInvalidFormat if the upload is not
+// a book.
+func (s *UploadService) Get(ctx context.Context, uploadID uuid.UUID) (domain.Upload, error) {
+	upload, err := s.get(ctx, uploadID)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if upload.Complete() {
+		return s.startAssembly(ctx, upload)
+	}
+
+	return upload, nil
+}
+
+func (s *UploadService) get(ctx context.Context, uploadID uuid.UUID) (domain.Upload, error) {
+	upload, err := s.uploads.Get(ctx, uploadID)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.Upload{}, servicerrors.ErrResourceNotFound{Inner: err}
+	}
+
+	return upload, err
+}
+
+// Append stores r as the part at offset. What has been read of r is kept
+// even if reading it fails, so that the upload can be resumed from there.
+// The assembly of the book starts with the last part.
+func (s *UploadService) Append(
+	ctx context.Context,
+	uploadID uuid.UUID,
+	offset uint64,
+	r io.Reader,
+) (domain.Upload, error) {
+	upload, err := s.get(ctx, uploadID)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if upload.Offset != offset {
+		return domain.Upload{}, servicerrors.ErrUploadConflict{Offset: upload.Offset}
+	}
+	if upload.Complete() {
+		// a retry of the last part
+		return s.startAssembly(ctx, upload)
+	}
+
+	partID, err := uuid.NewRandom()
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	// parts sent concurrently at the same offset must not overwrite each other
+	part := fmt.Sprintf("%s/%020d.%s", uploadID, offset, partID)
+
+	pr := &partialReader{r: io.LimitReader(r, int64(upload.Length-upload.Offset))}
+	size, err := s.parts.Put(ctx, part, pr)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if size == 0 {
+		return upload, errors.Join(pr.err, s.parts.Delete(ctx, part))
+	}
+
+	upload, err = s.uploads.Advance(ctx, uploadID, offset, part, size)
+	if repoerrors.IsErrConflict(err) {
+		current, getErr := s.get(ctx, uploadID)
+		return domain.Upload{}, errors.Join(
+			servicerrors.ErrUploadConflict{Offset: current.Offset},
+			getErr,
+			s.parts.Delete(ctx, part),
+		)
+	}
+	if err != nil {
+		return domain.Upload{}, errors.Join(err, s.parts.Delete(ctx, part))
+	}
+	if pr.err != nil {
+		return upload, pr.err
+	}
+
+	if upload.Complete() {
+		return s.startAssembly(ctx, upload)
+	}
+	return upload, nil
+}
+
+// startAssembly assembles the complete upload into a book in the
+// background, unless that is done already or in progress.
+func (s *UploadService) startAssembly(ctx context.Context, upload domain.Upload) (domain.Upload, error) {
+	if upload.Invalid {
+		return domain.Upload{}, servicerrors.ErrInvalidFormat{FileName: upload.FileName}
+	}
+	if upload.BookUUID != uuid.Nil {
+		return upload, nil
+	}
+
+	started, err := s.uploads.StartAssembly(ctx, upload.ID, assemblyStall)
+	if repoerrors.IsErrConflict(err) {
+		return s.get(ctx, upload.ID)
+	}
+	if err != nil {
+		return domain.Upload{}, err
+	}
+
+	// outlives the request, large books take longer to assemble than the
+	// clients wait for a response
+	go func() {
+		if err := s.assemble(context.WithoutCancel(ctx), started); err != nil {
+			slog.Error("Failed to assemble upload", "upload", started.ID, "err", err)
+		}
+	}()
+
+	return started, nil
+}
+
+func (s *UploadService) assemble(ctx context.Context, upload domain.Upload) error {
+	book, err := s.books.Create(ctx, upload.FileName, &progressReader{
+		r: &partsReader{
+			ctx:   ctx,
+			repo:  s.parts,
+			names: upload.Parts,
+		},
+		report: func(assembled uint64) error {
+			return s.uploads.Progress(ctx, upload.ID, assembled)
+		},
+		last: time.Now(),
+	})
+	if servicerrors.IsErrInvalidFormat(err) {
+		// no point in keeping what can never become a book
+		return errors.Join(
+			s.uploads.Reject(ctx, upload.ID),
+			s.deleteParts(ctx, upload.Parts),
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.uploads.Finish(ctx, upload.ID, book.UUID)
+	if repoerrors.IsErrConflict(err) {
+		// assembled concurrently, drop the reference taken by this assembly
+		return s.books.Delete(ctx, book.UUID)
+	}
+	if err != nil {
+		return errors.Join(err, s.books.Delete(ctx, book.UUID))
+	}
+
+	return s.deleteParts(ctx, upload.Parts)
+}
+
+// Delete cancels the upload, a book it has been assembled into is kept.
+func (s *UploadService) Delete(ctx context.Context, uploadID uuid.UUID) error {
+	upload, err := s.get(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(
+		s.deleteParts(ctx, upload.Parts),
+		s.uploads.Delete(ctx, uploadID),
+	)
+}
+
+// Run sweeps the parts of the expired uploads every sweepInterval until ctx
+// is done.
+func (s *UploadService) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		swept, err := s.Sweep(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to sweep upload parts", "err", err)
+		}
+		if swept > 0 {
+			slog.Info("Swept parts of expired uploads", "count", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes the parts of the expired uploads and returns how many were
+// deleted. The parts are kept for as long as their upload, which expires
+// UploadTTL after its last activity.
+func (s *UploadService) Sweep(ctx context.Context) (int, error) {
+	parts, err := s.parts.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		swept   int
+		expired = make(map[string]bool)
+	)
+	for _, part := range parts {
+		uploadID, _, _ := strings.Cut(part, "/")
+		gone, ok := expired[uploadID]
+		if !ok {
+			if gone, err = s.expired(ctx, uploadID); err != nil {
+				return swept, err
+			}
+			expired[uploadID] = gone
+		}
+		if !gone {
+			continue
+		}
+
+		if err = s.parts.Delete(ctx, part); err != nil {
+			return swept, err
+		}
+		swept++
+	}
+
+	return swept, nil
+}
+
+func (s *UploadService) expired(ctx context.Context, uploadID string) (bool, error) {
+	id, err := uuid.Parse(uploadID)
+	if err != nil {
+		// not a part of an upload
+		return true, nil
+	}
+
+	_, err = s.uploads.Get(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func (s *UploadService) deleteParts(ctx context.Context, parts []string) error {
+	var errs []error
+	for _, part := range parts {
+		errs = append(errs, s.parts.Delete(ctx, part))
+	}
+
+	return errors.Join(errs...)
+}
+
+// partialReader ends the part where reading fails and keeps the error
+// for later.
+type partialReader struct {
+	r   io.Reader
+	err error
+}
+
+func (pr *partialReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		pr.err = err
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// progressReader reports how much has been read, at most once every
+// progressInterval.
+type progressReader struct {
+	r      io.ReadCloser
+	report func(read uint64) error
+	read   uint64
+	last   time.Time
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.read += uint64(n)
+	if time.Since(pr.last) >= progressInterval {
+		pr.last = time.Now()
+		if rerr := pr.report(pr.read); rerr != nil {
+			// at worst the assembly is taken for stalled and started over,
+			// only one of them finishes
+			slog.Warn("Failed to record assembly progress", "err", rerr)
+		}
+	}
+
+	return n, err
+}
+
+func (pr *progressReader) Close() error {
+	return pr.r.Close()
+}
+
+// partsReader reads the parts one after another, opening each when
+// the previous one is over.
+type partsReader struct {
+	ctx   context.Context
+	repo  repository.PartRepo
+	names []string
+	cur   io.ReadCloser
+}
+
+func (pr *partsReader) Read(p []byte) (int, error) {
+	for {
+		if pr.cur == nil {
+			if len(pr.names) == 0 {
+				return 0, io.EOF
+			}
+
+			cur, err := pr.repo.Get(pr.ctx, pr.names[0])
+			if err != nil {
+				return 0, err
+			}
+			pr.cur, pr.names = cur, pr.names[1:]
+		}
+
+		n, err := pr.cur.Read(p)
+		if errors.Is(err, io.EOF) {
+			err = pr.cur.Close()
+			pr.cur = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (pr *partsReader) Close() error {
+	if pr.cur == nil {
+		return nil
+	}
+
+	return pr.cur.Close()
+}
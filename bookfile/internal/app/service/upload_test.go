@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/bookfile/internal/app/repository"
+	repoerrors "github.com/lunn06/library/bookfile/internal/app/repository/errors"
+	"github.com/lunn06/library/bookfile/internal/domain"
+)
+
+type memPartRepo map[string][]byte
+
+var _ repository.PartRepo = memPartRepo(nil)
+
+func (m memPartRepo) List(context.Context) ([]string, error) {
+	return slices.Sorted(maps.Keys(m)), nil
+}
+
+func (m memPartRepo) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, errors.New("no part " + name)
+	}
+	return io.NopCloser(iotest.HalfReader(bytes.NewReader(data))), nil
+}
+
+func (m memPartRepo) Put(_ context.Context, name string, r io.Reader) (uint64, error) {
+	data, err := io.ReadAll(r)
+	m[name] = data
+	return uint64(len(data)), err
+}
+
+func (m memPartRepo) Delete(_ context.Context, name string) error {
+	delete(m, name)
+	return nil
+}
+
+func TestPartsReader(t *testing.T) {
+	repo := memPartRepo{
+		"a": []byte("The Master "),
+		"b": nil,
+		"c": []byte("and Margarita"),
+	}
+	r := &partsReader{
+		ctx:   context.Background(),
+		repo:  repo,
+		names: []string{"a", "b", "c"},
+	}
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "The Master and Margarita", string(data))
+	assert.NoError(t, r.Close())
+}
+
+func TestPartsReaderMissingPart(t *testing.T) {
+	r := &partsReader{
+		ctx:   context.Background(),
+		repo:  memPartRepo{"a": []byte("abc")},
+		names: []string{"a", "b"},
+	}
+
+	_, err := io.ReadAll(r)
+	assert.Error(t, err)
+}
+
+func TestPartialReader(t *testing.T) {
+	errBroken := errors.New("connection reset")
+	pr := &partialReader{
+		r: io.MultiReader(
+			bytes.NewReader([]byte("received")),
+			iotest.ErrReader(errBroken),
+		),
+	}
+
+	repo := memPartRepo{}
+	size, err := repo.Put(context.Background(), "part", pr)
+	require.NoError(t, err)
+	assert.EqualValues(t, len("received"), size)
+	assert.ErrorIs(t, pr.err, errBroken)
+}
+
+// uploadSet has the uploads with the given ids.
+type uploadSet struct {
+	repository.UploadRepo
+	ids map[uuid.UUID]bool
+}
+
+func (u uploadSet) Get(_ context.Context, uploadID uuid.UUID) (domain.Upload, error) {
+	if !u.ids[uploadID] {
+		return domain.Upload{}, repoerrors.ErrNotFound{Inner: errors.New("no upload " + uploadID.String())}
+	}
+	return domain.Upload{ID: uploadID}, nil
+}
+
+func TestSweep(t *testing.T) {
+	active, expired := uuid.New(), uuid.New()
+	parts := memPartRepo{
+		active.String() + "/0":  []byte("kept"),
+		expired.String() + "/0": []byte("swept"),
+		expired.String() + "/1": []byte("swept"),
+	}
+	s := NewUploadService(uploadSet{ids: map[uuid.UUID]bool{active: true}}, parts, nil)
+
+	swept, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, swept)
+	assert.Equal(t, memPartRepo{active.String() + "/0": []byte("kept")}, parts)
+}
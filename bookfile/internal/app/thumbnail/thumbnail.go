@@ -0,0 +1,91 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	"golang.org/x/image/draw"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+	"github.com/lunn06/library/bookfile/pkg/webp"
+
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxPixels bounds the covers decoded while a book is uploaded, 16MP
+	// are plenty for a cover and take 64MB decoded.
+	maxPixels   = 16 << 20
+	jpegQuality = 85
+)
+
+var errTooLarge = errors.New("thumbnail: cover image is too large")
+
+// Generate scales the cover to every thumbnail size, each one encoded
+// as JPEG and WebP. Covers are never scaled up.
+func Generate(cover []byte) ([]domain.Cover, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(cover))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, errTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(cover))
+	if err != nil {
+		return nil, err
+	}
+
+	covers := make([]domain.Cover, 0, len(domain.CoverSizes)*len(domain.CoverMIMETypes))
+	for _, size := range domain.CoverSizes {
+		thumb := scale(src, size.Width())
+
+		for _, mimeType := range domain.CoverMIMETypes {
+			var buf bytes.Buffer
+			if err = encode(&buf, thumb, mimeType); err != nil {
+				return nil, err
+			}
+
+			covers = append(covers, domain.Cover{
+				Size:           size,
+				MIMEType:       mimeType,
+				Length:         uint64(buf.Len()),
+				FileReadCloser: io.NopCloser(&buf),
+			})
+		}
+	}
+
+	return covers, nil
+}
+
+// scale fits src into width, transparent covers are put on white.
+func scale(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	width = min(width, b.Dx())
+	height := max(1, (b.Dy()*width+b.Dx()/2)/b.Dx())
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	return dst
+}
+
+func encode(w io.Writer, m image.Image, mimeType string) error {
+	switch mimeType {
+	case domain.MIMETypeJPEG:
+		return jpeg.Encode(w, m, &jpeg.Options{Quality: jpegQuality})
+	case domain.MIMETypeWebP:
+		return webp.Encode(w, m)
+	default:
+		return errors.New("thumbnail: unsupported format " + mimeType)
+	}
+}
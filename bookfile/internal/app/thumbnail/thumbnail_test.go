@@ -0,0 +1,54 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
+
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+func TestGenerate(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 600))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+	var cover bytes.Buffer
+	require.NoError(t, png.Encode(&cover, src))
+
+	covers, err := Generate(cover.Bytes())
+	require.NoError(t, err)
+	require.Len(t, covers, len(domain.CoverSizes)*len(domain.CoverMIMETypes))
+
+	for _, c := range covers {
+		m, format, err := image.Decode(&c)
+		require.NoError(t, err)
+
+		assert.Equal(t, "image/"+format, c.MIMEType)
+		wantWidth := min(c.Size.Width(), 400)
+		assert.Equal(t, image.Pt(wantWidth, wantWidth*3/2), m.Bounds().Size(), "%s %s", c.Size, c.MIMEType)
+	}
+}
+
+func TestScaleFlattensTransparency(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+
+	thumb := scale(src, 5)
+
+	assert.Equal(t, image.Pt(5, 5), thumb.Bounds().Size())
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, thumb.At(2, 2))
+}
+
+func TestGenerateNotAnImage(t *testing.T) {
+	_, err := Generate([]byte("%PDF-1.7"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/bookfile/internal/api/nats"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+)
+
+type Config struct {
+	fx.Out
+
+	Nats      nats.Config
+	Reconcile service.ReconcileConfig
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -9,9 +10,22 @@ import (
 type Book struct {
 	UUID           uuid.UUID
 	FileName       string
+	Format         Format
+	Size           uint64
+	Digest         string
+	ModTime        time.Time
+	Metadata       Metadata
 	FileReadCloser io.ReadCloser
 }
 
+// Reference is a book referring to the stored file of another book, its
+// holder. Acquired is zero for the references taken before they were
+// recorded one by one.
+type Reference struct {
+	UUID     uuid.UUID
+	Acquired time.Time
+}
+
 func (b *Book) Read(p []byte) (int, error) {
 	return b.FileReadCloser.Read(p)
 }
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CoverSize string
+
+const (
+	CoverSmall  CoverSize = "small"
+	CoverMedium CoverSize = "medium"
+	CoverLarge  CoverSize = "large"
+)
+
+const (
+	MIMETypeJPEG = "image/jpeg"
+	MIMETypeWebP = "image/webp"
+)
+
+var (
+	CoverSizes     = []CoverSize{CoverSmall, CoverMedium, CoverLarge}
+	CoverMIMETypes = []string{MIMETypeJPEG, MIMETypeWebP}
+)
+
+// Width is the thumbnail width, the height keeps the cover aspect ratio.
+func (s CoverSize) Width() int {
+	switch s {
+	case CoverSmall:
+		return 160
+	case CoverMedium:
+		return 320
+	case CoverLarge:
+		return 640
+	default:
+		return 0
+	}
+}
+
+// Cover is a thumbnail of the book cover in one of the sizes and formats.
+type Cover struct {
+	BookUUID       uuid.UUID
+	Size           CoverSize
+	MIMEType       string
+	Length         uint64
+	Digest         string
+	ModTime        time.Time
+	FileReadCloser io.ReadCloser
+}
+
+func (c *Cover) Read(p []byte) (int, error) {
+	return c.FileReadCloser.Read(p)
+}
+
+func (c *Cover) Close() error {
+	return c.FileReadCloser.Close()
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// SniffLen is the amount of leading bytes DetectFormat needs to recognise
+// any of the supported formats.
+const SniffLen = 1024
+
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatPDF     Format = "pdf"
+	FormatEPUB    Format = "epub"
+	FormatFB2     Format = "fb2"
+	FormatDjVu    Format = "djvu"
+	FormatMOBI    Format = "mobi"
+	FormatText    Format = "txt"
+)
+
+var formatMIMETypes = map[Format]string{
+	FormatPDF:  "application/pdf",
+	FormatEPUB: "application/epub+zip",
+	FormatFB2:  "application/x-fictionbook+xml",
+	FormatDjVu: "image/vnd.djvu",
+	FormatMOBI: "application/x-mobipocket-ebook",
+	FormatText: "text/plain; charset=utf-8",
+}
+
+var extensionFormats = map[string]Format{
+	".pdf":  FormatPDF,
+	".epub": FormatEPUB,
+	".fb2":  FormatFB2,
+	".djvu": FormatDjVu,
+	".djv":  FormatDjVu,
+	".mobi": FormatMOBI,
+	".azw":  FormatMOBI,
+	".txt":  FormatText,
+}
+
+func (f Format) MIMEType() string {
+	return formatMIMETypes[f]
+}
+
+func (f Format) Extension() string {
+	if f == FormatUnknown {
+		return ""
+	}
+	return "." + string(f)
+}
+
+func FormatFromMIMEType(mimeType string) Format {
+	for format, known := range formatMIMETypes {
+		if known == mimeType {
+			return format
+		}
+	}
+	return FormatUnknown
+}
+
+func FormatFromFileName(fileName string) Format {
+	return extensionFormats[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// DetectFormat recognises the format by magic bytes of the file head.
+func DetectFormat(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF
+	case isEPUB(head):
+		return FormatEPUB
+	case bytes.HasPrefix(head, []byte("AT&TFORM")) && len(head) >= 16 &&
+		bytes.HasPrefix(head[12:], []byte("DJV")):
+		return FormatDjVu
+	case len(head) >= 68 && bytes.Equal(head[60:68], []byte("BOOKMOBI")):
+		return FormatMOBI
+	case bytes.Contains(head, []byte("<FictionBook")):
+		return FormatFB2
+	case isText(head):
+		return FormatText
+	default:
+		return FormatUnknown
+	}
+}
+
+// isEPUB checks the OCF requirement that the archive starts with an
+// uncompressed "mimetype" entry holding the EPUB media type.
+func isEPUB(head []byte) bool {
+	const localHeaderLen = 30
+	if len(head) < localHeaderLen || !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		return false
+	}
+
+	nameLen := int(binary.LittleEndian.Uint16(head[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(head[28:30]))
+	if len(head) < localHeaderLen+nameLen+extraLen {
+		return false
+	}
+
+	name := head[localHeaderLen : localHeaderLen+nameLen]
+	content := head[localHeaderLen+nameLen+extraLen:]
+
+	return string(name) == "mimetype" && bytes.HasPrefix(content, []byte("application/epub+zip"))
+}
+
+func isText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+
+	// the head may cut a multibyte rune in half
+	for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	if !utf8.Valid(head) {
+		return false
+	}
+
+	for _, r := range string(head) {
+		if r < ' ' && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	mobi := make([]byte, 78)
+	copy(mobi[60:], "BOOKMOBI")
+
+	for name, tc := range map[string]struct {
+		head   []byte
+		format Format
+	}{
+		"pdf":       {[]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), FormatPDF},
+		"epub":      {epubHead(t), FormatEPUB},
+		"djvu":      {[]byte("AT&TFORM\x00\x00\x10\x00DJVMDIRM"), FormatDjVu},
+		"mobi":      {mobi, FormatMOBI},
+		"fb2":       {[]byte(`<?xml version="1.0" encoding="utf-8"?><FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">`), FormatFB2},
+		"text":      {[]byte("Call me Ishmael.\r\nSome years ago\tnever mind how long"), FormatText},
+		"cut rune":  {[]byte("Привет")[:5], FormatText},
+		"plain zip": {[]byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"), FormatUnknown},
+		"binary":    {[]byte{0x00, 0x01, 0x02, 0xff}, FormatUnknown},
+		"empty":     {nil, FormatUnknown},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.format, DetectFormat(tc.head))
+		})
+	}
+}
+
+func TestFormatFromFileName(t *testing.T) {
+	assert.Equal(t, FormatPDF, FormatFromFileName("book.PDF"))
+	assert.Equal(t, FormatDjVu, FormatFromFileName("scan.djv"))
+	assert.Equal(t, FormatMOBI, FormatFromFileName("kindle.azw"))
+	assert.Equal(t, FormatUnknown, FormatFromFileName("archive.zip"))
+	assert.Equal(t, FormatUnknown, FormatFromFileName("noext"))
+}
+
+func TestFormatFromMIMEType(t *testing.T) {
+	for format := range formatMIMETypes {
+		assert.Equal(t, format, FormatFromMIMEType(format.MIMEType()))
+	}
+	assert.Equal(t, FormatUnknown, FormatFromMIMEType("application/zip"))
+}
+
+func epubHead(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("application/epub+zip"))
+	require.NoError(t, err)
+	_, err = zw.Create("META-INF/container.xml")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Metadata is what the book file tells about itself, it is only a suggestion
+// for the bookinfo record.
+type Metadata struct {
+	Title       string
+	Creators    []string
+	Language    string
+	Publisher   string
+	ISBN        string
+	Description string
+	HasCover    bool
+}
+
+func (m Metadata) IsZero() bool {
+	return m.Title == "" &&
+		len(m.Creators) == 0 &&
+		m.Language == "" &&
+		m.Publisher == "" &&
+		m.ISBN == "" &&
+		m.Description == "" &&
+		!m.HasCover
+}
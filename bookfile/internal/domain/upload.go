@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadTTL is how long an unfinished upload is kept after its last part.
+const UploadTTL = 24 * time.Hour
+
+// Upload is a book file sent in parts, so that an interrupted upload can
+// be resumed from the last stored one.
+type Upload struct {
+	ID       uuid.UUID
+	FileName string
+	Length   uint64
+	Offset   uint64
+	// Parts are the names of the stored parts in order.
+	Parts []string
+	// BookUUID is set once the parts are assembled into a book.
+	BookUUID uuid.UUID
+	// Assembled counts the bytes assembled into the book so far, the
+	// assembly runs in the background once the last part is stored.
+	Assembled  uint64
+	Assembling bool
+	// Invalid is set when the parts turn out not to be a book.
+	Invalid   bool
+	ExpiresAt time.Time
+}
+
+func (u Upload) Complete() bool {
+	return u.Offset == u.Length
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// BookPathPrefix is the path the gateway serves book files under, catalogue
+// books refer to their files with urls of such paths.
+const BookPathPrefix = "/book/file/"
+
+func BookPath(bookUUID uuid.UUID) string {
+	return BookPathPrefix + bookUUID.String()
+}
+
+// ParseBookURL returns the book a catalogue book url refers to, the url
+// may be absolute or just the path.
+func ParseBookURL(bookURL string) (uuid.UUID, bool) {
+	u, err := url.Parse(bookURL)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	_, id, ok := strings.Cut(u.Path, BookPathPrefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	bookUUID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return bookUUID, true
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBookURL(t *testing.T) {
+	bookUUID := uuid.MustParse("0b9a5f3e-5d1c-4c55-9f0b-2a6f7c1d8e42")
+
+	for _, bookURL := range []string{
+		BookPath(bookUUID),
+		"https://library.example/api" + BookPath(bookUUID),
+		"https://library.example" + BookPath(bookUUID) + "?download=1",
+	} {
+		parsed, ok := ParseBookURL(bookURL)
+		assert.True(t, ok, bookURL)
+		assert.Equal(t, bookUUID, parsed, bookURL)
+	}
+
+	for _, bookURL := range []string{
+		"",
+		"https://example.com/master-and-margarita.epub",
+		BookPathPrefix + "uploads",
+		"%zz",
+	} {
+		_, ok := ParseBookURL(bookURL)
+		assert.False(t, ok, bookURL)
+	}
+}
@@ -4,22 +4,25 @@ import (
 	"context"
 	"time"
 
+	"github.com/lunn06/library/bookinfo/pkg/events"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/lunn06/library/bookfile/internal/domain"
 )
 
 const (
 	BookBucket     = "bookbucket"
+	RefBucket      = "bookrefs"
+	CoverBucket    = "coverbucket"
+	UploadBucket   = "bookuploads"
+	PartBucket     = "bookparts"
+	EventBucket    = "bookevents"
 	defaultTimeout = time.Minute * 5
 )
 
-func NewJetStream(url string) (jetstream.JetStream, error) {
-	nc, err := nats.Connect(url)
-	if err != nil {
-		return nil, err
-	}
-
-	return jetstream.New(nc, jetstream.WithDefaultTimeout(defaultTimeout))
+func NewJetStream(conn *nats.Conn) (jetstream.JetStream, error) {
+	return jetstream.New(conn, jetstream.WithDefaultTimeout(defaultTimeout))
 }
 
 func NewObjectStore(js jetstream.JetStream) (jetstream.ObjectStore, error) {
@@ -29,3 +32,48 @@ func NewObjectStore(js jetstream.JetStream) (jetstream.ObjectStore, error) {
 		Compression: true,
 	})
 }
+
+func NewCoverStore(js jetstream.JetStream) (jetstream.ObjectStore, error) {
+	return js.CreateOrUpdateObjectStore(context.Background(), jetstream.ObjectStoreConfig{
+		Bucket:  CoverBucket,
+		Storage: jetstream.FileStorage,
+	})
+}
+
+func NewKeyValue(js jetstream.JetStream) (jetstream.KeyValue, error) {
+	return js.CreateOrUpdateKeyValue(context.Background(), jetstream.KeyValueConfig{
+		Bucket:      RefBucket,
+		Storage:     jetstream.FileStorage,
+		Compression: true,
+	})
+}
+
+// NewUploadKeyValue keeps the state of resumable uploads, abandoned ones
+// expire and their parts are swept by the upload service.
+func NewUploadKeyValue(js jetstream.JetStream) (jetstream.KeyValue, error) {
+	return js.CreateOrUpdateKeyValue(context.Background(), jetstream.KeyValueConfig{
+		Bucket:  UploadBucket,
+		Storage: jetstream.FileStorage,
+		TTL:     domain.UploadTTL,
+	})
+}
+
+// NewPartStore keeps the parts for as long as their upload, a TTL of the
+// bucket would count from the part rather than from the last activity of
+// the upload.
+func NewPartStore(js jetstream.JetStream) (jetstream.ObjectStore, error) {
+	return js.CreateOrUpdateObjectStore(context.Background(), jetstream.ObjectStoreConfig{
+		Bucket:  PartBucket,
+		Storage: jetstream.FileStorage,
+	})
+}
+
+// NewEventKeyValue remembers processed bookinfo events for as long as the
+// events stream keeps them.
+func NewEventKeyValue(js jetstream.JetStream) (jetstream.KeyValue, error) {
+	return js.CreateOrUpdateKeyValue(context.Background(), jetstream.KeyValueConfig{
+		Bucket:  EventBucket,
+		Storage: jetstream.FileStorage,
+		TTL:     events.MaxAge,
+	})
+}
@@ -0,0 +1,33 @@
+package js
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(
+		NewJetStream,
+		fx.Annotate(
+			NewObjectStore,
+			fx.ResultTags(`name:"books"`),
+		),
+		fx.Annotate(
+			NewCoverStore,
+			fx.ResultTags(`name:"covers"`),
+		),
+		fx.Annotate(
+			NewPartStore,
+			fx.ResultTags(`name:"parts"`),
+		),
+		fx.Annotate(
+			NewKeyValue,
+			fx.ResultTags(`name:"refs"`),
+		),
+		fx.Annotate(
+			NewUploadKeyValue,
+			fx.ResultTags(`name:"uploads"`),
+		),
+		fx.Annotate(
+			NewEventKeyValue,
+			fx.ResultTags(`name:"events"`),
+		),
+	),
+)
@@ -0,0 +1,11 @@
+package db
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/bookfile/internal/infrastructure/db/js"
+)
+
+var Module = fx.Options(
+	js.Module,
+)
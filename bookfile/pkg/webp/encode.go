@@ -0,0 +1,225 @@
+// Package webp encodes images as lossless WebP (VP8L).
+//
+// The encoder is deliberately simple: the only transform applied is
+// subtract green and pixels are written as literals without backward
+// references, which is plenty for thumbnails.
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+)
+
+const (
+	maxDimension = 1 << 14
+
+	vp8lSignature     = 0x2f
+	transformSubGreen = 2
+
+	numLiteralCodes  = 256
+	numLengthCodes   = 24
+	numDistanceCodes = 40
+	numCodeLengths   = 19
+	maxCodeLength    = 15
+	maxCodeLenLength = 7
+)
+
+// codeLengthOrder is the order in which the code length code lengths are stored.
+var codeLengthOrder = [numCodeLengths]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Encode writes m to w as a lossless WebP image.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > maxDimension || b.Dy() > maxDimension {
+		return errors.New("webp: invalid image size")
+	}
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), m, b.Min, draw.Src)
+
+	data := encodeVP8L(rgba)
+
+	var hdr [20]byte
+	copy(hdr[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(12+len(data)+len(data)%2))
+	copy(hdr[8:16], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(data)))
+
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+func encodeVP8L(m *image.NRGBA) []byte {
+	width, height := m.Rect.Dx(), m.Rect.Dy()
+
+	var (
+		pixels = make([][4]byte, 0, width*height)
+		alpha  bool
+	)
+	for y := 0; y < height; y++ {
+		row := m.Pix[y*m.Stride : y*m.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			r, g, b, a := row[x], row[x+1], row[x+2], row[x+3]
+			// green, red, blue, alpha is the order the prefix codes go in
+			pixels = append(pixels, [4]byte{g, r - g, b - g, a})
+			alpha = alpha || a != 0xff
+		}
+	}
+
+	w := &bitWriter{}
+	w.writeBits(vp8lSignature, 8)
+	w.writeBits(uint32(width-1), 14)
+	w.writeBits(uint32(height-1), 14)
+	w.writeBits(boolBit(alpha), 1)
+	w.writeBits(0, 3)
+
+	w.writeBits(1, 1)
+	w.writeBits(transformSubGreen, 2)
+	w.writeBits(0, 1)
+
+	// no color cache, a single set of prefix codes
+	w.writeBits(0, 1)
+	w.writeBits(0, 1)
+
+	var counts [4][numLiteralCodes]uint32
+	for _, p := range pixels {
+		for i, v := range p {
+			counts[i][v]++
+		}
+	}
+
+	var codes [4][]code
+	for i := range counts {
+		alphabet := numLiteralCodes
+		if i == 0 {
+			alphabet += numLengthCodes
+		}
+		codes[i] = writePrefixCode(w, counts[i][:], alphabet)
+	}
+	// there are no backward references, so no distances
+	writeSimpleCode(w, []int{0})
+
+	for _, p := range pixels {
+		for i, v := range p {
+			w.writeCode(codes[i][v])
+		}
+	}
+
+	return w.bytes()
+}
+
+// writePrefixCode stores the code for the counted symbols and returns it.
+func writePrefixCode(w *bitWriter, counts []uint32, alphabet int) []code {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) <= 2 {
+		writeSimpleCode(w, used)
+		codes := make([]code, len(counts))
+		if len(used) == 2 {
+			codes[used[0]] = code{bits: 0, length: 1}
+			codes[used[1]] = code{bits: 1, length: 1}
+		}
+		return codes
+	}
+
+	lengths := codeLengths(counts, maxCodeLength)
+	all := make([]uint8, alphabet)
+	copy(all, lengths)
+	writeCodeLengths(w, all)
+
+	return canonicalCodes(lengths)
+}
+
+// writeSimpleCode stores a code of one or two symbols below 256.
+func writeSimpleCode(w *bitWriter, symbols []int) {
+	if len(symbols) == 0 {
+		symbols = []int{0}
+	}
+
+	w.writeBits(1, 1)
+	w.writeBits(uint32(len(symbols)-1), 1)
+	if symbols[0] < 2 {
+		w.writeBits(0, 1)
+		w.writeBits(uint32(symbols[0]), 1)
+	} else {
+		w.writeBits(1, 1)
+		w.writeBits(uint32(symbols[0]), 8)
+	}
+	if len(symbols) == 2 {
+		w.writeBits(uint32(symbols[1]), 8)
+	}
+}
+
+// writeCodeLengths stores a normal code, the lengths are written as
+// literals of the code length code.
+func writeCodeLengths(w *bitWriter, lengths []uint8) {
+	var counts [numCodeLengths]uint32
+	for _, l := range lengths {
+		counts[l]++
+	}
+
+	clLengths := codeLengths(counts[:], maxCodeLenLength)
+	if used := nonZero(clLengths); used < 2 {
+		// a lone length still needs a complete code, pair it with a dummy
+		only := 0
+		for l, c := range counts {
+			if c > 0 {
+				only = l
+			}
+		}
+		clear(clLengths)
+		clLengths[only] = 1
+		clLengths[(only+1)%numCodeLengths] = 1
+	}
+	clCodes := canonicalCodes(clLengths)
+
+	numCodes := numCodeLengths
+	for numCodes > 4 && clLengths[codeLengthOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	w.writeBits(0, 1)
+	w.writeBits(uint32(numCodes-4), 4)
+	for _, l := range codeLengthOrder[:numCodes] {
+		w.writeBits(uint32(clLengths[l]), 3)
+	}
+
+	// every symbol is written, no max_symbol
+	w.writeBits(0, 1)
+	for _, l := range lengths {
+		w.writeCode(clCodes[l])
+	}
+}
+
+func nonZero(lengths []uint8) int {
+	var n int
+	for _, l := range lengths {
+		if l > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for name, m := range map[string]image.Image{
+		"gradient":    gradient(97, 61, 0xff),
+		"translucent": gradient(16, 9, 0x80),
+		"single":      solid(5, 7, color.NRGBA{R: 10, G: 200, B: 30, A: 0xff}),
+		"pixel":       solid(1, 1, color.NRGBA{R: 1, G: 2, B: 3, A: 4}),
+		"two colors":  checkerboard(8, 8),
+		"noise":       noise(256, 256),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, m))
+
+			decoded, err := webp.Decode(&buf)
+			require.NoError(t, err)
+
+			require.Equal(t, m.Bounds().Size(), decoded.Bounds().Size())
+			for y := 0; y < m.Bounds().Dy(); y++ {
+				for x := 0; x < m.Bounds().Dx(); x++ {
+					want := color.NRGBAModel.Convert(m.At(x, y))
+					got := color.NRGBAModel.Convert(decoded.At(x, y))
+					if !assert.Equal(t, want, got, "pixel %d,%d", x, y) {
+						return
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeInvalidSize(t *testing.T) {
+	assert.Error(t, Encode(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, 0, 10))))
+}
+
+func gradient(w, h int, a uint8) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8(x ^ y), A: a})
+		}
+	}
+	return m
+}
+
+func solid(w, h int, c color.NRGBA) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetNRGBA(x, y, c)
+		}
+	}
+	return m
+}
+
+func checkerboard(w, h int) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 1, G: 1, B: 1, A: 0xff}
+			if (x+y)%2 == 0 {
+				c = color.NRGBA{R: 250, G: 40, B: 7, A: 0xff}
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+	return m
+}
+
+func noise(w, h int) image.Image {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(rnd.UintN(256))
+	}
+	return m
+}
@@ -0,0 +1,120 @@
+package webp
+
+import (
+	"container/heap"
+	"math/bits"
+	"sort"
+)
+
+// code is a prefix code ready to be written, its bits are reversed
+// since the decoder reads them one by one from the least significant.
+type code struct {
+	bits   uint32
+	length uint
+}
+
+// codeLengths builds Huffman code lengths not longer than maxLen.
+// Rare symbols get their counts raised until the tree fits.
+func codeLengths(counts []uint32, maxLen int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	for floor := uint32(1); ; floor *= 2 {
+		if buildLengths(counts, floor, lengths) <= maxLen {
+			return lengths
+		}
+	}
+}
+
+type node struct {
+	count  uint64
+	symbol int
+	left   *node
+	right  *node
+}
+
+type nodeHeap []*node
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x any)   { *h = append(*h, x.(*node)) }
+func (h *nodeHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+func buildLengths(counts []uint32, floor uint32, lengths []uint8) int {
+	clear(lengths)
+
+	h := make(nodeHeap, 0, len(counts))
+	for symbol, count := range counts {
+		if count > 0 {
+			h = append(h, &node{count: uint64(max(count, floor)), symbol: symbol})
+		}
+	}
+	if len(h) < 2 {
+		// a single symbol needs no bits at all
+		return 0
+	}
+
+	heap.Init(&h)
+	for h.Len() > 1 {
+		a, b := heap.Pop(&h).(*node), heap.Pop(&h).(*node)
+		heap.Push(&h, &node{count: a.count + b.count, symbol: min(a.symbol, b.symbol), left: a, right: b})
+	}
+
+	var (
+		maxLen int
+		walk   func(n *node, depth int)
+	)
+	walk = func(n *node, depth int) {
+		if n.left == nil {
+			lengths[n.symbol] = uint8(depth)
+			maxLen = max(maxLen, depth)
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(h[0], 0)
+
+	return maxLen
+}
+
+// canonicalCodes assigns canonical codes to the lengths.
+func canonicalCodes(lengths []uint8) []code {
+	symbols := make([]int, 0, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return lengths[symbols[i]] < lengths[symbols[j]]
+	})
+
+	codes := make([]code, len(lengths))
+	var (
+		next    uint32
+		prevLen uint8
+	)
+	for _, symbol := range symbols {
+		length := lengths[symbol]
+		next <<= length - prevLen
+		prevLen = length
+
+		codes[symbol] = code{
+			bits:   bits.Reverse32(next) >> (32 - uint(length)),
+			length: uint(length),
+		}
+		next++
+	}
+
+	return codes
+}
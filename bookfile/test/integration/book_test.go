@@ -3,27 +3,59 @@
 package integration
 
 import (
+	"archive/zip"
 	"bytes"
-	"github.com/google/uuid"
+	"io"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	servicerrors "github.com/lunn06/library/bookfile/internal/app/service/errors"
-	"github.com/lunn06/library/bookfile/internal/domain"
-
-	_ "embed"
 )
 
-const testFileName = "test1.txt"
+const testFileName = "test1.epub"
+
+// testBook builds a minimal EPUB, books with distinct titles are stored
+// separately as their contents differ.
+func testBook(tb testing.TB, title string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(tb, err)
+	_, err = w.Write([]byte("application/epub+zip"))
+	require.NoError(tb, err)
+	w, err = zw.Create("META-INF/container.xml")
+	require.NoError(tb, err)
+	_, err = w.Write([]byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`))
+	require.NoError(tb, err)
+	w, err = zw.Create("content.opf")
+	require.NoError(tb, err)
+	_, err = w.Write([]byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>` + title + `</dc:title></metadata>
+</package>`))
+	require.NoError(tb, err)
+	require.NoError(tb, zw.Close())
+
+	return buf.Bytes()
+}
 
-//go:embed test.dat
-var testFile []byte
+func create(tb testing.TB, data []byte) uuid.UUID {
+	book, err := boofileClient.Create(tb.Context(), testFileName, io.NopCloser(bytes.NewReader(data)))
+	require.NoError(tb, err)
+
+	return book.UUID
+}
 
 func BenchmarkBookFileCreate(b *testing.B) {
+	data := testBook(b, b.Name())
 	for b.Loop() {
-		_, err := boofileClient.Create(b.Context(), testFileName, bytes.NewBuffer(testFile))
+		_, err := boofileClient.Create(b.Context(), testFileName, io.NopCloser(bytes.NewReader(data)))
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -35,68 +67,74 @@ func BenchmarkBookFileCreate(b *testing.B) {
 func BenchmarkBookFileGetMultipleFile(b *testing.B) {
 	uuids := make([]uuid.UUID, b.N)
 	for i := 0; i < b.N; i++ {
-		bookUUID, err := boofileClient.Create(b.Context(), testFileName, bytes.NewBuffer(testFile))
-		if err != nil {
-			b.Fatal(err)
-		}
-
-		uuids[i] = bookUUID
+		uuids[i] = create(b, testBook(b, uuid.NewString()))
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := boofileClient.Get(b.Context(), uuids[i])
+		book, err := boofileClient.Get(b.Context(), uuids[i])
 		if err != nil {
 			b.Fatal(err)
 		}
+		_, _ = io.Copy(io.Discard, &book)
+		_ = book.Close()
 	}
 
 	b.ReportAllocs()
 }
 
 func BenchmarkBookFileGetOneFile(b *testing.B) {
-	bookUUID, err := boofileClient.Create(b.Context(), testFileName, bytes.NewBuffer(testFile))
-	if err != nil {
-		b.Fatal(err)
-	}
+	bookUUID := create(b, testBook(b, b.Name()))
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err = boofileClient.Get(b.Context(), bookUUID)
+		book, err := boofileClient.Get(b.Context(), bookUUID)
 		if err != nil {
 			b.Fatal(err)
 		}
+		_, _ = io.Copy(io.Discard, &book)
+		_ = book.Close()
 	}
 
 	b.ReportAllocs()
 }
 
 func TestBookFileCreate(t *testing.T) {
+	data := testBook(t, t.Name())
+
 	// Put bookfile
-	bookUUID, err := boofileClient.Create(t.Context(), testFileName, bytes.NewBuffer(testFile))
-	require.NoError(t, err)
+	bookUUID := create(t, data)
 	//////////////
 
 	// Check putted bookfile
 	book, err := boofileClient.Get(t.Context(), bookUUID)
 	require.NoError(t, err)
+	defer book.Close()
 
+	content, err := io.ReadAll(&book)
+	require.NoError(t, err)
 	assert.Equal(t, bookUUID, book.UUID)
 	assert.Equal(t, testFileName, book.FileName)
-	assert.Equal(t, testFile, book.Buffer.Bytes())
+	assert.Equal(t, t.Name(), book.Metadata.Title)
+	assert.Equal(t, data, content)
 }
 
 func TestBookFileDelete(t *testing.T) {
+	data := testBook(t, t.Name())
+
 	// Put bookfile
-	bookUUID, err := boofileClient.Create(t.Context(), testFileName, bytes.NewBuffer(testFile))
-	require.NoError(t, err)
+	bookUUID := create(t, data)
 	//////////////
 
 	// Check putted bookfile
 	book, err := boofileClient.Get(t.Context(), bookUUID)
 	require.NoError(t, err)
 
+	content, err := io.ReadAll(&book)
+	require.NoError(t, err)
+	require.NoError(t, book.Close())
 	assert.Equal(t, testFileName, book.FileName)
-	assert.Equal(t, testFile, book.Buffer.Bytes())
+	assert.Equal(t, data, content)
 	//////////////
 
 	// Delete bookfile
@@ -105,8 +143,39 @@ func TestBookFileDelete(t *testing.T) {
 	//////////////
 
 	// Check deleted bookfile not found
-	book, err = boofileClient.Get(t.Context(), bookUUID)
+	_, err = boofileClient.Get(t.Context(), bookUUID)
+	assert.True(t, servicerrors.IsErrResourceNotFound(err))
+}
+
+func TestBookFileDeleteShared(t *testing.T) {
+	data := testBook(t, t.Name())
+
+	// Put the same bookfile twice
+	first := create(t, data)
+	second := create(t, data)
+	require.NotEqual(t, first, second)
+	//////////////
+
+	// Delete the first one twice
+	require.NoError(t, boofileClient.Delete(t.Context(), first))
+	err := boofileClient.Delete(t.Context(), first)
+	assert.True(t, servicerrors.IsErrResourceNotFound(err))
+	//////////////
+
+	// Check the second one is left
+	book, err := boofileClient.Get(t.Context(), second)
+	require.NoError(t, err)
+	content, err := io.ReadAll(&book)
+	require.NoError(t, err)
+	require.NoError(t, book.Close())
+	assert.Equal(t, second, book.UUID)
+	assert.Equal(t, data, content)
+	//////////////
 
-	assert.Equal(t, domain.Book{}, book)
-	assert.Error(t, servicerrors.ErrResourceNotFound{}, err)
+	// Delete the second one
+	require.NoError(t, boofileClient.Delete(t.Context(), second))
+	_, err = boofileClient.Get(t.Context(), second)
+	assert.True(t, servicerrors.IsErrResourceNotFound(err))
+	err = boofileClient.Delete(t.Context(), second)
+	assert.True(t, servicerrors.IsErrResourceNotFound(err))
 }
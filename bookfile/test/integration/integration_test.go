@@ -6,11 +6,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"testing"
+	"time"
+
 	"github.com/testcontainers/testcontainers-go"
 	natscontainer "github.com/testcontainers/testcontainers-go/modules/nats"
-	"testing"
+	"go.uber.org/fx"
 
 	"github.com/lunn06/library/bookfile/client"
+	"github.com/lunn06/library/bookfile/internal/api"
+	natsapi "github.com/lunn06/library/bookfile/internal/api/nats"
+	"github.com/lunn06/library/bookfile/internal/app"
+	"github.com/lunn06/library/bookfile/internal/app/service"
+	"github.com/lunn06/library/bookfile/internal/infrastructure"
 )
 
 var boofileClient *client.Client
@@ -21,10 +29,25 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		panic(err)
 	}
+	natsURL := fmt.Sprintf("nats://%s", natsEndpoint)
 
-	var cfg client.Config
-	cfg.URL = fmt.Sprintf("nats://%s", natsEndpoint)
+	server := fx.New(
+		app.Module,
+		api.Module,
+		infrastructure.Module,
+		fx.Supply(
+			natsapi.Config{URL: natsURL},
+			service.ReconcileConfig{Interval: time.Hour, GracePeriod: time.Hour, BatchSize: 100},
+		),
+		fx.NopLogger,
+	)
+	if err = server.Start(context.Background()); err != nil {
+		panic(err)
+	}
 
+	var cfg client.Config
+	cfg.URL = natsURL
+	cfg.Timeout = 10 * time.Second
 	boofileClient, err = client.New(cfg)
 	if err != nil {
 		panic(err)
@@ -34,6 +57,7 @@ func TestMain(m *testing.M) {
 
 	err = errors.Join(
 		boofileClient.Close(),
+		server.Stop(context.Background()),
 		natsC.Terminate(context.Background()),
 	)
 	if err != nil {
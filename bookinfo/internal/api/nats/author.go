@@ -11,6 +11,7 @@ import (
 	authorpb "github.com/lunn06/library/bookinfo/internal/api/proto/author"
 	authorservice "github.com/lunn06/library/bookinfo/internal/app/service/author"
 	"github.com/lunn06/library/bookinfo/internal/app/service/errors"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
 )
 
@@ -206,10 +207,14 @@ func (ac *AuthorConsumer) Update(msg *nats.Msg) {
 		BooksIDs:    fromTo[int64, int](req.BooksIds),
 	}
 
-	err := ac.service.Update(context.Background(), update)
+	err := ac.service.Update(authz.MsgContext(msg), update)
 	if errors.IsErrResourceNotFound(err) {
 		slog.Error("Not found on author update", "err", err)
 		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
 	} else if err != nil {
 		slog.Error("Error on author update", "err", err)
 		statusCode = http.StatusInternalServerError
@@ -244,8 +249,14 @@ func (ac *AuthorConsumer) Delete(msg *nats.Msg) {
 
 	statusCode := http.StatusOK
 
-	err := ac.service.Delete(context.Background(), int(req.AuthorId))
-	if err != nil {
+	err := ac.service.Delete(authz.MsgContext(msg), int(req.AuthorId))
+	if errors.IsErrResourceNotFound(err) {
+		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
+	} else if err != nil {
 		slog.Error("Error on author delete", "err", err)
 		statusCode = http.StatusInternalServerError
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"google.golang.org/protobuf/proto"
@@ -11,6 +12,7 @@ import (
 	bookpb "github.com/lunn06/library/bookinfo/internal/api/proto/book"
 	bookservice "github.com/lunn06/library/bookinfo/internal/app/service/book"
 	"github.com/lunn06/library/bookinfo/internal/app/service/errors"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
 )
 
@@ -20,11 +22,13 @@ func RegisterBookConsumer(conn *nats.Conn, cons *BookConsumer) error {
 		middleware.Logger(slog.Default()),
 	}
 	for subj, handler := range map[string]nats.MsgHandler{
-		"book.search": cons.Search,
-		"book.get":    cons.Get,
-		"book.put":    cons.Put,
-		"book.update": cons.Update,
-		"book.delete": cons.Delete,
+		"book.search":         cons.Search,
+		"book.get":            cons.Get,
+		"book.put":            cons.Put,
+		"book.update":         cons.Update,
+		"book.delete":         cons.Delete,
+		"book.exists":         cons.Exists,
+		"book.getAllByUserId": cons.GetAllByUserID,
 	} {
 		_, err := conn.Subscribe(subj, middleware.With(handler, mws...))
 		if err != nil {
@@ -223,7 +227,6 @@ func (bc *BookConsumer) Update(msg *nats.Msg) {
 
 	update := bookservice.UpdateRequest{
 		ID:          int(req.Id),
-		UserID:      int(req.UserId),
 		Title:       req.Title,
 		Description: req.Description,
 		BookURL:     req.BookUrl,
@@ -232,10 +235,14 @@ func (bc *BookConsumer) Update(msg *nats.Msg) {
 		GenresIDs:   fromTo[int64, int](req.GenresIds),
 	}
 
-	err := bc.service.Update(context.Background(), update)
+	err := bc.service.Update(authz.MsgContext(msg), update)
 	if errors.IsErrResourceNotFound(err) {
 		slog.Error("Not found on gateway update", "err", err)
 		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
 	} else if err != nil {
 		slog.Error("Error on gateway update", "err", err)
 		statusCode = http.StatusInternalServerError
@@ -270,8 +277,14 @@ func (bc *BookConsumer) Delete(msg *nats.Msg) {
 
 	statusCode := http.StatusOK
 
-	err := bc.service.Delete(context.Background(), int(req.BookId))
-	if err != nil {
+	err := bc.service.Delete(authz.MsgContext(msg), int(req.BookId))
+	if errors.IsErrResourceNotFound(err) {
+		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
+	} else if err != nil {
 		slog.Error("Error on gateway delete", "err", err)
 		statusCode = http.StatusInternalServerError
 	}
@@ -287,3 +300,104 @@ func (bc *BookConsumer) Delete(msg *nats.Msg) {
 
 	_ = msg.Respond(out)
 }
+
+// Exists reports which of the requested books are gone, services holding
+// data of books use it to find what is left after deletions.
+func (bc *BookConsumer) Exists(msg *nats.Msg) {
+	var req bookpb.ExistsRequest
+	if err := proto.Unmarshal(msg.Data, &req); err != nil {
+		out, err := proto.Marshal(&bookpb.ExistsResponse{
+			StatusCode: http.StatusUnprocessableEntity,
+		})
+		if err != nil {
+			slog.Error("Error on marshal", "err", err)
+			_ = msg.Nak()
+			return
+		}
+		_ = msg.Respond(out)
+		return
+	}
+
+	statusCode := http.StatusOK
+
+	missingIDs, missingURLs, err := bc.service.Missing(
+		context.Background(),
+		fromTo[int64, int](req.BookIds),
+		req.BookUrls,
+	)
+	if err != nil {
+		slog.Error("Error on book exists", "err", err)
+		statusCode = http.StatusInternalServerError
+	}
+
+	out, err := proto.Marshal(&bookpb.ExistsResponse{
+		MissingBookIds:  fromTo[int, int64](missingIDs),
+		MissingBookUrls: missingURLs,
+		StatusCode:      int32(statusCode),
+	})
+	if err != nil {
+		slog.Error("Error on marshal", "err", err)
+		_ = msg.Nak()
+		return
+	}
+
+	_ = msg.Respond(out)
+}
+
+// GetAllByUserID lists the books added by the user, newest first.
+func (bc *BookConsumer) GetAllByUserID(msg *nats.Msg) {
+	var req bookpb.GetByUserIdRequest
+	if err := proto.Unmarshal(msg.Data, &req); err != nil {
+		out, err := proto.Marshal(&bookpb.GetByUserIdResponse{
+			StatusCode: http.StatusUnprocessableEntity,
+		})
+		if err != nil {
+			slog.Error("Error on marshal", "err", err)
+			_ = msg.Nak()
+			return
+		}
+		_ = msg.Respond(out)
+		return
+	}
+
+	statusCode := http.StatusOK
+
+	list := bookservice.UserBooksRequest{
+		UserID: int(req.UserId),
+		Limit:  int(req.Limit),
+	}
+	if req.CreatedBefore != nil {
+		list.Before = time.Unix(*req.CreatedBefore, 0)
+	}
+
+	books, err := bc.service.GetAllByUserID(context.Background(), list)
+	if err != nil {
+		slog.Error("Error on books of user get", "err", err)
+		statusCode = http.StatusInternalServerError
+	}
+
+	items := make([]*bookpb.UserBookItem, len(books))
+	for i, book := range books {
+		items[i] = &bookpb.UserBookItem{
+			Id:          int64(book.ID),
+			UserId:      int64(book.UserID),
+			Title:       book.Title,
+			Description: book.Description,
+			BookUrl:     book.BookURL,
+			CoverUrl:    book.CoverURL,
+			CreatedAt:   book.CreatedAt.Unix(),
+		}
+	}
+
+	out, err := proto.Marshal(&bookpb.GetByUserIdResponse{
+		Items:      items,
+		StatusCode: int32(statusCode),
+	})
+	if err != nil {
+		slog.Error("Error on marshal", "err", err)
+		_ = msg.Nak()
+		return
+	}
+
+	_ = msg.Respond(out)
+}
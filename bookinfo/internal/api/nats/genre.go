@@ -11,6 +11,7 @@ import (
 	genrepb "github.com/lunn06/library/bookinfo/internal/api/proto/genre"
 	"github.com/lunn06/library/bookinfo/internal/app/service/errors"
 	genreservice "github.com/lunn06/library/bookinfo/internal/app/service/genre"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
 )
 
@@ -205,10 +206,14 @@ func (gc *GenreConsumer) Update(msg *nats.Msg) {
 		BooksIDs:    fromTo[int64, int](req.BooksIds),
 	}
 
-	err := gc.service.Update(context.Background(), update)
+	err := gc.service.Update(authz.MsgContext(msg), update)
 	if errors.IsErrResourceNotFound(err) {
 		slog.Error("Not found on genre update", "err", err)
 		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
 	} else if err != nil {
 		slog.Error("Error on genre update", "err", err)
 		statusCode = http.StatusInternalServerError
@@ -243,8 +248,14 @@ func (gc *GenreConsumer) Delete(msg *nats.Msg) {
 
 	statusCode := http.StatusOK
 
-	err := gc.service.Delete(context.Background(), int(req.GenreId))
-	if err != nil {
+	err := gc.service.Delete(authz.MsgContext(msg), int(req.GenreId))
+	if errors.IsErrResourceNotFound(err) {
+		statusCode = http.StatusNotFound
+	} else if errors.IsErrUnauthenticated(err) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.IsErrForbidden(err) {
+		statusCode = http.StatusForbidden
+	} else if err != nil {
 		statusCode = http.StatusInternalServerError
 	}
 
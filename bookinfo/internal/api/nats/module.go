@@ -1,10 +1,19 @@
 package nats
 
-import "go.uber.org/fx"
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/bookinfo/internal/app/service/outbox"
+)
 
 var Module = fx.Options(
 	fx.Provide(
 		NewConnection,
+		NewJetStream,
+		fx.Annotate(
+			NewEventPublisher,
+			fx.As(new(outbox.Publisher)),
+		),
 		NewAuthorConsumer,
 		NewBookConsumer,
 		NewGenreConsumer,
@@ -0,0 +1,45 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/lunn06/library/bookinfo/internal/domain"
+	"github.com/lunn06/library/bookinfo/pkg/events"
+)
+
+func NewJetStream(conn *nats.Conn) (jetstream.JetStream, error) {
+	return jetstream.New(conn)
+}
+
+func NewEventPublisher(js jetstream.JetStream) (*EventPublisher, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := events.EnsureStream(ctx, js); err != nil {
+		return nil, err
+	}
+
+	return &EventPublisher{js: js}, nil
+}
+
+// EventPublisher publishes outbox events to the events stream, the id of
+// the outbox row is the message id, so the stream drops republished events.
+type EventPublisher struct {
+	js jetstream.JetStream
+}
+
+func (ep *EventPublisher) Publish(ctx context.Context, event domain.Event) error {
+	_, err := ep.js.Publish(
+		ctx,
+		event.Subject,
+		event.Payload,
+		jetstream.WithMsgID(fmt.Sprintf("bookinfo.outbox.%d", event.ID)),
+	)
+
+	return err
+}
@@ -2,12 +2,17 @@ package author
 
 import (
 	"context"
+	"time"
 
+	eventspb "github.com/lunn06/library/bookinfo/internal/api/proto/events"
 	"github.com/lunn06/library/bookinfo/internal/app/repository/errors"
+	"github.com/lunn06/library/bookinfo/internal/app/repository/outbox"
 	"github.com/lunn06/library/bookinfo/internal/domain"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/author"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/converter"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/postgres"
+	"github.com/lunn06/library/bookinfo/pkg/events"
 )
 
 type Repo interface {
@@ -21,11 +26,12 @@ type Repo interface {
 var _ Repo = (*EntRepo)(nil)
 
 func NewEntRepo(client *ent.Client) *EntRepo {
-	return &EntRepo{AuthorClient: client.Author}
+	return &EntRepo{AuthorClient: client.Author, client: client}
 }
 
 type EntRepo struct {
 	*ent.AuthorClient
+	client *ent.Client
 }
 
 func (ear *EntRepo) Get(ctx context.Context, id int) (domain.Author, error) {
@@ -68,12 +74,28 @@ func (ear *EntRepo) SearchByNameWithLimitOffset(
 }
 
 func (ear *EntRepo) Put(ctx context.Context, author domain.Author, booksIDs ...int) (domain.Author, error) {
-	entAuthor, err := ear.
-		Create().
-		SetName(author.Name).
-		SetDescription(author.Description).
-		AddBookIDs(booksIDs...).
-		Save(ctx)
+	var entAuthor *ent.Author
+	err := postgres.WithTx(ctx, ear.client, func(tx *ent.Tx) error {
+		created, err := tx.Author.
+			Create().
+			SetName(author.Name).
+			SetDescription(author.Description).
+			AddBookIDs(booksIDs...).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		entAuthor, err = queryWithBooks(ctx, tx, created.ID)
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.AuthorCreated, &eventspb.AuthorCreated{
+			Author:    outbox.AuthorToProto(entAuthor),
+			CreatedAt: time.Now().Unix(),
+		})
+	})
 	if err != nil {
 		return domain.Author{}, err
 	}
@@ -82,23 +104,55 @@ func (ear *EntRepo) Put(ctx context.Context, author domain.Author, booksIDs ...i
 }
 
 func (ear *EntRepo) Update(ctx context.Context, author domain.Author, booksIDs ...int) error {
-	err := ear.AuthorClient.
-		UpdateOneID(author.ID).
-		SetName(author.Name).
-		SetDescription(author.Description).
-		AddBookIDs(booksIDs...).
-		Exec(ctx)
-	if ent.IsNotFound(err) {
-		return errors.ErrNotFound{Inner: err}
-	}
-
-	return err
+	return postgres.WithTx(ctx, ear.client, func(tx *ent.Tx) error {
+		err := tx.Author.
+			UpdateOneID(author.ID).
+			SetName(author.Name).
+			SetDescription(author.Description).
+			AddBookIDs(booksIDs...).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		entAuthor, err := queryWithBooks(ctx, tx, author.ID)
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.AuthorUpdated, &eventspb.AuthorUpdated{
+			Author:    outbox.AuthorToProto(entAuthor),
+			UpdatedAt: time.Now().Unix(),
+		})
+	})
 }
 
 func (ear *EntRepo) Delete(ctx context.Context, id int) error {
-	err := ear.AuthorClient.
-		DeleteOneID(id).
-		Exec(ctx)
+	return postgres.WithTx(ctx, ear.client, func(tx *ent.Tx) error {
+		err := tx.Author.
+			DeleteOneID(id).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.AuthorDeleted, &eventspb.AuthorDeleted{
+			AuthorId:  int64(id),
+			DeletedAt: time.Now().Unix(),
+		})
+	})
+}
 
-	return err
+func queryWithBooks(ctx context.Context, tx *ent.Tx, id int) (*ent.Author, error) {
+	return tx.Author.
+		Query().
+		Where(author.ID(id)).
+		WithBooks().
+		Only(ctx)
 }
@@ -2,30 +2,43 @@ package book
 
 import (
 	"context"
+	"slices"
+	"time"
 
+	eventspb "github.com/lunn06/library/bookinfo/internal/api/proto/events"
 	"github.com/lunn06/library/bookinfo/internal/app/repository/errors"
+	"github.com/lunn06/library/bookinfo/internal/app/repository/outbox"
 	"github.com/lunn06/library/bookinfo/internal/domain"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/book"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/converter"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/predicate"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/postgres"
+	"github.com/lunn06/library/bookinfo/pkg/events"
 )
 
 type Repo interface {
 	Get(ctx context.Context, id int) (domain.Book, error)
 	SearchByTitleWithLimitOffset(ctx context.Context, title string, limit int, offset int) ([]domain.Book, error)
+	// GetAllByUserID returns up to limit books added by the user before the
+	// given time, newest first. A zero before means now.
+	GetAllByUserID(ctx context.Context, userID int, before time.Time, limit int) ([]domain.Book, error)
 	Put(ctx context.Context, book domain.Book, authorsIDs []int, genresIDs []int) (domain.Book, error)
 	Update(ctx context.Context, book domain.Book, authorsIDs []int, genresIDs []int) error
 	Delete(ctx context.Context, id int) error
+	MissingIDs(ctx context.Context, ids []int) ([]int, error)
+	MissingBookURLs(ctx context.Context, paths []string) ([]string, error)
 }
 
 var _ Repo = (*EntRepo)(nil)
 
 func NewEntRepo(client *ent.Client) *EntRepo {
-	return &EntRepo{BookClient: client.Book}
+	return &EntRepo{BookClient: client.Book, client: client}
 }
 
 type EntRepo struct {
 	*ent.BookClient
+	client *ent.Client
 }
 
 // TODO: add errors handling
@@ -73,22 +86,68 @@ func (ebr *EntRepo) SearchByTitleWithLimitOffset(
 	return converter.BooksToDomain(entBooks), nil
 }
 
+func (ebr *EntRepo) GetAllByUserID(
+	ctx context.Context,
+	userID int,
+	before time.Time,
+	limit int,
+) ([]domain.Book, error) {
+	predicates := []predicate.Book{book.UserID(userID)}
+	if !before.IsZero() {
+		predicates = append(predicates, book.CreatedAtLT(before))
+	}
+
+	entBooks, err := ebr.
+		Query().
+		Where(predicates...).
+		Order(ent.Desc(book.FieldCreatedAt, book.FieldID)).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	books := converter.BooksToDomain(entBooks)
+	for i, entBook := range entBooks {
+		books[i].CreatedAt = entBook.CreatedAt
+	}
+
+	return books, nil
+}
+
 func (ebr *EntRepo) Put(
 	ctx context.Context,
 	book domain.Book,
 	authorsIDs []int,
 	genresIDs []int,
 ) (domain.Book, error) {
-	entBook, err := ebr.
-		Create().
-		SetTitle(book.Title).
-		SetDescription(book.Description).
-		SetUserID(book.UserID).
-		SetBookURL(book.BookURL).
-		SetNillableCoverURL(book.CoverURL).
-		AddAuthorIDs(authorsIDs...).
-		AddGenreIDs(genresIDs...).
-		Save(ctx)
+	var entBook *ent.Book
+	err := postgres.WithTx(ctx, ebr.client, func(tx *ent.Tx) error {
+		created, err := tx.Book.
+			Create().
+			SetTitle(book.Title).
+			SetDescription(book.Description).
+			SetUserID(book.UserID).
+			SetBookURL(book.BookURL).
+			SetBookFile(domain.BookFile(book.BookURL)).
+			SetNillableCoverURL(book.CoverURL).
+			AddAuthorIDs(authorsIDs...).
+			AddGenreIDs(genresIDs...).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		entBook, err = queryWithEdges(ctx, tx, created.ID)
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.BookCreated, &eventspb.BookCreated{
+			Book:      outbox.BookToProto(entBook),
+			CreatedAt: time.Now().Unix(),
+		})
+	})
 	if err != nil {
 		return domain.Book{}, err
 	}
@@ -102,27 +161,111 @@ func (ebr *EntRepo) Update(
 	authorsIDs []int,
 	genresIDs []int,
 ) error {
-	err := ebr.
-		UpdateOneID(book.ID).
-		SetUserID(book.UserID).
-		SetTitle(book.Title).
-		SetDescription(book.Description).
-		SetBookURL(book.BookURL).
-		SetNillableCoverURL(book.CoverURL).
-		AddAuthorIDs(authorsIDs...).
-		AddGenreIDs(genresIDs...).
-		Exec(ctx)
-	if ent.IsNotFound(err) {
-		return errors.ErrNotFound{Inner: err}
-	}
+	return postgres.WithTx(ctx, ebr.client, func(tx *ent.Tx) error {
+		err := tx.Book.
+			UpdateOneID(book.ID).
+			SetUserID(book.UserID).
+			SetTitle(book.Title).
+			SetDescription(book.Description).
+			SetBookURL(book.BookURL).
+			SetBookFile(domain.BookFile(book.BookURL)).
+			SetNillableCoverURL(book.CoverURL).
+			AddAuthorIDs(authorsIDs...).
+			AddGenreIDs(genresIDs...).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		entBook, err := queryWithEdges(ctx, tx, book.ID)
+		if err != nil {
+			return err
+		}
 
-	return err
+		return outbox.Enqueue(ctx, tx, events.BookUpdated, &eventspb.BookUpdated{
+			Book:      outbox.BookToProto(entBook),
+			UpdatedAt: time.Now().Unix(),
+		})
+	})
 }
 
+// Delete removes the book and saves the book.deleted event in the same
+// transaction, so that reviews and files of the book are removed as well.
 func (ebr *EntRepo) Delete(ctx context.Context, id int) error {
-	err := ebr.
-		DeleteOneID(id).
-		Exec(ctx)
+	return postgres.WithTx(ctx, ebr.client, func(tx *ent.Tx) error {
+		entBook, err := tx.Book.Get(ctx, id)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Book.DeleteOneID(id).Exec(ctx); err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.BookDeleted, &eventspb.BookDeleted{
+			BookId:    int64(entBook.ID),
+			UserId:    int64(entBook.UserID),
+			BookUrl:   entBook.BookURL,
+			DeletedAt: time.Now().Unix(),
+		})
+	})
+}
 
-	return err
+func (ebr *EntRepo) MissingIDs(ctx context.Context, ids []int) ([]int, error) {
+	found, err := ebr.
+		Query().
+		Where(book.IDIn(ids...)).
+		IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if !slices.Contains(found, id) {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing, nil
+}
+
+// MissingBookURLs returns the book file paths no book url refers to.
+func (ebr *EntRepo) MissingBookURLs(ctx context.Context, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	found, err := ebr.
+		Query().
+		Where(book.BookFileIn(paths...)).
+		Select(book.FieldBookFile).
+		Strings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, path := range paths {
+		if !slices.Contains(found, path) {
+			missing = append(missing, path)
+		}
+	}
+
+	return missing, nil
+}
+
+func queryWithEdges(ctx context.Context, tx *ent.Tx, id int) (*ent.Book, error) {
+	return tx.Book.
+		Query().
+		Where(book.ID(id)).
+		WithAuthors().
+		WithGenres().
+		Only(ctx)
 }
@@ -2,12 +2,17 @@ package genre
 
 import (
 	"context"
+	"time"
 
+	eventspb "github.com/lunn06/library/bookinfo/internal/api/proto/events"
 	"github.com/lunn06/library/bookinfo/internal/app/repository/errors"
+	"github.com/lunn06/library/bookinfo/internal/app/repository/outbox"
 	"github.com/lunn06/library/bookinfo/internal/domain"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/converter"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/genre"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/postgres"
+	"github.com/lunn06/library/bookinfo/pkg/events"
 )
 
 type Repo interface {
@@ -21,11 +26,12 @@ type Repo interface {
 var _ Repo = (*EntRepo)(nil)
 
 func NewEntRepo(client *ent.Client) *EntRepo {
-	return &EntRepo{GenreClient: client.Genre}
+	return &EntRepo{GenreClient: client.Genre, client: client}
 }
 
 type EntRepo struct {
 	*ent.GenreClient
+	client *ent.Client
 }
 
 func (egr *EntRepo) Get(ctx context.Context, id int) (domain.Genre, error) {
@@ -68,12 +74,28 @@ func (egr *EntRepo) SearchByTitleWithLimitOffset(
 }
 
 func (egr *EntRepo) Put(ctx context.Context, genre domain.Genre, booksIDs ...int) (domain.Genre, error) {
-	entGenre, err := egr.
-		Create().
-		SetTitle(genre.Title).
-		SetDescription(genre.Description).
-		AddBookIDs(booksIDs...).
-		Save(ctx)
+	var entGenre *ent.Genre
+	err := postgres.WithTx(ctx, egr.client, func(tx *ent.Tx) error {
+		created, err := tx.Genre.
+			Create().
+			SetTitle(genre.Title).
+			SetDescription(genre.Description).
+			AddBookIDs(booksIDs...).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		entGenre, err = queryWithBooks(ctx, tx, created.ID)
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.GenreCreated, &eventspb.GenreCreated{
+			Genre:     outbox.GenreToProto(entGenre),
+			CreatedAt: time.Now().Unix(),
+		})
+	})
 	if err != nil {
 		return domain.Genre{}, err
 	}
@@ -81,24 +103,56 @@ func (egr *EntRepo) Put(ctx context.Context, genre domain.Genre, booksIDs ...int
 	return converter.GenreToDomain(entGenre), nil
 }
 
-func (egr *EntRepo) Update(ctx context.Context, genre domain.Genre, booksIDs ...int) error {
-	err := egr.
-		UpdateOneID(genre.ID).
-		SetTitle(genre.Title).
-		SetDescription(genre.Description).
-		AddBookIDs(booksIDs...).
-		Exec(ctx)
-	if ent.IsNotFound(err) {
-		return errors.ErrNotFound{Inner: err}
-	}
-
-	return err
+func (egr *EntRepo) Update(ctx context.Context, genre domain.Genre, bookIDs ...int) error {
+	return postgres.WithTx(ctx, egr.client, func(tx *ent.Tx) error {
+		err := tx.Genre.
+			UpdateOneID(genre.ID).
+			SetTitle(genre.Title).
+			SetDescription(genre.Description).
+			AddBookIDs(bookIDs...).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		entGenre, err := queryWithBooks(ctx, tx, genre.ID)
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.GenreUpdated, &eventspb.GenreUpdated{
+			Genre:     outbox.GenreToProto(entGenre),
+			UpdatedAt: time.Now().Unix(),
+		})
+	})
 }
 
 func (egr *EntRepo) Delete(ctx context.Context, id int) error {
-	err := egr.GenreClient.
-		DeleteOneID(id).
-		Exec(ctx)
+	return postgres.WithTx(ctx, egr.client, func(tx *ent.Tx) error {
+		err := tx.Genre.
+			DeleteOneID(id).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, events.GenreDeleted, &eventspb.GenreDeleted{
+			GenreId:   int64(id),
+			DeletedAt: time.Now().Unix(),
+		})
+	})
+}
 
-	return err
+func queryWithBooks(ctx context.Context, tx *ent.Tx, id int) (*ent.Genre, error) {
+	return tx.Genre.
+		Query().
+		Where(genre.ID(id)).
+		WithBooks().
+		Only(ctx)
 }
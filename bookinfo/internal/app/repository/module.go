@@ -6,10 +6,12 @@ import (
 	"github.com/lunn06/library/bookinfo/internal/app/repository/author"
 	"github.com/lunn06/library/bookinfo/internal/app/repository/book"
 	"github.com/lunn06/library/bookinfo/internal/app/repository/genre"
+	"github.com/lunn06/library/bookinfo/internal/app/repository/outbox"
 )
 
 var Module = fx.Options(
 	author.Module,
 	book.Module,
 	genre.Module,
+	outbox.Module,
 )
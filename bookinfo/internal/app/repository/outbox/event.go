@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	eventspb "github.com/lunn06/library/bookinfo/internal/api/proto/events"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
+)
+
+// BookToProto converts the book, its authors and genres edges have to be
+// loaded.
+func BookToProto(entBook *ent.Book) *eventspb.Book {
+	event := &eventspb.Book{
+		Id:          int64(entBook.ID),
+		UserId:      int64(entBook.UserID),
+		Title:       entBook.Title,
+		Description: entBook.Description,
+		BookUrl:     entBook.BookURL,
+		CoverUrl:    entBook.CoverURL,
+		AuthorsIds:  make([]int64, len(entBook.Edges.Authors)),
+		GenresIds:   make([]int64, len(entBook.Edges.Genres)),
+	}
+	for i, entAuthor := range entBook.Edges.Authors {
+		event.AuthorsIds[i] = int64(entAuthor.ID)
+	}
+	for i, entGenre := range entBook.Edges.Genres {
+		event.GenresIds[i] = int64(entGenre.ID)
+	}
+
+	return event
+}
+
+// AuthorToProto converts the author, its books edge has to be loaded.
+func AuthorToProto(entAuthor *ent.Author) *eventspb.Author {
+	event := &eventspb.Author{
+		Id:          int64(entAuthor.ID),
+		Name:        entAuthor.Name,
+		Description: entAuthor.Description,
+		BooksIds:    make([]int64, len(entAuthor.Edges.Books)),
+	}
+	for i, entBook := range entAuthor.Edges.Books {
+		event.BooksIds[i] = int64(entBook.ID)
+	}
+
+	return event
+}
+
+// GenreToProto converts the genre, its books edge has to be loaded.
+func GenreToProto(entGenre *ent.Genre) *eventspb.Genre {
+	event := &eventspb.Genre{
+		Id:          int64(entGenre.ID),
+		Title:       entGenre.Title,
+		Description: entGenre.Description,
+		BooksIds:    make([]int64, len(entGenre.Edges.Books)),
+	}
+	for i, entBook := range entGenre.Edges.Books {
+		event.BooksIds[i] = int64(entBook.ID)
+	}
+
+	return event
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	eventspb "github.com/lunn06/library/bookinfo/internal/api/proto/events"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
+)
+
+func TestBookToProto(t *testing.T) {
+	coverURL := "/book/file/covers/1"
+	entBook := &ent.Book{
+		ID:       1,
+		UserID:   7,
+		Title:    "The Master and Margarita",
+		BookURL:  "/book/file/0b9a5f3e-5d1c-4c55-9f0b-2a6f7c1d8e42",
+		CoverURL: &coverURL,
+		Edges: ent.BookEdges{
+			Authors: []*ent.Author{{ID: 3}},
+			Genres:  []*ent.Genre{{ID: 4}, {ID: 5}},
+		},
+	}
+
+	assert.True(t, proto.Equal(&eventspb.Book{
+		Id:         1,
+		UserId:     7,
+		Title:      "The Master and Margarita",
+		BookUrl:    "/book/file/0b9a5f3e-5d1c-4c55-9f0b-2a6f7c1d8e42",
+		CoverUrl:   &coverURL,
+		AuthorsIds: []int64{3},
+		GenresIds:  []int64{4, 5},
+	}, BookToProto(entBook)))
+}
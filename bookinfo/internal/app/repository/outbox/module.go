@@ -0,0 +1,14 @@
+package outbox
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			NewEntRepo,
+			fx.As(new(Repo)),
+		),
+	),
+)
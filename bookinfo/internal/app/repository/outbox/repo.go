@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/lunn06/library/bookinfo/internal/domain"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent/outbox"
+)
+
+type Repo interface {
+	// Pending returns up to limit unpublished events, oldest first.
+	Pending(ctx context.Context, limit int) ([]domain.Event, error)
+	Delete(ctx context.Context, ids ...int) error
+}
+
+var _ Repo = (*EntRepo)(nil)
+
+func NewEntRepo(client *ent.Client) *EntRepo {
+	return &EntRepo{OutboxClient: client.Outbox}
+}
+
+type EntRepo struct {
+	*ent.OutboxClient
+}
+
+// Enqueue saves the event within tx, so that it is published only if
+// the change it describes is committed.
+func Enqueue(ctx context.Context, tx *ent.Tx, subject string, event proto.Message) error {
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Outbox.
+		Create().
+		SetSubject(subject).
+		SetPayload(payload).
+		Exec(ctx)
+}
+
+func (er *EntRepo) Pending(ctx context.Context, limit int) ([]domain.Event, error) {
+	entEvents, err := er.
+		Query().
+		Order(ent.Asc(outbox.FieldID)).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]domain.Event, len(entEvents))
+	for i, entEvent := range entEvents {
+		events[i] = domain.Event{
+			ID:        entEvent.ID,
+			Subject:   entEvent.Subject,
+			Payload:   entEvent.Payload,
+			CreatedAt: entEvent.CreatedAt,
+		}
+	}
+
+	return events, nil
+}
+
+func (er *EntRepo) Delete(ctx context.Context, ids ...int) error {
+	_, err := er.OutboxClient.
+		Delete().
+		Where(outbox.IDIn(ids...)).
+		Exec(ctx)
+
+	return err
+}
@@ -2,11 +2,14 @@ package book
 
 import (
 	"context"
+	stderrors "errors"
+	"time"
 
 	bookrepo "github.com/lunn06/library/bookinfo/internal/app/repository/book"
 	repoerrors "github.com/lunn06/library/bookinfo/internal/app/repository/errors"
 	"github.com/lunn06/library/bookinfo/internal/app/service/errors"
 	"github.com/lunn06/library/bookinfo/internal/domain"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 // TODO: add errors handling
@@ -17,9 +20,21 @@ type SearchRequest struct {
 	Limit  int
 }
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type UserBooksRequest struct {
+	UserID int
+	// Before is exclusive, zero means now.
+	Before time.Time
+	Limit  int
+}
+
+// UpdateRequest edits a book, the user who added it stays its owner.
 type UpdateRequest struct {
 	ID          int
-	UserID      int
 	Title       string
 	Description string
 	BookURL     string
@@ -64,17 +79,24 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (int, error) {
 	return book.ID, nil
 }
 
+// Update edits the book on behalf of the user of the context, see
+// authz.Policy.
 func (s *Service) Update(ctx context.Context, req UpdateRequest) error {
+	current, err := s.authorize(ctx, req.ID, authz.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
 	book := domain.Book{
 		ID:          req.ID,
-		UserID:      req.UserID,
+		UserID:      current.UserID,
 		Title:       req.Title,
 		Description: req.Description,
 		BookURL:     req.BookURL,
 		CoverURL:    req.CoverURL,
 	}
 
-	err := s.repo.Update(ctx, book, req.AuthorsIDs, req.GenresIDs)
+	err = s.repo.Update(ctx, book, req.AuthorsIDs, req.GenresIDs)
 	if repoerrors.IsErrNotFound(err) {
 		return errors.ErrResourceNotFound{Inner: err}
 	}
@@ -91,6 +113,17 @@ func (s *Service) Search(ctx context.Context, req SearchRequest) ([]domain.Book,
 	return books, err
 }
 
+// GetAllByUserID returns the books added by the user, newest first.
+func (s *Service) GetAllByUserID(ctx context.Context, req UserBooksRequest) ([]domain.Book, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	return s.repo.GetAllByUserID(ctx, req.UserID, req.Before, limit)
+}
+
 func (s *Service) Get(ctx context.Context, id int) (domain.Book, error) {
 	book, err := s.repo.Get(ctx, id)
 	if repoerrors.IsErrNotFound(err) {
@@ -100,6 +133,52 @@ func (s *Service) Get(ctx context.Context, id int) (domain.Book, error) {
 	return book, err
 }
 
+// Delete removes the book on behalf of the user of the context, see
+// authz.Policy.
 func (s *Service) Delete(ctx context.Context, id int) error {
-	return s.repo.Delete(ctx, id)
+	if _, err := s.authorize(ctx, id, authz.ActionDelete); err != nil {
+		return err
+	}
+
+	err := s.repo.Delete(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// authorize returns the book if the user of the context may do the action
+// on it.
+func (s *Service) authorize(ctx context.Context, id int, action authz.Action) (domain.Book, error) {
+	book, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	err = authz.Authorize(ctx, authz.ResourceBook, action, book.UserID)
+	switch {
+	case stderrors.Is(err, authz.ErrUnauthenticated):
+		return domain.Book{}, errors.ErrUnauthenticated{Inner: err}
+	case err != nil:
+		return domain.Book{}, errors.ErrForbidden{Inner: err}
+	}
+
+	return book, nil
+}
+
+// Missing returns the ids and the book file paths of the given books which
+// are not in the catalogue.
+func (s *Service) Missing(ctx context.Context, ids []int, bookURLs []string) ([]int, []string, error) {
+	missingIDs, err := s.repo.MissingIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missingURLs, err := s.repo.MissingBookURLs(ctx, bookURLs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return missingIDs, missingURLs, nil
 }
@@ -30,3 +30,57 @@ func (err ErrResourceNotFound) Is(target error) bool {
 	_, ok := target.(ErrResourceNotFound)
 	return ok
 }
+
+func IsErrUnauthenticated(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrUnauthenticated{})
+}
+
+// ErrUnauthenticated is returned for the requests made without a user
+// which require one.
+type ErrUnauthenticated struct {
+	Inner error
+}
+
+func (err ErrUnauthenticated) Error() string {
+	return fmt.Sprintf("unauthenticated: %s", err.Inner.Error())
+}
+func (err ErrUnauthenticated) Unwrap() error {
+	return err.Inner
+}
+
+func (err ErrUnauthenticated) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrUnauthenticated)
+	return ok
+}
+
+func IsErrForbidden(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrForbidden{})
+}
+
+type ErrForbidden struct {
+	Inner error
+}
+
+func (err ErrForbidden) Error() string {
+	return fmt.Sprintf("forbidden: %s", err.Inner.Error())
+}
+func (err ErrForbidden) Unwrap() error {
+	return err.Inner
+}
+
+func (err ErrForbidden) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrForbidden)
+	return ok
+}
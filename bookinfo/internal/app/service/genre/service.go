@@ -2,11 +2,13 @@ package genre
 
 import (
 	"context"
+	stderrors "errors"
 
 	repoerrors "github.com/lunn06/library/bookinfo/internal/app/repository/errors"
 	genrerepo "github.com/lunn06/library/bookinfo/internal/app/repository/genre"
 	"github.com/lunn06/library/bookinfo/internal/app/service/errors"
 	"github.com/lunn06/library/bookinfo/internal/domain"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 // TODO: add errors handling
@@ -54,6 +56,10 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (int, error) {
 }
 
 func (s *Service) Update(ctx context.Context, req UpdateRequest) error {
+	if err := authorize(ctx, authz.ActionUpdate); err != nil {
+		return err
+	}
+
 	genre := domain.Genre{
 		ID:          req.ID,
 		Title:       req.Title,
@@ -87,5 +93,28 @@ func (s *Service) Get(ctx context.Context, id int) (domain.Genre, error) {
 }
 
 func (s *Service) Delete(ctx context.Context, id int) error {
-	return s.repo.Delete(ctx, id)
+	if err := authorize(ctx, authz.ActionDelete); err != nil {
+		return err
+	}
+
+	err := s.repo.Delete(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// authorize fails unless the user of the context may do the action on the
+// genres, see authz.Policy.
+func authorize(ctx context.Context, action authz.Action) error {
+	err := authz.Authorize(ctx, authz.ResourceGenre, action, 0)
+	switch {
+	case stderrors.Is(err, authz.ErrUnauthenticated):
+		return errors.ErrUnauthenticated{Inner: err}
+	case err != nil:
+		return errors.ErrForbidden{Inner: err}
+	}
+
+	return nil
 }
@@ -6,10 +6,12 @@ import (
 	"github.com/lunn06/library/bookinfo/internal/app/service/author"
 	"github.com/lunn06/library/bookinfo/internal/app/service/book"
 	"github.com/lunn06/library/bookinfo/internal/app/service/genre"
+	"github.com/lunn06/library/bookinfo/internal/app/service/outbox"
 )
 
 var Module = fx.Options(
 	author.Module,
 	book.Module,
 	genre.Module,
+	outbox.Module,
 )
@@ -0,0 +1,8 @@
+package outbox
+
+import "time"
+
+type Config struct {
+	Interval  time.Duration `default:"1s"`
+	BatchSize int           `default:"100" split_words:"true"`
+}
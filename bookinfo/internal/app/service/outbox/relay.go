@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	outboxrepo "github.com/lunn06/library/bookinfo/internal/app/repository/outbox"
+	"github.com/lunn06/library/bookinfo/internal/domain"
+)
+
+// Publisher delivers an event, publishing the same event twice must not
+// deliver it twice.
+type Publisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
+
+func NewRelay(repo outboxrepo.Repo, publisher Publisher, cfg Config) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Relay publishes the events saved in the outbox and removes the published
+// ones. An event is removed only after it is published, so it may be
+// published again if the relay stops in between.
+type Relay struct {
+	repo      outboxrepo.Repo
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+// Run flushes the outbox every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to relay outbox events", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes pending events in batches until the outbox is empty.
+func (r *Relay) Flush(ctx context.Context) error {
+	for {
+		events, err := r.repo.Pending(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		published := make([]int, 0, len(events))
+		for _, event := range events {
+			if err = r.publisher.Publish(ctx, event); err != nil {
+				break
+			}
+			published = append(published, event.ID)
+		}
+		if len(published) > 0 {
+			if derr := r.repo.Delete(ctx, published...); derr != nil {
+				return derr
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(events) < r.batchSize {
+			return nil
+		}
+	}
+}
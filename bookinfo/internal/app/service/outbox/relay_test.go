@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/bookinfo/internal/domain"
+)
+
+type memRepo struct {
+	events []domain.Event
+}
+
+func (m *memRepo) Pending(_ context.Context, limit int) ([]domain.Event, error) {
+	return m.events[:min(limit, len(m.events))], nil
+}
+
+func (m *memRepo) Delete(_ context.Context, ids ...int) error {
+	kept := m.events[:0]
+	for _, event := range m.events {
+		deleted := false
+		for _, id := range ids {
+			deleted = deleted || event.ID == id
+		}
+		if !deleted {
+			kept = append(kept, event)
+		}
+	}
+	m.events = kept
+	return nil
+}
+
+type failingPublisher struct {
+	failOn    int
+	published []int
+}
+
+func (p *failingPublisher) Publish(_ context.Context, event domain.Event) error {
+	if event.ID == p.failOn {
+		return errors.New("no responders")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func TestRelayFlush(t *testing.T) {
+	repo := &memRepo{}
+	for id := 1; id <= 5; id++ {
+		repo.events = append(repo.events, domain.Event{ID: id, Subject: "bookinfo.events.book.deleted"})
+	}
+	publisher := &failingPublisher{}
+
+	relay := NewRelay(repo, publisher, Config{BatchSize: 2})
+	require.NoError(t, relay.Flush(context.Background()))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, publisher.published)
+	assert.Empty(t, repo.events)
+}
+
+func TestRelayFlushKeepsUnpublished(t *testing.T) {
+	repo := &memRepo{}
+	for id := 1; id <= 3; id++ {
+		repo.events = append(repo.events, domain.Event{ID: id})
+	}
+	publisher := &failingPublisher{failOn: 2}
+
+	relay := NewRelay(repo, publisher, Config{BatchSize: 10})
+	assert.Error(t, relay.Flush(context.Background()))
+	assert.Equal(t, []int{1}, publisher.published)
+	assert.Equal(t, []domain.Event{{ID: 2}, {ID: 3}}, repo.events)
+}
@@ -4,6 +4,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/lunn06/library/bookinfo/internal/api/nats"
+	"github.com/lunn06/library/bookinfo/internal/app/service/outbox"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/postgres"
 )
 
@@ -12,4 +13,5 @@ type Config struct {
 
 	Nats     nats.Config
 	Postgres postgres.Config
+	Relay    outbox.Config
 }
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type Book struct {
 	ID          int
 	UserID      int
@@ -9,4 +11,5 @@ type Book struct {
 	CoverURL    *string
 	Authors     []Author
 	Genres      []Genre
+	CreatedAt   time.Time
 }
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// Event is a domain event waiting in the outbox to be published.
+type Event struct {
+	ID        int
+	Subject   string
+	Payload   []byte
+	CreatedAt time.Time
+}
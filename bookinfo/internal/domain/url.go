@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+)
+
+// bookFilePathPrefix is the path the gateway serves book files under.
+const bookFilePathPrefix = "/book/file/"
+
+// BookFile returns the path of the book file the book url refers to, from
+// the prefix of the book files on, or "" for urls of other files. The url
+// may be absolute or just the path.
+func BookFile(bookURL string) string {
+	u, err := url.Parse(bookURL)
+	if err != nil {
+		return ""
+	}
+
+	i := strings.Index(u.Path, bookFilePathPrefix)
+	if i < 0 || i+len(bookFilePathPrefix) == len(u.Path) {
+		return ""
+	}
+
+	return u.Path[i:]
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookFile(t *testing.T) {
+	const path = "/book/file/3f0a8f3e-6d5c-4a5e-9c8f-2b7e4b5f1a2c"
+
+	for _, bookURL := range []string{
+		path,
+		"https://library.example" + path,
+		"https://library.example/api/v1" + path + "?download=1",
+	} {
+		assert.Equal(t, path, BookFile(bookURL), bookURL)
+	}
+
+	for _, bookURL := range []string{
+		"",
+		"https://library.example/book/file/",
+		"https://library.example/covers/1.jpg",
+		"%zz",
+	} {
+		assert.Empty(t, BookFile(bookURL), bookURL)
+	}
+}
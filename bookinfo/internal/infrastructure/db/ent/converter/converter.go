@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"github.com/lunn06/library/bookinfo/internal/domain"
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
+)
+
+func BookToDomain(entBook *ent.Book) domain.Book {
+	return domain.Book{
+		ID:          entBook.ID,
+		UserID:      entBook.UserID,
+		Title:       entBook.Title,
+		Description: entBook.Description,
+		BookURL:     entBook.BookURL,
+		CoverURL:    entBook.CoverURL,
+		Authors:     AuthorsToDomain(entBook.Edges.Authors),
+		Genres:      GenresToDomain(entBook.Edges.Genres),
+		CreatedAt:   entBook.CreatedAt,
+	}
+}
+
+func BooksToDomain(entBooks []*ent.Book) []domain.Book {
+	books := make([]domain.Book, len(entBooks))
+	for i, entBook := range entBooks {
+		books[i] = BookToDomain(entBook)
+	}
+
+	return books
+}
+
+func AuthorToDomain(entAuthor *ent.Author) domain.Author {
+	return domain.Author{
+		ID:          entAuthor.ID,
+		Name:        entAuthor.Name,
+		Description: entAuthor.Description,
+		Books:       BooksToDomain(entAuthor.Edges.Books),
+	}
+}
+
+func AuthorsToDomain(entAuthors []*ent.Author) []domain.Author {
+	authors := make([]domain.Author, len(entAuthors))
+	for i, entAuthor := range entAuthors {
+		authors[i] = AuthorToDomain(entAuthor)
+	}
+
+	return authors
+}
+
+func GenreToDomain(entGenre *ent.Genre) domain.Genre {
+	return domain.Genre{
+		ID:          entGenre.ID,
+		Title:       entGenre.Title,
+		Description: entGenre.Description,
+		Books:       BooksToDomain(entGenre.Edges.Books),
+	}
+}
+
+func GenresToDomain(entGenres []*ent.Genre) []domain.Genre {
+	genres := make([]domain.Genre, len(entGenres))
+	for i, entGenre := range entGenres {
+		genres[i] = GenreToDomain(entGenre)
+	}
+
+	return genres
+}
@@ -0,0 +1,22 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+type Author struct{ ent.Schema }
+
+func (Author) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name"),
+		field.String("description"),
+	}
+}
+
+func (Author) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("books", Book.Type),
+	}
+}
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type Book struct{ ent.Schema }
+
+func (Book) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("user_id"),
+		field.String("title"),
+		field.String("description"),
+		field.String("book_url"),
+		// the path of the book file of book_url, matched by the files
+		// reconciliation of bookfile
+		field.String("book_file").Optional(),
+		field.String("cover_url").Optional().Nillable(),
+		// books added before the column existed get the time of the migration
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			Annotations(entsql.Default("CURRENT_TIMESTAMP")),
+	}
+}
+
+func (Book) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("authors", Author.Type).Ref("books"),
+		edge.From("genres", Genre.Type).Ref("books"),
+	}
+}
+
+func (Book) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "created_at"),
+		index.Fields("book_file"),
+	}
+}
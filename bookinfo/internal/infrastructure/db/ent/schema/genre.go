@@ -0,0 +1,22 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+type Genre struct{ ent.Schema }
+
+func (Genre) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("title"),
+		field.String("description"),
+	}
+}
+
+func (Genre) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("books", Book.Type),
+	}
+}
@@ -0,0 +1,20 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+)
+
+// Outbox holds domain events saved in the transaction of the change they
+// describe until the relay publishes them.
+type Outbox struct{ ent.Schema }
+
+func (Outbox) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("subject"),
+		field.Bytes("payload"),
+		field.Time("created_at").Default(time.Now).Immutable(),
+	}
+}
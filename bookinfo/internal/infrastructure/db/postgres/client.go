@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
 
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
 
@@ -18,7 +19,7 @@ func Connect(cfg Config) (*ent.Client, error) {
 		"postgres://%s:%s@%s/%s?sslmode=%s",
 		cfg.User, cfg.Password, cfg.URL, cfg.DB, cfg.SslMode,
 	)
-	client, err := ent.Open(dialect.Postgres, dns)
+	drv, err := sql.Open(dialect.Postgres, dns)
 	if err != nil {
 		slog.Error(
 			"Failed opening connection to Postgres",
@@ -28,6 +29,8 @@ func Connect(cfg Config) (*ent.Client, error) {
 		return nil, err
 	}
 
+	client := ent.NewClient(ent.Driver(drv))
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 	if err = client.Schema.Create(ctx); err != nil {
@@ -38,5 +41,14 @@ func Connect(cfg Config) (*ent.Client, error) {
 		return nil, err
 	}
 
+	// the data migrations may take longer than the schema one
+	if err = migrate(context.Background(), drv.DB(), afterSchema); err != nil {
+		slog.Error(
+			"Failed migrating the catalogue after the schema",
+			"error", err,
+		)
+		return nil, err
+	}
+
 	return client, nil
 }
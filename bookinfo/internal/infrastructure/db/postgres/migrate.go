@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lunn06/library/bookinfo/internal/domain"
+)
+
+// migration changes the data of the catalogue, which the schema migration of
+// ent can't do. Each migration is applied once, the names of the applied ones
+// are kept in the data_migrations table.
+type migration struct {
+	name string
+	up   func(ctx context.Context, tx *sql.Tx) error
+}
+
+// afterSchema are applied after the schema is migrated, they fill in the
+// columns it added.
+var afterSchema = []migration{
+	{name: "backfill_book_file", up: backfillBookFile},
+}
+
+func migrate(ctx context.Context, db *sql.DB, migrations []migration) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS data_migrations (
+		name text PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err = apply(ctx, db, m); err != nil {
+			return fmt.Errorf("applying migration %s: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// apply runs the migration unless it was applied, the row of the migration
+// locks it for the other replicas starting at the same time.
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT DO NOTHING`, m.name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err = m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backfillBookFile sets the book_file of the books added before the column
+// existed.
+func backfillBookFile(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, book_url FROM books WHERE book_file IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[int]string)
+	for rows.Next() {
+		var (
+			id      int
+			bookURL string
+		)
+		if err = rows.Scan(&id, &bookURL); err != nil {
+			return err
+		}
+		files[id] = domain.BookFile(bookURL)
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for id, file := range files {
+		_, err = tx.ExecContext(ctx, `UPDATE books SET book_file = $1 WHERE id = $2`, file, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
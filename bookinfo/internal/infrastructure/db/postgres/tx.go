@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/ent"
+)
+
+// WithTx runs fn in a transaction, which is rolled back if fn fails and
+// committed otherwise.
+func WithTx(ctx context.Context, client *ent.Client, fn func(tx *ent.Tx) error) error {
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return errors.Join(err, fmt.Errorf("rolling back transaction: %w", rerr))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,190 @@
+// Package authz decides who may change the resources of the services.
+//
+// The gateway authenticates the users and passes them to the services in
+// the UserIDHeader and RolesHeader headers of the NATS messages, which the
+// services trust: clients do not reach NATS directly.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	UserIDHeader = "User-Id"
+	// RolesHeader lists the roles of the user separated by commas.
+	RolesHeader = "User-Roles"
+)
+
+type Role string
+
+const (
+	RoleModerator Role = "moderator"
+	// RoleAdmin may do anything.
+	RoleAdmin Role = "admin"
+)
+
+type Resource string
+
+const (
+	ResourceBook    Resource = "book"
+	ResourceReview  Resource = "review"
+	ResourceComment Resource = "comment"
+	// ResourceUser is the profile of a user, owned by the user.
+	ResourceUser Resource = "user"
+	// ResourceAuthor and ResourceGenre are shared by the books, no user
+	// owns them.
+	ResourceAuthor Resource = "author"
+	ResourceGenre  Resource = "genre"
+)
+
+type Action string
+
+const (
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	// ActionModerate covers settling the reviews and reading the queue of
+	// the ones waiting for it.
+	ActionModerate Action = "moderate"
+)
+
+// Rule allows an action to the owner of the resource if Owner is set, and
+// to the users with any of the roles.
+type Rule struct {
+	Owner bool
+	Roles []Role
+}
+
+// Policy is the rules of the actions on the resources, the actions
+// missing here are left to admins.
+var Policy = map[Resource]map[Action]Rule{
+	ResourceBook: {
+		ActionUpdate: {Owner: true},
+		ActionDelete: {Owner: true},
+	},
+	ResourceReview: {
+		// moderators hide reviews instead of rewriting them
+		ActionUpdate:   {Owner: true},
+		ActionDelete:   {Owner: true, Roles: []Role{RoleModerator}},
+		ActionModerate: {Roles: []Role{RoleModerator}},
+	},
+	ResourceComment: {
+		ActionUpdate: {Owner: true},
+		ActionDelete: {Owner: true, Roles: []Role{RoleModerator}},
+	},
+	ResourceUser: {
+		ActionUpdate: {Owner: true},
+	},
+	ResourceAuthor: {
+		ActionUpdate: {Roles: []Role{RoleModerator}},
+		ActionDelete: {Roles: []Role{RoleModerator}},
+	},
+	ResourceGenre: {
+		ActionUpdate: {Roles: []Role{RoleModerator}},
+		ActionDelete: {Roles: []Role{RoleModerator}},
+	},
+}
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// Principal is the user a request is made by, the zero Principal is
+// anonymous.
+type Principal struct {
+	UserID int
+	Roles  []Role
+}
+
+func (p Principal) Anonymous() bool {
+	return p.UserID == 0
+}
+
+func (p Principal) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FromHeader reads the principal set by the gateway, malformed user ids
+// leave it anonymous.
+func FromHeader(header nats.Header) Principal {
+	userID, err := strconv.Atoi(header.Get(UserIDHeader))
+	if err != nil || userID <= 0 {
+		return Principal{}
+	}
+
+	p := Principal{UserID: userID}
+	for _, role := range strings.Split(header.Get(RolesHeader), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			p.Roles = append(p.Roles, Role(role))
+		}
+	}
+
+	return p
+}
+
+// SetHeader sets the principal in the header the way FromHeader reads it,
+// an anonymous principal removes them.
+func SetHeader(header nats.Header, p Principal) {
+	if p.Anonymous() {
+		header.Del(UserIDHeader)
+		header.Del(RolesHeader)
+		return
+	}
+
+	roles := make([]string, len(p.Roles))
+	for i, role := range p.Roles {
+		roles[i] = string(role)
+	}
+	header.Set(UserIDHeader, strconv.Itoa(p.UserID))
+	header.Set(RolesHeader, strings.Join(roles, ","))
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey{}).(Principal)
+	return p
+}
+
+// MsgContext returns the context to handle the message in, carrying its
+// principal.
+func MsgContext(msg *nats.Msg) context.Context {
+	return NewContext(context.Background(), FromHeader(msg.Header))
+}
+
+// Authorize fails with ErrUnauthenticated or ErrForbidden unless the
+// principal of the context may do the action on the resource owned by the
+// user with ownerID.
+func Authorize(ctx context.Context, resource Resource, action Action, ownerID int) error {
+	p := FromContext(ctx)
+	if p.Anonymous() {
+		return ErrUnauthenticated
+	}
+	if p.HasRole(RoleAdmin) {
+		return nil
+	}
+
+	rule, ok := Policy[resource][action]
+	if ok && (rule.Owner && p.UserID == ownerID || p.HasRole(rule.Roles...)) {
+		return nil
+	}
+
+	return fmt.Errorf("user %d may not %s %s: %w", p.UserID, action, resource, ErrForbidden)
+}
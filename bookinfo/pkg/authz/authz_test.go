@@ -0,0 +1,74 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromHeader(t *testing.T) {
+	header := nats.Header{}
+	assert.True(t, FromHeader(header).Anonymous())
+
+	header.Set(UserIDHeader, "x")
+	assert.True(t, FromHeader(header).Anonymous())
+
+	header.Set(UserIDHeader, "7")
+	header.Set(RolesHeader, "moderator, ,admin")
+	assert.Equal(t, Principal{UserID: 7, Roles: []Role{RoleModerator, RoleAdmin}}, FromHeader(header))
+}
+
+func TestSetHeader(t *testing.T) {
+	header := nats.Header{}
+	p := Principal{UserID: 7, Roles: []Role{RoleModerator, RoleAdmin}}
+	SetHeader(header, p)
+	assert.Equal(t, p, FromHeader(header))
+
+	SetHeader(header, Principal{})
+	assert.Empty(t, header)
+}
+
+func TestAuthorize(t *testing.T) {
+	const ownerID = 1
+	ctx := func(p Principal) context.Context {
+		return NewContext(context.Background(), p)
+	}
+	owner := ctx(Principal{UserID: ownerID})
+	stranger := ctx(Principal{UserID: 2})
+	moderator := ctx(Principal{UserID: 3, Roles: []Role{RoleModerator}})
+	admin := ctx(Principal{UserID: 4, Roles: []Role{RoleAdmin}})
+
+	assert.ErrorIs(t, Authorize(context.Background(), ResourceReview, ActionUpdate, ownerID), ErrUnauthenticated)
+
+	for _, c := range []struct {
+		ctx      context.Context
+		resource Resource
+		action   Action
+		allowed  bool
+	}{
+		{owner, ResourceBook, ActionUpdate, true},
+		{stranger, ResourceBook, ActionDelete, false},
+		{moderator, ResourceBook, ActionDelete, false},
+		{admin, ResourceBook, ActionDelete, true},
+		{moderator, ResourceReview, ActionUpdate, false},
+		{moderator, ResourceReview, ActionDelete, true},
+		{owner, ResourceReview, ActionModerate, false},
+		{moderator, ResourceReview, ActionModerate, true},
+		{stranger, ResourceComment, ActionUpdate, false},
+		{owner, ResourceAuthor, ActionUpdate, false},
+		{moderator, ResourceAuthor, ActionDelete, true},
+		{stranger, ResourceGenre, ActionDelete, false},
+		{moderator, ResourceGenre, ActionUpdate, true},
+		{owner, "unknown", ActionUpdate, false},
+		{admin, "unknown", ActionUpdate, true},
+	} {
+		err := Authorize(c.ctx, c.resource, c.action, ownerID)
+		if c.allowed {
+			assert.NoError(t, err, "%v %s %s", FromContext(c.ctx), c.action, c.resource)
+		} else {
+			assert.ErrorIs(t, err, ErrForbidden, "%v %s %s", FromContext(c.ctx), c.action, c.resource)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package events describes the JetStream stream bookinfo publishes its
+// domain events to, so that other services can subscribe to it.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	StreamName = "BOOKINFO_EVENTS"
+
+	SubjectPrefix = "bookinfo.events."
+
+	BookCreated   = SubjectPrefix + "book.created"
+	BookUpdated   = SubjectPrefix + "book.updated"
+	BookDeleted   = SubjectPrefix + "book.deleted"
+	AuthorCreated = SubjectPrefix + "author.created"
+	AuthorUpdated = SubjectPrefix + "author.updated"
+	AuthorDeleted = SubjectPrefix + "author.deleted"
+	GenreCreated  = SubjectPrefix + "genre.created"
+	GenreUpdated  = SubjectPrefix + "genre.updated"
+	GenreDeleted  = SubjectPrefix + "genre.deleted"
+
+	// DuplicateWindow is how long the stream remembers Nats-Msg-Id headers
+	// of published events, republishing within it is a no-op.
+	DuplicateWindow = 10 * time.Minute
+	MaxAge          = 7 * 24 * time.Hour
+)
+
+func StreamConfig() jetstream.StreamConfig {
+	return jetstream.StreamConfig{
+		Name:       StreamName,
+		Subjects:   []string{SubjectPrefix + ">"},
+		Storage:    jetstream.FileStorage,
+		Retention:  jetstream.LimitsPolicy,
+		MaxAge:     MaxAge,
+		Duplicates: DuplicateWindow,
+	}
+}
+
+// EnsureStream creates the stream or updates it to the current config,
+// both the publisher and the consumers call it on start.
+func EnsureStream(ctx context.Context, js jetstream.JetStream) (jetstream.Stream, error) {
+	return js.CreateOrUpdateStream(ctx, StreamConfig())
+}
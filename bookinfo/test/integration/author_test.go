@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	authorpb "github.com/lunn06/library/bookinfo/internal/api/proto/author"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 func TestAuthorCreateWithNoBooks(t *testing.T) {
@@ -72,6 +73,11 @@ func TestAuthorUpdateWithNoBooks(t *testing.T) {
 	err = request(authorUpdateSubj, &updateReq, &updateResp)
 	require.NoError(t, err)
 
+	assert.Equal(t, http.StatusUnauthorized, int(updateResp.StatusCode))
+
+	err = requestAs(moderator, authorUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+
 	assert.Equal(t, http.StatusOK, int(updateResp.StatusCode))
 	//////////////
 
@@ -123,7 +129,12 @@ func TestAuthorDelete(t *testing.T) {
 		AuthorId: putResp.AuthorId,
 	}
 	var deleteResp authorpb.EmptyResponse
-	err = request(authorDeleteSubj, &deleteReq, &deleteResp)
+	err = requestAs(authz.Principal{UserID: 999}, authorDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, int(deleteResp.StatusCode))
+
+	err = requestAs(moderator, authorDeleteSubj, &deleteReq, &deleteResp)
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(deleteResp.StatusCode))
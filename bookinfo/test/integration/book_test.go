@@ -11,6 +11,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	bookpb "github.com/lunn06/library/bookinfo/internal/api/proto/book"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 func TestBookCreateWithNoAdditional(t *testing.T) {
@@ -66,8 +67,8 @@ func TestBookUpdateWithNoAdditional(t *testing.T) {
 		testDescription
 		testBookUrl
 
-		testUpdatedUserID = 1000
-		testUpdatedTitle  = "UpdatedTestBookUpdateWithNoAdditional"
+		testOtherUserID  = 1000
+		testUpdatedTitle = "UpdatedTestBookUpdateWithNoAdditional"
 		testUpdatedDescription
 		testUpdatedBookUrl
 	)
@@ -88,7 +89,7 @@ func TestBookUpdateWithNoAdditional(t *testing.T) {
 	// Update gateway
 	updateReq := bookpb.UpdateRequest{
 		Id:          putResp.BookId,
-		UserId:      testUpdatedUserID,
+		UserId:      testOtherUserID,
 		Title:       testUpdatedTitle,
 		Description: testUpdatedDescription,
 		BookUrl:     testUpdatedBookUrl,
@@ -96,7 +97,14 @@ func TestBookUpdateWithNoAdditional(t *testing.T) {
 	var updateResp bookpb.EmptyResponse
 	err = request(bookUpdateSubj, &updateReq, &updateResp)
 	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, int(updateResp.StatusCode))
 
+	err = requestAs(authz.Principal{UserID: testOtherUserID}, bookUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(updateResp.StatusCode))
+
+	err = requestAs(authz.Principal{UserID: testUserID}, bookUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, int(updateResp.StatusCode))
 	//////////////
 
@@ -109,7 +117,7 @@ func TestBookUpdateWithNoAdditional(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(getResp.StatusCode))
-	assert.Equal(t, testUpdatedUserID, int(getResp.UserId))
+	assert.Equal(t, testUserID, int(getResp.UserId), "the owner does not change")
 	assert.Equal(t, testUpdatedTitle, getResp.Title)
 	assert.Equal(t, testUpdatedDescription, getResp.Description)
 	assert.Equal(t, testUpdatedBookUrl, getResp.BookUrl)
@@ -156,7 +164,12 @@ func TestBookDelete(t *testing.T) {
 		BookId: putResp.BookId,
 	}
 	var deleteResp bookpb.EmptyResponse
-	err = request(bookDeleteSubj, &deleteReq, &deleteResp)
+	err = requestAs(authz.Principal{UserID: testUserID + 1}, bookDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(deleteResp.StatusCode))
+
+	admin := authz.Principal{UserID: testUserID + 2, Roles: []authz.Role{authz.RoleAdmin}}
+	err = requestAs(admin, bookDeleteSubj, &deleteReq, &deleteResp)
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(deleteResp.StatusCode))
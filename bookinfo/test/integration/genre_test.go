@@ -11,6 +11,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	genrepb "github.com/lunn06/library/bookinfo/internal/api/proto/genre"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 func TestGenreCreateWithNoBooks(t *testing.T) {
@@ -83,6 +84,11 @@ func TestGenreUpdateWithNoBooks(t *testing.T) {
 	err = request(genreUpdateSubj, &updateReq, &updateResp)
 	require.NoError(t, err)
 
+	assert.Equal(t, http.StatusUnauthorized, int(updateResp.StatusCode))
+
+	err = requestAs(moderator, genreUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+
 	assert.Equal(t, http.StatusOK, int(updateResp.StatusCode))
 	//////////////
 
@@ -134,7 +140,12 @@ func TestGenreDelete(t *testing.T) {
 		GenreId: putResp.GenreId,
 	}
 	var deleteResp genrepb.EmptyResponse
-	err = request(genreDeleteSubj, &deleteReq, &deleteResp)
+	err = requestAs(authz.Principal{UserID: 999}, genreDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, int(deleteResp.StatusCode))
+
+	err = requestAs(moderator, genreDeleteSubj, &deleteReq, &deleteResp)
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(deleteResp.StatusCode))
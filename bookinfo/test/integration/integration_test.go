@@ -25,6 +25,7 @@ import (
 	"github.com/lunn06/library/bookinfo/internal/app/service/genre"
 	"github.com/lunn06/library/bookinfo/internal/config"
 	"github.com/lunn06/library/bookinfo/internal/infrastructure/db/postgres"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 )
 
 const reqTimeout = time.Second
@@ -36,11 +37,11 @@ const (
 	authorUpdateSubj = "author.update"
 	authorDeleteSubj = "author.delete"
 
-	bookSearchSubj = "gateway.search"
-	bookGetSubj    = "gateway.get"
-	bookPutSubj    = "gateway.put"
-	bookUpdateSubj = "gateway.update"
-	bookDeleteSubj = "gateway.delete"
+	bookSearchSubj = "book.search"
+	bookGetSubj    = "book.get"
+	bookPutSubj    = "book.put"
+	bookUpdateSubj = "book.update"
+	bookDeleteSubj = "book.delete"
 
 	genreSearchSubj = "genre.search"
 	genreGetSubj    = "genre.get"
@@ -152,13 +153,26 @@ func postgresContainer() testcontainers.Container {
 	return postgresC
 }
 
+// moderator may change the authors and the genres, see authz.Policy.
+var moderator = authz.Principal{UserID: 1001, Roles: []authz.Role{authz.RoleModerator}}
+
 func request(subj string, req, resp proto.Message) error {
+	return requestAs(authz.Principal{}, subj, req, resp)
+}
+
+// requestAs makes the request on behalf of the principal, as the gateway
+// does for authenticated users.
+func requestAs(p authz.Principal, subj string, req, resp proto.Message) error {
 	data, err := proto.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	resMsg, err := nc.Request(subj, data, reqTimeout)
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	authz.SetHeader(msg.Header, p)
+
+	resMsg, err := nc.RequestMsg(msg, reqTimeout)
 	if err != nil {
 		return err
 	}
@@ -5,9 +5,11 @@ go 1.24.2
 require (
 	github.com/docker/docker v28.1.1+incompatible
 	github.com/gofiber/fiber/v2 v2.52.6
+	github.com/golang-jwt/jwt/v5 v5.1.0
 	github.com/google/uuid v1.6.0
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/lunn06/library/bookfile v0.0.0-20250508164128-1b24ecadb69a
+	github.com/lunn06/library/bookinfo v0.0.0-20250508164128-1b24ecadb69a
 	github.com/nats-io/nats.go v1.42.0
 	github.com/samber/slog-fiber v1.18.0
 	github.com/stretchr/testify v1.10.0
@@ -44,3 +46,8 @@ require (
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace (
+	github.com/lunn06/library/bookfile => ../bookfile
+	github.com/lunn06/library/bookinfo => ../bookinfo
+)
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	bookpb "github.com/lunn06/library/gateway/internal/api/proto/book"
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+)
+
+const (
+	bookInfoGetAllByUserIdSubj = "book.getAllByUserId"
+	userActivityName           = "user.activity"
+)
+
+const (
+	activityDefaultLimit = 20
+	activityMaxLimit     = 50
+	// sourceMaxLimit is the page size limit of both review and bookinfo.
+	sourceMaxLimit = 100
+)
+
+const (
+	activityReview = "review"
+	activityBook   = "book"
+)
+
+func NewActivityAPI(conn *nats.Conn) ActivityAPI {
+	return ActivityAPI{conn: conn}
+}
+
+// ActivityAPI merges what a user did across the services into a single
+// feed, newest first.
+type ActivityAPI struct {
+	conn *nats.Conn
+}
+
+func (aa ActivityAPI) Register(router fiber.Router) {
+	router.
+		Get("/activity/user/:id", aa.Get).
+		Name(userActivityName)
+}
+
+type activityItem struct {
+	Type      string
+	CreatedAt int64
+	Review    *reviewpb.ReviewItem
+	Book      *bookpb.UserBookItem
+}
+
+// MarshalJSON writes the review or the book as the other routes do.
+func (item activityItem) MarshalJSON() ([]byte, error) {
+	var payload proto.Message = item.Review
+	if item.Type == activityBook {
+		payload = item.Book
+	}
+	data, err := protojson.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"type":       item.Type,
+		"created_at": item.CreatedAt,
+		item.Type:    json.RawMessage(data),
+	})
+}
+
+type activityPage struct {
+	Items []activityItem `json:"items"`
+	// empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// activityCursor points past the items of the previous pages. The services
+// only tell the time in seconds, so the items created in the second of the
+// last returned one are fetched again and the ones already returned skipped.
+type activityCursor struct {
+	Before  int64   `json:"t"`
+	Reviews []int64 `json:"r,omitempty"`
+	Books   []int64 `json:"b,omitempty"`
+}
+
+func parseActivityCursor(s string) (activityCursor, error) {
+	var cursor activityCursor
+	if s == "" {
+		return cursor, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return activityCursor{}, err
+	}
+	err = json.Unmarshal(data, &cursor)
+
+	return cursor, err
+}
+
+func (c activityCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Get returns a page of the reviews written and the books added by the
+// user. The query may set limit and the cursor of the previous page.
+func (aa ActivityAPI) Get(ctx *fiber.Ctx) error {
+	userID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	limit := activityDefaultLimit
+	if value := ctx.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid limit")
+		}
+		limit = min(limit, activityMaxLimit)
+	}
+
+	cursor, err := parseActivityCursor(ctx.Query("cursor"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor")
+	}
+	var createdBefore *int64
+	if cursor.Before != 0 {
+		createdBefore = proto.Int64(cursor.Before + 1)
+	}
+
+	reviewLimit := min(limit+len(cursor.Reviews), sourceMaxLimit)
+	reviews, err := requestUserReviews(ctx, aa.conn, &reviewpb.GetByUserIdRequest{
+		UserId:        int64(userID),
+		Limit:         int32(reviewLimit),
+		Sort:          reviewpb.Sort_SORT_NEWEST,
+		CreatedBefore: createdBefore,
+	})
+	if err != nil {
+		return err
+	}
+	if reviews.StatusCode != fiber.StatusOK {
+		return ctx.Status(int(reviews.StatusCode)).JSON(reviews)
+	}
+
+	bookLimit := min(limit+len(cursor.Books), sourceMaxLimit)
+	books, err := aa.userBooks(ctx, &bookpb.GetByUserIdRequest{
+		UserId:        int64(userID),
+		Limit:         int32(bookLimit),
+		CreatedBefore: createdBefore,
+	})
+	if err != nil {
+		return err
+	}
+	if books.StatusCode != fiber.StatusOK {
+		return ctx.Status(int(books.StatusCode)).JSON(books)
+	}
+
+	more := len(reviews.Reviews) == reviewLimit || len(books.Items) == bookLimit
+	return ctx.JSON(mergeActivity(reviews.Reviews, books.Items, cursor, limit, more))
+}
+
+func (aa ActivityAPI) userBooks(ctx *fiber.Ctx, req *bookpb.GetByUserIdRequest) (*bookpb.GetByUserIdResponse, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respMsg, err := natsRequest(ctx, aa.conn, bookInfoGetAllByUserIdSubj, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp bookpb.GetByUserIdResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// mergeActivity merges the reviews and the books, both newest first, into
+// a page of up to limit items after the cursor. Reviews go first among the
+// items created in the same second. more tells whether the services have
+// items beyond the given ones.
+func mergeActivity(
+	reviews []*reviewpb.ReviewItem,
+	books []*bookpb.UserBookItem,
+	cursor activityCursor,
+	limit int,
+	more bool,
+) activityPage {
+	seen := func(createdAt, id int64, ids []int64) bool {
+		return createdAt == cursor.Before && slices.Contains(ids, id)
+	}
+	reviews = slices.DeleteFunc(slices.Clone(reviews), func(r *reviewpb.ReviewItem) bool {
+		return seen(r.CreatedAt, r.Id, cursor.Reviews)
+	})
+	books = slices.DeleteFunc(slices.Clone(books), func(b *bookpb.UserBookItem) bool {
+		return seen(b.CreatedAt, b.Id, cursor.Books)
+	})
+
+	page := activityPage{Items: make([]activityItem, 0, limit)}
+	for len(page.Items) < limit && (len(reviews) > 0 || len(books) > 0) {
+		if len(books) == 0 || len(reviews) > 0 && reviews[0].CreatedAt >= books[0].CreatedAt {
+			page.Items = append(page.Items, activityItem{
+				Type:      activityReview,
+				CreatedAt: reviews[0].CreatedAt,
+				Review:    reviews[0],
+			})
+			reviews = reviews[1:]
+		} else {
+			page.Items = append(page.Items, activityItem{
+				Type:      activityBook,
+				CreatedAt: books[0].CreatedAt,
+				Book:      books[0],
+			})
+			books = books[1:]
+		}
+	}
+
+	if len(page.Items) == 0 || !more && len(reviews) == 0 && len(books) == 0 {
+		return page
+	}
+
+	next := activityCursor{Before: page.Items[len(page.Items)-1].CreatedAt}
+	if next.Before == cursor.Before {
+		next.Reviews = slices.Clone(cursor.Reviews)
+		next.Books = slices.Clone(cursor.Books)
+	}
+	for _, item := range page.Items {
+		if item.CreatedAt != next.Before {
+			continue
+		}
+		switch item.Type {
+		case activityReview:
+			next.Reviews = append(next.Reviews, item.Review.Id)
+		case activityBook:
+			next.Books = append(next.Books, item.Book.Id)
+		}
+	}
+	page.NextCursor = next.String()
+
+	return page
+}
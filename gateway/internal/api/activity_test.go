@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bookpb "github.com/lunn06/library/gateway/internal/api/proto/book"
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+)
+
+func activityIDs(page activityPage) []string {
+	ids := make([]string, len(page.Items))
+	for i, item := range page.Items {
+		switch item.Type {
+		case activityReview:
+			ids[i] = "r" + string(rune('0'+item.Review.Id))
+		case activityBook:
+			ids[i] = "b" + string(rune('0'+item.Book.Id))
+		}
+	}
+	return ids
+}
+
+func TestMergeActivity(t *testing.T) {
+	reviews := []*reviewpb.ReviewItem{
+		{Id: 3, CreatedAt: 50},
+		{Id: 2, CreatedAt: 30},
+		{Id: 1, CreatedAt: 30},
+	}
+	books := []*bookpb.UserBookItem{
+		{Id: 2, CreatedAt: 40},
+		{Id: 1, CreatedAt: 30},
+	}
+
+	page := mergeActivity(reviews, books, activityCursor{}, 3, false)
+	assert.Equal(t, []string{"r3", "b2", "r2"}, activityIDs(page))
+	require.NotEmpty(t, page.NextCursor)
+
+	cursor, err := parseActivityCursor(page.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, activityCursor{Before: 30, Reviews: []int64{2}}, cursor)
+
+	// the services return the items of the second of the cursor again
+	page = mergeActivity(reviews[1:], books[1:], cursor, 3, false)
+	assert.Equal(t, []string{"r1", "b1"}, activityIDs(page))
+	assert.Empty(t, page.NextCursor)
+
+	page = mergeActivity(reviews[1:], books[1:], cursor, 1, false)
+	assert.Equal(t, []string{"r1"}, activityIDs(page))
+	cursor, err = parseActivityCursor(page.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, activityCursor{Before: 30, Reviews: []int64{2, 1}}, cursor)
+
+	page = mergeActivity(nil, nil, activityCursor{}, 3, false)
+	assert.Empty(t, page.Items)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestActivityItemJSON(t *testing.T) {
+	data, err := json.Marshal(activityItem{
+		Type:      activityBook,
+		CreatedAt: 30,
+		Book:      &bookpb.UserBookItem{Id: 1, Title: "The Master and Margarita", CreatedAt: 30},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "book",
+		"created_at": 30,
+		"book": {"id": "1", "title": "The Master and Margarita", "createdAt": "30"}
+	}`, string(data))
+}
+
+func TestParseActivityCursor(t *testing.T) {
+	_, err := parseActivityCursor("not a cursor")
+	assert.Error(t, err)
+
+	cursor, err := parseActivityCursor("")
+	require.NoError(t, err)
+	assert.Zero(t, cursor)
+}
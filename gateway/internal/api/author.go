@@ -57,7 +57,7 @@ func (ai AuthorAPI) Search(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ai.conn.RequestWithContext(ctx.Context(), authorSearchSubj, data)
+	respMsg, err := natsRequest(ctx, ai.conn, authorSearchSubj, data)
 	if err != nil {
 		return err
 	}
@@ -81,7 +81,7 @@ func (ai AuthorAPI) Get(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ai.conn.RequestWithContext(ctx.Context(), authorGetSubj, data)
+	respMsg, err := natsRequest(ctx, ai.conn, authorGetSubj, data)
 	if err != nil {
 		return err
 	}
@@ -105,7 +105,7 @@ func (ai AuthorAPI) Put(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ai.conn.RequestWithContext(ctx.Context(), authorPutSubj, data)
+	respMsg, err := natsRequest(ctx, ai.conn, authorPutSubj, data)
 	if err != nil {
 		return err
 	}
@@ -129,7 +129,7 @@ func (ai AuthorAPI) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ai.conn.RequestWithContext(ctx.Context(), authorUpdateSubj, data)
+	respMsg, err := natsRequest(ctx, ai.conn, authorUpdateSubj, data)
 	if err != nil {
 		return err
 	}
@@ -153,7 +153,7 @@ func (ai AuthorAPI) Delete(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ai.conn.RequestWithContext(ctx.Context(), authorDeleteSubj, data)
+	respMsg, err := natsRequest(ctx, ai.conn, authorDeleteSubj, data)
 	if err != nil {
 		return err
 	}
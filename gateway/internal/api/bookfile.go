@@ -2,14 +2,24 @@ package api
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/docker/docker/pkg/ioutils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/lunn06/library/bookfile/client"
 	"github.com/lunn06/library/gateway/internal/api/nats"
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+	"github.com/lunn06/library/gateway/internal/api/server"
 
 	bookfilepb "github.com/lunn06/library/gateway/internal/api/proto/bookfile"
 )
@@ -42,23 +52,38 @@ import (
 //	StatusCode int    `json:"status_code"`
 //}
 
+const (
+	mimeImageJPEG     = "image/jpeg"
+	mimeImageWebP     = "image/webp"
+	defaultCoverSize  = "medium"
+	coverCacheControl = "public, max-age=86400"
+)
+
 func NewBookFileClient(config nats.Config) (*client.Client, error) {
 	return client.New(client.Config{URL: config.URL})
 }
 
-func NewBookFileAPI(client *client.Client) BookFileAPI {
-	return BookFileAPI{client: client}
+func NewBookFileAPI(client *client.Client, limiter *ratelimit.Limiter, cfg BookFileConfig) BookFileAPI {
+	return BookFileAPI{
+		client:  client,
+		limiter: limiter,
+		maxSize: cfg.MaxSize,
+	}
 }
 
 type BookFileAPI struct {
-	client *client.Client
+	client  *client.Client
+	limiter *ratelimit.Limiter
+	maxSize int64
 }
 
 func (bi BookFileAPI) Register(router fiber.Router) {
 	router.
 		Get("/book/file/:uuid", bi.Get).
-		Post("/book/file", bi.Put).
-		Delete("/book/file/:uuid", bi.Delete)
+		Get("/book/file/:uuid/metadata", bi.Metadata).
+		Get("/book/file/:uuid/cover", bi.Cover).
+		Head("/book/file/sha256/:digest", bi.Exists).
+		Post("/book/file", bi.Put)
 }
 
 func (bi BookFileAPI) Get(ctx *fiber.Ctx) error {
@@ -74,13 +99,69 @@ func (bi BookFileAPI) Get(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	if ctx.Accepts(fiber.MIMEOctetStream, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+		return bi.getJSON(ctx, bookUUID)
+	}
+
+	book, err := bi.client.Info(ctx.Context(), bookUUID)
+	if err != nil {
+		return err
+	}
+
+	etag := strconv.Quote(book.Digest)
+	lastModified := book.ModTime.UTC().Truncate(time.Second)
+
+	ctx.Attachment(book.FileName)
+	if mimeType := book.Format.MIMEType(); mimeType != "" {
+		ctx.Set(fiber.HeaderContentType, mimeType)
+	}
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	ctx.Set(fiber.HeaderETag, etag)
+	ctx.Set(fiber.HeaderLastModified, lastModified.Format(http.TimeFormat))
+
+	if notModified(ctx.Get(fiber.HeaderIfNoneMatch), ctx.Get(fiber.HeaderIfModifiedSince), etag, lastModified) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	if ctx.Get(fiber.HeaderRange) == "" || !rangeApplies(ctx.Get(fiber.HeaderIfRange), etag, lastModified) {
+		return bi.sendFile(ctx, bookUUID)
+	}
+
+	rng, err := ctx.Range(int(book.Size))
+	if errors.Is(err, fiber.ErrRangeUnsatisfiable) {
+		ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", book.Size))
+		return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+	if err != nil || rng.Type != "bytes" || len(rng.Ranges) != 1 {
+		return bi.sendFile(ctx, bookUUID)
+	}
+
+	offset, end := uint64(rng.Ranges[0].Start), uint64(rng.Ranges[0].End)
+	book, err = bi.client.GetRange(ctx.Context(), bookUUID, offset, end-offset+1)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, end, book.Size))
+	return ctx.Status(fiber.StatusPartialContent).SendStream(&book, int(end-offset+1))
+}
+
+func (bi BookFileAPI) sendFile(ctx *fiber.Ctx, bookUUID uuid.UUID) error {
 	book, err := bi.client.Get(ctx.Context(), bookUUID)
 	if err != nil {
 		return err
 	}
-	defer book.Close()
 
-	//return ctx.SendStream(book.FileReader)
+	// fasthttp closes the stream once the body is written
+	return ctx.Status(http.StatusOK).SendStream(&book, int(book.Size))
+}
+
+func (bi BookFileAPI) getJSON(ctx *fiber.Ctx, bookUUID uuid.UUID) error {
+	book, err := bi.client.Get(ctx.Context(), bookUUID)
+	if err != nil {
+		return err
+	}
+	defer book.Close()
 
 	bookBytes := &bytes.Buffer{}
 	if _, err = bookBytes.ReadFrom(&book); err != nil {
@@ -90,49 +171,58 @@ func (bi BookFileAPI) Get(ctx *fiber.Ctx) error {
 		BookUuid: book.UUID.String(),
 		FileName: book.FileName,
 		File:     bookBytes.Bytes(),
+		Format:   string(book.Format),
+		MimeType: book.Format.MIMEType(),
 	})
 }
 
-func (bi BookFileAPI) Put(ctx *fiber.Ctx) error {
-	//var req CreateRequest
-	//if err := ctx.BodyParser(&req); err != nil {
-	//	return err
-	//}
-
-	mp, err := ctx.MultipartForm()
-	if err != nil {
+// Metadata suggests the fields of a bookinfo CreateRequest
+// from what the file tells about itself.
+func (bi BookFileAPI) Metadata(ctx *fiber.Ctx) error {
+	var params struct {
+		UUID string `params:"uuid"`
+	}
+	if err := ctx.ParamsParser(&params); err != nil {
 		return err
 	}
 
-	fileData, ok := mp.Value["book"]
-	if !ok {
-		return ctx.SendStatus(fiber.StatusBadRequest)
+	bookUUID, err := uuid.Parse(params.UUID)
+	if err != nil {
+		return err
 	}
 
-	fileName, ok := mp.Value["filename"]
-	if !ok {
-		return ctx.SendStatus(fiber.StatusBadRequest)
+	book, err := bi.client.Info(ctx.Context(), bookUUID)
+	if client.IsErrResourceNotFound(err) {
+		return ctx.Status(fiber.StatusNotFound).JSON(&bookfilepb.MetadataResponse{
+			StatusCode: fiber.StatusNotFound,
+		})
 	}
-
-	bookUUID, err := bi.client.Create(ctx.Context(),
-		fileName[0],
-		ioutils.NewReadCloserWrapper(
-			strings.NewReader(fileData[0]),
-			func() error {
-				return nil
-			},
-		),
-	)
 	if err != nil {
 		return err
 	}
 
-	return ctx.Status(http.StatusOK).JSON(&bookfilepb.CreateResponse{
-		BookUuid: bookUUID.String(),
+	var coverURL string
+	if book.Metadata.HasCover {
+		coverURL = "/book/file/" + book.UUID.String() + "/cover"
+	}
+
+	return ctx.Status(http.StatusOK).JSON(&bookfilepb.MetadataResponse{
+		BookUuid:    book.UUID.String(),
+		Title:       book.Metadata.Title,
+		Creators:    book.Metadata.Creators,
+		Language:    book.Metadata.Language,
+		Publisher:   book.Metadata.Publisher,
+		Isbn:        book.Metadata.ISBN,
+		Description: book.Metadata.Description,
+		BookUrl:     "/book/file/" + book.UUID.String(),
+		StatusCode:  http.StatusOK,
+		CoverUrl:    coverURL,
 	})
 }
 
-func (bi BookFileAPI) Delete(ctx *fiber.Ctx) error {
+// Cover serves a thumbnail of the book cover, WebP only for clients
+// asking for it explicitly.
+func (bi BookFileAPI) Cover(ctx *fiber.Ctx) error {
 	var params struct {
 		UUID string `params:"uuid"`
 	}
@@ -145,11 +235,169 @@ func (bi BookFileAPI) Delete(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	if err = bi.client.Delete(ctx.Context(), bookUUID); err != nil {
+	mimeType := ctx.Accepts(mimeImageJPEG, mimeImageWebP)
+	if mimeType == "" {
+		return ctx.SendStatus(fiber.StatusNotAcceptable)
+	}
+
+	cover, err := bi.client.Cover(ctx.Context(), bookUUID, ctx.Query("size", defaultCoverSize), mimeType)
+	if client.IsErrResourceNotFound(err) {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+	if err != nil {
 		return err
 	}
 
-	return ctx.Status(http.StatusOK).JSON(&bookfilepb.DeleteResponse{
+	etag := strconv.Quote(cover.Digest)
+	lastModified := cover.ModTime.UTC().Truncate(time.Second)
+
+	ctx.Vary(fiber.HeaderAccept)
+	ctx.Set(fiber.HeaderContentType, cover.MIMEType)
+	ctx.Set(fiber.HeaderETag, etag)
+	ctx.Set(fiber.HeaderLastModified, lastModified.Format(http.TimeFormat))
+	ctx.Set(fiber.HeaderCacheControl, coverCacheControl)
+
+	if notModified(ctx.Get(fiber.HeaderIfNoneMatch), ctx.Get(fiber.HeaderIfModifiedSince), etag, lastModified) {
+		_ = cover.Close()
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	return ctx.Status(http.StatusOK).SendStream(&cover, int(cover.Length))
+}
+
+// Exists lets clients skip uploading a file the storage already has.
+func (bi BookFileAPI) Exists(ctx *fiber.Ctx) error {
+	var params struct {
+		Digest string `params:"digest"`
+	}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	if sum, err := hex.DecodeString(params.Digest); err != nil || len(sum) != sha256.Size {
+		return ctx.SendStatus(fiber.StatusBadRequest)
+	}
+
+	book, err := bi.client.FindByDigest(ctx.Context(), strings.ToLower(params.Digest))
+	if client.IsErrResourceNotFound(err) {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentLocation, "/book/file/"+book.UUID.String())
+	ctx.Set(fiber.HeaderETag, strconv.Quote(book.Digest))
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+// Put stores the file uploaded by the authenticated user, the files are
+// deleted with the books referring to them.
+func (bi BookFileAPI) Put(ctx *fiber.Ctx) error {
+	if _, err := server.RequireUser(ctx); err != nil {
+		return err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(ctx.Get(fiber.HeaderContentType))
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusUnsupportedMediaType)
+	}
+
+	switch mediaType {
+	case fiber.MIMEMultipartForm:
+		return bi.putMultipart(ctx, params["boundary"])
+	case fiber.MIMEOctetStream:
+		return bi.putRaw(ctx)
+	default:
+		return ctx.SendStatus(fiber.StatusUnsupportedMediaType)
+	}
+}
+
+func (bi BookFileAPI) putMultipart(ctx *fiber.Ctx, boundary string) error {
+	if boundary == "" {
+		return ctx.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var fileName string
+	mr := multipart.NewReader(requestBody(ctx), boundary)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return ctx.SendStatus(fiber.StatusBadRequest)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch part.FormName() {
+		case "filename":
+			name, err := io.ReadAll(io.LimitReader(part, maxFileNameLen))
+			if err != nil {
+				return err
+			}
+			fileName = string(name)
+		case "book":
+			if fileName == "" {
+				fileName = part.FileName()
+			}
+			return bi.create(ctx, fileName, part)
+		}
+	}
+}
+
+func (bi BookFileAPI) putRaw(ctx *fiber.Ctx) error {
+	if ctx.Request().Header.ContentLength() > int(bi.maxSize) {
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(&bookfilepb.CreateResponse{
+			StatusCode: fiber.StatusRequestEntityTooLarge,
+		})
+	}
+
+	var fileName string
+	if _, params, err := mime.ParseMediaType(ctx.Get(fiber.HeaderContentDisposition)); err == nil {
+		fileName = params["filename"]
+	}
+
+	return bi.create(ctx, fileName, requestBody(ctx))
+}
+
+func (bi BookFileAPI) create(ctx *fiber.Ctx, fileName string, r io.Reader) error {
+	quota, err := bi.limiter.QuotaReader(ctx, r)
+	if quotaExceeded(ctx, err) {
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(&bookfilepb.CreateResponse{
+			StatusCode: fiber.StatusTooManyRequests,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	book, err := bi.client.Create(ctx.Context(),
+		fileName,
+		io.NopCloser(newMaxBytesReader(quota, bi.maxSize)),
+	)
+	chargeQuota(ctx, quota)
+	if quotaExceeded(ctx, err) {
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(&bookfilepb.CreateResponse{
+			StatusCode: fiber.StatusTooManyRequests,
+		})
+	}
+	if errors.Is(err, errBookFileTooLarge) {
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(&bookfilepb.CreateResponse{
+			StatusCode: fiber.StatusRequestEntityTooLarge,
+		})
+	}
+	if client.IsErrInvalidFormat(err) {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(&bookfilepb.CreateResponse{
+			StatusCode: fiber.StatusUnprocessableEntity,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(http.StatusOK).JSON(&bookfilepb.CreateResponse{
+		BookUuid:   book.UUID.String(),
 		StatusCode: http.StatusOK,
+		Digest:     book.Digest,
 	})
 }
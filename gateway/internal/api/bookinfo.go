@@ -7,6 +7,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	bookpb "github.com/lunn06/library/gateway/internal/api/proto/book"
+	"github.com/lunn06/library/gateway/internal/api/server"
 )
 
 const (
@@ -57,7 +58,7 @@ func (bi BookInfoAPI) Search(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := bi.conn.RequestWithContext(ctx.Context(), bookInfoSearchSubj, data)
+	respMsg, err := natsRequest(ctx, bi.conn, bookInfoSearchSubj, data)
 	if err != nil {
 		return err
 	}
@@ -81,7 +82,7 @@ func (bi BookInfoAPI) Get(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := bi.conn.RequestWithContext(ctx.Context(), bookInfoGetSubj, data)
+	respMsg, err := natsRequest(ctx, bi.conn, bookInfoGetSubj, data)
 	if err != nil {
 		return err
 	}
@@ -94,18 +95,25 @@ func (bi BookInfoAPI) Get(ctx *fiber.Ctx) error {
 	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
 }
 
+// Put adds the book on behalf of the authenticated user.
 func (bi BookInfoAPI) Put(ctx *fiber.Ctx) error {
 	var req bookpb.CreateRequest
 	if err := ctx.BodyParser(&req); err != nil {
 		return err
 	}
 
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	req.UserId = userID
+
 	data, err := proto.Marshal(&req)
 	if err != nil {
 		return err
 	}
 
-	respMsg, err := bi.conn.RequestWithContext(ctx.Context(), bookInfoPutSubj, data)
+	respMsg, err := natsRequest(ctx, bi.conn, bookInfoPutSubj, data)
 	if err != nil {
 		return err
 	}
@@ -124,12 +132,18 @@ func (bi BookInfoAPI) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	req.UserId = userID
+
 	data, err := proto.Marshal(&req)
 	if err != nil {
 		return err
 	}
 
-	respMsg, err := bi.conn.RequestWithContext(ctx.Context(), bookInfoUpdateSubj, data)
+	respMsg, err := natsRequest(ctx, bi.conn, bookInfoUpdateSubj, data)
 	if err != nil {
 		return err
 	}
@@ -153,7 +167,7 @@ func (bi BookInfoAPI) Delete(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := bi.conn.RequestWithContext(ctx.Context(), bookInfoDeleteSubj, data)
+	respMsg, err := natsRequest(ctx, bi.conn, bookInfoDeleteSubj, data)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+const (
+	commentGetSubj    = "review.comment.get"
+	commentListSubj   = "review.comment.list"
+	commentPutSubj    = "review.comment.put"
+	commentUpdateSubj = "review.comment.update"
+	commentDeleteSubj = "review.comment.delete"
+)
+
+func NewCommentAPI(conn *nats.Conn) CommentAPI {
+	return CommentAPI{conn: conn}
+}
+
+// CommentAPI serves the threads of comments on reviews.
+type CommentAPI struct {
+	conn *nats.Conn
+}
+
+func (ca CommentAPI) Register(router fiber.Router) {
+	router.
+		Get("/review/:id/comments", ca.List).
+		Name(commentListSubj).
+		Post("/review/:id/comments", ca.Put).
+		Name(commentPutSubj).
+		Get("/review/:id/comments/:commentId", ca.Get).
+		Name(commentGetSubj).
+		Patch("/review/:id/comments/:commentId", ca.Update).
+		Name(commentUpdateSubj).
+		Delete("/review/:id/comments/:commentId", ca.Delete).
+		Name(commentDeleteSubj)
+}
+
+// List returns a page of the thread of the review, oldest first. The query
+// may set limit and after_id, the next_after_id of the previous page.
+func (ca CommentAPI) List(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var params struct {
+		AfterID int64 `query:"after_id"`
+		Limit   int32 `query:"limit"`
+	}
+	if err = ctx.QueryParser(&params); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var resp reviewpb.ListCommentsResponse
+	err = ca.request(ctx, commentListSubj, &reviewpb.ListCommentsRequest{
+		ReviewId: int64(reviewID),
+		AfterId:  params.AfterID,
+		Limit:    params.Limit,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Put replies to the review, or to the comment given as parent_id.
+func (ca CommentAPI) Put(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req reviewpb.CreateCommentRequest
+	if err = ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.ReviewId = int64(reviewID)
+	req.UserId = userID
+
+	var resp reviewpb.CreateCommentResponse
+	if err = ca.request(ctx, commentPutSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ca CommentAPI) Get(ctx *fiber.Ctx) error {
+	reviewID, commentID, err := commentParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	var resp reviewpb.GetCommentResponse
+	err = ca.request(ctx, commentGetSubj, &reviewpb.GetCommentRequest{
+		ReviewId:  reviewID,
+		CommentId: commentID,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ca CommentAPI) Update(ctx *fiber.Ctx) error {
+	reviewID, commentID, err := commentParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req reviewpb.UpdateCommentRequest
+	if err = ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.ReviewId = reviewID
+	req.CommentId = commentID
+
+	var resp reviewpb.EmptyResponse
+	if err = ca.request(ctx, commentUpdateSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ca CommentAPI) Delete(ctx *fiber.Ctx) error {
+	reviewID, commentID, err := commentParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	var resp reviewpb.EmptyResponse
+	err = ca.request(ctx, commentDeleteSubj, &reviewpb.DeleteCommentRequest{
+		ReviewId:  reviewID,
+		CommentId: commentID,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ca CommentAPI) request(ctx *fiber.Ctx, subj string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ca.conn, subj, data)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(respMsg.Data, resp)
+}
+
+func commentParams(ctx *fiber.Ctx) (int64, int64, error) {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return 0, 0, fiber.ErrBadRequest
+	}
+	commentID, err := ctx.ParamsInt("commentId")
+	if err != nil {
+		return 0, 0, fiber.ErrBadRequest
+	}
+
+	return int64(reviewID), int64(commentID), nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	const etag = `"SHA-256=abc"`
+	lastModified := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, notModified(etag, "", etag, lastModified))
+	assert.True(t, notModified(`"other", W/"SHA-256=abc"`, "", etag, lastModified))
+	assert.True(t, notModified("*", "", etag, lastModified))
+	assert.False(t, notModified(`"other"`, lastModified.Format(http.TimeFormat), etag, lastModified))
+
+	assert.True(t, notModified("", lastModified.Format(http.TimeFormat), etag, lastModified))
+	assert.False(t, notModified("", lastModified.Add(-time.Hour).Format(http.TimeFormat), etag, lastModified))
+	assert.False(t, notModified("", "garbage", etag, lastModified))
+	assert.False(t, notModified("", "", etag, lastModified))
+}
+
+func TestRangeApplies(t *testing.T) {
+	const etag = `"SHA-256=abc"`
+	lastModified := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, rangeApplies("", etag, lastModified))
+	assert.True(t, rangeApplies(etag, etag, lastModified))
+	assert.False(t, rangeApplies(`"other"`, etag, lastModified))
+	assert.True(t, rangeApplies(lastModified.Format(http.TimeFormat), etag, lastModified))
+	assert.False(t, rangeApplies(lastModified.Add(time.Hour).Format(http.TimeFormat), etag, lastModified))
+}
@@ -0,0 +1,5 @@
+package api
+
+type BookFileConfig struct {
+	MaxSize int64 `default:"536870912" split_words:"true"`
+}
@@ -0,0 +1,801 @@
+package api
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/lunn06/library/gateway/internal/api/openapi"
+	authorpb "github.com/lunn06/library/gateway/internal/api/proto/author"
+	bookpb "github.com/lunn06/library/gateway/internal/api/proto/book"
+	bookfilepb "github.com/lunn06/library/gateway/internal/api/proto/bookfile"
+	genrepb "github.com/lunn06/library/gateway/internal/api/proto/genre"
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+	userpb "github.com/lunn06/library/gateway/internal/api/proto/user"
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+const (
+	docsSpecPath   = "/openapi.json"
+	docsUIPath     = "/docs"
+	docsScriptPath = "/docs/redoc.standalone.js"
+
+	docsTitle       = "Library"
+	docsVersion     = "1.0.0"
+	docsDescription = `The gateway of the library services.
+
+Most responses carry a statusCode, the same as the one of the response.
+The errors of the gateway itself, such as 401 for an invalid token, come as
+text.
+
+Requests with a bearer token act on behalf of its user, the routes marked
+with it require one.
+
+Clients are rate limited per route group, the responses tell the state of
+their limit in the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+RateLimit-Policy headers. Requests over the limit fail with 429 and a
+Retry-After header. Clients are identified by the ` + ratelimit.HeaderAPIKey + ` header,
+their user or their address. Requests with a token are limited by their
+failures with 401 too, past the limit they fail with 429 whatever the token.
+
+The 64-bit integers of the responses are strings, the ones of the request
+bodies are numbers.`
+)
+
+func NewDocsAPI(server *server.Server) DocsAPI {
+	return DocsAPI{
+		spec: sync.OnceValues(func() ([]byte, error) {
+			return json.Marshal(newSpec(server.Routes()))
+		}),
+	}
+}
+
+// DocsAPI serves the OpenAPI document of the routes and a page to browse
+// it.
+type DocsAPI struct {
+	spec func() ([]byte, error)
+}
+
+func (da DocsAPI) Register(router fiber.Router) {
+	router.
+		Get(docsSpecPath, da.Spec).
+		Get(docsUIPath, openapi.UI(docsTitle, docsSpecPath, docsScriptPath)).
+		Get(docsScriptPath, openapi.Script())
+}
+
+// Spec returns the document, built on the first request once all the
+// routes are registered.
+func (da DocsAPI) Spec(ctx *fiber.Ctx) error {
+	data, err := da.spec()
+	if err != nil {
+		return err
+	}
+
+	ctx.Type("json")
+	return ctx.Send(data)
+}
+
+// newSpec documents the routes described in routeDocs, the ones missing
+// from it are left out.
+func newSpec(routes []fiber.Route) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       docsTitle,
+		Description: docsDescription,
+		Version:     docsVersion,
+	})
+
+	docs := make(map[string]routeDoc)
+	for _, group := range routeDocs {
+		for _, rd := range group.routes {
+			rd.tag = group.tag
+			docs[rd.method+" "+rd.path] = rd
+		}
+	}
+
+	gets := make(map[string]bool)
+	for _, route := range routes {
+		if route.Method == fiber.MethodGet {
+			gets[route.Path] = true
+		}
+	}
+
+	for _, route := range routes {
+		// the router answers HEAD requests to GET routes as well
+		if route.Method == fiber.MethodHead && gets[route.Path] {
+			continue
+		}
+		if rd, ok := docs[route.Method+" "+route.Path]; ok {
+			doc.Add(route.Method, route.Path, rd.operation(doc))
+		}
+	}
+
+	return doc
+}
+
+// routeDoc describes a route, the parameters of its path are documented
+// from their names.
+type routeDoc struct {
+	method, path string
+	summary      string
+	description  string
+	tag          string
+	// auth tells the route requires a bearer token.
+	auth bool
+	// pathParams overrides the schemas of the path parameters.
+	pathParams map[string]*openapi.Schema
+	// params are the query and header parameters.
+	params []openapi.Parameter
+
+	// request is decoded from the JSON body, without the omitted fields
+	// the gateway sets itself.
+	request proto.Message
+	omit    []string
+	// body describes the other bodies.
+	body *openapi.RequestBody
+
+	// status is the one of a successful response, 200 by default.
+	status int
+	// response is the JSON of the response.
+	response proto.Message
+	// content describes the other responses.
+	content func(doc *openapi.Document) map[string]openapi.MediaType
+	headers map[string]openapi.Header
+}
+
+func (rd routeDoc) operation(doc *openapi.Document) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:     rd.summary,
+		Description: rd.description,
+		Tags:        []string{rd.tag},
+		Parameters:  slices.Clone(rd.params),
+	}
+	if rd.auth {
+		op.Security = []map[string][]string{{openapi.BearerAuth: {}}}
+	}
+
+	_, names := openapi.Path(rd.path)
+	pathParams := make([]openapi.Parameter, len(names))
+	for i, name := range names {
+		schema, ok := rd.pathParams[name]
+		if !ok {
+			schema = pathParamSchema(name)
+		}
+		pathParams[i] = openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema}
+	}
+	op.Parameters = append(pathParams, op.Parameters...)
+
+	switch {
+	case rd.request != nil:
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				openapi.MediaJSON: {Schema: openapi.Request(rd.request, rd.omit...)},
+			},
+		}
+	case rd.body != nil:
+		op.RequestBody = rd.body
+	}
+
+	status := rd.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := openapi.Response{
+		Description: http.StatusText(status),
+		Headers:     rd.headers,
+	}
+	failure := openapi.Response{
+		Description: "The error, as text when it is the one of the gateway",
+		Content: map[string]openapi.MediaType{
+			openapi.MediaText: {Schema: openapi.String("")},
+		},
+	}
+	switch {
+	case rd.response != nil:
+		schema := doc.Response(rd.response)
+		success.Content = map[string]openapi.MediaType{openapi.MediaJSON: {Schema: schema}}
+		failure.Content[openapi.MediaJSON] = openapi.MediaType{Schema: schema}
+	case rd.content != nil:
+		success.Content = rd.content(doc)
+	}
+	op.Responses = map[string]openapi.Response{
+		strconv.Itoa(status): success,
+		"default":            failure,
+	}
+
+	return op
+}
+
+// pathParamSchema tells the schema of a path parameter from its name, the
+// ids are integers.
+func pathParamSchema(name string) *openapi.Schema {
+	switch {
+	case name == "uuid":
+		return openapi.String("uuid")
+	case name == "id" || strings.HasSuffix(name, "Id"):
+		return openapi.Integer("int64")
+	default:
+		return openapi.String("")
+	}
+}
+
+func queryParam(name string, schema *openapi.Schema, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Schema: schema, Description: description}
+}
+
+func headerParam(name string, required bool, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "header",
+		Required:    required,
+		Schema:      openapi.String(""),
+		Description: description,
+	}
+}
+
+func enumSchema[V any](values map[string]V) *openapi.Schema {
+	schema := openapi.String("")
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		schema.Enum = append(schema.Enum, name)
+	}
+	return schema
+}
+
+func binaryContent(mediaTypes ...string) func(*openapi.Document) map[string]openapi.MediaType {
+	return func(*openapi.Document) map[string]openapi.MediaType {
+		content := make(map[string]openapi.MediaType, len(mediaTypes))
+		for _, mediaType := range mediaTypes {
+			content[mediaType] = openapi.MediaType{Schema: openapi.String("binary")}
+		}
+		return content
+	}
+}
+
+func stringHeader(description string) openapi.Header {
+	return openapi.Header{Description: description, Schema: openapi.String("")}
+}
+
+var (
+	pageLimitParam  = queryParam("limit", openapi.Integer("int32"), "The size of the page")
+	pageCursorParam = queryParam("cursor", openapi.String(""), "The cursor of the next page, from the previous one")
+
+	reviewListParams = []openapi.Parameter{
+		pageLimitParam,
+		pageCursorParam,
+		queryParam("sort", enumSchema(reviewSorts), "The order of the reviews, newest by default"),
+		queryParam("min_score", openapi.Integer("int32"), ""),
+		queryParam("max_score", openapi.Integer("int32"), ""),
+		queryParam("created_after", openapi.String("date-time"), ""),
+		queryParam("created_before", openapi.String("date-time"), ""),
+	}
+
+	tusResumableParam = headerParam(headerTusResumable, true, "The version of the protocol, "+tusVersion)
+	uploadIDParams    = map[string]*openapi.Schema{"id": openapi.String("uuid")}
+)
+
+// routeDocs documents the routes by API, TestSpecCoversRoutes fails for
+// the registered routes missing from it.
+var routeDocs = []struct {
+	tag    string
+	routes []routeDoc
+}{
+	{"books", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/book/search/:title",
+			summary:  "Search the books by title",
+			response: &bookpb.SearchResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/book/:id",
+			summary:  "Get a book",
+			response: &bookpb.GetResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/book",
+			summary: "Add a book",
+			auth:    true,
+			request: &bookpb.CreateRequest{}, omit: []string{"user_id"},
+			response: &bookpb.CreateResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/book/:id",
+			summary:     "Update a book",
+			description: "The id of the body tells the book, only its owner may update it.",
+			auth:        true,
+			request:     &bookpb.UpdateRequest{}, omit: []string{"user_id"},
+			response: &bookpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/book/:id",
+			summary:     "Delete a book",
+			description: "The book_id of the body tells the book, only its owner may delete it.",
+			auth:        true,
+			request:     &bookpb.DeleteRequest{},
+			response:    &bookpb.EmptyResponse{},
+		},
+	}},
+	{"authors", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/author/search/:name",
+			summary:  "Search the authors by name",
+			response: &authorpb.SearchResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/author/:id",
+			summary:  "Get an author",
+			response: &authorpb.GetResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/author",
+			summary:  "Add an author",
+			request:  &authorpb.CreateRequest{},
+			response: &authorpb.CreateResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/author/:id",
+			summary:     "Update an author",
+			description: "The id of the body tells the author, only moderators may update it.",
+			auth:        true,
+			request:     &authorpb.UpdateRequest{},
+			response:    &authorpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/author/:id",
+			summary:     "Delete an author",
+			description: "The author_id of the body tells the author, only moderators may delete it.",
+			auth:        true,
+			request:     &authorpb.DeleteRequest{},
+			response:    &authorpb.EmptyResponse{},
+		},
+	}},
+	{"genres", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/genre/search/:title",
+			summary:  "Search the genres by title",
+			response: &genrepb.SearchResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/genre/:id",
+			summary:  "Get a genre",
+			response: &genrepb.GetResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/genre",
+			summary:  "Add a genre",
+			request:  &genrepb.CreateRequest{},
+			response: &genrepb.CreateResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/genre/:id",
+			summary:     "Update a genre",
+			description: "The id of the body tells the genre, only moderators may update it.",
+			auth:        true,
+			request:     &genrepb.UpdateRequest{},
+			response:    &genrepb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/genre/:id",
+			summary:     "Delete a genre",
+			description: "The genre_id of the body tells the genre, only moderators may delete it.",
+			auth:        true,
+			request:     &genrepb.DeleteRequest{},
+			response:    &genrepb.EmptyResponse{},
+		},
+	}},
+	{"book files", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/book/file/:uuid",
+			summary: "Download a book file",
+			description: "Clients accepting only application/json get the file base64 encoded in the body. " +
+				"Ranges and conditional requests are supported.",
+			params: []openapi.Parameter{
+				headerParam(fiber.HeaderRange, false, "A single range of bytes"),
+				headerParam(fiber.HeaderIfNoneMatch, false, ""),
+				headerParam(fiber.HeaderIfModifiedSince, false, ""),
+			},
+			content: func(doc *openapi.Document) map[string]openapi.MediaType {
+				content := binaryContent(openapi.MediaOctetStream)(doc)
+				content[openapi.MediaJSON] = openapi.MediaType{Schema: doc.Response(&bookfilepb.GetResponse{})}
+				return content
+			},
+			headers: map[string]openapi.Header{
+				fiber.HeaderETag:         stringHeader("The quoted SHA-256 of the file"),
+				fiber.HeaderLastModified: stringHeader(""),
+			},
+		},
+		{
+			method: fiber.MethodGet, path: "/book/file/:uuid/metadata",
+			summary:     "Get the metadata of a book file",
+			description: "The fields of the metadata suggest the ones of a new book.",
+			response:    &bookfilepb.MetadataResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/book/file/:uuid/cover",
+			summary:     "Get the cover of a book file",
+			description: "The cover is WebP only for clients asking for it explicitly.",
+			params: []openapi.Parameter{
+				queryParam("size", &openapi.Schema{Type: openapi.TypeString, Enum: []any{"small", "medium", "large"}}, "The size of the thumbnail, "+defaultCoverSize+" by default"),
+			},
+			content: binaryContent(mimeImageJPEG, mimeImageWebP),
+		},
+		{
+			method: fiber.MethodHead, path: "/book/file/sha256/:digest",
+			summary:     "Find a book file by its SHA-256",
+			description: "Clients may skip uploading a file already stored, 404 tells it is not.",
+			pathParams:  map[string]*openapi.Schema{"digest": {Type: openapi.TypeString, Description: "The hex encoded SHA-256 of the file"}},
+			headers: map[string]openapi.Header{
+				fiber.HeaderContentLocation: stringHeader("The path of the file"),
+				fiber.HeaderETag:            stringHeader("The quoted SHA-256 of the file"),
+			},
+		},
+		{
+			method: fiber.MethodPost, path: "/book/file",
+			summary: "Upload a book file",
+			auth:    true,
+			description: "The file counts against the daily upload quota of the client, " +
+				"uploads over it fail with 429. Files over the size limit fail with 413.",
+			params: []openapi.Parameter{
+				headerParam(fiber.HeaderContentDisposition, false, "The file name of an octet stream body, as attachment; filename=..."),
+			},
+			body: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]openapi.MediaType{
+					fiber.MIMEMultipartForm: {Schema: &openapi.Schema{
+						Type: openapi.TypeObject,
+						Properties: map[string]*openapi.Schema{
+							"filename": {Type: openapi.TypeString, Description: "The file name, the one of the book part by default"},
+							"book":     openapi.String("binary"),
+						},
+						Required: []string{"book"},
+					}},
+					openapi.MediaOctetStream: {Schema: openapi.String("binary")},
+				},
+			},
+			response: &bookfilepb.CreateResponse{},
+		},
+	}},
+	{"uploads", []routeDoc{
+		{
+			method: fiber.MethodOptions, path: "/book/file/uploads",
+			summary:     "Get the capabilities of the resumable uploads",
+			description: "The uploads follow the tus protocol, see https://tus.io/protocols/resumable-upload.",
+			status:      http.StatusNoContent,
+			headers: map[string]openapi.Header{
+				headerTusVersion:   stringHeader(""),
+				headerTusExtension: stringHeader(""),
+				headerTusMaxSize:   {Schema: openapi.Integer("int64")},
+			},
+		},
+		{
+			method: fiber.MethodPost, path: "/book/file/uploads",
+			summary: "Create a resumable upload",
+			auth:    true,
+			params: []openapi.Parameter{
+				tusResumableParam,
+				headerParam(headerUploadLength, true, "The size of the file"),
+				headerParam(headerUploadMetadata, false, "The filename key gives the file name"),
+			},
+			status: http.StatusCreated,
+			headers: map[string]openapi.Header{
+				fiber.HeaderLocation: stringHeader("The path of the upload"),
+				headerUploadExpires:  stringHeader(""),
+			},
+		},
+		{
+			method: fiber.MethodHead, path: "/book/file/uploads/:id",
+			summary: "Get the offset of an upload",
+			description: "The file is assembled in the background once all of it is uploaded, " +
+				"Content-Location tells it is done. Files which are not books fail with 422.",
+			pathParams: uploadIDParams,
+			params:     []openapi.Parameter{tusResumableParam},
+			headers: map[string]openapi.Header{
+				headerUploadOffset:          {Schema: openapi.Integer("int64")},
+				headerUploadLength:          {Schema: openapi.Integer("int64")},
+				headerUploadExpires:         stringHeader("Until the file is assembled"),
+				headerUploadAssembled:       {Schema: openapi.Integer("int64"), Description: "The bytes of the file assembled so far"},
+				fiber.HeaderContentLocation: stringHeader("The path of the file once it is assembled"),
+			},
+		},
+		{
+			method: fiber.MethodPatch, path: "/book/file/uploads/:id",
+			summary:     "Append a part to an upload",
+			auth:        true,
+			description: "The part counts against the daily upload quota of the client, parts over it fail with 429.",
+			pathParams:  uploadIDParams,
+			params: []openapi.Parameter{
+				tusResumableParam,
+				headerParam(headerUploadOffset, true, "The offset of the part, the one of the upload"),
+			},
+			body: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]openapi.MediaType{
+					mimeOffsetOctetStream: {Schema: openapi.String("binary")},
+				},
+			},
+			status: http.StatusNoContent,
+			headers: map[string]openapi.Header{
+				headerUploadOffset:          {Schema: openapi.Integer("int64")},
+				headerUploadAssembled:       {Schema: openapi.Integer("int64"), Description: "The bytes of the file assembled so far"},
+				fiber.HeaderContentLocation: stringHeader("The path of the file once it is assembled"),
+			},
+		},
+		{
+			method: fiber.MethodDelete, path: "/book/file/uploads/:id",
+			summary:    "Cancel an upload",
+			auth:       true,
+			pathParams: uploadIDParams,
+			params:     []openapi.Parameter{tusResumableParam},
+			status:     http.StatusNoContent,
+		},
+	}},
+	{"reviews", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/reviews/book/:id",
+			summary:  "List the reviews of a book",
+			params:   reviewListParams,
+			response: &reviewpb.GetByBookIdResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/reviews/user/:id",
+			summary:  "List the reviews of a user",
+			params:   reviewListParams,
+			response: &reviewpb.GetByUserIdResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/reviews/book/:id/stats",
+			summary:  "Get the review stats of a book",
+			response: &reviewpb.BookStats{},
+		},
+		{
+			method: fiber.MethodGet, path: "/reviews/stats",
+			summary: "Get the review stats of several books",
+			params: []openapi.Parameter{
+				queryParam("book_ids", openapi.String(""), "The comma separated ids of the books"),
+			},
+			response: &reviewpb.StatsResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/review/:id",
+			summary:  "Get a review",
+			response: &reviewpb.GetResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/review",
+			summary: "Review a book",
+			auth:    true,
+			request: &reviewpb.CreateRequest{}, omit: []string{"user_id"},
+			response: &reviewpb.CreateResponse{},
+		},
+		{
+			method: fiber.MethodPut, path: "/review",
+			summary:     "Review a book or update the review",
+			description: "A user has a single review of a book.",
+			auth:        true,
+			request:     &reviewpb.CreateRequest{}, omit: []string{"user_id"},
+			response: &reviewpb.UpsertResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/review/:id",
+			summary:     "Update a review",
+			description: "The body tells the review, only its author may update it.",
+			auth:        true,
+			request:     &reviewpb.UpdateRequest{},
+			response:    &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/review/:id",
+			summary:     "Delete a review",
+			description: "The body tells the review, only its author and the moderators may delete it.",
+			auth:        true,
+			request:     &reviewpb.DeleteRequest{},
+			response:    &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodPut, path: "/review/:id/vote",
+			summary: "Vote whether a review is helpful",
+			auth:    true,
+			request: &reviewpb.VoteRequest{}, omit: []string{"review_id", "user_id"},
+			response: &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/review/:id/vote",
+			summary:  "Withdraw the vote on a review",
+			auth:     true,
+			response: &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/review/:id/history",
+			summary: "List the earlier versions of a review",
+			params: []openapi.Parameter{
+				pageLimitParam,
+				queryParam("after", openapi.Integer("int32"), "The next_after of the previous page"),
+			},
+			response: &reviewpb.HistoryResponse{},
+		},
+	}},
+	{"comments", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/review/:id/comments",
+			summary: "List the comments on a review",
+			params: []openapi.Parameter{
+				pageLimitParam,
+				queryParam("after_id", openapi.Integer("int64"), "The next_after_id of the previous page"),
+			},
+			response: &reviewpb.ListCommentsResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/review/:id/comments",
+			summary:     "Comment on a review",
+			description: "The comment replies to the review, or to the comment given as parent_id.",
+			auth:        true,
+			request:     &reviewpb.CreateCommentRequest{}, omit: []string{"review_id", "user_id"},
+			response: &reviewpb.CreateCommentResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/review/:id/comments/:commentId",
+			summary:  "Get a comment",
+			response: &reviewpb.GetCommentResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/review/:id/comments/:commentId",
+			summary:     "Update a comment",
+			description: "Only the author of the comment may update it.",
+			auth:        true,
+			request:     &reviewpb.UpdateCommentRequest{}, omit: []string{"review_id", "comment_id"},
+			response: &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/review/:id/comments/:commentId",
+			summary:     "Delete a comment",
+			description: "Only the author of the comment and the moderators may delete it.",
+			auth:        true,
+			response:    &reviewpb.EmptyResponse{},
+		},
+	}},
+	{"moderation", []routeDoc{
+		{
+			method: fiber.MethodPost, path: "/review/:id/report",
+			summary: "Report a review",
+			auth:    true,
+			body: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]openapi.MediaType{
+					openapi.MediaJSON: {Schema: &openapi.Schema{
+						Type: openapi.TypeObject,
+						Properties: map[string]*openapi.Schema{
+							"reason":  enumSchema(reportReasons),
+							"comment": openapi.String(""),
+						},
+					}},
+				},
+			},
+			response: &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodPut, path: "/review/:id/moderation",
+			summary: "Moderate a review",
+			auth:    true,
+			body: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]openapi.MediaType{
+					openapi.MediaJSON: {Schema: openapi.Object(map[string]*openapi.Schema{
+						"status": enumSchema(moderationStatuses),
+					})},
+				},
+			},
+			response: &reviewpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/reviews/moderation/queue",
+			summary:  "List the reviews waiting for a moderator",
+			auth:     true,
+			params:   []openapi.Parameter{pageLimitParam, pageCursorParam},
+			response: &reviewpb.QueueResponse{},
+		},
+	}},
+	{"activity", []routeDoc{
+		{
+			method: fiber.MethodGet, path: "/activity/user/:id",
+			summary:     "List what a user did",
+			description: "The reviews written and the books added by the user, newest first.",
+			params:      []openapi.Parameter{pageLimitParam, pageCursorParam},
+			content: func(doc *openapi.Document) map[string]openapi.MediaType {
+				item := openapi.Object(map[string]*openapi.Schema{
+					"type":       {Type: openapi.TypeString, Enum: []any{activityReview, activityBook}},
+					"created_at": openapi.Integer("int64"),
+				})
+				item.Properties[activityReview] = doc.Response(&reviewpb.ReviewItem{})
+				item.Properties[activityBook] = doc.Response(&bookpb.UserBookItem{})
+
+				page := openapi.Object(map[string]*openapi.Schema{
+					"items": {Type: openapi.TypeArray, Items: item},
+				})
+				page.Properties["next_cursor"] = &openapi.Schema{
+					Type:        openapi.TypeString,
+					Description: "Missing on the last page",
+				}
+
+				return map[string]openapi.MediaType{openapi.MediaJSON: {Schema: page}}
+			},
+		},
+	}},
+	{"users", []routeDoc{
+		{
+			method: fiber.MethodPost, path: "/auth/register",
+			summary:  "Register a user",
+			request:  &userpb.RegisterRequest{},
+			status:   http.StatusCreated,
+			response: &userpb.RegisterResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/auth/login",
+			summary:     "Log in",
+			description: "The access token is the bearer token of the other requests, the refresh token gets new ones.",
+			request:     &userpb.LoginRequest{},
+			response:    &userpb.TokenResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/auth/refresh",
+			summary:     "Refresh the tokens",
+			description: "The refresh token is replaced, using it again logs out the user everywhere.",
+			request:     &userpb.RefreshRequest{},
+			response:    &userpb.TokenResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/auth/logout",
+			summary:  "Log out",
+			request:  &userpb.LogoutRequest{},
+			response: &userpb.EmptyResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/user/me",
+			summary:     "Get the profile of the user",
+			description: "The profile has the email of the user.",
+			auth:        true,
+			response:    &userpb.GetResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/user/me",
+			summary: "Update the profile of the user",
+			auth:    true,
+			request: &userpb.UpdateRequest{}, omit: []string{"user_id"},
+			response: &userpb.GetResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/user/:id",
+			summary:  "Get the profile of a user",
+			response: &userpb.GetResponse{},
+		},
+	}},
+	{"docs", []routeDoc{
+		{
+			method: fiber.MethodGet, path: docsSpecPath,
+			summary: "Get this document",
+			content: func(*openapi.Document) map[string]openapi.MediaType {
+				return map[string]openapi.MediaType{openapi.MediaJSON: {}}
+			},
+		},
+		{
+			method: fiber.MethodGet, path: docsUIPath,
+			summary: "Browse this document",
+			content: func(*openapi.Document) map[string]openapi.MediaType {
+				return map[string]openapi.MediaType{fiber.MIMETextHTML: {}}
+			},
+		},
+		{
+			method: fiber.MethodGet, path: docsScriptPath,
+			summary: "Get the script of the docs page",
+			content: func(*openapi.Document) map[string]openapi.MediaType {
+				return map[string]openapi.MediaType{fiber.MIMETextJavaScript: {}}
+			},
+		},
+	}},
+}
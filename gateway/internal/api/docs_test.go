@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/gateway/internal/api/openapi"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	routes := newTestServer(t).Routes()
+	spec := newSpec(routes)
+
+	registered := make(map[string]bool)
+	gets := make(map[string]bool)
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+		if route.Method == fiber.MethodGet {
+			gets[route.Path] = true
+		}
+	}
+
+	for _, route := range routes {
+		if route.Method == fiber.MethodHead && gets[route.Path] {
+			continue
+		}
+		assert.NotNil(t, spec.Operation(route.Method, route.Path),
+			"%s %s is missing from routeDocs", route.Method, route.Path)
+	}
+
+	operationIDs := make(map[string]bool)
+	for _, group := range routeDocs {
+		for _, rd := range group.routes {
+			assert.True(t, registered[rd.method+" "+rd.path],
+				"%s %s is documented but not registered", rd.method, rd.path)
+
+			op := spec.Operation(rd.method, rd.path)
+			if op == nil {
+				continue
+			}
+			assert.False(t, operationIDs[op.OperationID], "duplicate operation id %s", op.OperationID)
+			operationIDs[op.OperationID] = true
+		}
+	}
+}
+
+func TestDocsAPI(t *testing.T) {
+	docs := NewDocsAPI(newTestServer(t))
+	app := fiber.New()
+	docs.Register(app)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, docsSpecPath, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+
+	var spec struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	assert.Equal(t, openapi.Version, spec.OpenAPI)
+	assert.Contains(t, spec.Paths["/book/{id}"], "get")
+	assert.Contains(t, spec.Paths["/book/file/uploads/{id}"], "patch")
+	assert.Contains(t, spec.Components.Schemas, "book.GetResponse")
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, docsUIPath, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `spec-url="`+docsSpecPath+`"`)
+	assert.Contains(t, string(page), `src="`+docsScriptPath+`"`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, docsScriptPath, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fiber.MIMETextJavaScriptCharsetUTF8, resp.Header.Get(fiber.HeaderContentType))
+}
@@ -57,7 +57,7 @@ func (gi GenreAPI) Search(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := gi.conn.RequestWithContext(ctx.Context(), genreSearchSubj, data)
+	respMsg, err := natsRequest(ctx, gi.conn, genreSearchSubj, data)
 	if err != nil {
 		return err
 	}
@@ -81,7 +81,7 @@ func (gi GenreAPI) Get(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := gi.conn.RequestWithContext(ctx.Context(), genreGetSubj, data)
+	respMsg, err := natsRequest(ctx, gi.conn, genreGetSubj, data)
 	if err != nil {
 		return err
 	}
@@ -105,7 +105,7 @@ func (gi GenreAPI) Put(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := gi.conn.RequestWithContext(ctx.Context(), genrePutSubj, data)
+	respMsg, err := natsRequest(ctx, gi.conn, genrePutSubj, data)
 	if err != nil {
 		return err
 	}
@@ -129,7 +129,7 @@ func (gi GenreAPI) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := gi.conn.RequestWithContext(ctx.Context(), genreUpdateSubj, data)
+	respMsg, err := natsRequest(ctx, gi.conn, genreUpdateSubj, data)
 	if err != nil {
 		return err
 	}
@@ -153,7 +153,7 @@ func (gi GenreAPI) Delete(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := gi.conn.RequestWithContext(ctx.Context(), genreDeleteSubj, data)
+	respMsg, err := natsRequest(ctx, gi.conn, genreDeleteSubj, data)
 	if err != nil {
 		return err
 	}
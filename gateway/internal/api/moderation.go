@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+const (
+	reviewReportSubj   = "review.report"
+	reviewModerateSubj = "review.moderate"
+	reviewQueueSubj    = "review.queue"
+)
+
+var reportReasons = map[string]reviewpb.ReportReason{
+	"other":     reviewpb.ReportReason_REPORT_REASON_OTHER,
+	"spam":      reviewpb.ReportReason_REPORT_REASON_SPAM,
+	"abuse":     reviewpb.ReportReason_REPORT_REASON_ABUSE,
+	"off_topic": reviewpb.ReportReason_REPORT_REASON_OFF_TOPIC,
+	"spoiler":   reviewpb.ReportReason_REPORT_REASON_SPOILER,
+}
+
+var moderationStatuses = map[string]reviewpb.ModerationStatus{
+	"published": reviewpb.ModerationStatus_MODERATION_STATUS_PUBLISHED,
+	"hidden":    reviewpb.ModerationStatus_MODERATION_STATUS_HIDDEN,
+	"rejected":  reviewpb.ModerationStatus_MODERATION_STATUS_REJECTED,
+}
+
+func NewModerationAPI(conn *nats.Conn) ModerationAPI {
+	return ModerationAPI{conn: conn}
+}
+
+// ModerationAPI takes the reports of readers and serves the moderation
+// queue of reviews.
+type ModerationAPI struct {
+	conn *nats.Conn
+}
+
+func (ma ModerationAPI) Register(router fiber.Router) {
+	router.
+		Post("/review/:id/report", ma.Report).
+		Name(reviewReportSubj).
+		Put("/review/:id/moderation", ma.Moderate).
+		Name(reviewModerateSubj).
+		Get("/reviews/moderation/queue", ma.Queue).
+		Name(reviewQueueSubj)
+}
+
+// Report files the complaint of the authenticated user about the review.
+// The body has reason (other, spam, abuse, off_topic or spoiler) and
+// comment.
+func (ma ModerationAPI) Report(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
+	}
+	if err = ctx.BodyParser(&body); err != nil {
+		return err
+	}
+	reason := reviewpb.ReportReason_REPORT_REASON_OTHER
+	if body.Reason != "" {
+		var ok bool
+		if reason, ok = reportReasons[body.Reason]; !ok {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unknown reason %q", body.Reason))
+		}
+	}
+
+	var resp reviewpb.EmptyResponse
+	err = ma.request(ctx, reviewReportSubj, &reviewpb.ReportRequest{
+		ReviewId: int64(reviewID),
+		UserId:   userID,
+		Reason:   reason,
+		Comment:  body.Comment,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Moderate sets the status of the review, published, hidden or rejected,
+// given in the body.
+func (ma ModerationAPI) Moderate(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err = ctx.BodyParser(&body); err != nil {
+		return err
+	}
+	status, ok := moderationStatuses[body.Status]
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unknown status %q", body.Status))
+	}
+
+	var resp reviewpb.EmptyResponse
+	err = ma.request(ctx, reviewModerateSubj, &reviewpb.ModerateRequest{
+		ReviewId: int64(reviewID),
+		Status:   status,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Queue returns a page of the reviews waiting for a moderator, oldest
+// first. The query may set limit and cursor.
+func (ma ModerationAPI) Queue(ctx *fiber.Ctx) error {
+	var params struct {
+		Limit  int32  `query:"limit"`
+		Cursor string `query:"cursor"`
+	}
+	if err := ctx.QueryParser(&params); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var resp reviewpb.QueueResponse
+	err := ma.request(ctx, reviewQueueSubj, &reviewpb.QueueRequest{
+		Limit:  params.Limit,
+		Cursor: params.Cursor,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ma ModerationAPI) request(ctx *fiber.Ctx, subj string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ma.conn, subj, data)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(respMsg.Data, resp)
+}
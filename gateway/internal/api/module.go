@@ -5,18 +5,26 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/lunn06/library/gateway/internal/api/nats"
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
 )
 
 var Module = fx.Module("api",
 	nats.Module,
 	server.Module,
+	ratelimit.Module,
 
 	fx.Provide(
 		NewBookInfoAPI,
 		NewAuthorAPI,
 		NewGenreAPI,
 		NewBookFileAPI,
+		NewBookUploadAPI,
 		NewReviewAPI,
+		NewActivityAPI,
+		NewCommentAPI,
+		NewModerationAPI,
+		NewUserAPI,
+		NewDocsAPI,
 
 		NewBookFileClient,
 	),
@@ -27,18 +35,35 @@ var Module = fx.Module("api",
 )
 
 func registerRouters(
-	server *server.Server,
+	srv *server.Server,
+	auth *server.Auth,
+	limiter *ratelimit.Limiter,
 	bookInfo BookInfoAPI,
 	author AuthorAPI,
 	genre GenreAPI,
 	bookFile BookFileAPI,
+	bookUpload BookUploadAPI,
 	review ReviewAPI,
+	activity ActivityAPI,
+	comment CommentAPI,
+	moderation ModerationAPI,
+	user UserAPI,
+	docs DocsAPI,
 ) {
-	router := server.Router()
+	router := srv.Router()
+	// the invalid tokens are limited before the authentication rejects
+	// them, the rest of the limits need the user
+	router.Use(limiter.TokenMiddleware(), auth.Middleware(), limiter.Middleware())
 
 	bookInfo.Register(router)
 	author.Register(router)
 	genre.Register(router)
 	bookFile.Register(router)
+	bookUpload.Register(router)
 	review.Register(router)
+	activity.Register(router)
+	comment.Register(router)
+	moderation.Register(router)
+	user.Register(router)
+	docs.Register(router)
 }
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+func newTestServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	auth, err := server.NewAuth(server.AuthConfig{Secret: "secret"})
+	require.NoError(t, err)
+	limiter, err := ratelimit.NewLimiter(ratelimit.Config{}, nil)
+	require.NoError(t, err)
+
+	srv := server.NewServer(server.Config{})
+	registerRouters(
+		srv,
+		auth,
+		limiter,
+		NewBookInfoAPI(nil),
+		NewAuthorAPI(nil),
+		NewGenreAPI(nil),
+		NewBookFileAPI(nil, limiter, BookFileConfig{}),
+		NewBookUploadAPI(nil, limiter, BookFileConfig{}),
+		NewReviewAPI(nil),
+		NewActivityAPI(nil),
+		NewCommentAPI(nil),
+		NewModerationAPI(nil),
+		NewUserAPI(nil),
+		NewDocsAPI(srv),
+	)
+
+	return srv
+}
+
+func TestRouteNamesUnique(t *testing.T) {
+	names := make(map[string]string)
+	for _, route := range newTestServer(t).Routes() {
+		if route.Name == "" || route.Method == fiber.MethodHead {
+			continue
+		}
+		path := route.Method + " " + route.Path
+		if other, ok := names[route.Name]; ok {
+			assert.Failf(t, "duplicate route name", "%s names both %s and %s", route.Name, other, path)
+		}
+		names[route.Name] = path
+	}
+}
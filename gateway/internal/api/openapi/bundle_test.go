@@ -0,0 +1,18 @@
+//go:build release
+
+package openapi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedocBundle keeps a release from shipping the placeholder of the Redoc
+// bundle, the image build runs it after go generate fetched the bundle.
+func TestRedocBundle(t *testing.T) {
+	assert.False(t, bytes.Contains(redocScript, []byte("The Redoc bundle is missing")),
+		"the Redoc bundle is the placeholder, run go generate ./internal/api/openapi")
+	assert.Greater(t, len(redocScript), 100<<10, "the Redoc bundle is incomplete")
+}
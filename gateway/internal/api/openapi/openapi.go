@@ -0,0 +1,167 @@
+// Package openapi builds the OpenAPI 3.1 document of the gateway, see
+// https://spec.openapis.org/oas/v3.1.0.
+package openapi
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const Version = "3.1.0"
+
+// The media types of the bodies.
+const (
+	MediaJSON        = "application/json"
+	MediaText        = "text/plain"
+	MediaOctetStream = "application/octet-stream"
+)
+
+// BearerAuth names the security scheme of the JWTs the gateway verifies.
+const BearerAuth = "bearer"
+
+// Document has only the parts of the specification the gateway uses.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps the lower case methods to the operations of a path.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// New returns a document without paths, with the BearerAuth scheme.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+// Add adds the operation on the route, a path of the router such as
+// /book/:id.
+func (d *Document) Add(method, route string, op *Operation) {
+	path, _ := Path(route)
+	if op.OperationID == "" {
+		op.OperationID = OperationID(method, route)
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Operation returns the operation on the route, nil if there is none.
+func (d *Document) Operation(method, route string) *Operation {
+	path, _ := Path(route)
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Path converts the route to an OpenAPI path, /book/:id to /book/{id}, and
+// returns the names of its parameters.
+func Path(route string) (string, []string) {
+	var params []string
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "?")
+		params = append(params, name)
+		segments[i] = "{" + name + "}"
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// OperationID names the operation after the route, e.g. getBookById for
+// GET /book/:id.
+func OperationID(method, route string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(route, "/") {
+		name, param := strings.CutPrefix(segment, ":")
+		name = strings.TrimSuffix(name, "?")
+		if param {
+			id.WriteString("By")
+		}
+		for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			r, size := utf8.DecodeRuneInString(word)
+			id.WriteRune(unicode.ToUpper(r))
+			id.WriteString(word[size:])
+		}
+	}
+
+	return id.String()
+}
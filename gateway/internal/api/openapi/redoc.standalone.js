@@ -0,0 +1,5 @@
+// Placeholder of the Redoc standalone bundle, replace it with the real one
+// by running go generate in this directory before building the gateway.
+document.querySelectorAll("redoc").forEach(function (el) {
+  el.textContent = "The Redoc bundle is missing from this build of the gateway.";
+});
@@ -0,0 +1,202 @@
+package openapi
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema is a JSON Schema, with only the keywords the gateway uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// The JSON types.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+func String(format string) *Schema {
+	return &Schema{Type: TypeString, Format: format}
+}
+
+func Integer(format string) *Schema {
+	return &Schema{Type: TypeInteger, Format: format}
+}
+
+// Object returns the schema of an object with the properties, all of them
+// required.
+func Object(properties map[string]*Schema) *Schema {
+	schema := &Schema{Type: TypeObject, Properties: properties}
+	for name := range properties {
+		schema.Required = append(schema.Required, name)
+	}
+
+	return schema
+}
+
+// Response returns the schema of a proto message written as JSON by
+// protojson, as the gateway writes its responses. It refers to a component
+// schema named after the message, added to the document with the ones of
+// the nested messages.
+func (d *Document) Response(m proto.Message) *Schema {
+	return d.protoJSON(m.ProtoReflect().Descriptor())
+}
+
+func (d *Document) protoJSON(desc protoreflect.MessageDescriptor) *Schema {
+	name := string(desc.FullName())
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := d.Components.Schemas[name]; ok {
+		return ref
+	}
+
+	schema := &Schema{Type: TypeObject, Properties: make(map[string]*Schema)}
+	// set before the fields, which may refer to the message
+	d.Components.Schemas[name] = schema
+
+	fields := desc.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		schema.Properties[field.JSONName()] = d.protoJSONField(field)
+	}
+
+	return ref
+}
+
+func (d *Document) protoJSONField(field protoreflect.FieldDescriptor) *Schema {
+	switch {
+	case field.IsMap():
+		return &Schema{
+			Type:                 TypeObject,
+			AdditionalProperties: d.protoJSONValue(field.MapValue()),
+		}
+	case field.IsList():
+		return &Schema{Type: TypeArray, Items: d.protoJSONValue(field)}
+	default:
+		return d.protoJSONValue(field)
+	}
+}
+
+func (d *Document) protoJSONValue(field protoreflect.FieldDescriptor) *Schema {
+	switch field.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson quotes the 64-bit integers
+		return String("int64")
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return String("uint64")
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		schema := &Schema{Type: TypeString}
+		for i := range values.Len() {
+			schema.Enum = append(schema.Enum, string(values.Get(i).Name()))
+		}
+		return schema
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return d.protoJSON(field.Message())
+	default:
+		return scalar(field.Kind())
+	}
+}
+
+// Request returns the schema of a proto message read from JSON by
+// encoding/json, as the gateway reads the request bodies into the generated
+// structs. It is inlined, leaving out the fields the gateway sets itself.
+func Request(m proto.Message, omit ...string) *Schema {
+	schema := goJSON(m.ProtoReflect().Descriptor(), make(map[protoreflect.FullName]bool))
+	for _, name := range omit {
+		delete(schema.Properties, name)
+	}
+
+	return schema
+}
+
+func goJSON(desc protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) *Schema {
+	schema := &Schema{Type: TypeObject}
+	if seen[desc.FullName()] {
+		return schema
+	}
+	seen[desc.FullName()] = true
+	defer delete(seen, desc.FullName())
+
+	schema.Properties = make(map[string]*Schema)
+	fields := desc.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+
+		var property *Schema
+		switch {
+		case field.IsMap():
+			property = &Schema{
+				Type:                 TypeObject,
+				AdditionalProperties: goJSONValue(field.MapValue(), seen),
+			}
+		case field.IsList():
+			property = &Schema{Type: TypeArray, Items: goJSONValue(field, seen)}
+		default:
+			property = goJSONValue(field, seen)
+			if field.HasPresence() && property.Type != nil {
+				// optional fields are pointers
+				property.Type = []string{property.Type.(string), TypeNull}
+			}
+		}
+		// the generated structs are tagged with the proto names
+		schema.Properties[string(field.Name())] = property
+	}
+
+	return schema
+}
+
+func goJSONValue(field protoreflect.FieldDescriptor, seen map[protoreflect.FullName]bool) *Schema {
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		schema := Integer("int32")
+		for i := range values.Len() {
+			schema.Enum = append(schema.Enum, int32(values.Get(i).Number()))
+		}
+		return schema
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return goJSON(field.Message(), seen)
+	default:
+		return scalar(field.Kind())
+	}
+}
+
+// scalar returns the schema of the other kinds as encoding/json reads them,
+// protojson differs only for the 64-bit integers.
+func scalar(kind protoreflect.Kind) *Schema {
+	switch kind {
+	case protoreflect.BoolKind:
+		return &Schema{Type: TypeBoolean}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return Integer("int32")
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return Integer("uint32")
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return Integer("int64")
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return Integer("uint64")
+	case protoreflect.FloatKind:
+		return &Schema{Type: TypeNumber, Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: TypeNumber, Format: "double"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: TypeString, ContentEncoding: "base64"}
+	default:
+		return String("")
+	}
+}
@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:generate curl -fsSL -o redoc.standalone.js https://cdn.jsdelivr.net/npm/redoc@2.5.0/bundles/redoc.standalone.js
+
+// redocScript is the standalone bundle of Redoc, the gateway serves it so
+// the docs page works without access to a CDN.
+//
+//go:embed redoc.standalone.js
+var redocScript []byte
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>%s</title>
+</head>
+<body>
+  <redoc spec-url="%s"></redoc>
+  <script src="%s"></script>
+</body>
+</html>
+`
+
+// UI serves a page rendering the document at specURL with Redoc, whose
+// bundle Script serves at scriptURL.
+func UI(title, specURL, scriptURL string) fiber.Handler {
+	page := fmt.Sprintf(uiPage,
+		html.EscapeString(title),
+		html.EscapeString(specURL),
+		html.EscapeString(scriptURL),
+	)
+
+	return func(ctx *fiber.Ctx) error {
+		ctx.Type("html", "utf-8")
+		return ctx.SendString(page)
+	}
+}
+
+// Script serves the Redoc bundle embedded in the gateway.
+func Script() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderContentType, fiber.MIMETextJavaScriptCharsetUTF8)
+		ctx.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+		return ctx.Send(redocScript)
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Enabled bool `default:"true"`
+	// Bucket and QuotaBucket name the NATS KV buckets with the state of the
+	// limits, shared by the replicas of the gateway.
+	Bucket      string `default:"gateway-ratelimit"`
+	QuotaBucket string `default:"gateway-quota" split_words:"true"`
+	// APIKeys are the keys accepted in the X-API-Key header, the requests
+	// with one are limited per key rather than per user or address.
+	APIKeys []string `envconfig:"API_KEYS"`
+
+	// The limits of the route groups, see Limit.
+	Search Limit `default:"30/1m"`
+	Auth   Limit `default:"10/1m"`
+	Upload Limit `default:"60/1m"`
+	Write  Limit `default:"60/1m"`
+	Read   Limit `default:"300/1m"`
+	// InvalidToken limits the requests of a client failing with 401 for the
+	// token they carry, the rest of the requests with a token do not count.
+	InvalidToken Limit `default:"10/1m" split_words:"true"`
+
+	// UploadQuota is the number of bytes a client may upload a day (UTC),
+	// zero disables it.
+	UploadQuota int64 `default:"2147483648" split_words:"true"`
+}
+
+// Limit is a token bucket refilled with Requests tokens every Period and
+// holding up to Burst of them.
+// It is configured as "requests/period" or "requests/period,burst", e.g.
+// "30/1m" or "30/1m,10", the burst defaults to the requests. An empty
+// value disables the limit.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+func (l *Limit) Decode(value string) error {
+	if value == "" {
+		*l = Limit{}
+		return nil
+	}
+
+	rate, burst, hasBurst := strings.Cut(value, ",")
+	requests, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return fmt.Errorf("limit %q: expected requests/period", value)
+	}
+
+	var (
+		limit Limit
+		err   error
+	)
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return fmt.Errorf("limit %q: bad requests", value)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return fmt.Errorf("limit %q: bad period", value)
+	}
+	limit.Burst = limit.Requests
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return fmt.Errorf("limit %q: bad burst", value)
+		}
+	}
+
+	*l = limit
+	return nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0
+}
+
+// fillTime is how long an empty bucket takes to fill up.
+func (l Limit) fillTime() time.Duration {
+	return l.Period * time.Duration(l.Burst) / time.Duration(l.Requests)
+}
@@ -0,0 +1,9 @@
+package ratelimit
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(
+		NewLimiter,
+	),
+)
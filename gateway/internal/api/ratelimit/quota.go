@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ErrQuotaExceeded struct {
+	Quota int64
+	// Reset is when the client has a fresh quota.
+	Reset time.Time
+}
+
+func (e ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("daily upload quota of %d bytes exceeded", e.Quota)
+}
+
+// quotaChunk is how many bytes of the quota an upload reserves at a time,
+// the reserved bytes it does not read are returned by Charge.
+const quotaChunk = 1 << 20
+
+// errQuotaLeft aborts a reservation of a client with nothing left.
+var errQuotaLeft = errors.New("no upload quota left")
+
+// QuotaReader reads an upload of a client, failing with ErrQuotaExceeded
+// past the bytes left of the daily quota of the client. The bytes are
+// reserved as they arrive, so that concurrent uploads of a client share
+// the bytes left.
+type QuotaReader struct {
+	r        io.Reader
+	read     int64
+	reserved int64
+	// unlimited is set once the quota is not available
+	unlimited bool
+
+	quota ErrQuotaExceeded
+	ctx   context.Context
+	store Store
+	key   string
+}
+
+// QuotaReader reads the upload r of the client of the request, it fails
+// right away when the client has nothing left.
+// Uploads are not limited when the quotas are not available.
+func (l *Limiter) QuotaReader(ctx *fiber.Ctx, r io.Reader) (*QuotaReader, error) {
+	qr := &QuotaReader{r: r, unlimited: true}
+	if !l.enabled || l.uploadQuota <= 0 {
+		return qr, nil
+	}
+
+	now := l.now().UTC()
+	year, month, day := now.Date()
+	qr.quota = ErrQuotaExceeded{
+		Quota: l.uploadQuota,
+		Reset: time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC),
+	}
+	qr.unlimited = false
+	qr.ctx = ctx.Context()
+	qr.store = l.quotas
+	qr.key = "upload." + l.client(ctx) + "." + now.Format("20060102")
+
+	value, err := qr.store.Get(qr.ctx, qr.key)
+	if err != nil {
+		slog.Error("Reading upload quota failed", "err", err)
+		return qr, nil
+	}
+	used, err := parseUsage(value)
+	if err != nil {
+		slog.Error("Reading upload quota failed", "key", qr.key, "err", err)
+		return qr, nil
+	}
+	if used >= l.uploadQuota {
+		return nil, qr.quota
+	}
+
+	return qr, nil
+}
+
+func (qr *QuotaReader) Read(p []byte) (int, error) {
+	if qr.unlimited {
+		return qr.r.Read(p)
+	}
+
+	if qr.read == qr.reserved {
+		err := qr.reserve()
+		var quotaErr ErrQuotaExceeded
+		if errors.As(err, &quotaErr) {
+			// an upload of exactly the bytes left ends here
+			var probe [1]byte
+			if n, readErr := io.ReadFull(qr.r, probe[:]); n == 0 && readErr == io.EOF {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if err != nil {
+			return 0, err
+		}
+		if qr.unlimited {
+			return qr.r.Read(p)
+		}
+	}
+
+	if left := qr.reserved - qr.read; left < int64(len(p)) {
+		p = p[:left]
+	}
+	n, err := qr.r.Read(p)
+	qr.read += int64(n)
+
+	return n, err
+}
+
+// reserve adds up to quotaChunk bytes of the quota to the ones the upload
+// may read. It gives up on the quota when it is not available.
+func (qr *QuotaReader) reserve() error {
+	var grant int64
+	err := qr.store.Update(qr.ctx, qr.key, func(value []byte) ([]byte, error) {
+		used, err := parseUsage(value)
+		if err != nil {
+			return nil, err
+		}
+
+		grant = min(qr.quota.Quota-used, quotaChunk)
+		if grant <= 0 {
+			return nil, errQuotaLeft
+		}
+		return strconv.AppendInt(nil, used+grant, 10), nil
+	})
+	if errors.Is(err, errQuotaLeft) {
+		return qr.quota
+	}
+	if err != nil {
+		slog.Error("Reserving upload quota failed", "key", qr.key, "err", err)
+		qr.unlimited = true
+		return nil
+	}
+
+	qr.reserved += grant
+	return nil
+}
+
+// Charge returns the bytes reserved but not read to the quota of the
+// client, the ones read are charged whether the upload succeeded or not.
+func (qr *QuotaReader) Charge(ctx context.Context) error {
+	unread := qr.reserved - qr.read
+	if unread == 0 {
+		return nil
+	}
+
+	return qr.store.Update(ctx, qr.key, func(value []byte) ([]byte, error) {
+		used, err := parseUsage(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return strconv.AppendInt(nil, max(used-unread, 0), 10), nil
+	})
+}
+
+func parseUsage(value []byte) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(string(value), 10, 64)
+}
@@ -0,0 +1,351 @@
+// Package ratelimit limits the requests and the uploads of the clients of
+// the gateway, keeping the counters in NATS KV buckets so that the replicas
+// share them.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+// HeaderAPIKey carries the API key of the client, see Config.APIKeys.
+const HeaderAPIKey = "X-API-Key"
+
+// the headers of https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+const (
+	headerLimit     = "RateLimit-Limit"
+	headerRemaining = "RateLimit-Remaining"
+	headerReset     = "RateLimit-Reset"
+	headerPolicy    = "RateLimit-Policy"
+)
+
+// The route groups, each client has a bucket per group.
+const (
+	groupSearch = "search"
+	groupAuth   = "auth"
+	groupUpload = "upload"
+	groupWrite  = "write"
+	groupRead   = "read"
+	// groupInvalidToken counts the requests with an invalid token, see
+	// Limiter.TokenMiddleware.
+	groupInvalidToken = "token"
+)
+
+const (
+	// minBucketTTL keeps the buckets of short limits for a while, a bucket
+	// removed before it is full would be refilled early.
+	minBucketTTL = time.Minute
+	// quotaTTL keeps the usage of a day until it is over everywhere.
+	quotaTTL = 48 * time.Hour
+)
+
+// NewLimiter opens the buckets of the limits, the limiter of a disabled
+// config lets everything through without them.
+func NewLimiter(cfg Config, conn *nats.Conn) (*Limiter, error) {
+	if !cfg.Enabled {
+		return newLimiter(cfg, nil, nil), nil
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ttl := minBucketTTL
+	for _, limit := range []Limit{cfg.Search, cfg.Auth, cfg.Upload, cfg.Write, cfg.Read, cfg.InvalidToken} {
+		if limit.Enabled() {
+			ttl = max(ttl, limit.fillTime())
+		}
+	}
+	// losing the buckets with the server costs the clients a fresh start
+	buckets, err := NewKVStore(ctx, js, jetstream.KeyValueConfig{
+		Bucket:  cfg.Bucket,
+		TTL:     ttl,
+		Storage: jetstream.MemoryStorage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := NewKVStore(ctx, js, jetstream.KeyValueConfig{
+		Bucket:  cfg.QuotaBucket,
+		TTL:     quotaTTL,
+		Storage: jetstream.FileStorage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newLimiter(cfg, buckets, quotas), nil
+}
+
+func newLimiter(cfg Config, buckets, quotas Store) *Limiter {
+	apiKeys := make(map[string]string, len(cfg.APIKeys))
+	for _, key := range cfg.APIKeys {
+		hash := hashKey(key)
+		// the keys are not kept in the buckets
+		apiKeys[hash] = hash[:16]
+	}
+
+	return &Limiter{
+		enabled: cfg.Enabled,
+		limits: map[string]Limit{
+			groupSearch: cfg.Search,
+			groupAuth:   cfg.Auth,
+			groupUpload: cfg.Upload,
+			groupWrite:  cfg.Write,
+			groupRead:   cfg.Read,
+
+			groupInvalidToken: cfg.InvalidToken,
+		},
+		apiKeys:     apiKeys,
+		uploadQuota: cfg.UploadQuota,
+		buckets:     buckets,
+		quotas:      quotas,
+		now:         time.Now,
+	}
+}
+
+// Limiter limits the clients, identified by their API key, user or address
+// in this order.
+type Limiter struct {
+	enabled     bool
+	limits      map[string]Limit
+	apiKeys     map[string]string
+	uploadQuota int64
+
+	buckets Store
+	quotas  Store
+	now     func() time.Time
+}
+
+// Middleware rejects the requests of the clients over the limit of the
+// route group with 429, it must run after the authentication, see
+// TokenMiddleware for the requests the authentication rejects.
+// The requests go through when the buckets are not available, the gateway
+// does not fail with them.
+func (l *Limiter) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		group := routeGroup(ctx)
+		limit := l.limits[group]
+		if !l.enabled || !limit.Enabled() {
+			return ctx.Next()
+		}
+
+		res, err := l.take(ctx.Context(), group+"."+l.client(ctx), limit)
+		if err != nil {
+			slog.Error("Rate limit failed", "group", group, "err", err)
+			return ctx.Next()
+		}
+
+		ctx.Set(headerLimit, strconv.Itoa(limit.Burst))
+		ctx.Set(headerRemaining, strconv.Itoa(res.remaining))
+		ctx.Set(headerReset, seconds(res.reset))
+		ctx.Set(headerPolicy, fmt.Sprintf("%d;w=%s;burst=%d", limit.Requests, seconds(limit.Period), limit.Burst))
+
+		if !res.allowed {
+			ctx.Set(fiber.HeaderRetryAfter, seconds(res.retryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+
+		return ctx.Next()
+	}
+}
+
+// TokenMiddleware rejects the requests with a token of the clients over the
+// limit of invalid tokens with 429, before the authentication checks the
+// token, and counts the ones failing with 401. It must run before the
+// authentication, so the clients are identified by their API key or their
+// address.
+func (l *Limiter) TokenMiddleware() fiber.Handler {
+	limit := l.limits[groupInvalidToken]
+	return func(ctx *fiber.Ctx) error {
+		if !l.enabled || !limit.Enabled() || ctx.Get(fiber.HeaderAuthorization) == "" {
+			return ctx.Next()
+		}
+
+		// only the failures take from the bucket, the requests get through
+		// while it holds a token
+		key := groupInvalidToken + "." + l.client(ctx)
+		res, err := l.peek(ctx.Context(), key, limit)
+		if err != nil {
+			slog.Error("Rate limit failed", "group", groupInvalidToken, "err", err)
+			return ctx.Next()
+		}
+		if !res.allowed {
+			ctx.Set(fiber.HeaderRetryAfter, seconds(res.retryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, "too many invalid tokens")
+		}
+
+		err = ctx.Next()
+		if unauthorized(ctx, err) {
+			if _, takeErr := l.take(ctx.Context(), key, limit); takeErr != nil {
+				slog.Error("Rate limit failed", "group", groupInvalidToken, "err", takeErr)
+			}
+		}
+
+		return err
+	}
+}
+
+// unauthorized tells whether the next handlers of the request failed with
+// 401, with an error the error handler writes yet or with a response.
+func unauthorized(ctx *fiber.Ctx, err error) bool {
+	if err == nil {
+		return ctx.Response().StatusCode() == fiber.StatusUnauthorized
+	}
+
+	var fiberErr *fiber.Error
+	return errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusUnauthorized
+}
+
+// routeGroup returns the group of the route of the request, the router
+// ignores the case and the trailing slash of paths.
+func routeGroup(ctx *fiber.Ctx) string {
+	path := strings.TrimSuffix(strings.ToLower(ctx.Path()), "/")
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	switch {
+	case segments[0] == "auth":
+		return groupAuth
+	case len(segments) > 2 && segments[1] == "search":
+		return groupSearch
+	case path == "/book/file" && ctx.Method() == fiber.MethodPost,
+		strings.HasPrefix(path, "/book/file/uploads"):
+		return groupUpload
+	}
+
+	switch ctx.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return groupRead
+	default:
+		return groupWrite
+	}
+}
+
+// client identifies the client of the request in keys of the buckets.
+func (l *Limiter) client(ctx *fiber.Ctx) string {
+	if key := ctx.Get(HeaderAPIKey); key != "" {
+		if id, ok := l.apiKeys[hashKey(key)]; ok {
+			return "key." + id
+		}
+	}
+	if userID, ok := server.UserID(ctx); ok {
+		return "user." + strconv.FormatInt(userID, 10)
+	}
+
+	// the keys of the buckets do not allow the colons of IPv6 addresses
+	return "ip." + base64.RawURLEncoding.EncodeToString([]byte(ctx.IP()))
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	// At is when the tokens were counted, in Unix nanoseconds.
+	At int64 `json:"at"`
+}
+
+type result struct {
+	allowed   bool
+	remaining int
+	// reset is how long the bucket takes to fill up.
+	reset time.Duration
+	// retryAfter is how long the bucket takes to hold a token again, when
+	// the request is not allowed.
+	retryAfter time.Duration
+}
+
+func (l *Limiter) take(ctx context.Context, key string, limit Limit) (result, error) {
+	var res result
+	err := l.buckets.Update(ctx, key, func(value []byte) ([]byte, error) {
+		b, err := decodeBucket(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		b, res = limit.take(b, l.now())
+		return json.Marshal(b)
+	})
+
+	return res, err
+}
+
+// peek tells what taking a token from the bucket would, without taking it.
+func (l *Limiter) peek(ctx context.Context, key string, limit Limit) (result, error) {
+	value, err := l.buckets.Get(ctx, key)
+	if err != nil {
+		return result{}, err
+	}
+	b, err := decodeBucket(key, value)
+	if err != nil {
+		return result{}, err
+	}
+
+	_, res := limit.take(b, l.now())
+	return res, nil
+}
+
+func decodeBucket(key string, value []byte) (bucket, error) {
+	var b bucket
+	if value != nil {
+		if err := json.Unmarshal(value, &b); err != nil {
+			return bucket{}, fmt.Errorf("bucket %s: %w", key, err)
+		}
+	}
+
+	return b, nil
+}
+
+// take refills the bucket for the time since it was last counted and takes
+// a token from it if it has one. A new bucket is full.
+func (l Limit) take(b bucket, now time.Time) (bucket, result) {
+	burst := float64(l.Burst)
+	// nanoseconds per token
+	interval := float64(l.Period) / float64(l.Requests)
+
+	tokens := burst
+	if b.At != 0 {
+		// the clocks of the replicas may disagree a little
+		elapsed := max(now.UnixNano()-b.At, 0)
+		tokens = min(burst, b.Tokens+float64(elapsed)/interval)
+	}
+
+	var res result
+	if tokens >= 1 {
+		tokens--
+		res.allowed = true
+	} else {
+		res.retryAfter = time.Duration(math.Round((1 - tokens) * interval))
+	}
+	res.remaining = int(tokens)
+	res.reset = time.Duration(math.Round((burst - tokens) * interval))
+
+	return bucket{Tokens: tokens, At: max(now.UnixNano(), b.At)}, res
+}
+
+// seconds formats the duration in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((max(d, 0)+time.Second-1)/time.Second), 10)
+}
@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key], nil
+}
+
+func (s *memoryStore) Update(_ context.Context, key string, fn func([]byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := fn(s.values[key])
+	if err != nil {
+		return err
+	}
+	s.values[key] = value
+	return nil
+}
+
+func TestLimitDecode(t *testing.T) {
+	for value, want := range map[string]Limit{
+		"30/1m":    {Requests: 30, Period: time.Minute, Burst: 30},
+		"30/1m,10": {Requests: 30, Period: time.Minute, Burst: 10},
+		"":         {},
+	} {
+		var limit Limit
+		require.NoError(t, limit.Decode(value), value)
+		assert.Equal(t, want, limit, value)
+	}
+
+	for _, value := range []string{"30", "0/1m", "30/forever", "30/1m,", "30/-1m"} {
+		var limit Limit
+		assert.Error(t, limit.Decode(value), value)
+	}
+}
+
+func TestLimitTake(t *testing.T) {
+	limit := Limit{Requests: 2, Period: time.Second, Burst: 2}
+	now := time.Unix(1000, 0)
+
+	b, res := limit.take(bucket{}, now)
+	assert.True(t, res.allowed)
+	assert.Equal(t, 1, res.remaining)
+	assert.Equal(t, 500*time.Millisecond, res.reset)
+
+	b, res = limit.take(b, now)
+	assert.True(t, res.allowed)
+	assert.Equal(t, 0, res.remaining)
+
+	b, res = limit.take(b, now.Add(100*time.Millisecond))
+	assert.False(t, res.allowed)
+	assert.Equal(t, 400*time.Millisecond, res.retryAfter)
+
+	_, res = limit.take(b, now.Add(time.Hour))
+	assert.True(t, res.allowed, "the bucket refills")
+	assert.Equal(t, 1, res.remaining, "up to the burst")
+}
+
+func newTestApp(limiter *Limiter) *fiber.App {
+	app := fiber.New()
+	app.Use(limiter.Middleware())
+	app.Get("/book/search/:title", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/book/:id", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+	// responds with the reset of an exceeded quota
+	app.Post("/book/file", func(ctx *fiber.Ctx) error {
+		qr, err := limiter.QuotaReader(ctx, bytes.NewReader(ctx.Body()))
+		if err == nil {
+			_, err = io.Copy(io.Discard, qr)
+			if chargeErr := qr.Charge(ctx.Context()); chargeErr != nil {
+				return chargeErr
+			}
+		}
+
+		var quotaErr ErrQuotaExceeded
+		if errors.As(err, &quotaErr) {
+			return ctx.Status(fiber.StatusTooManyRequests).SendString(quotaErr.Reset.Format(time.RFC3339))
+		}
+		return err
+	})
+
+	return app
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter := newLimiter(Config{
+		Enabled: true,
+		APIKeys: []string{"partner"},
+		Search:  Limit{Requests: 2, Period: time.Minute, Burst: 2},
+		Read:    Limit{Requests: 100, Period: time.Minute, Burst: 100},
+	}, newMemoryStore(), newMemoryStore())
+	app := newTestApp(limiter)
+
+	request := func(method, path, apiKey string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		if apiKey != "" {
+			req.Header.Set(HeaderAPIKey, apiKey)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := request(fiber.MethodGet, "/book/search/dune", "")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get(headerLimit))
+	assert.Equal(t, "1", resp.Header.Get(headerRemaining))
+	assert.Equal(t, "30", resp.Header.Get(headerReset))
+	assert.Equal(t, "2;w=60;burst=2", resp.Header.Get(headerPolicy))
+
+	resp = request(fiber.MethodGet, "/Book/Search/dune/", "")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get(headerRemaining), "the same route in other case")
+
+	resp = request(fiber.MethodGet, "/book/search/dune", "")
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get(fiber.HeaderRetryAfter))
+
+	resp = request(fiber.MethodGet, "/book/search/dune", "partner")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode, "a known key has its own bucket")
+	resp = request(fiber.MethodGet, "/book/search/dune", "unknown")
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode, "an unknown key is limited by address")
+
+	resp = request(fiber.MethodGet, "/book/1", "")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode, "the other groups have their own buckets")
+	assert.Equal(t, "99", resp.Header.Get(headerRemaining))
+
+	resp = request(fiber.MethodPost, "/book/file", "")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(headerLimit), "the upload group has no limit")
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	limiter := newLimiter(Config{
+		Enabled:      true,
+		InvalidToken: Limit{Requests: 2, Period: time.Minute, Burst: 2},
+	}, newMemoryStore(), newMemoryStore())
+	app := fiber.New()
+	app.Use(limiter.TokenMiddleware(), func(ctx *fiber.Ctx) error {
+		if header := ctx.Get(fiber.HeaderAuthorization); header != "" && header != "Bearer valid" {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+		}
+		return ctx.Next()
+	})
+	app.Get("/book/:id", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+
+	request := func(token string) *http.Response {
+		req := httptest.NewRequest(fiber.MethodGet, "/book/1", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	for range 3 {
+		assert.Equal(t, fiber.StatusOK, request("valid").StatusCode, "valid tokens do not count")
+	}
+	assert.Equal(t, fiber.StatusUnauthorized, request("guess").StatusCode)
+	assert.Equal(t, fiber.StatusUnauthorized, request("guess").StatusCode)
+
+	resp := request("guess")
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get(fiber.HeaderRetryAfter))
+	assert.Equal(t, fiber.StatusTooManyRequests, request("valid").StatusCode, "the address is limited")
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/book/1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode, "requests without a token are not limited")
+}
+
+func TestDisabled(t *testing.T) {
+	limiter, err := NewLimiter(Config{Search: Limit{Requests: 1, Period: time.Minute, Burst: 1}, UploadQuota: 1}, nil)
+	require.NoError(t, err)
+	app := newTestApp(limiter)
+
+	for range 3 {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/book/search/dune", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(fiber.MethodPost, "/book/file", strings.NewReader("book")))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestQuotaReader(t *testing.T) {
+	limiter := newLimiter(Config{Enabled: true, UploadQuota: 10}, newMemoryStore(), newMemoryStore())
+	limiter.now = func() time.Time {
+		return time.Date(2025, 5, 10, 23, 0, 0, 0, time.UTC)
+	}
+	app := newTestApp(limiter)
+
+	upload := func(body string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/book/file", strings.NewReader(body)))
+		require.NoError(t, err)
+
+		reset, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(reset)
+	}
+
+	status, _ := upload("123456")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, reset := upload("12345")
+	assert.Equal(t, fiber.StatusTooManyRequests, status, "over the bytes left")
+	assert.Equal(t, "2025-05-11T00:00:00Z", reset)
+
+	status, _ = upload("1")
+	assert.Equal(t, fiber.StatusTooManyRequests, status, "the failed upload is charged")
+
+	limiter.now = func() time.Time {
+		return time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC)
+	}
+	status, _ = upload("0123456789")
+	assert.Equal(t, fiber.StatusOK, status, "a fresh quota the next day")
+}
+
+func TestQuotaReaderConcurrent(t *testing.T) {
+	limiter := newLimiter(Config{Enabled: true, UploadQuota: 10}, newMemoryStore(), newMemoryStore())
+	app := fiber.New()
+	app.Post("/", func(ctx *fiber.Ctx) error {
+		first, err := limiter.QuotaReader(ctx, strings.NewReader("123456"))
+		require.NoError(t, err)
+		second, err := limiter.QuotaReader(ctx, strings.NewReader("123456"))
+		require.NoError(t, err, "nothing is used yet")
+
+		_, err = io.Copy(io.Discard, first)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, second)
+		assert.ErrorAs(t, err, &ErrQuotaExceeded{}, "the first upload reserved the bytes left")
+
+		require.NoError(t, first.Charge(ctx.Context()))
+		require.NoError(t, second.Charge(ctx.Context()))
+
+		third, err := limiter.QuotaReader(ctx, strings.NewReader("1234"))
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, third)
+		assert.NoError(t, err, "the bytes the first did not read are returned")
+		require.NoError(t, third.Charge(ctx.Context()))
+
+		_, err = limiter.QuotaReader(ctx, strings.NewReader("1"))
+		assert.ErrorAs(t, err, &ErrQuotaExceeded{})
+		return nil
+	})
+
+	_, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/", nil))
+	require.NoError(t, err)
+}
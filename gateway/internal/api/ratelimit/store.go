@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const maxUpdateAttempts = 5
+
+var errConflict = errors.New("too many concurrent updates")
+
+// Store keeps the state of the limits.
+type Store interface {
+	// Get returns the value of the key, nil if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Update replaces the value of the key with the one fn returns for the
+	// current value, nil if there is none. fn runs again when another
+	// replica updates the key meanwhile.
+	Update(ctx context.Context, key string, fn func([]byte) ([]byte, error)) error
+}
+
+// NewKVStore opens the bucket, creating it or updating it to cfg.
+func NewKVStore(ctx context.Context, js jetstream.JetStream, cfg jetstream.KeyValueConfig) (Store, error) {
+	kv, err := js.CreateOrUpdateKeyValue(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("opening bucket %s: %w", cfg.Bucket, err)
+	}
+
+	return kvStore{kv: kv}, nil
+}
+
+type kvStore struct {
+	kv jetstream.KeyValue
+}
+
+func (s kvStore) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, err := s.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Value(), nil
+}
+
+func (s kvStore) Update(ctx context.Context, key string, fn func([]byte) ([]byte, error)) error {
+	for range maxUpdateAttempts {
+		var (
+			value    []byte
+			revision uint64
+		)
+		entry, err := s.kv.Get(ctx, key)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			value, revision = entry.Value(), entry.Revision()
+		}
+
+		next, err := fn(value)
+		if err != nil {
+			return err
+		}
+
+		if revision == 0 {
+			_, err = s.kv.Create(ctx, key, next)
+		} else {
+			_, err = s.kv.Update(ctx, key, next, revision)
+		}
+		// both fail with the wrong last sequence error of ErrKeyExists
+		// when the key changed since Get
+		if !errors.Is(err, jetstream.ErrKeyExists) {
+			return err
+		}
+	}
+
+	return errConflict
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/nats-io/nats.go"
+
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+// natsRequest sends the request to a service on behalf of the user of ctx,
+// see authz.SetHeader. Anonymous requests go without a user.
+func natsRequest(ctx *fiber.Ctx, conn *nats.Conn, subj string, data []byte) (*nats.Msg, error) {
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	authz.SetHeader(msg.Header, server.Principal(ctx))
+
+	return conn.RequestMsgWithContext(ctx.Context(), msg)
+}
@@ -1,22 +1,37 @@
 package api
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/nats-io/nats.go"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
 	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+	"github.com/lunn06/library/gateway/internal/api/server"
 )
 
 const (
 	reviewGetSubj            = "review.get"
 	reviewGetAllByBookIdSubj = "review.getAllByBookId"
+	reviewGetAllByUserIdSubj = "review.getAllByUserId"
 	reviewPutSubj            = "review.put"
+	reviewUpsertSubj         = "review.upsert"
 	reviewUpdateSubj         = "review.update"
 	reviewDeleteSubj         = "review.delete"
+	reviewStatsSubj          = "review.stats"
+	reviewVoteSubj           = "review.vote"
+	reviewUnvoteSubj         = "review.unvote"
+	reviewHistorySubj        = "review.history"
 )
 
+// reviewBookStatsRoute names the route of the stats of a single book, it
+// requests reviewStatsSubj as well.
+const reviewBookStatsRoute = "review.bookStats"
+
 func NewReviewAPI(conn *nats.Conn) ReviewAPI {
 	return ReviewAPI{conn: conn}
 }
@@ -29,44 +44,198 @@ func (ri ReviewAPI) Register(router fiber.Router) {
 	router.
 		Get("/reviews/book/:id", ri.GetByBookID).
 		Name(reviewGetAllByBookIdSubj).
+		Get("/reviews/user/:id", ri.GetByUserID).
+		Name(reviewGetAllByUserIdSubj).
+		Get("/reviews/book/:id/stats", ri.BookStats).
+		Name(reviewBookStatsRoute).
+		Get("/reviews/stats", ri.Stats).
+		Name(reviewStatsSubj).
 		Get("/review/:id", ri.Get).
 		Name(reviewGetSubj).
 		Post("/review", ri.Put).
 		Name(reviewPutSubj).
+		Put("/review", ri.Upsert).
+		Name(reviewUpsertSubj).
 		Patch("/review/:id", ri.Update).
 		Name(reviewUpdateSubj).
 		Delete("/review/:id", ri.Delete).
-		Name(reviewDeleteSubj)
+		Name(reviewDeleteSubj).
+		Put("/review/:id/vote", ri.Vote).
+		Name(reviewVoteSubj).
+		Delete("/review/:id/vote", ri.Unvote).
+		Name(reviewUnvoteSubj).
+		Get("/review/:id/history", ri.History).
+		Name(reviewHistorySubj)
 }
 
+// GetByBookID returns a page of the book reviews, see parseReviewList for
+// the query.
 func (ri ReviewAPI) GetByBookID(ctx *fiber.Ctx) error {
-	var params struct {
-		bookID int64 `params:"id"`
+	bookID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
 	}
-	if err := ctx.ParamsParser(&params); err != nil {
-		return err
+
+	list, err := parseReviewList(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
 	data, err := proto.Marshal(&reviewpb.GetByBookIdRequest{
-		BookId: params.bookID,
+		BookId:        int64(bookID),
+		Limit:         list.limit,
+		Cursor:        list.cursor,
+		Sort:          list.sort,
+		MinScore:      list.minScore,
+		MaxScore:      list.maxScore,
+		CreatedAfter:  list.createdAfter,
+		CreatedBefore: list.createdBefore,
 	})
 	if err != nil {
 		return err
 	}
 
-	respMsg, err := ri.conn.RequestWithContext(ctx.Context(), reviewGetAllByBookIdSubj, data)
+	respMsg, err := natsRequest(ctx, ri.conn, reviewGetAllByBookIdSubj, data)
 	if err != nil {
 		return err
 	}
 
 	var resp reviewpb.GetByBookIdResponse
-	if err = protojson.Unmarshal(respMsg.Data, &resp); err != nil {
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
 		return err
 	}
 
 	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
 }
 
+// GetByUserID returns a page of the reviews written by the user, see
+// parseReviewList for the query.
+func (ri ReviewAPI) GetByUserID(ctx *fiber.Ctx) error {
+	userID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	list, err := parseReviewList(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	resp, err := requestUserReviews(ctx, ri.conn, &reviewpb.GetByUserIdRequest{
+		UserId:        int64(userID),
+		Limit:         list.limit,
+		Cursor:        list.cursor,
+		Sort:          list.sort,
+		MinScore:      list.minScore,
+		MaxScore:      list.maxScore,
+		CreatedAfter:  list.createdAfter,
+		CreatedBefore: list.createdBefore,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(resp)
+}
+
+func requestUserReviews(
+	ctx *fiber.Ctx,
+	conn *nats.Conn,
+	req *reviewpb.GetByUserIdRequest,
+) (*reviewpb.GetByUserIdResponse, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respMsg, err := natsRequest(ctx, conn, reviewGetAllByUserIdSubj, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp reviewpb.GetByUserIdResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+var reviewSorts = map[string]reviewpb.Sort{
+	"newest":  reviewpb.Sort_SORT_NEWEST,
+	"oldest":  reviewpb.Sort_SORT_OLDEST,
+	"highest": reviewpb.Sort_SORT_HIGHEST_SCORE,
+	"lowest":  reviewpb.Sort_SORT_LOWEST_SCORE,
+	"helpful": reviewpb.Sort_SORT_MOST_HELPFUL,
+}
+
+type reviewList struct {
+	limit         int32
+	cursor        string
+	sort          reviewpb.Sort
+	minScore      *int32
+	maxScore      *int32
+	createdAfter  *int64
+	createdBefore *int64
+}
+
+// parseReviewList reads the page of reviews from the query, which may set
+// limit, cursor, sort (newest, oldest, highest, lowest or helpful),
+// min_score, max_score and created_after, created_before as RFC 3339 times.
+func parseReviewList(ctx *fiber.Ctx) (reviewList, error) {
+	list := reviewList{
+		cursor: ctx.Query("cursor"),
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			return reviewList{}, fmt.Errorf("invalid limit %q", limit)
+		}
+		list.limit = int32(n)
+	}
+
+	if sort := ctx.Query("sort"); sort != "" {
+		s, ok := reviewSorts[sort]
+		if !ok {
+			return reviewList{}, fmt.Errorf("unknown sort %q", sort)
+		}
+		list.sort = s
+	}
+
+	for name, score := range map[string]**int32{
+		"min_score": &list.minScore,
+		"max_score": &list.maxScore,
+	} {
+		value := ctx.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return reviewList{}, fmt.Errorf("invalid %s %q", name, value)
+		}
+		*score = proto.Int32(int32(n))
+	}
+
+	for name, created := range map[string]**int64{
+		"created_after":  &list.createdAfter,
+		"created_before": &list.createdBefore,
+	} {
+		value := ctx.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return reviewList{}, fmt.Errorf("invalid %s %q", name, value)
+		}
+		*created = proto.Int64(t.Unix())
+	}
+
+	return list, nil
+}
+
 func (ri ReviewAPI) Get(ctx *fiber.Ctx) error {
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
@@ -78,7 +247,7 @@ func (ri ReviewAPI) Get(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ri.conn.RequestWithContext(ctx.Context(), reviewGetSubj, data)
+	respMsg, err := natsRequest(ctx, ri.conn, reviewGetSubj, data)
 	if err != nil {
 		return err
 	}
@@ -91,18 +260,25 @@ func (ri ReviewAPI) Get(ctx *fiber.Ctx) error {
 	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
 }
 
+// Put adds the review of the authenticated user.
 func (ri ReviewAPI) Put(ctx *fiber.Ctx) error {
 	var req reviewpb.CreateRequest
 	if err := ctx.BodyParser(&req); err != nil {
 		return err
 	}
 
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	req.UserId = userID
+
 	data, err := proto.Marshal(&req)
 	if err != nil {
 		return err
 	}
 
-	respMsg, err := ri.conn.RequestWithContext(ctx.Context(), reviewPutSubj, data)
+	respMsg, err := natsRequest(ctx, ri.conn, reviewPutSubj, data)
 	if err != nil {
 		return err
 	}
@@ -115,6 +291,38 @@ func (ri ReviewAPI) Put(ctx *fiber.Ctx) error {
 	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
 }
 
+// Upsert creates the review of the book by the authenticated user or
+// replaces the one they have already written.
+func (ri ReviewAPI) Upsert(ctx *fiber.Ctx) error {
+	var req reviewpb.CreateRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	req.UserId = userID
+
+	data, err := proto.Marshal(&req)
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ri.conn, reviewUpsertSubj, data)
+	if err != nil {
+		return err
+	}
+
+	var resp reviewpb.UpsertResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
 func (ri ReviewAPI) Update(ctx *fiber.Ctx) error {
 	var req reviewpb.UpdateRequest
 	if err := ctx.BodyParser(&req); err != nil {
@@ -126,7 +334,7 @@ func (ri ReviewAPI) Update(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ri.conn.RequestWithContext(ctx.Context(), reviewUpdateSubj, data)
+	respMsg, err := natsRequest(ctx, ri.conn, reviewUpdateSubj, data)
 	if err != nil {
 		return err
 	}
@@ -150,7 +358,104 @@ func (ri ReviewAPI) Delete(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	respMsg, err := ri.conn.RequestWithContext(ctx.Context(), reviewDeleteSubj, data)
+	respMsg, err := natsRequest(ctx, ri.conn, reviewDeleteSubj, data)
+	if err != nil {
+		return err
+	}
+
+	var resp reviewpb.EmptyResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Vote records whether the authenticated user found the review helpful.
+func (ri ReviewAPI) Vote(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req reviewpb.VoteRequest
+	if err = ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.ReviewId = int64(reviewID)
+	req.UserId = userID
+
+	return ri.requestEmpty(ctx, reviewVoteSubj, &req)
+}
+
+func (ri ReviewAPI) Unvote(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := reviewpb.UnvoteRequest{
+		ReviewId: int64(reviewID),
+		UserId:   userID,
+	}
+
+	return ri.requestEmpty(ctx, reviewUnvoteSubj, &req)
+}
+
+// History returns a page of the earlier versions of the review, oldest
+// first. The query may set limit and after, the next_after of the previous
+// page.
+func (ri ReviewAPI) History(ctx *fiber.Ctx) error {
+	reviewID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	var params struct {
+		After int32 `query:"after"`
+		Limit int32 `query:"limit"`
+	}
+	if err = ctx.QueryParser(&params); err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	data, err := proto.Marshal(&reviewpb.HistoryRequest{
+		ReviewId: int64(reviewID),
+		After:    params.After,
+		Limit:    params.Limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ri.conn, reviewHistorySubj, data)
+	if err != nil {
+		return err
+	}
+
+	var resp reviewpb.HistoryResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ri ReviewAPI) requestEmpty(ctx *fiber.Ctx, subj string, req proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ri.conn, subj, data)
 	if err != nil {
 		return err
 	}
@@ -162,3 +467,64 @@ func (ri ReviewAPI) Delete(ctx *fiber.Ctx) error {
 
 	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
 }
+
+// BookStats returns the review count, mean scores and score histogram
+// of the book.
+func (ri ReviewAPI) BookStats(ctx *fiber.Ctx) error {
+	bookID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	resp, err := ri.stats(ctx, []int64{int64(bookID)})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != fiber.StatusOK || len(resp.Stats) != 1 {
+		return ctx.Status(int(resp.StatusCode)).JSON(resp)
+	}
+
+	return ctx.JSON(resp.Stats[0])
+}
+
+// Stats returns the stats of several books at once, their ids are given
+// as book_ids=1,2,3.
+func (ri ReviewAPI) Stats(ctx *fiber.Ctx) error {
+	var bookIDs []int64
+	for _, id := range strings.Split(ctx.Query("book_ids"), ",") {
+		if id == "" {
+			continue
+		}
+		bookID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		bookIDs = append(bookIDs, bookID)
+	}
+
+	resp, err := ri.stats(ctx, bookIDs)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(resp)
+}
+
+func (ri ReviewAPI) stats(ctx *fiber.Ctx, bookIDs []int64) (*reviewpb.StatsResponse, error) {
+	data, err := proto.Marshal(&reviewpb.StatsRequest{BookIds: bookIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	respMsg, err := natsRequest(ctx, ri.conn, reviewStatsSubj, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp reviewpb.StatsResponse
+	if err = proto.Unmarshal(respMsg.Data, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
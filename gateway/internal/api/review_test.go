@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "github.com/lunn06/library/gateway/internal/api/proto/review"
+)
+
+func TestParseReviewList(t *testing.T) {
+	var (
+		list reviewList
+		err  error
+	)
+	app := fiber.New()
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		list, err = parseReviewList(ctx)
+		return nil
+	})
+
+	query := "/?limit=5&cursor=abc&sort=helpful&min_score=3&max_score=5" +
+		"&created_after=2025-01-02T00:00:00Z&created_before=2025-02-01T00:00:00Z"
+	_, testErr := app.Test(httptest.NewRequest(http.MethodGet, query, nil))
+	require.NoError(t, testErr)
+	require.NoError(t, err)
+	assert.Equal(t, reviewList{
+		limit:         5,
+		cursor:        "abc",
+		sort:          reviewpb.Sort_SORT_MOST_HELPFUL,
+		minScore:      proto.Int32(3),
+		maxScore:      proto.Int32(5),
+		createdAfter:  proto.Int64(1735776000),
+		createdBefore: proto.Int64(1738368000),
+	}, list)
+
+	_, testErr = app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, testErr)
+	require.NoError(t, err)
+	assert.Equal(t, reviewList{}, list)
+
+	for _, query := range []string{"/?limit=x", "/?sort=best", "/?min_score=high", "/?created_after=yesterday"} {
+		_, testErr = app.Test(httptest.NewRequest(http.MethodGet, query, nil))
+		require.NoError(t, testErr)
+		assert.Error(t, err, query)
+	}
+}
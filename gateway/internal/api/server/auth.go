@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+)
+
+const (
+	userIDKey = "userID"
+	rolesKey  = "roles"
+)
+
+type AuthConfig struct {
+	// Secret verifies HS256 tokens.
+	Secret string
+	// JWKS is the path or the http(s) URL of the key set verifying RS256
+	// tokens. A key set behind a URL is fetched again for unknown key ids,
+	// at most once per JWKSRefresh.
+	JWKS        string        `envconfig:"JWKS"`
+	JWKSRefresh time.Duration `default:"5m" split_words:"true"`
+	Issuer      string
+	Audience    string
+	// UserClaim names the claim with the numeric id of the user.
+	UserClaim string `default:"sub" split_words:"true"`
+	// RolesClaim names the claim with the roles of the user, a list or a
+	// space separated string, e.g. moderator or admin.
+	RolesClaim string        `default:"roles" split_words:"true"`
+	Leeway     time.Duration `default:"30s"`
+}
+
+// User is the subject of a valid token.
+type User struct {
+	ID    int64
+	Roles []string
+}
+
+// NewAuth fails unless a secret or a key set is configured, the gateway
+// does not run without authentication.
+func NewAuth(cfg AuthConfig) (*Auth, error) {
+	auth := &Auth{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var methods []string
+	if cfg.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKS != "" {
+		if err := auth.loadKeys(context.Background()); err != nil {
+			return nil, fmt.Errorf("loading key set %s: %w", cfg.JWKS, err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("auth: neither a secret nor a key set is configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	auth.parser = jwt.NewParser(opts...)
+
+	return auth, nil
+}
+
+// Auth authenticates the requests with bearer JWTs.
+type Auth struct {
+	cfg    AuthConfig
+	parser *jwt.Parser
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]*rsa.PublicKey
+	loadedAt time.Time
+}
+
+// Middleware sets the user of a request with a valid token, see UserID and
+// Roles.
+// Requests without a token pass anonymous, the ones with an invalid token
+// are rejected.
+func (a *Auth) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		header := ctx.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return ctx.Next()
+		}
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return fiber.NewError(fiber.StatusUnauthorized, "expected a bearer token")
+		}
+
+		user, err := a.Authenticate(ctx.Context(), token)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		ctx.Locals(userIDKey, user.ID)
+		ctx.Locals(rolesKey, user.Roles)
+
+		return ctx.Next()
+	}
+}
+
+// Authenticate returns the user of a valid token.
+func (a *Auth) Authenticate(ctx context.Context, token string) (User, error) {
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return []byte(a.cfg.Secret), nil
+		default:
+			kid, _ := t.Header["kid"].(string)
+			return a.key(ctx, kid)
+		}
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	var userID int64
+	switch value := claims[a.cfg.UserClaim].(type) {
+	case string:
+		userID, err = strconv.ParseInt(value, 10, 64)
+	case float64:
+		userID = int64(value)
+		if float64(userID) != value {
+			err = errors.New("not an integer")
+		}
+	default:
+		err = errors.New("missing")
+	}
+	if err != nil || userID <= 0 {
+		return User{}, fmt.Errorf("invalid %s claim", a.cfg.UserClaim)
+	}
+
+	roles, err := rolesClaim(claims[a.cfg.RolesClaim])
+	if err != nil {
+		return User{}, fmt.Errorf("invalid %s claim: %w", a.cfg.RolesClaim, err)
+	}
+
+	return User{ID: userID, Roles: roles}, nil
+}
+
+func rolesClaim(value any) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(value), nil
+	case []any:
+		roles := make([]string, len(value))
+		for i, role := range value {
+			s, ok := role.(string)
+			if !ok || s == "" || strings.ContainsAny(s, ", ") {
+				return nil, fmt.Errorf("bad role %v", role)
+			}
+			roles[i] = s
+		}
+		return roles, nil
+	default:
+		return nil, errors.New("neither a list nor a string")
+	}
+}
+
+// key returns the key with the id, the only key of the set for tokens
+// without one.
+func (a *Auth) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	lookup := func() (*rsa.PublicKey, bool, bool) {
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+
+		stale := time.Since(a.loadedAt) >= a.cfg.JWKSRefresh
+		if kid == "" && len(a.keys) == 1 {
+			for _, key := range a.keys {
+				return key, true, stale
+			}
+		}
+		key, ok := a.keys[kid]
+		return key, ok, stale
+	}
+
+	key, ok, stale := lookup()
+	if ok {
+		return key, nil
+	}
+	if !stale || !isURL(a.cfg.JWKS) {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if err := a.loadKeys(ctx); err != nil {
+		return nil, fmt.Errorf("refreshing key set: %w", err)
+	}
+	if key, ok, _ = lookup(); !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (a *Auth) loadKeys(ctx context.Context) error {
+	data, err := a.readKeySet(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = keys
+	a.loadedAt = time.Now()
+
+	return nil
+}
+
+func (a *Auth) readKeySet(ctx context.Context) ([]byte, error) {
+	if !isURL(a.cfg.JWKS) {
+		return os.ReadFile(a.cfg.JWKS)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.cfg.JWKS, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// parseKeySet reads the RSA keys of a JWK set (RFC 7517), skipping the
+// others.
+func parseKeySet(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: exponent: %w", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q: exponent too large", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+
+	return keys, nil
+}
+
+// UserID returns the id of the authenticated user of the request.
+func UserID(ctx *fiber.Ctx) (int64, bool) {
+	userID, ok := ctx.Locals(userIDKey).(int64)
+	return userID, ok
+}
+
+// Roles returns the roles of the authenticated user of the request.
+func Roles(ctx *fiber.Ctx) []string {
+	roles, _ := ctx.Locals(rolesKey).([]string)
+	return roles
+}
+
+// Principal returns the authenticated user of the request as the services
+// see it, the anonymous one for anonymous requests.
+func Principal(ctx *fiber.Ctx) authz.Principal {
+	userID, ok := UserID(ctx)
+	if !ok {
+		return authz.Principal{}
+	}
+
+	p := authz.Principal{UserID: int(userID)}
+	for _, role := range Roles(ctx) {
+		p.Roles = append(p.Roles, authz.Role(role))
+	}
+
+	return p
+}
+
+// RequireUser returns the id of the authenticated user of the request,
+// failing with 401 for anonymous requests.
+func RequireUser(ctx *fiber.Ctx) (int64, error) {
+	userID, ok := UserID(ctx)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusUnauthorized, "authentication required")
+	}
+
+	return userID, nil
+}
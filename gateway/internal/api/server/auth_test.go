@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func signHS256(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthenticateHS256(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{Secret: testSecret, UserClaim: "sub", RolesClaim: "roles", Issuer: "library"})
+	require.NoError(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	user, err := auth.Authenticate(t.Context(), signHS256(t, jwt.MapClaims{"sub": "42", "iss": "library", "exp": exp}))
+	require.NoError(t, err)
+	assert.Equal(t, User{ID: 42}, user)
+
+	for roles, want := range map[any][]string{
+		"moderator admin": {"moderator", "admin"},
+		"":                {},
+	} {
+		user, err = auth.Authenticate(t.Context(), signHS256(t, jwt.MapClaims{"sub": "42", "iss": "library", "exp": exp, "roles": roles}))
+		require.NoError(t, err)
+		assert.Equal(t, want, user.Roles)
+	}
+	user, err = auth.Authenticate(t.Context(), signHS256(t, jwt.MapClaims{"sub": "42", "iss": "library", "exp": exp, "roles": []string{"admin"}}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, user.Roles)
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired":      {"sub": "42", "iss": "library", "exp": time.Now().Add(-time.Hour).Unix()},
+		"no expiry":    {"sub": "42", "iss": "library"},
+		"other issuer": {"sub": "42", "iss": "other", "exp": exp},
+		"no user":      {"iss": "library", "exp": exp},
+		"bad user":     {"sub": "alice", "iss": "library", "exp": exp},
+		"bad roles":    {"sub": "42", "iss": "library", "exp": exp, "roles": []any{"admin", 1}},
+	} {
+		_, err = auth.Authenticate(t.Context(), signHS256(t, claims))
+		assert.Error(t, err, name)
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "42", "iss": "library", "exp": exp}).
+		SignedString([]byte("other-secret"))
+	require.NoError(t, err)
+	_, err = auth.Authenticate(t.Context(), forged)
+	assert.Error(t, err)
+}
+
+func TestAuthenticateRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	set, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, set, 0o600))
+
+	auth, err := NewAuth(AuthConfig{JWKS: path, UserClaim: "uid"})
+	require.NoError(t, err)
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"uid": 7,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	user, err := auth.Authenticate(t.Context(), sign("k1"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, user.ID)
+
+	_, err = auth.Authenticate(t.Context(), sign("k2"))
+	assert.Error(t, err)
+
+	_, err = auth.Authenticate(t.Context(), signHS256(t, jwt.MapClaims{"uid": 7, "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.Error(t, err, "HS256 is not configured")
+}
+
+func TestNewAuthRequiresKeys(t *testing.T) {
+	_, err := NewAuth(AuthConfig{})
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{Secret: testSecret, UserClaim: "sub", RolesClaim: "roles"})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(auth.Middleware())
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		userID, ok := UserID(ctx)
+		if !ok {
+			return ctx.SendString("anonymous")
+		}
+		return ctx.SendString(strconv.FormatInt(userID, 10) + strings.Join(Roles(ctx), ","))
+	})
+
+	for header, want := range map[string]struct {
+		status int
+		body   string
+	}{
+		"": {fiber.StatusOK, "anonymous"},
+		"Bearer " + signHS256(t, jwt.MapClaims{"sub": "5", "exp": time.Now().Add(time.Hour).Unix()}):                   {fiber.StatusOK, "5"},
+		"Bearer " + signHS256(t, jwt.MapClaims{"sub": "5", "exp": time.Now().Add(time.Hour).Unix(), "roles": "admin"}): {fiber.StatusOK, "5admin"},
+		"Bearer garbage":     {fiber.StatusUnauthorized, ""},
+		"Basic dXNlcjpwYXNz": {fiber.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(fiber.HeaderAuthorization, header)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, want.status, resp.StatusCode, header)
+		if want.body != "" {
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, want.body, string(body))
+		}
+	}
+}
@@ -9,4 +9,5 @@ type Config struct {
 	ReadTimeout  time.Duration `default:"5s" split_words:"true"`
 	WriteTimeout time.Duration `default:"5s" split_words:"true"`
 	IdleTimeout  time.Duration `default:"15s" split_words:"true"`
+	BodyLimit    int           `default:"4194304" split_words:"true"`
 }
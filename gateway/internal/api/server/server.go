@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,8 +20,16 @@ func NewServer(cfg Config) *Server {
 		EnablePrintRoutes:     cfg.Debug,
 		DisableStartupMessage: !cfg.Debug,
 		JSONEncoder: func(v interface{}) ([]byte, error) {
-			return protojson.Marshal(v.(proto.Message))
+			if m, ok := v.(proto.Message); ok {
+				return protojson.Marshal(m)
+			}
+			return json.Marshal(v)
 		},
+
+		// book files are streamed part by part, see BookFileAPI.Put
+		BodyLimit:                    cfg.BodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	app.Use(slogfiber.New(slog.Default()))
@@ -40,6 +49,11 @@ func (s Server) Router() fiber.Router {
 	return s.app
 }
 
+// Routes lists the registered routes, without the middlewares.
+func (s Server) Routes() []fiber.Route {
+	return s.app.GetRoutes(true)
+}
+
 func (s Server) Listen() error {
 	return s.app.Listen(s.addr)
 }
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lunn06/library/bookfile/client"
+
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+// headers and values of the tus resumable upload protocol, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+
+	headerTusResumable   = "Tus-Resumable"
+	headerTusVersion     = "Tus-Version"
+	headerTusExtension   = "Tus-Extension"
+	headerTusMaxSize     = "Tus-Max-Size"
+	headerUploadLength   = "Upload-Length"
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadMetadata = "Upload-Metadata"
+	headerUploadExpires  = "Upload-Expires"
+	// not a part of tus, the bytes assembled into the book so far
+	headerUploadAssembled = "Upload-Assembled"
+
+	mimeOffsetOctetStream = "application/offset+octet-stream"
+)
+
+func NewBookUploadAPI(client *client.Client, limiter *ratelimit.Limiter, cfg BookFileConfig) BookUploadAPI {
+	return BookUploadAPI{
+		client:  client,
+		limiter: limiter,
+		maxSize: cfg.MaxSize,
+	}
+}
+
+// BookUploadAPI lets large book files be uploaded in parts over several
+// requests, resuming from the last stored part when one fails.
+type BookUploadAPI struct {
+	client  *client.Client
+	limiter *ratelimit.Limiter
+	maxSize int64
+}
+
+func (bu BookUploadAPI) Register(router fiber.Router) {
+	router.Group("/book/file/uploads", bu.tusResumable).
+		Options("", bu.Options).
+		Post("", bu.Create).
+		Head("/:id", bu.Info).
+		Patch("/:id", bu.Append).
+		Delete("/:id", bu.Delete)
+}
+
+func (bu BookUploadAPI) tusResumable(ctx *fiber.Ctx) error {
+	ctx.Set(headerTusResumable, tusVersion)
+	if ctx.Method() != fiber.MethodOptions && ctx.Get(headerTusResumable) != tusVersion {
+		ctx.Set(headerTusVersion, tusVersion)
+		return ctx.SendStatus(fiber.StatusPreconditionFailed)
+	}
+
+	return ctx.Next()
+}
+
+func (bu BookUploadAPI) Options(ctx *fiber.Ctx) error {
+	ctx.Set(headerTusVersion, tusVersion)
+	ctx.Set(headerTusExtension, tusExtensions)
+	ctx.Set(headerTusMaxSize, strconv.FormatInt(bu.maxSize, 10))
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (bu BookUploadAPI) Create(ctx *fiber.Ctx) error {
+	if _, err := server.RequireUser(ctx); err != nil {
+		return err
+	}
+
+	length, err := strconv.ParseUint(ctx.Get(headerUploadLength), 10, 64)
+	if err != nil {
+		// deferred lengths are not supported
+		return ctx.SendStatus(fiber.StatusBadRequest)
+	}
+	if length > uint64(bu.maxSize) {
+		return ctx.SendStatus(fiber.StatusRequestEntityTooLarge)
+	}
+
+	metadata := parseUploadMetadata(ctx.Get(headerUploadMetadata))
+	fileName := metadata["filename"]
+	if fileName == "" {
+		fileName = metadata["name"]
+	}
+
+	upload, err := bu.client.CreateUpload(ctx.Context(), fileName, length)
+	if err != nil {
+		return err
+	}
+
+	ctx.Location("/book/file/uploads/" + upload.ID.String())
+	ctx.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	return ctx.SendStatus(fiber.StatusCreated)
+}
+
+func (bu BookUploadAPI) Info(ctx *fiber.Ctx) error {
+	uploadID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+
+	upload, err := bu.client.Upload(ctx.Context(), uploadID)
+	switch {
+	case client.IsErrResourceNotFound(err):
+		return ctx.SendStatus(fiber.StatusNotFound)
+	case client.IsErrInvalidFormat(err):
+		return ctx.SendStatus(fiber.StatusUnprocessableEntity)
+	case err != nil:
+		return err
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(headerUploadLength, strconv.FormatUint(upload.Length, 10))
+	setUploadHeaders(ctx, upload)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+// Append stores the request body as the part of the upload starting at
+// Upload-Offset, the book is assembled in the background after the last
+// part.
+func (bu BookUploadAPI) Append(ctx *fiber.Ctx) error {
+	if _, err := server.RequireUser(ctx); err != nil {
+		return err
+	}
+
+	if ctx.Get(fiber.HeaderContentType) != mimeOffsetOctetStream {
+		return ctx.SendStatus(fiber.StatusUnsupportedMediaType)
+	}
+
+	uploadID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+
+	offset, err := strconv.ParseUint(ctx.Get(headerUploadOffset), 10, 64)
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusBadRequest)
+	}
+
+	quota, err := bu.limiter.QuotaReader(ctx, requestBody(ctx))
+	if quotaExceeded(ctx, err) {
+		return ctx.SendStatus(fiber.StatusTooManyRequests)
+	}
+	if err != nil {
+		return err
+	}
+
+	upload, err := bu.client.AppendUpload(ctx.Context(), uploadID, offset, quota)
+	chargeQuota(ctx, quota)
+	switch {
+	case quotaExceeded(ctx, err):
+		return ctx.SendStatus(fiber.StatusTooManyRequests)
+	case client.IsErrResourceNotFound(err):
+		return ctx.SendStatus(fiber.StatusNotFound)
+	case client.IsErrUploadConflict(err):
+		return ctx.SendStatus(fiber.StatusConflict)
+	case client.IsErrInvalidFormat(err):
+		return ctx.SendStatus(fiber.StatusUnprocessableEntity)
+	case err != nil:
+		return err
+	}
+
+	setUploadHeaders(ctx, upload)
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (bu BookUploadAPI) Delete(ctx *fiber.Ctx) error {
+	if _, err := server.RequireUser(ctx); err != nil {
+		return err
+	}
+
+	uploadID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+
+	err = bu.client.DeleteUpload(ctx.Context(), uploadID)
+	if client.IsErrResourceNotFound(err) {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// setUploadHeaders reports the progress of the upload and of its assembly,
+// and the book once it is assembled.
+func setUploadHeaders(ctx *fiber.Ctx, upload client.Upload) {
+	ctx.Set(headerUploadOffset, strconv.FormatUint(upload.Offset, 10))
+	if upload.BookUUID != uuid.Nil {
+		ctx.Set(fiber.HeaderContentLocation, "/book/file/"+upload.BookUUID.String())
+		return
+	}
+	if upload.Assembling {
+		ctx.Set(headerUploadAssembled, strconv.FormatUint(upload.Assembled, 10))
+	}
+	ctx.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata decodes the comma separated pairs of a key and
+// a base64 encoded value, malformed pairs are skipped.
+func parseUploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata
+}
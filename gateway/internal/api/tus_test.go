@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata := parseUploadMetadata("filename bWFzdGVyLmVwdWI=, is_confidential,broken !!!")
+	assert.Equal(t, map[string]string{
+		"filename":        "master.epub",
+		"is_confidential": "",
+	}, metadata)
+
+	assert.Empty(t, parseUploadMetadata(""))
+}
+
+func TestTusResumable(t *testing.T) {
+	limiter, err := ratelimit.NewLimiter(ratelimit.Config{}, nil)
+	require.NoError(t, err)
+
+	auth, err := server.NewAuth(server.AuthConfig{Secret: "secret", UserClaim: "sub"})
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(auth.Middleware())
+	NewBookUploadAPI(nil, limiter, BookFileConfig{MaxSize: 1024}).Register(app)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodOptions, "/book/file/uploads", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, tusVersion, resp.Header.Get(headerTusVersion))
+	assert.Equal(t, tusExtensions, resp.Header.Get(headerTusExtension))
+	assert.Equal(t, "1024", resp.Header.Get(headerTusMaxSize))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/book/file/uploads", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+
+	req := httptest.NewRequest(http.MethodPost, "/book/file/uploads", nil)
+	req.Header.Set(headerTusResumable, tusVersion)
+	req.Header.Set(headerUploadLength, "2048")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, "anonymous upload")
+
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, tusVersion, resp.Header.Get(headerTusResumable))
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
+)
+
+const maxFileNameLen = 1024
+
+var errBookFileTooLarge = errors.New("book file is too large")
+
+// requestBody returns the request body without buffering it, as long as the
+// server runs with StreamRequestBody.
+func requestBody(ctx *fiber.Ctx) io.Reader {
+	if r := ctx.Context().RequestBodyStream(); r != nil {
+		return r
+	}
+	return bytes.NewReader(ctx.Body())
+}
+
+func newMaxBytesReader(r io.Reader, limit int64) *maxBytesReader {
+	return &maxBytesReader{r: r, remaining: limit}
+}
+
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (mr *maxBytesReader) Read(p []byte) (int, error) {
+	if mr.remaining < 0 {
+		return 0, errBookFileTooLarge
+	}
+	if int64(len(p)) > mr.remaining+1 {
+		p = p[:mr.remaining+1]
+	}
+
+	n, err := mr.r.Read(p)
+	mr.remaining -= int64(n)
+	if mr.remaining < 0 {
+		return 0, errBookFileTooLarge
+	}
+
+	return n, err
+}
+
+// quotaExceeded tells whether err is the one of an upload over the daily
+// quota of the client, setting Retry-After to when it is reset.
+func quotaExceeded(ctx *fiber.Ctx, err error) bool {
+	var quotaErr ratelimit.ErrQuotaExceeded
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	retryAfter := time.Until(quotaErr.Reset).Round(time.Second)
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(max(retryAfter.Seconds(), 1))))
+	return true
+}
+
+func chargeQuota(ctx *fiber.Ctx, quota *ratelimit.QuotaReader) {
+	if err := quota.Charge(ctx.Context()); err != nil {
+		slog.Error("Charging upload quota failed", "err", err)
+	}
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBytesReader(t *testing.T) {
+	data, err := io.ReadAll(newMaxBytesReader(strings.NewReader("0123456789"), 10))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	_, err = io.Copy(io.Discard, newMaxBytesReader(strings.NewReader("0123456789"), 9))
+	assert.ErrorIs(t, err, errBookFileTooLarge)
+
+	_, err = io.Copy(io.Discard, newMaxBytesReader(bytes.NewReader(make([]byte, 1<<20)), 1<<10))
+	assert.ErrorIs(t, err, errBookFileTooLarge)
+}
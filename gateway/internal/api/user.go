@@ -0,0 +1,179 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	userpb "github.com/lunn06/library/gateway/internal/api/proto/user"
+	"github.com/lunn06/library/gateway/internal/api/server"
+)
+
+const (
+	userRegisterSubj = "user.register"
+	userLoginSubj    = "user.login"
+	userRefreshSubj  = "user.refresh"
+	userLogoutSubj   = "user.logout"
+	userGetSubj      = "user.get"
+	userUpdateSubj   = "user.update"
+)
+
+// userMeRoute names the route of the profile of the user, it requests
+// userGetSubj as well.
+const userMeRoute = "user.me"
+
+func NewUserAPI(conn *nats.Conn) UserAPI {
+	return UserAPI{conn: conn}
+}
+
+// UserAPI registers users, logs them in and serves their profiles.
+type UserAPI struct {
+	conn *nats.Conn
+}
+
+func (ua UserAPI) Register(router fiber.Router) {
+	router.
+		Post("/auth/register", ua.SignUp).
+		Name(userRegisterSubj).
+		Post("/auth/login", ua.Login).
+		Name(userLoginSubj).
+		Post("/auth/refresh", ua.Refresh).
+		Name(userRefreshSubj).
+		Post("/auth/logout", ua.Logout).
+		Name(userLogoutSubj).
+		Get("/user/me", ua.Me).
+		Name(userMeRoute).
+		Patch("/user/me", ua.Update).
+		Name(userUpdateSubj).
+		Get("/user/:id", ua.Get).
+		Name(userGetSubj)
+}
+
+// SignUp registers a user with the email, password and display_name of
+// the body.
+func (ua UserAPI) SignUp(ctx *fiber.Ctx) error {
+	var req userpb.RegisterRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	var resp userpb.RegisterResponse
+	if err := ua.request(ctx, userRegisterSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Login returns an access token to send as a bearer token and a refresh
+// token for Refresh.
+func (ua UserAPI) Login(ctx *fiber.Ctx) error {
+	var req userpb.LoginRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	var resp userpb.TokenResponse
+	if err := ua.request(ctx, userLoginSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Refresh exchanges the refresh_token of the body for new tokens.
+func (ua UserAPI) Refresh(ctx *fiber.Ctx) error {
+	var req userpb.RefreshRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	var resp userpb.TokenResponse
+	if err := ua.request(ctx, userRefreshSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Logout revokes the refresh_token of the body.
+func (ua UserAPI) Logout(ctx *fiber.Ctx) error {
+	var req userpb.LogoutRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	var resp userpb.EmptyResponse
+	if err := ua.request(ctx, userLogoutSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+// Me returns the profile of the authenticated user, with their email.
+func (ua UserAPI) Me(ctx *fiber.Ctx) error {
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ua.get(ctx, userID)
+}
+
+func (ua UserAPI) Get(ctx *fiber.Ctx) error {
+	userID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+
+	return ua.get(ctx, int64(userID))
+}
+
+// Update replaces the display_name, avatar_url and bio of the
+// authenticated user.
+func (ua UserAPI) Update(ctx *fiber.Ctx) error {
+	userID, err := server.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req userpb.UpdateRequest
+	if err = ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	req.UserId = userID
+
+	var resp userpb.GetResponse
+	if err = ua.request(ctx, userUpdateSubj, &req, &resp); err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ua UserAPI) get(ctx *fiber.Ctx, userID int64) error {
+	var resp userpb.GetResponse
+	err := ua.request(ctx, userGetSubj, &userpb.GetRequest{
+		UserId: userID,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(int(resp.StatusCode)).JSON(&resp)
+}
+
+func (ua UserAPI) request(ctx *fiber.Ctx, subj string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	respMsg, err := natsRequest(ctx, ua.conn, subj, data)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(respMsg.Data, resp)
+}
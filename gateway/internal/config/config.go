@@ -1,7 +1,9 @@
 package config
 
 import (
+	"github.com/lunn06/library/gateway/internal/api"
 	"github.com/lunn06/library/gateway/internal/api/nats"
+	"github.com/lunn06/library/gateway/internal/api/ratelimit"
 	"github.com/lunn06/library/gateway/internal/api/server"
 	"go.uber.org/fx"
 )
@@ -9,6 +11,9 @@ import (
 type Config struct {
 	fx.Out
 
-	Nats   nats.Config
-	Server server.Config
+	Nats      nats.Config
+	Server    server.Config
+	Auth      server.AuthConfig
+	RateLimit ratelimit.Config
+	BookFile  api.BookFileConfig
 }
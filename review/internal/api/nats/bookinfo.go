@@ -0,0 +1,60 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	bookpb "github.com/lunn06/library/review/internal/api/proto/book"
+	"github.com/lunn06/library/review/internal/app/service"
+)
+
+const bookInfoTimeout = 10 * time.Second
+
+var _ service.BookChecker = (*BookInfoClient)(nil)
+
+func NewBookInfoClient(conn *nats.Conn) *BookInfoClient {
+	return &BookInfoClient{conn: conn}
+}
+
+// BookInfoClient asks bookinfo about books.
+type BookInfoClient struct {
+	conn *nats.Conn
+}
+
+func (bc *BookInfoClient) MissingBooks(ctx context.Context, bookIDs []int) ([]int, error) {
+	req := bookpb.ExistsRequest{BookIds: make([]int64, len(bookIDs))}
+	for i, id := range bookIDs {
+		req.BookIds[i] = int64(id)
+	}
+	data, err := proto.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bookInfoTimeout)
+	defer cancel()
+	msg, err := bc.conn.RequestWithContext(ctx, "book.exists", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp bookpb.ExistsResponse
+	if err = proto.Unmarshal(msg.Data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("book.exists responded with status %d", resp.StatusCode)
+	}
+
+	missing := make([]int, len(resp.MissingBookIds))
+	for i, id := range resp.MissingBookIds {
+		missing[i] = int(id)
+	}
+
+	return missing, nil
+}
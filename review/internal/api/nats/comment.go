@@ -0,0 +1,264 @@
+package nats
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "github.com/lunn06/library/review/internal/api/proto/review"
+	"github.com/lunn06/library/review/internal/app/service"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+func RegisterCommentConsumer(conn *nats.Conn, cons *CommentConsumer) error {
+	mws := []middleware.Middleware{
+		middleware.Recover(),
+		middleware.Logger(slog.Default()),
+	}
+	for subj, handler := range map[string]nats.MsgHandler{
+		"review.comment.get":    cons.Get,
+		"review.comment.list":   cons.List,
+		"review.comment.put":    cons.Put,
+		"review.comment.update": cons.Update,
+		"review.comment.delete": cons.Delete,
+	} {
+		_, err := conn.Subscribe(subj, middleware.With(handler, mws...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewCommentConsumer(service *service.CommentService) *CommentConsumer {
+	return &CommentConsumer{
+		service: service,
+	}
+}
+
+type CommentConsumer struct {
+	service *service.CommentService
+}
+
+func (cc CommentConsumer) Get(msg *nats.Msg) {
+	var (
+		req  reviewpb.GetCommentRequest
+		resp reviewpb.GetCommentResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on comment get", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	comment, err := cc.service.Get(context.Background(), int(req.ReviewId), int(req.CommentId))
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Comment = commentToProto(comment)
+	resp.StatusCode = http.StatusOK
+}
+
+func (cc CommentConsumer) List(msg *nats.Msg) {
+	var (
+		req  reviewpb.ListCommentsRequest
+		resp reviewpb.ListCommentsResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on comments list", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	list := service.ListCommentsRequest{
+		ReviewID: int(req.ReviewId),
+		AfterID:  int(req.AfterId),
+		Limit:    int(req.Limit),
+	}
+
+	page, err := cc.service.List(context.Background(), list)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Comments = make([]*reviewpb.Comment, len(page.Comments))
+	for i, comment := range page.Comments {
+		resp.Comments[i] = commentToProto(comment)
+	}
+	resp.NextAfterId = int64(page.NextAfterID)
+	resp.StatusCode = http.StatusOK
+}
+
+func (cc CommentConsumer) Put(msg *nats.Msg) {
+	var (
+		req  reviewpb.CreateCommentRequest
+		resp reviewpb.CreateCommentResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on comment put", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	create := service.CreateCommentRequest{
+		ReviewID: int(req.ReviewId),
+		UserID:   int(req.UserId),
+		Text:     req.Text,
+	}
+	if req.ParentId != nil {
+		parentID := int(*req.ParentId)
+		create.ParentID = &parentID
+	}
+
+	id, err := cc.service.Create(context.Background(), create)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.CommentId = int64(id)
+	resp.StatusCode = http.StatusCreated
+}
+
+func (cc CommentConsumer) Update(msg *nats.Msg) {
+	var (
+		req  reviewpb.UpdateCommentRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on comment update", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = cc.service.Update(authz.MsgContext(msg), int(req.ReviewId), int(req.CommentId), req.Text)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (cc CommentConsumer) Delete(msg *nats.Msg) {
+	var (
+		req  reviewpb.DeleteCommentRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on comment delete", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = cc.service.Delete(authz.MsgContext(msg), int(req.ReviewId), int(req.CommentId))
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func commentToProto(comment domain.Comment) *reviewpb.Comment {
+	pb := &reviewpb.Comment{
+		Id:        int64(comment.ID),
+		ReviewId:  int64(comment.ReviewID),
+		UserId:    int64(comment.UserID),
+		Text:      comment.Text,
+		CreatedAt: comment.CreatedAt.Unix(),
+		UpdatedAt: comment.UpdatedAt.Unix(),
+		Deleted:   comment.Deleted(),
+	}
+	if comment.ParentID != nil {
+		pb.ParentId = proto.Int64(int64(*comment.ParentID))
+	}
+
+	return pb
+}
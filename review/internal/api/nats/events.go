@@ -0,0 +1,84 @@
+package nats
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lunn06/library/bookinfo/pkg/events"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/fx"
+	"google.golang.org/protobuf/proto"
+
+	eventspb "github.com/lunn06/library/review/internal/api/proto/events"
+	"github.com/lunn06/library/review/internal/app/service"
+)
+
+const (
+	bookEventsDurable = "review"
+	retryDelay        = 5 * time.Second
+)
+
+func NewJetStream(conn *nats.Conn) (jetstream.JetStream, error) {
+	return jetstream.New(conn)
+}
+
+// RegisterBookEventsConsumer subscribes to book.deleted with a durable
+// consumer, so events published while the service is down are not lost.
+func RegisterBookEventsConsumer(lifecycle fx.Lifecycle, js jetstream.JetStream, cons *BookEventsConsumer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := events.EnsureStream(ctx, js)
+	if err != nil {
+		return err
+	}
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       bookEventsDurable,
+		FilterSubject: events.BookDeleted,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	consumeCtx, err := consumer.Consume(cons.BookDeleted)
+	if err != nil {
+		return err
+	}
+	lifecycle.Append(fx.StopHook(consumeCtx.Stop))
+
+	return nil
+}
+
+func NewBookEventsConsumer(service *service.ReviewService) *BookEventsConsumer {
+	return &BookEventsConsumer{
+		service: service,
+	}
+}
+
+type BookEventsConsumer struct {
+	service *service.ReviewService
+}
+
+// BookDeleted removes the reviews of the deleted book. Deleting them again
+// is a no-op, so redelivered events are processed as usual.
+func (bc BookEventsConsumer) BookDeleted(msg jetstream.Msg) {
+	var event eventspb.BookDeleted
+	if err := proto.Unmarshal(msg.Data(), &event); err != nil {
+		slog.Error("Malformed book.deleted event", "err", err)
+		_ = msg.Term()
+		return
+	}
+
+	deleted, err := bc.service.DeleteAllByBookID(context.Background(), int(event.BookId))
+	if err != nil {
+		slog.Error("Error on deleting reviews of deleted book", "bookID", event.BookId, "err", err)
+		_ = msg.NakWithDelay(retryDelay)
+		return
+	}
+
+	slog.Info("Deleted reviews of deleted book", "bookID", event.BookId, "count", deleted)
+	_ = msg.Ack()
+}
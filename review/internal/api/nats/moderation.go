@@ -0,0 +1,174 @@
+package nats
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "github.com/lunn06/library/review/internal/api/proto/review"
+	"github.com/lunn06/library/review/internal/app/service"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+var moderationStatuses = map[domain.ModerationStatus]reviewpb.ModerationStatus{
+	domain.StatusPublished: reviewpb.ModerationStatus_MODERATION_STATUS_PUBLISHED,
+	domain.StatusPending:   reviewpb.ModerationStatus_MODERATION_STATUS_PENDING,
+	domain.StatusHidden:    reviewpb.ModerationStatus_MODERATION_STATUS_HIDDEN,
+	domain.StatusRejected:  reviewpb.ModerationStatus_MODERATION_STATUS_REJECTED,
+}
+
+var reportReasons = map[reviewpb.ReportReason]domain.ReportReason{
+	reviewpb.ReportReason_REPORT_REASON_OTHER:     domain.ReasonOther,
+	reviewpb.ReportReason_REPORT_REASON_SPAM:      domain.ReasonSpam,
+	reviewpb.ReportReason_REPORT_REASON_ABUSE:     domain.ReasonAbuse,
+	reviewpb.ReportReason_REPORT_REASON_OFF_TOPIC: domain.ReasonOffTopic,
+	reviewpb.ReportReason_REPORT_REASON_SPOILER:   domain.ReasonSpoiler,
+}
+
+func moderationStatus(status reviewpb.ModerationStatus) domain.ModerationStatus {
+	for s, pb := range moderationStatuses {
+		if pb == status {
+			return s
+		}
+	}
+
+	return domain.ModerationStatus(status.String())
+}
+
+// Report answers 409 when the user has already reported the review.
+func (rc ReviewConsumer) Report(msg *nats.Msg) {
+	var (
+		req  reviewpb.ReportRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review report", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	reason, ok := reportReasons[req.Reason]
+	if !ok {
+		reason = domain.ReportReason(req.Reason.String())
+	}
+	report := service.ReportRequest{
+		ReviewID: int(req.ReviewId),
+		UserID:   int(req.UserId),
+		Reason:   reason,
+		Comment:  req.Comment,
+	}
+
+	err = rc.service.Report(context.Background(), report)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrAlreadyExists(err) {
+		resp.StatusCode = http.StatusConflict
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusCreated
+}
+
+func (rc ReviewConsumer) Moderate(msg *nats.Msg) {
+	var (
+		req  reviewpb.ModerateRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review moderate", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = rc.service.Moderate(authz.MsgContext(msg), int(req.ReviewId), moderationStatus(req.Status))
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (rc ReviewConsumer) Queue(msg *nats.Msg) {
+	var (
+		req  reviewpb.QueueRequest
+		resp reviewpb.QueueResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on moderation queue", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	page, err := rc.service.Queue(authz.MsgContext(msg), service.ListRequest{
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+	})
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp = reviewpb.QueueResponse{
+		Reviews:    reviewItems(page.Reviews),
+		NextCursor: page.NextCursor,
+		StatusCode: http.StatusOK,
+	}
+}
@@ -1,13 +1,26 @@
 package nats
 
-import "go.uber.org/fx"
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/review/internal/app/service"
+)
 
 var Module = fx.Options(
 	fx.Provide(
 		NewConnection,
+		NewJetStream,
 		NewReviewConsumer,
+		NewCommentConsumer,
+		NewBookEventsConsumer,
+		fx.Annotate(
+			NewBookInfoClient,
+			fx.As(new(service.BookChecker)),
+		),
 	),
 	fx.Invoke(
 		RegisterReviewConsumer,
+		RegisterCommentConsumer,
+		RegisterBookEventsConsumer,
 	),
 )
@@ -2,9 +2,12 @@ package nats
 
 import (
 	"context"
+	stderrors "errors"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
 	"github.com/nats-io/nats.go"
 	"google.golang.org/protobuf/proto"
@@ -12,6 +15,7 @@ import (
 	reviewpb "github.com/lunn06/library/review/internal/api/proto/review"
 	"github.com/lunn06/library/review/internal/app/service"
 	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
 )
 
 func RegisterReviewConsumer(conn *nats.Conn, cons *ReviewConsumer) error {
@@ -22,9 +26,18 @@ func RegisterReviewConsumer(conn *nats.Conn, cons *ReviewConsumer) error {
 	for subj, handler := range map[string]nats.MsgHandler{
 		"review.get":            cons.Get,
 		"review.getAllByBookId": cons.GetAllByBookID,
+		"review.getAllByUserId": cons.GetAllByUserID,
 		"review.put":            cons.Put,
+		"review.upsert":         cons.Upsert,
 		"review.update":         cons.Update,
 		"review.delete":         cons.Delete,
+		"review.stats":          cons.Stats,
+		"review.vote":           cons.Vote,
+		"review.unvote":         cons.Unvote,
+		"review.report":         cons.Report,
+		"review.moderate":       cons.Moderate,
+		"review.queue":          cons.Queue,
+		"review.history":        cons.History,
 	} {
 		_, err := conn.Subscribe(subj, middleware.With(handler, mws...))
 		if err != nil {
@@ -64,8 +77,12 @@ func (rc ReviewConsumer) GetAllByBookID(msg *nats.Msg) {
 		return
 	}
 
-	reviews, err := rc.service.GetAllByBookID(context.Background(), int(req.BookId))
-	if errors.IsErrResourceNotFound(err) {
+	list := listRequest(req.Limit, req.Cursor, req.Sort, req.MinScore, req.MaxScore, req.CreatedAfter, req.CreatedBefore)
+	page, err := rc.service.GetAllByBookID(context.Background(), int(req.BookId), list)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
 		resp.StatusCode = http.StatusNotFound
 		return
 	} else if err != nil {
@@ -73,21 +90,45 @@ func (rc ReviewConsumer) GetAllByBookID(msg *nats.Msg) {
 		return
 	}
 
-	reviewItems := make([]*reviewpb.ReviewItem, len(reviews))
-	for i, review := range reviews {
-		reviewItems[i] = &reviewpb.ReviewItem{
-			Id:        int64(review.ID),
-			UserId:    int64(review.UserID),
-			BookId:    int64(review.BookID),
-			CreatedAt: review.CreatedAt.Unix(),
-			Title:     review.Title,
-			Text:      review.Text,
-			Score:     int32(review.Score),
+	resp = reviewpb.GetByBookIdResponse{
+		Reviews:    reviewItems(page.Reviews),
+		NextCursor: page.NextCursor,
+		StatusCode: http.StatusOK,
+	}
+}
+
+func (rc ReviewConsumer) GetAllByUserID(msg *nats.Msg) {
+	var (
+		req  reviewpb.GetByUserIdRequest
+		resp reviewpb.GetByUserIdResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on user reviews get", "err", err)
 		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = int32(http.StatusUnprocessableEntity)
+		return
 	}
 
-	resp = reviewpb.GetByBookIdResponse{
-		Reviews:    reviewItems,
+	list := listRequest(req.Limit, req.Cursor, req.Sort, req.MinScore, req.MaxScore, req.CreatedAfter, req.CreatedBefore)
+	page, err := rc.service.GetAllByUserID(context.Background(), int(req.UserId), list)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp = reviewpb.GetByUserIdResponse{
+		Reviews:    reviewItems(page.Reviews),
+		NextCursor: page.NextCursor,
 		StatusCode: http.StatusOK,
 	}
 }
@@ -121,14 +162,17 @@ func (rc ReviewConsumer) Get(msg *nats.Msg) {
 	}
 
 	resp = reviewpb.GetResponse{
-		Id:         int64(review.ID),
-		UserId:     int64(review.UserID),
-		BookId:     int64(review.BookID),
-		CreatedAt:  review.CreatedAt.Unix(),
-		Title:      review.Title,
-		Text:       review.Text,
-		Score:      int32(review.Score),
-		StatusCode: int32(http.StatusOK),
+		Id:             int64(review.ID),
+		UserId:         int64(review.UserID),
+		BookId:         int64(review.BookID),
+		CreatedAt:      review.CreatedAt.Unix(),
+		UpdatedAt:      review.UpdatedAt.Unix(),
+		Title:          review.Title,
+		Text:           review.Text,
+		Score:          int32(review.Score),
+		HelpfulCount:   int64(review.HelpfulCount),
+		UnhelpfulCount: int64(review.UnhelpfulCount),
+		StatusCode:     int32(http.StatusOK),
 	}
 }
 
@@ -151,12 +195,58 @@ func (rc ReviewConsumer) Put(msg *nats.Msg) {
 		return
 	}
 
+	create := service.CreateRequest{
+		UserID: int(req.UserId),
+		BookID: int(req.BookId),
+		Title:  req.Title,
+		Text:   req.Text,
+		Score:  int(req.Score),
+	}
+
+	review, err := rc.service.Create(context.Background(), create)
+	if stderrors.Is(err, domain.ErrInvalidScore) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrAlreadyExists(err) {
+		resp.StatusCode = http.StatusConflict
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp = reviewpb.CreateResponse{
+		ReviewId:   int64(review.ID),
+		Status:     moderationStatuses[review.Status],
+		StatusCode: int32(http.StatusOK),
+	}
+}
+
+// Upsert creates or replaces the review of the book by the user, answering
+// 201 and 200 respectively.
+func (rc ReviewConsumer) Upsert(msg *nats.Msg) {
+	var (
+		req  reviewpb.CreateRequest
+		resp reviewpb.UpsertResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review upsert", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
 	if err = proto.Unmarshal(msg.Data, &req); err != nil {
 		resp.StatusCode = int32(http.StatusUnprocessableEntity)
 		return
 	}
 
-	create := service.CreateRequest{
+	upsert := service.CreateRequest{
 		UserID: int(req.UserId),
 		BookID: int(req.BookId),
 		Title:  req.Title,
@@ -164,14 +254,26 @@ func (rc ReviewConsumer) Put(msg *nats.Msg) {
 		Score:  int(req.Score),
 	}
 
-	id, err := rc.service.Create(context.Background(), create)
-	if err != nil {
+	review, created, err := rc.service.Upsert(context.Background(), upsert)
+	if stderrors.Is(err, domain.ErrInvalidScore) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
 		resp.StatusCode = http.StatusInternalServerError
+		return
 	}
 
-	resp = reviewpb.CreateResponse{
-		ReviewId:   int64(id),
-		StatusCode: int32(http.StatusOK),
+	resp = reviewpb.UpsertResponse{
+		ReviewId:   int64(review.ID),
+		Created:    created,
+		Status:     moderationStatuses[review.Status],
+		StatusCode: http.StatusOK,
+	}
+	if created {
+		resp.StatusCode = http.StatusCreated
 	}
 }
 
@@ -201,11 +303,22 @@ func (rc ReviewConsumer) Update(msg *nats.Msg) {
 		Score: int(req.Score),
 	}
 
-	err = rc.service.Update(context.Background(), update)
-	if errors.IsErrResourceNotFound(err) {
+	err = rc.service.Update(authz.MsgContext(msg), update)
+	if stderrors.Is(err, domain.ErrInvalidScore) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
 		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
 	} else if err != nil {
 		resp.StatusCode = http.StatusInternalServerError
+		return
 	}
 
 	resp.StatusCode = int32(http.StatusOK)
@@ -230,11 +343,239 @@ func (rc ReviewConsumer) Delete(msg *nats.Msg) {
 		return
 	}
 
-	err = rc.service.Delete(context.Background(), int(req.ReviewId))
-	if err != nil {
+	err = rc.service.Delete(authz.MsgContext(msg), int(req.ReviewId))
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
 		resp.StatusCode = http.StatusInternalServerError
 		return
 	}
 
 	resp.StatusCode = int32(http.StatusOK)
 }
+
+func (rc ReviewConsumer) Vote(msg *nats.Msg) {
+	var (
+		req  reviewpb.VoteRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review vote", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	vote := service.VoteRequest{
+		ReviewID: int(req.ReviewId),
+		UserID:   int(req.UserId),
+		Helpful:  req.Helpful,
+	}
+
+	err = rc.service.Vote(context.Background(), vote)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (rc ReviewConsumer) Unvote(msg *nats.Msg) {
+	var (
+		req  reviewpb.UnvoteRequest
+		resp reviewpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review unvote", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	err = rc.service.Unvote(context.Background(), int(req.ReviewId), int(req.UserId))
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+func (rc ReviewConsumer) History(msg *nats.Msg) {
+	var (
+		req  reviewpb.HistoryRequest
+		resp reviewpb.HistoryResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review history", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	page, err := rc.service.History(context.Background(), int(req.ReviewId), int(req.After), int(req.Limit))
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Revisions = make([]*reviewpb.Revision, len(page.Revisions))
+	for i, revision := range page.Revisions {
+		resp.Revisions[i] = &reviewpb.Revision{
+			Number:   int32(revision.Number),
+			Title:    revision.Title,
+			Text:     revision.Text,
+			Score:    int32(revision.Score),
+			EditedAt: revision.EditedAt.Unix(),
+		}
+	}
+	resp.NextAfter = int32(page.NextAfter)
+	resp.StatusCode = http.StatusOK
+}
+
+// Stats aggregates the reviews of the requested books.
+func (rc ReviewConsumer) Stats(msg *nats.Msg) {
+	var (
+		req  reviewpb.StatsRequest
+		resp reviewpb.StatsResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on review stats", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = int32(http.StatusUnprocessableEntity)
+		return
+	}
+
+	bookIDs := make([]int, len(req.BookIds))
+	for i, bookID := range req.BookIds {
+		bookIDs[i] = int(bookID)
+	}
+
+	stats, err := rc.service.Stats(context.Background(), bookIDs)
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.Stats = make([]*reviewpb.BookStats, len(stats))
+	for i, bookStats := range stats {
+		histogram := make([]int64, len(bookStats.Histogram))
+		for score, count := range bookStats.Histogram {
+			histogram[score] = int64(count)
+		}
+		resp.Stats[i] = &reviewpb.BookStats{
+			BookId:       int64(bookStats.BookID),
+			Count:        int64(bookStats.Count),
+			Mean:         bookStats.Mean,
+			BayesianMean: bookStats.BayesianMean,
+			Histogram:    histogram,
+		}
+	}
+	resp.StatusCode = http.StatusOK
+}
+
+func listRequest(
+	limit int32,
+	cursor string,
+	sort reviewpb.Sort,
+	minScore, maxScore *int32,
+	createdAfter, createdBefore *int64,
+) service.ListRequest {
+	list := service.ListRequest{
+		Limit:  int(limit),
+		Cursor: cursor,
+		Sort:   domain.Sort(sort),
+	}
+	if minScore != nil {
+		score := int(*minScore)
+		list.MinScore = &score
+	}
+	if maxScore != nil {
+		score := int(*maxScore)
+		list.MaxScore = &score
+	}
+	if createdAfter != nil {
+		list.CreatedAfter = time.Unix(*createdAfter, 0)
+	}
+	if createdBefore != nil {
+		list.CreatedBefore = time.Unix(*createdBefore, 0)
+	}
+
+	return list
+}
+
+func reviewItems(reviews []domain.Review) []*reviewpb.ReviewItem {
+	items := make([]*reviewpb.ReviewItem, len(reviews))
+	for i, review := range reviews {
+		items[i] = &reviewpb.ReviewItem{
+			Id:             int64(review.ID),
+			UserId:         int64(review.UserID),
+			BookId:         int64(review.BookID),
+			CreatedAt:      review.CreatedAt.Unix(),
+			UpdatedAt:      review.UpdatedAt.Unix(),
+			Title:          review.Title,
+			Text:           review.Text,
+			Score:          int32(review.Score),
+			HelpfulCount:   int64(review.HelpfulCount),
+			UnhelpfulCount: int64(review.UnhelpfulCount),
+			Status:         moderationStatuses[review.Status],
+			ReportCount:    int64(review.ReportCount),
+		}
+	}
+
+	return items
+}
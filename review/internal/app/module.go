@@ -4,10 +4,12 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/lunn06/library/review/internal/app/repository"
+	"github.com/lunn06/library/review/internal/app/screen"
 	"github.com/lunn06/library/review/internal/app/service"
 )
 
 var Module = fx.Module("app",
 	repository.Module,
+	screen.Module,
 	service.Module,
 )
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/comment"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/converter"
+)
+
+type CommentRepo interface {
+	// Get finds the comment among the comments of the review.
+	Get(ctx context.Context, reviewID int, id int) (domain.Comment, error)
+	// GetAllByReviewID returns up to limit comments of the review with ids
+	// greater than afterID, oldest first.
+	GetAllByReviewID(ctx context.Context, reviewID int, afterID int, limit int) (domain.CommentPage, error)
+	Put(ctx context.Context, comment domain.Comment) (domain.Comment, error)
+	// Update changes the text of the comment, deleted comments are not found.
+	Update(ctx context.Context, reviewID int, id int, text string) error
+	// Delete marks the comment deleted and drops its text.
+	Delete(ctx context.Context, reviewID int, id int) error
+}
+
+var _ CommentRepo = (*EntCommentRepo)(nil)
+
+func NewEntCommentRepo(client *ent.Client) *EntCommentRepo {
+	return &EntCommentRepo{CommentClient: client.Comment}
+}
+
+type EntCommentRepo struct {
+	*ent.CommentClient
+}
+
+func (cr *EntCommentRepo) Get(ctx context.Context, reviewID int, id int) (domain.Comment, error) {
+	entComment, err := cr.
+		Query().
+		Where(
+			comment.ID(id),
+			comment.ReviewID(reviewID),
+		).
+		Only(ctx)
+	if ent.IsNotFound(err) {
+		return domain.Comment{}, errors.ErrNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.Comment{}, err
+	}
+
+	return converter.CommentToDomain(entComment), nil
+}
+
+func (cr *EntCommentRepo) GetAllByReviewID(
+	ctx context.Context,
+	reviewID int,
+	afterID int,
+	limit int,
+) (domain.CommentPage, error) {
+	entComments, err := cr.
+		Query().
+		Where(
+			comment.ReviewID(reviewID),
+			comment.IDGT(afterID),
+		).
+		Order(ent.Asc(comment.FieldID)).
+		Limit(limit + 1).
+		All(ctx)
+	if err != nil {
+		return domain.CommentPage{}, err
+	}
+
+	var page domain.CommentPage
+	if len(entComments) > limit {
+		entComments = entComments[:limit]
+		page.NextAfterID = entComments[len(entComments)-1].ID
+	}
+	page.Comments = converter.CommentsToDomain(entComments)
+
+	return page, nil
+}
+
+func (cr *EntCommentRepo) Put(ctx context.Context, c domain.Comment) (domain.Comment, error) {
+	entComment, err := cr.
+		Create().
+		SetReviewID(c.ReviewID).
+		SetNillableParentID(c.ParentID).
+		SetUserID(c.UserID).
+		SetText(c.Text).
+		Save(ctx)
+	if ent.IsConstraintError(err) {
+		return domain.Comment{}, errors.ErrNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.Comment{}, err
+	}
+
+	return converter.CommentToDomain(entComment), nil
+}
+
+func (cr *EntCommentRepo) Update(ctx context.Context, reviewID int, id int, text string) error {
+	n, err := cr.CommentClient.
+		Update().
+		Where(
+			comment.ID(id),
+			comment.ReviewID(reviewID),
+			comment.DeletedAtIsNil(),
+		).
+		SetText(text).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrNotFound{Inner: fmt.Errorf("comment %d", id)}
+	}
+
+	return nil
+}
+
+func (cr *EntCommentRepo) Delete(ctx context.Context, reviewID int, id int) error {
+	n, err := cr.CommentClient.
+		Update().
+		Where(
+			comment.ID(id),
+			comment.ReviewID(reviewID),
+			comment.DeletedAtIsNil(),
+		).
+		SetText("").
+		SetDeletedAt(time.Now()).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrNotFound{Inner: fmt.Errorf("comment %d", id)}
+	}
+
+	return nil
+}
@@ -15,3 +15,11 @@ func TestIsErrNotFound(t *testing.T) {
 		fmt.Errorf("wrapped: %w", ErrNotFound{Inner: errors.New("outer: not found")}),
 	))
 }
+
+func TestIsErrAlreadyExists(t *testing.T) {
+	assert.True(t, IsErrAlreadyExists(ErrAlreadyExists{Inner: errors.New("duplicate key")}))
+	assert.False(t, IsErrAlreadyExists(ErrNotFound{Inner: errors.New("not found")}))
+	assert.True(t, IsErrAlreadyExists(
+		fmt.Errorf("wrapped: %w", ErrAlreadyExists{Inner: errors.New("duplicate key")}),
+	))
+}
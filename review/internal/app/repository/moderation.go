@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/report"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/review"
+	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
+)
+
+func (rr *EntReviewRepo) Report(ctx context.Context, r domain.Report) error {
+	return postgres.WithTx(ctx, rr.client, func(tx *ent.Tx) error {
+		exists, err := tx.Review.Query().Where(review.ID(r.ReviewID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.ErrNotFound{Inner: fmt.Errorf("review %d", r.ReviewID)}
+		}
+
+		err = tx.Report.
+			Create().
+			SetReviewID(r.ReviewID).
+			SetUserID(r.UserID).
+			SetReason(r.Reason).
+			SetComment(r.Comment).
+			Exec(ctx)
+		if ent.IsConstraintError(err) {
+			return errors.ErrAlreadyExists{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Review.
+			UpdateOneID(r.ReviewID).
+			AddReportCount(1).
+			Exec(ctx)
+	})
+}
+
+func (rr *EntReviewRepo) Moderate(ctx context.Context, id int, status domain.ModerationStatus) error {
+	return postgres.WithTx(ctx, rr.client, func(tx *ent.Tx) error {
+		err := tx.Review.
+			UpdateOneID(id).
+			SetStatus(status).
+			SetReportCount(0).
+			Exec(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Report.
+			Update().
+			Where(
+				report.ReviewID(id),
+				report.Resolved(false),
+			).
+			SetResolved(true).
+			Exec(ctx)
+	})
+}
+
+func (rr *EntReviewRepo) ModerationQueue(ctx context.Context, query domain.ReviewQuery) (domain.ReviewPage, error) {
+	return rr.page(ctx, query, review.Or(
+		review.StatusEQ(domain.StatusPending),
+		review.And(
+			review.StatusEQ(domain.StatusPublished),
+			review.ReportCountGT(0),
+		),
+	))
+}
+
+func (rr *EntReviewRepo) CountByTextHash(ctx context.Context, hash string, userID int, bookID int) (int, error) {
+	return rr.ReviewClient.
+		Query().
+		Where(
+			review.TextHash(hash),
+			review.Not(review.And(
+				review.UserID(userID),
+				review.BookID(bookID),
+			)),
+		).
+		Count(ctx)
+}
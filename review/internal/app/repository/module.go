@@ -10,5 +10,9 @@ var Module = fx.Options(
 			NewEntReviewRepo,
 			fx.As(new(ReviewRepo)),
 		),
+		fx.Annotate(
+			NewEntCommentRepo,
+			fx.As(new(CommentRepo)),
+		),
 	),
 )
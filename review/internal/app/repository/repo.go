@@ -2,46 +2,196 @@ package repository
 
 import (
 	"context"
+	stdsql "database/sql"
+	stderrors "errors"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/lib/pq"
 
 	"github.com/lunn06/library/review/internal/app/repository/errors"
 	"github.com/lunn06/library/review/internal/domain"
 	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
 	"github.com/lunn06/library/review/internal/infrastructure/db/ent/converter"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/predicate"
 	"github.com/lunn06/library/review/internal/infrastructure/db/ent/review"
 )
 
+// reviewUserBookIndex is the unique index of the reviews on user_id and
+// book_id, see schema.Review.
+const reviewUserBookIndex = "review_user_id_book_id"
+
 type ReviewRepo interface {
-	GetAllByBookID(ctx context.Context, bookID int) ([]domain.Review, error)
+	GetAllByBookID(ctx context.Context, bookID int, query domain.ReviewQuery) (domain.ReviewPage, error)
+	GetAllByUserID(ctx context.Context, userID int, query domain.ReviewQuery) (domain.ReviewPage, error)
 	Get(ctx context.Context, id int) (domain.Review, error)
+	// Put fails with ErrAlreadyExists when the user has already reviewed
+	// the book.
 	Put(ctx context.Context, book domain.Review) (domain.Review, error)
+	// Upsert creates the review of the book by the user or replaces the
+	// title, text and score of the existing one, reporting which happened.
+	Upsert(ctx context.Context, review domain.Review) (domain.Review, bool, error)
+	// Update replaces the title, text and score of the review, keeping the
+	// previous ones as a revision. Upsert keeps them too.
 	Update(ctx context.Context, book domain.Review) error
 	Delete(ctx context.Context, id int) error
+	DeleteAllByBookID(ctx context.Context, bookID int) (int, error)
+	// BookIDs returns up to limit distinct ids of reviewed books greater
+	// than after, in ascending order.
+	BookIDs(ctx context.Context, after int, limit int) ([]int, error)
+	// ScoreHistograms counts the published reviews of each book by score,
+	// books without them are left out.
+	ScoreHistograms(ctx context.Context, bookIDs []int) (map[int]domain.Histogram, error)
+	// ScoreHistogram counts all the published reviews by score.
+	ScoreHistogram(ctx context.Context) (domain.Histogram, error)
+	// Vote records the vote of the user for the review, replacing their
+	// previous one, and keeps the counts of votes of the review in step.
+	Vote(ctx context.Context, vote domain.Vote) error
+	Unvote(ctx context.Context, userID int, reviewID int) error
+	// Revisions returns up to limit revisions of the review with numbers
+	// greater than after, oldest first.
+	Revisions(ctx context.Context, reviewID int, after int, limit int) (domain.RevisionPage, error)
+	// Report files the report of the user, once per review, and counts it
+	// on the review.
+	Report(ctx context.Context, report domain.Report) error
+	// Moderate sets the status of the review and resolves its reports.
+	Moderate(ctx context.Context, id int, status domain.ModerationStatus) error
+	// ModerationQueue returns the pending reviews and the published ones
+	// with open reports.
+	ModerationQueue(ctx context.Context, query domain.ReviewQuery) (domain.ReviewPage, error)
+	// CountByTextHash counts the reviews with the text of the hash, see
+	// domain.TextHash, other than the one of the book by the user.
+	CountByTextHash(ctx context.Context, hash string, userID int, bookID int) (int, error)
 }
 
 var _ ReviewRepo = (*EntReviewRepo)(nil)
 
 func NewEntReviewRepo(client *ent.Client) *EntReviewRepo {
-	return &EntReviewRepo{ReviewClient: client.Review}
+	return &EntReviewRepo{ReviewClient: client.Review, client: client}
 }
 
 type EntReviewRepo struct {
 	*ent.ReviewClient
+	client *ent.Client
 }
 
 // TODO: add errors handling
 
-func (rr *EntReviewRepo) GetAllByBookID(ctx context.Context, bookID int) ([]domain.Review, error) {
+func (rr *EntReviewRepo) GetAllByBookID(
+	ctx context.Context,
+	bookID int,
+	query domain.ReviewQuery,
+) (domain.ReviewPage, error) {
+	return rr.page(ctx, query, review.BookID(bookID), review.StatusEQ(domain.StatusPublished))
+}
+
+func (rr *EntReviewRepo) GetAllByUserID(
+	ctx context.Context,
+	userID int,
+	query domain.ReviewQuery,
+) (domain.ReviewPage, error) {
+	return rr.page(ctx, query, review.UserID(userID), review.StatusEQ(domain.StatusPublished))
+}
+
+// page returns the reviews matching the predicates and the query, one more
+// review than the limit is fetched to tell whether there is a next page.
+func (rr *EntReviewRepo) page(
+	ctx context.Context,
+	query domain.ReviewQuery,
+	predicates ...predicate.Review,
+) (domain.ReviewPage, error) {
+	predicates = append(predicates, filterPredicates(query.Filter)...)
+	if query.Cursor != nil {
+		predicates = append(predicates, cursorPredicate(*query.Cursor))
+	}
+
 	entReviews, err := rr.ReviewClient.
 		Query().
-		Where(
-			review.BookID(bookID),
-		).
+		Where(predicates...).
+		Order(sortOrder(query.Sort)...).
+		Limit(query.Limit + 1).
 		All(ctx)
 	if err != nil {
-		return nil, err
+		return domain.ReviewPage{}, err
+	}
+
+	var page domain.ReviewPage
+	if len(entReviews) > query.Limit {
+		entReviews = entReviews[:query.Limit]
+		last := converter.ReviewToDomain(entReviews[len(entReviews)-1])
+		page.NextCursor = domain.CursorAfter(query.Sort, last).String()
+	}
+	page.Reviews = converter.ReviewsToDomain(entReviews)
+
+	return page, nil
+}
+
+func filterPredicates(filter domain.ReviewFilter) []predicate.Review {
+	var predicates []predicate.Review
+	if filter.MinScore != nil {
+		predicates = append(predicates, review.ScoreGTE(*filter.MinScore))
+	}
+	if filter.MaxScore != nil {
+		predicates = append(predicates, review.ScoreLTE(*filter.MaxScore))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		predicates = append(predicates, review.CreatedAtGTE(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		predicates = append(predicates, review.CreatedAtLT(filter.CreatedBefore))
 	}
 
-	return converter.ReviewsToDomain(entReviews), nil
+	return predicates
+}
+
+func sortOrder(sort domain.Sort) []review.OrderOption {
+	var opts []sql.OrderTermOption
+	if sort.Descending() {
+		opts = append(opts, sql.OrderDesc())
+	}
+
+	switch sort {
+	case domain.SortHighestScore, domain.SortLowestScore:
+		return []review.OrderOption{review.ByScore(opts...), review.ByID(opts...)}
+	case domain.SortMostHelpful:
+		return []review.OrderOption{review.ByHelpfulCount(opts...), review.ByID(opts...)}
+	default:
+		return []review.OrderOption{review.ByCreatedAt(opts...), review.ByID(opts...)}
+	}
+}
+
+// cursorPredicate selects the reviews after the cursor in its sort order.
+func cursorPredicate(cursor domain.Cursor) predicate.Review {
+	descending := cursor.Sort.Descending()
+
+	var after, tie predicate.Review
+	switch cursor.Sort {
+	case domain.SortHighestScore, domain.SortLowestScore:
+		score := domain.Score(cursor.Key)
+		after, tie = review.ScoreGT(score), review.ScoreEQ(score)
+		if descending {
+			after = review.ScoreLT(score)
+		}
+	case domain.SortMostHelpful:
+		count := int(cursor.Key)
+		after, tie = review.HelpfulCountGT(count), review.HelpfulCountEQ(count)
+		if descending {
+			after = review.HelpfulCountLT(count)
+		}
+	default:
+		createdAt := time.UnixMicro(cursor.Key)
+		after, tie = review.CreatedAtGT(createdAt), review.CreatedAtEQ(createdAt)
+		if descending {
+			after = review.CreatedAtLT(createdAt)
+		}
+	}
+
+	idAfter := review.IDGT(cursor.ID)
+	if descending {
+		idAfter = review.IDLT(cursor.ID)
+	}
+
+	return review.Or(after, review.And(tie, idAfter))
 }
 
 func (rr *EntReviewRepo) Get(ctx context.Context, id int) (domain.Review, error) {
@@ -60,19 +210,10 @@ func (rr *EntReviewRepo) Put(
 	ctx context.Context,
 	review domain.Review,
 ) (domain.Review, error) {
-	cte := rr.
-		Create().
-		SetUserID(review.UserID).
-		SetBookID(review.BookID).
-		SetTitle(review.Title).
-		SetText(review.Text).
-		SetScore(review.Score)
-
-	if !review.CreatedAt.IsZero() {
-		cte = cte.SetCreatedAt(review.CreatedAt)
+	entReview, err := createReview(rr.ReviewClient, review).Save(ctx)
+	if isUniqueViolation(err, reviewUserBookIndex) {
+		return domain.Review{}, errors.ErrAlreadyExists{Inner: err}
 	}
-
-	entReview, err := cte.Save(ctx)
 	if err != nil {
 		return domain.Review{}, err
 	}
@@ -80,21 +221,96 @@ func (rr *EntReviewRepo) Put(
 	return converter.ReviewToDomain(entReview), nil
 }
 
+// Upsert inserts the review unless the user has already reviewed the book,
+// in which case the insert does nothing and the existing review is revised
+// in the same transaction.
+func (rr *EntReviewRepo) Upsert(
+	ctx context.Context,
+	r domain.Review,
+) (domain.Review, bool, error) {
+	var (
+		upserted domain.Review
+		created  bool
+	)
+	err := rr.withRevisionRetry(ctx, func(tx *ent.Tx) error {
+		id, err := createReview(tx.Review, r).
+			OnConflictColumns(review.FieldUserID, review.FieldBookID).
+			DoNothing().
+			ID(ctx)
+		created = err == nil
+		switch {
+		case stderrors.Is(err, stdsql.ErrNoRows):
+			current, err := tx.Review.
+				Query().
+				Where(
+					review.UserID(r.UserID),
+					review.BookID(r.BookID),
+				).
+				Only(ctx)
+			if ent.IsNotFound(err) {
+				// deleted since the insert conflicted with it
+				return errRevisionChanged
+			}
+			if err != nil {
+				return err
+			}
+			if err = revise(ctx, tx, current, r); err != nil {
+				return err
+			}
+			id = current.ID
+		case err != nil:
+			return err
+		}
+
+		entReview, err := tx.Review.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		upserted = converter.ReviewToDomain(entReview)
+
+		return nil
+	})
+
+	return upserted, created, err
+}
+
+func createReview(client *ent.ReviewClient, r domain.Review) *ent.ReviewCreate {
+	cte := client.
+		Create().
+		SetUserID(r.UserID).
+		SetBookID(r.BookID).
+		SetTitle(r.Title).
+		SetText(r.Text).
+		SetTextHash(domain.TextHash(r.Text)).
+		SetScore(r.Score)
+
+	if !r.CreatedAt.IsZero() {
+		cte = cte.
+			SetCreatedAt(r.CreatedAt).
+			SetUpdatedAt(r.CreatedAt)
+	}
+	if r.Status != "" {
+		cte = cte.SetStatus(r.Status)
+	}
+
+	return cte
+}
+
 func (rr *EntReviewRepo) Update(
 	ctx context.Context,
-	review domain.Review,
+	r domain.Review,
 ) error {
-	err := rr.
-		UpdateOneID(review.ID).
-		SetTitle(review.Title).
-		SetText(review.Text).
-		SetScore(review.Score).
-		Exec(ctx)
-	if ent.IsNotFound(err) {
-		return errors.ErrNotFound{Inner: err}
-	}
+	return rr.withRevisionRetry(ctx, func(tx *ent.Tx) error {
+		current, err := tx.Review.Get(ctx, r.ID)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
 
-	return err
+		return revise(ctx, tx, current, r)
+	})
 }
 
 func (rr *EntReviewRepo) Delete(ctx context.Context, id int) error {
@@ -104,3 +320,85 @@ func (rr *EntReviewRepo) Delete(ctx context.Context, id int) error {
 
 	return err
 }
+
+func (rr *EntReviewRepo) DeleteAllByBookID(ctx context.Context, bookID int) (int, error) {
+	return rr.ReviewClient.
+		Delete().
+		Where(
+			review.BookID(bookID),
+		).
+		Exec(ctx)
+}
+
+func (rr *EntReviewRepo) BookIDs(ctx context.Context, after int, limit int) ([]int, error) {
+	return rr.ReviewClient.
+		Query().
+		Where(
+			review.BookIDGT(after),
+		).
+		Order(ent.Asc(review.FieldBookID)).
+		Limit(limit).
+		Unique(true).
+		Select(review.FieldBookID).
+		Ints(ctx)
+}
+
+type scoreCount struct {
+	BookID int `json:"book_id"`
+	Score  int `json:"score"`
+	Count  int `json:"count"`
+}
+
+func (rr *EntReviewRepo) ScoreHistograms(ctx context.Context, bookIDs []int) (map[int]domain.Histogram, error) {
+	var counts []scoreCount
+	err := rr.ReviewClient.
+		Query().
+		Where(
+			review.BookIDIn(bookIDs...),
+			review.StatusEQ(domain.StatusPublished),
+		).
+		GroupBy(review.FieldBookID, review.FieldScore).
+		Aggregate(ent.Count()).
+		Scan(ctx, &counts)
+	if err != nil {
+		return nil, err
+	}
+
+	histograms := make(map[int]domain.Histogram)
+	for _, count := range counts {
+		histogram := histograms[count.BookID]
+		histogram[count.Score-domain.MinScore] = count.Count
+		histograms[count.BookID] = histogram
+	}
+
+	return histograms, nil
+}
+
+func (rr *EntReviewRepo) ScoreHistogram(ctx context.Context) (domain.Histogram, error) {
+	var counts []scoreCount
+	err := rr.ReviewClient.
+		Query().
+		Where(
+			review.StatusEQ(domain.StatusPublished),
+		).
+		GroupBy(review.FieldScore).
+		Aggregate(ent.Count()).
+		Scan(ctx, &counts)
+	if err != nil {
+		return domain.Histogram{}, err
+	}
+
+	var histogram domain.Histogram
+	for _, count := range counts {
+		histogram[count.Score-domain.MinScore] = count.Count
+	}
+
+	return histogram, nil
+}
+
+// isUniqueViolation reports whether err violates the unique index or
+// constraint with the name.
+func isUniqueViolation(err error, name string) bool {
+	var pqErr *pq.Error
+	return stderrors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" && pqErr.Constraint == name
+}
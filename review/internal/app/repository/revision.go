@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/converter"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/review"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/revision"
+	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
+)
+
+// reviseAttempts bounds the retries of an edit racing with another edit of
+// the same review.
+const reviseAttempts = 3
+
+// errRevisionChanged rolls back a transaction which found the review edited
+// since it read it, so the revision it keeps is not stale.
+var errRevisionChanged = stderrors.New("review edited concurrently")
+
+func (rr *EntReviewRepo) Revisions(ctx context.Context, reviewID int, after int, limit int) (domain.RevisionPage, error) {
+	entRevisions, err := rr.client.Revision.
+		Query().
+		Where(
+			revision.ReviewID(reviewID),
+			revision.NumberGT(after),
+		).
+		Order(ent.Asc(revision.FieldNumber)).
+		Limit(limit + 1).
+		All(ctx)
+	if err != nil {
+		return domain.RevisionPage{}, err
+	}
+
+	var page domain.RevisionPage
+	if len(entRevisions) > limit {
+		entRevisions = entRevisions[:limit]
+		page.NextAfter = entRevisions[len(entRevisions)-1].Number
+	}
+	page.Revisions = converter.RevisionsToDomain(entRevisions)
+
+	return page, nil
+}
+
+func (rr *EntReviewRepo) withRevisionRetry(ctx context.Context, fn func(tx *ent.Tx) error) error {
+	for range reviseAttempts {
+		err := postgres.WithTx(ctx, rr.client, fn)
+		if !stderrors.Is(err, errRevisionChanged) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("edit review: %w", errRevisionChanged)
+}
+
+// revise replaces the content of the current review with the one of r,
+// keeping the current one as a revision unless it is the same, and sets
+// the status of r unless a moderator has taken the review down.
+func revise(ctx context.Context, tx *ent.Tx, current *ent.Review, r domain.Review) error {
+	if current.Title != r.Title || current.Text != r.Text || current.Score != r.Score {
+		now := time.Now()
+		n, err := tx.Review.
+			Update().
+			Where(
+				review.ID(current.ID),
+				review.Revision(current.Revision),
+			).
+			SetTitle(r.Title).
+			SetText(r.Text).
+			SetTextHash(domain.TextHash(r.Text)).
+			SetScore(r.Score).
+			SetUpdatedAt(now).
+			AddRevision(1).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errRevisionChanged
+		}
+
+		err = tx.Revision.
+			Create().
+			SetReviewID(current.ID).
+			SetNumber(current.Revision + 1).
+			SetTitle(current.Title).
+			SetText(current.Text).
+			SetScore(current.Score).
+			SetEditedAt(now).
+			Exec(ctx)
+		if ent.IsConstraintError(err) {
+			return errRevisionChanged
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Status == "" || current.Status.Settled() {
+		return nil
+	}
+
+	return tx.Review.
+		Update().
+		Where(
+			review.ID(current.ID),
+			review.StatusNotIn(domain.StatusHidden, domain.StatusRejected),
+		).
+		SetStatus(r.Status).
+		Exec(ctx)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/review"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent/vote"
+	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
+)
+
+// voteAttempts bounds the retries of a vote racing with another change of
+// the same vote.
+const voteAttempts = 3
+
+// errVoteChanged rolls back a transaction which found the vote changed
+// since it read it, so the counts are not adjusted twice.
+var errVoteChanged = stderrors.New("vote changed concurrently")
+
+func (rr *EntReviewRepo) Vote(ctx context.Context, v domain.Vote) error {
+	return rr.withVoteRetry(ctx, func(tx *ent.Tx) error {
+		exists, err := tx.Review.Query().Where(review.ID(v.ReviewID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.ErrNotFound{Inner: fmt.Errorf("review %d", v.ReviewID)}
+		}
+
+		existing, err := tx.Vote.
+			Query().
+			Where(
+				vote.UserID(v.UserID),
+				vote.ReviewID(v.ReviewID),
+			).
+			Only(ctx)
+		switch {
+		case ent.IsNotFound(err):
+			err = tx.Vote.
+				Create().
+				SetUserID(v.UserID).
+				SetReviewID(v.ReviewID).
+				SetHelpful(v.Helpful).
+				Exec(ctx)
+			if ent.IsConstraintError(err) {
+				return errVoteChanged
+			}
+			if err != nil {
+				return err
+			}
+			return addVotes(ctx, tx, v.ReviewID, v.Helpful, 1)
+		case err != nil:
+			return err
+		case existing.Helpful == v.Helpful:
+			return nil
+		}
+
+		n, err := tx.Vote.
+			Update().
+			Where(
+				vote.ID(existing.ID),
+				vote.Helpful(existing.Helpful),
+			).
+			SetHelpful(v.Helpful).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errVoteChanged
+		}
+
+		if err = addVotes(ctx, tx, v.ReviewID, existing.Helpful, -1); err != nil {
+			return err
+		}
+		return addVotes(ctx, tx, v.ReviewID, v.Helpful, 1)
+	})
+}
+
+func (rr *EntReviewRepo) Unvote(ctx context.Context, userID int, reviewID int) error {
+	return rr.withVoteRetry(ctx, func(tx *ent.Tx) error {
+		existing, err := tx.Vote.
+			Query().
+			Where(
+				vote.UserID(userID),
+				vote.ReviewID(reviewID),
+			).
+			Only(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err := tx.Vote.
+			Delete().
+			Where(
+				vote.ID(existing.ID),
+				vote.Helpful(existing.Helpful),
+			).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errVoteChanged
+		}
+
+		return addVotes(ctx, tx, reviewID, existing.Helpful, -1)
+	})
+}
+
+func (rr *EntReviewRepo) withVoteRetry(ctx context.Context, fn func(tx *ent.Tx) error) error {
+	for range voteAttempts {
+		err := postgres.WithTx(ctx, rr.client, fn)
+		if !stderrors.Is(err, errVoteChanged) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("vote: %w", errVoteChanged)
+}
+
+func addVotes(ctx context.Context, tx *ent.Tx, reviewID int, helpful bool, n int) error {
+	update := tx.Review.UpdateOneID(reviewID)
+	if helpful {
+		update.AddHelpfulCount(n)
+	} else {
+		update.AddUnhelpfulCount(n)
+	}
+
+	err := update.Exec(ctx)
+	if ent.IsNotFound(err) {
+		return errors.ErrNotFound{Inner: err}
+	}
+
+	return err
+}
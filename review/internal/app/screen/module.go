@@ -0,0 +1,15 @@
+package screen
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/review/internal/app/repository"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		func(cfg Config, repo repository.ReviewRepo) Screener {
+			return New(cfg, repo)
+		},
+	),
+)
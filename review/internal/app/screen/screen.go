@@ -0,0 +1,148 @@
+// Package screen flags the new and edited reviews a moderator should look
+// at before they are published.
+package screen
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// Screener returns the reasons to hold the review for moderation, none if
+// it can be published.
+type Screener interface {
+	Screen(ctx context.Context, review domain.Review) ([]string, error)
+}
+
+type Config struct {
+	Enabled bool `default:"true"`
+	// BannedWords are words or phrases matched regardless of the case.
+	BannedWords []string `split_words:"true"`
+	MaxLinks    int      `default:"2" split_words:"true"`
+	// DuplicateMinLength keeps short texts like "Great book!" from being
+	// taken for copies.
+	DuplicateMinLength int `default:"50" split_words:"true"`
+}
+
+// New chains the screeners enabled by the config.
+func New(cfg Config, finder DuplicateFinder) Screener {
+	if !cfg.Enabled {
+		return Chain{}
+	}
+
+	chain := Chain{
+		NewLinkCounter(cfg.MaxLinks),
+		NewDuplicates(finder, cfg.DuplicateMinLength),
+	}
+	if len(cfg.BannedWords) > 0 {
+		chain = append(chain, NewWordList(cfg.BannedWords))
+	}
+
+	return chain
+}
+
+// Chain collects the reasons of all its screeners.
+type Chain []Screener
+
+func (c Chain) Screen(ctx context.Context, review domain.Review) ([]string, error) {
+	var reasons []string
+	for _, screener := range c {
+		r, err := screener.Screen(ctx, review)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, r...)
+	}
+
+	return reasons, nil
+}
+
+// WordList flags the reviews containing any of its words or phrases as
+// whole words.
+type WordList struct {
+	phrases []string
+}
+
+func NewWordList(phrases []string) WordList {
+	var wl WordList
+	for _, phrase := range phrases {
+		if phrase = words(phrase); phrase != "" {
+			wl.phrases = append(wl.phrases, phrase)
+		}
+	}
+
+	return wl
+}
+
+func (wl WordList) Screen(_ context.Context, review domain.Review) ([]string, error) {
+	text := " " + words(review.Title+" "+review.Text) + " "
+	for _, phrase := range wl.phrases {
+		if strings.Contains(text, " "+phrase+" ") {
+			return []string{"banned words"}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// words lowercases the text and separates its words with single spaces.
+func words(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkCounter flags the reviews with more links than allowed, which is
+// what most spam looks like.
+type LinkCounter struct {
+	max int
+}
+
+func NewLinkCounter(max int) LinkCounter {
+	return LinkCounter{max: max}
+}
+
+func (lc LinkCounter) Screen(_ context.Context, review domain.Review) ([]string, error) {
+	n := len(linkRe.FindAllStringIndex(review.Title, -1)) + len(linkRe.FindAllStringIndex(review.Text, -1))
+	if n > lc.max {
+		return []string{fmt.Sprintf("%d links", n)}, nil
+	}
+
+	return nil, nil
+}
+
+type DuplicateFinder interface {
+	CountByTextHash(ctx context.Context, hash string, userID int, bookID int) (int, error)
+}
+
+// Duplicates flags the reviews copying the text of another review.
+type Duplicates struct {
+	finder    DuplicateFinder
+	minLength int
+}
+
+func NewDuplicates(finder DuplicateFinder, minLength int) Duplicates {
+	return Duplicates{finder: finder, minLength: minLength}
+}
+
+func (d Duplicates) Screen(ctx context.Context, review domain.Review) ([]string, error) {
+	if len([]rune(domain.NormalizeText(review.Text))) < d.minLength {
+		return nil, nil
+	}
+
+	n, err := d.finder.CountByTextHash(ctx, domain.TextHash(review.Text), review.UserID, review.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return []string{"duplicate text"}, nil
+	}
+
+	return nil, nil
+}
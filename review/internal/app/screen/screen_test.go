@@ -0,0 +1,88 @@
+package screen
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// hashSet maps the text hashes to the user and book of the review with
+// the text.
+type hashSet map[string][2]int
+
+func (h hashSet) CountByTextHash(_ context.Context, hash string, userID int, bookID int) (int, error) {
+	owner, ok := h[hash]
+	if !ok || owner == [2]int{userID, bookID} {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func TestWordList(t *testing.T) {
+	wl := NewWordList([]string{"Buy Now", "idiot", " "})
+
+	for text, flagged := range map[string]bool{
+		"BUY   now, limited offer": true,
+		"The author is an Idiot!":  true,
+		"Idiotic plot":             false,
+		"I will buy nowhere else":  false,
+	} {
+		reasons, err := wl.Screen(context.Background(), domain.Review{Text: text})
+		require.NoError(t, err)
+		assert.Equal(t, flagged, len(reasons) > 0, text)
+	}
+}
+
+func TestLinkCounter(t *testing.T) {
+	lc := NewLinkCounter(1)
+
+	reasons, err := lc.Screen(context.Background(), domain.Review{Text: "See https://example.com"})
+	require.NoError(t, err)
+	assert.Empty(t, reasons)
+
+	reasons, err = lc.Screen(context.Background(), domain.Review{
+		Title: "www.example.com",
+		Text:  "and HTTP://example.org/deal",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2 links"}, reasons)
+}
+
+func TestDuplicates(t *testing.T) {
+	text := strings.Repeat("the same words again ", 5)
+	d := NewDuplicates(hashSet{domain.TextHash(text): {1, 10}}, 50)
+
+	reasons, err := d.Screen(context.Background(), domain.Review{UserID: 2, BookID: 10, Text: strings.ToUpper(text)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"duplicate text"}, reasons)
+
+	reasons, err = d.Screen(context.Background(), domain.Review{UserID: 1, BookID: 11, Text: text})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"duplicate text"}, reasons, "same text on another book")
+
+	// the review being replaced, which Upsert has no id of
+	reasons, err = d.Screen(context.Background(), domain.Review{UserID: 1, BookID: 10, Text: text})
+	require.NoError(t, err)
+	assert.Empty(t, reasons, "review is not a copy of itself")
+
+	reasons, err = d.Screen(context.Background(), domain.Review{Text: "Great book!"})
+	require.NoError(t, err)
+	assert.Empty(t, reasons)
+}
+
+func TestNew(t *testing.T) {
+	screener := New(Config{Enabled: true, BannedWords: []string{"spam"}, MaxLinks: 0, DuplicateMinLength: 50}, hashSet{})
+
+	reasons, err := screener.Screen(context.Background(), domain.Review{Text: "spam at https://example.com"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"banned words", "1 links"}, reasons)
+
+	reasons, err = New(Config{}, hashSet{}).Screen(context.Background(), domain.Review{Text: "spam at https://example.com"})
+	require.NoError(t, err)
+	assert.Empty(t, reasons)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+type BookCacheConfig struct {
+	TTL time.Duration `default:"10m"`
+	// MissingTTL is shorter, so that a book is found soon after it is created.
+	MissingTTL time.Duration `default:"30s" split_words:"true"`
+	Size       int           `default:"10000"`
+}
+
+type bookCacheEntry struct {
+	exists    bool
+	expiresAt time.Time
+}
+
+// bookCache remembers whether books exist, so that creating a review does
+// not ask bookinfo every time.
+type bookCache struct {
+	mu         sync.Mutex
+	entries    map[int]bookCacheEntry
+	ttl        time.Duration
+	missingTTL time.Duration
+	size       int
+	now        func() time.Time
+}
+
+func newBookCache(cfg BookCacheConfig) *bookCache {
+	return &bookCache{
+		entries:    make(map[int]bookCacheEntry),
+		ttl:        cfg.TTL,
+		missingTTL: cfg.MissingTTL,
+		size:       cfg.Size,
+		now:        time.Now,
+	}
+}
+
+func (c *bookCache) Get(bookID int) (exists bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[bookID]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return false, false
+	}
+
+	return entry.exists, true
+}
+
+func (c *bookCache) Set(bookID int, exists bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[bookID]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+
+	ttl := c.ttl
+	if !exists {
+		ttl = c.missingTTL
+	}
+	c.entries[bookID] = bookCacheEntry{exists: exists, expiresAt: now.Add(ttl)}
+}
+
+// evict drops the expired entries, or an arbitrary one if none expired.
+func (c *bookCache) evict(now time.Time) {
+	for bookID, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, bookID)
+		}
+	}
+	for bookID := range c.entries {
+		if len(c.entries) < c.size {
+			return
+		}
+		delete(c.entries, bookID)
+	}
+}
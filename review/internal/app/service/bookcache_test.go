@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookCache(t *testing.T) {
+	now := time.Now()
+	cache := newBookCache(BookCacheConfig{TTL: time.Minute, MissingTTL: time.Second, Size: 2})
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+
+	cache.Set(1, true)
+	cache.Set(2, false)
+	exists, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.True(t, exists)
+	exists, ok = cache.Get(2)
+	assert.True(t, ok)
+	assert.False(t, exists)
+
+	now = now.Add(2 * time.Second)
+	_, ok = cache.Get(2)
+	assert.False(t, ok, "missing books expire sooner")
+
+	cache.Set(3, true)
+	assert.Len(t, cache.entries, 2)
+	_, ok = cache.Get(1)
+	assert.True(t, ok, "expired entries are evicted first")
+}
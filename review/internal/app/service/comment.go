@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+
+	"github.com/lunn06/library/review/internal/app/repository"
+	repoerrors "github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+type CreateCommentRequest struct {
+	ReviewID int
+	// ParentID is the comment replied to, nil for a reply to the review.
+	ParentID *int
+	UserID   int
+	Text     string
+}
+
+type ListCommentsRequest struct {
+	ReviewID int
+	AfterID  int
+	Limit    int
+}
+
+func NewCommentService(comments repository.CommentRepo, reviews repository.ReviewRepo) *CommentService {
+	return &CommentService{
+		comments: comments,
+		reviews:  reviews,
+	}
+}
+
+// CommentService keeps the threads of comments on reviews.
+type CommentService struct {
+	comments repository.CommentRepo
+	reviews  repository.ReviewRepo
+}
+
+func (s *CommentService) Create(ctx context.Context, req CreateCommentRequest) (int, error) {
+	text, err := commentText(req.Text)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.checkReview(ctx, req.ReviewID); err != nil {
+		return 0, err
+	}
+
+	if req.ParentID != nil {
+		parent, err := s.comments.Get(ctx, req.ReviewID, *req.ParentID)
+		if repoerrors.IsErrNotFound(err) {
+			return 0, errors.ErrInvalidRequest{Reason: fmt.Sprintf("no comment %d on review %d", *req.ParentID, req.ReviewID)}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if parent.Deleted() {
+			return 0, errors.ErrResourceNotFound{Inner: fmt.Errorf("comment %d is deleted", parent.ID)}
+		}
+	}
+
+	comment, err := s.comments.Put(ctx, domain.Comment{
+		ReviewID: req.ReviewID,
+		ParentID: req.ParentID,
+		UserID:   req.UserID,
+		Text:     text,
+	})
+	if repoerrors.IsErrNotFound(err) {
+		return 0, errors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return comment.ID, nil
+}
+
+func (s *CommentService) Get(ctx context.Context, reviewID int, id int) (domain.Comment, error) {
+	comment, err := s.comments.Get(ctx, reviewID, id)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.Comment{}, errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return comment, err
+}
+
+// List returns a page of the thread of the review, oldest first. Deleted
+// comments are kept so the replies to them can be placed.
+func (s *CommentService) List(ctx context.Context, req ListCommentsRequest) (domain.CommentPage, error) {
+	if req.Limit < 0 || req.AfterID < 0 {
+		return domain.CommentPage{}, errors.ErrInvalidRequest{Reason: "negative limit or after id"}
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	if err := s.checkReview(ctx, req.ReviewID); err != nil {
+		return domain.CommentPage{}, err
+	}
+
+	return s.comments.GetAllByReviewID(ctx, req.ReviewID, req.AfterID, limit)
+}
+
+// Update edits the comment on behalf of the user of the context, see
+// authz.Policy.
+func (s *CommentService) Update(ctx context.Context, reviewID int, id int, text string) error {
+	text, err := commentText(text)
+	if err != nil {
+		return err
+	}
+	if err = s.authorize(ctx, reviewID, id, authz.ActionUpdate); err != nil {
+		return err
+	}
+
+	err = s.comments.Update(ctx, reviewID, id, text)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// Delete removes the comment on behalf of the user of the context, see
+// authz.Policy.
+func (s *CommentService) Delete(ctx context.Context, reviewID int, id int) error {
+	if err := s.authorize(ctx, reviewID, id, authz.ActionDelete); err != nil {
+		return err
+	}
+
+	err := s.comments.Delete(ctx, reviewID, id)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+func (s *CommentService) authorize(ctx context.Context, reviewID int, id int, action authz.Action) error {
+	comment, err := s.Get(ctx, reviewID, id)
+	if err != nil {
+		return err
+	}
+
+	return authorize(ctx, authz.ResourceComment, action, comment.UserID)
+}
+
+// checkReview fails with ErrResourceNotFound unless the review is
+// published.
+func (s *CommentService) checkReview(ctx context.Context, reviewID int) error {
+	review, err := s.reviews.Get(ctx, reviewID)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil {
+		return err
+	}
+	if review.Status != domain.StatusPublished {
+		return errors.ErrResourceNotFound{Inner: fmt.Errorf("review %d is %s", reviewID, review.Status)}
+	}
+
+	return nil
+}
+
+func commentText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return "", errors.ErrInvalidRequest{Reason: "empty comment"}
+	case utf8.RuneCountInString(text) > domain.MaxCommentLength:
+		return "", errors.ErrInvalidRequest{Reason: fmt.Sprintf("comment longer than %d characters", domain.MaxCommentLength)}
+	}
+
+	return text, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/app/repository"
+	repoerrors "github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+type memCommentRepo struct {
+	repository.CommentRepo
+	comments []domain.Comment
+}
+
+func (m *memCommentRepo) Get(_ context.Context, reviewID int, id int) (domain.Comment, error) {
+	if id < 1 || id > len(m.comments) || m.comments[id-1].ReviewID != reviewID {
+		return domain.Comment{}, repoerrors.ErrNotFound{Inner: fmt.Errorf("comment %d", id)}
+	}
+	return m.comments[id-1], nil
+}
+
+func (m *memCommentRepo) Put(_ context.Context, comment domain.Comment) (domain.Comment, error) {
+	comment.ID = len(m.comments) + 1
+	m.comments = append(m.comments, comment)
+	return comment, nil
+}
+
+func (m *memCommentRepo) Delete(_ context.Context, reviewID int, id int) error {
+	if _, err := m.Get(context.Background(), reviewID, id); err != nil {
+		return err
+	}
+	now := time.Now()
+	m.comments[id-1].DeletedAt = &now
+	return nil
+}
+
+func TestCreateComment(t *testing.T) {
+	comments := &memCommentRepo{}
+	reviews := newMemReviewRepo(t,
+		domain.Review{UserID: 1, BookID: 1, Status: domain.StatusPublished},
+		domain.Review{UserID: 1, BookID: 2, Status: domain.StatusPublished},
+	)
+	s := NewCommentService(comments, reviews)
+	ctx := context.Background()
+
+	rootID, err := s.Create(ctx, CreateCommentRequest{ReviewID: 1, UserID: 1, Text: "  Agreed  "})
+	require.NoError(t, err)
+	assert.Equal(t, "Agreed", comments.comments[0].Text)
+
+	replyID, err := s.Create(ctx, CreateCommentRequest{ReviewID: 1, ParentID: &rootID, UserID: 2, Text: "Not me"})
+	require.NoError(t, err)
+	assert.Equal(t, &rootID, comments.comments[replyID-1].ParentID)
+
+	_, err = s.Create(ctx, CreateCommentRequest{ReviewID: 2, ParentID: &rootID, UserID: 2, Text: "Wrong thread"})
+	assert.True(t, errors.IsErrInvalidRequest(err))
+
+	_, err = s.Create(ctx, CreateCommentRequest{ReviewID: 3, UserID: 2, Text: "No review"})
+	assert.True(t, errors.IsErrResourceNotFound(err))
+
+	for _, text := range []string{" ", strings.Repeat("a", domain.MaxCommentLength+1)} {
+		_, err = s.Create(ctx, CreateCommentRequest{ReviewID: 1, UserID: 2, Text: text})
+		assert.True(t, errors.IsErrInvalidRequest(err))
+	}
+
+	assert.True(t, errors.IsErrUnauthenticated(s.Delete(ctx, 1, rootID)))
+	asOther := authz.NewContext(ctx, authz.Principal{UserID: 2})
+	assert.True(t, errors.IsErrForbidden(s.Update(asOther, 1, rootID, "Disagreed")))
+	assert.True(t, errors.IsErrForbidden(s.Delete(asOther, 1, rootID)))
+	require.NoError(t, s.Delete(authz.NewContext(ctx, authz.Principal{UserID: 1}), 1, rootID))
+	_, err = s.Create(ctx, CreateCommentRequest{ReviewID: 1, ParentID: &rootID, UserID: 2, Text: "Too late"})
+	assert.True(t, errors.IsErrResourceNotFound(err))
+	assert.True(t, errors.IsErrResourceNotFound(s.Delete(asOther, 2, replyID)))
+}
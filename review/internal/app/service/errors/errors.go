@@ -30,3 +30,107 @@ func (err ErrResourceNotFound) Is(target error) bool {
 	_, ok := target.(ErrResourceNotFound)
 	return ok
 }
+
+func IsErrInvalidRequest(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrInvalidRequest{})
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (err ErrInvalidRequest) Error() string {
+	return fmt.Sprintf("invalid request: %s", err.Reason)
+}
+
+func (err ErrInvalidRequest) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrInvalidRequest)
+	return ok
+}
+
+func IsErrAlreadyExists(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrAlreadyExists{})
+}
+
+type ErrAlreadyExists struct {
+	Inner error
+}
+
+func (err ErrAlreadyExists) Error() string {
+	return fmt.Sprintf("resource already exists: %s", err.Inner.Error())
+}
+
+func (err ErrAlreadyExists) Unwrap() error {
+	return err.Inner
+}
+
+func (err ErrAlreadyExists) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrAlreadyExists)
+	return ok
+}
+
+func IsErrUnauthenticated(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrUnauthenticated{})
+}
+
+type ErrUnauthenticated struct {
+	Inner error
+}
+
+func (err ErrUnauthenticated) Error() string {
+	return fmt.Sprintf("unauthenticated: %s", err.Inner.Error())
+}
+
+func (err ErrUnauthenticated) Unwrap() error {
+	return err.Inner
+}
+
+func (err ErrUnauthenticated) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrUnauthenticated)
+	return ok
+}
+
+func IsErrForbidden(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrForbidden{})
+}
+
+type ErrForbidden struct {
+	Inner error
+}
+
+func (err ErrForbidden) Error() string {
+	return fmt.Sprintf("forbidden: %s", err.Inner.Error())
+}
+
+func (err ErrForbidden) Unwrap() error {
+	return err.Inner
+}
+
+func (err ErrForbidden) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	_, ok := target.(ErrForbidden)
+	return ok
+}
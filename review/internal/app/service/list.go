@@ -0,0 +1,72 @@
+package service
+
+import (
+	"time"
+
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListRequest selects a page of reviews, the cursor of the next page is
+// returned with the previous one and has to be used with the same sort.
+type ListRequest struct {
+	Limit         int
+	Cursor        string
+	Sort          domain.Sort
+	MinScore      *int
+	MaxScore      *int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func (r ListRequest) query() (domain.ReviewQuery, error) {
+	query := domain.ReviewQuery{
+		Sort:  r.Sort,
+		Limit: min(r.Limit, MaxPageSize),
+		Filter: domain.ReviewFilter{
+			CreatedAfter:  r.CreatedAfter,
+			CreatedBefore: r.CreatedBefore,
+		},
+	}
+	if r.Limit < 0 {
+		return domain.ReviewQuery{}, errors.ErrInvalidRequest{Reason: "negative limit"}
+	}
+	if r.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if r.Sort < domain.SortNewest || r.Sort > domain.SortMostHelpful {
+		return domain.ReviewQuery{}, errors.ErrInvalidRequest{Reason: "unknown sort"}
+	}
+
+	for _, bound := range []struct {
+		value *int
+		score **domain.Score
+	}{
+		{r.MinScore, &query.Filter.MinScore},
+		{r.MaxScore, &query.Filter.MaxScore},
+	} {
+		if bound.value == nil {
+			continue
+		}
+		score, err := domain.NewScore(*bound.value)
+		if err != nil {
+			return domain.ReviewQuery{}, errors.ErrInvalidRequest{Reason: err.Error()}
+		}
+		*bound.score = &score
+	}
+
+	if r.Cursor != "" {
+		cursor, err := domain.ParseCursor(r.Cursor)
+		if err != nil || cursor.Sort != r.Sort {
+			return domain.ReviewQuery{}, errors.ErrInvalidRequest{Reason: domain.ErrInvalidCursor.Error()}
+		}
+		query.Cursor = &cursor
+	}
+
+	return query, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+func TestListRequestQuery(t *testing.T) {
+	query, err := ListRequest{}.query()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPageSize, query.Limit)
+	assert.Nil(t, query.Cursor)
+
+	minScore, maxScore := 7, 10
+	cursor := domain.Cursor{Sort: domain.SortHighestScore, Key: 9, ID: 12}
+	query, err = ListRequest{
+		Limit:    1000,
+		Sort:     domain.SortHighestScore,
+		Cursor:   cursor.String(),
+		MinScore: &minScore,
+		MaxScore: &maxScore,
+	}.query()
+	require.NoError(t, err)
+	assert.Equal(t, MaxPageSize, query.Limit)
+	assert.Equal(t, &cursor, query.Cursor)
+	assert.Equal(t, domain.Score(7), *query.Filter.MinScore)
+	assert.Equal(t, domain.Score(10), *query.Filter.MaxScore)
+
+	tooHigh := 11
+	for _, req := range []ListRequest{
+		{Limit: -1},
+		{Sort: domain.Sort(42)},
+		{MinScore: &tooHigh},
+		{Cursor: "broken"},
+		{Cursor: cursor.String(), Sort: domain.SortNewest},
+	} {
+		_, err = req.query()
+		assert.True(t, errors.IsErrInvalidRequest(err), "%+v", req)
+	}
+}
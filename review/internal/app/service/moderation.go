@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+
+	repoerrors "github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+const MaxReportCommentLength = 1000
+
+type ReportRequest struct {
+	ReviewID int
+	UserID   int
+	// Reason defaults to other.
+	Reason  domain.ReportReason
+	Comment string
+}
+
+// Report files the complaint of the user about a published review, a
+// user reports a review once.
+func (s *ReviewService) Report(ctx context.Context, req ReportRequest) error {
+	if req.Reason == "" {
+		req.Reason = domain.ReasonOther
+	}
+	if !slices.Contains(req.Reason.Values(), string(req.Reason)) {
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("unknown reason %q", req.Reason)}
+	}
+	if utf8.RuneCountInString(req.Comment) > MaxReportCommentLength {
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("comment longer than %d characters", MaxReportCommentLength)}
+	}
+
+	review, err := s.Get(ctx, req.ReviewID)
+	if err != nil {
+		return err
+	}
+	if review.UserID == req.UserID {
+		return errors.ErrInvalidRequest{Reason: "reporting own review"}
+	}
+
+	err = s.repo.Report(ctx, domain.Report{
+		ReviewID: req.ReviewID,
+		UserID:   req.UserID,
+		Reason:   req.Reason,
+		Comment:  req.Comment,
+	})
+	switch {
+	case repoerrors.IsErrNotFound(err):
+		return errors.ErrResourceNotFound{Inner: err}
+	case repoerrors.IsErrAlreadyExists(err):
+		return errors.ErrAlreadyExists{Inner: err}
+	}
+
+	return err
+}
+
+// Moderate settles the status of the review, taking it off the moderation
+// queue. Only moderators settle reviews.
+func (s *ReviewService) Moderate(ctx context.Context, id int, status domain.ModerationStatus) error {
+	if err := authorize(ctx, authz.ResourceReview, authz.ActionModerate, 0); err != nil {
+		return err
+	}
+	if !slices.Contains(status.Values(), string(status)) || status == domain.StatusPending {
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("cannot set status %q", status)}
+	}
+
+	err := s.repo.Moderate(ctx, id, status)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// Queue returns a page of the reviews waiting for a moderator, oldest
+// first. Only moderators see the queue.
+func (s *ReviewService) Queue(ctx context.Context, req ListRequest) (domain.ReviewPage, error) {
+	if err := authorize(ctx, authz.ResourceReview, authz.ActionModerate, 0); err != nil {
+		return domain.ReviewPage{}, err
+	}
+
+	req.Sort = domain.SortOldest
+	query, err := req.query()
+	if err != nil {
+		return domain.ReviewPage{}, err
+	}
+
+	return s.repo.ModerationQueue(ctx, query)
+}
+
+// screen returns the status of a new or edited review.
+func (s *ReviewService) screen(ctx context.Context, review domain.Review) (domain.ModerationStatus, error) {
+	if s.screener == nil {
+		return domain.StatusPublished, nil
+	}
+
+	reasons, err := s.screener.Screen(ctx, review)
+	if err != nil {
+		return "", err
+	}
+	if len(reasons) > 0 {
+		slog.Info("Review held for moderation",
+			"user", review.UserID, "book", review.BookID, "reasons", reasons)
+		return domain.StatusPending, nil
+	}
+
+	return domain.StatusPublished, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/app/screen"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+func TestModeration(t *testing.T) {
+	repo := newMemReviewRepo(t)
+	s := NewReviewService(repo, bookSet{1: true}, screen.NewWordList([]string{"spoiler"}), BookCacheConfig{}, StatsConfig{})
+	ctx := context.Background()
+
+	flagged, err := s.Create(ctx, CreateRequest{UserID: 1, BookID: 1, Text: "Spoiler: the butler did it", Score: 5})
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusPending, flagged.Status)
+	_, err = s.Get(ctx, flagged.ID)
+	assert.True(t, errors.IsErrResourceNotFound(err), "pending review is not shown")
+
+	asAuthor := authz.NewContext(ctx, authz.Principal{UserID: 1})
+	asModerator := authz.NewContext(ctx, authz.Principal{UserID: 4, Roles: []authz.Role{authz.RoleModerator}})
+	clean := UpdateRequest{ID: flagged.ID, Text: "The butler is a fine character", Score: 5}
+	assert.True(t, errors.IsErrUnauthenticated(s.Update(ctx, clean)))
+	assert.True(t, errors.IsErrForbidden(s.Update(asModerator, clean)), "moderators do not rewrite reviews")
+	require.NoError(t, s.Update(asAuthor, clean))
+	assert.Equal(t, domain.StatusPublished, repo.reviews[flagged.ID].Status, "clean edit publishes")
+
+	report := ReportRequest{ReviewID: flagged.ID, UserID: 2, Reason: domain.ReasonSpoiler}
+	require.NoError(t, s.Report(ctx, report))
+	assert.True(t, errors.IsErrAlreadyExists(s.Report(ctx, report)))
+	assert.True(t, errors.IsErrInvalidRequest(s.Report(ctx, ReportRequest{ReviewID: flagged.ID, UserID: 1})), "own review")
+	assert.True(t, errors.IsErrInvalidRequest(s.Report(ctx, ReportRequest{ReviewID: flagged.ID, UserID: 3, Reason: "boring"})))
+
+	assert.True(t, errors.IsErrForbidden(s.Moderate(asAuthor, flagged.ID, domain.StatusPublished)))
+	_, err = s.Queue(asAuthor, ListRequest{})
+	assert.True(t, errors.IsErrForbidden(err))
+	assert.True(t, errors.IsErrInvalidRequest(s.Moderate(asModerator, flagged.ID, domain.StatusPending)))
+	require.NoError(t, s.Moderate(asModerator, flagged.ID, domain.StatusHidden))
+	require.NoError(t, s.Update(asAuthor, UpdateRequest{ID: flagged.ID, Text: "Edited again", Score: 5}))
+	assert.Equal(t, domain.StatusHidden, repo.reviews[flagged.ID].Status, "edits keep hidden reviews hidden")
+	assert.True(t, errors.IsErrResourceNotFound(s.Report(ctx, ReportRequest{ReviewID: flagged.ID, UserID: 3})))
+}
@@ -1,9 +1,40 @@
 package service
 
 import (
+	"context"
+
 	"go.uber.org/fx"
 )
 
 var Module = fx.Options(
-	fx.Provide(NewReviewService),
+	fx.Provide(
+		NewReviewService,
+		NewCommentService,
+		NewReconciler,
+	),
+	fx.Invoke(runReconciler),
 )
+
+func runReconciler(lifecycle fx.Lifecycle, reconciler *Reconciler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				reconciler.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lunn06/library/review/internal/app/repository"
+)
+
+// BookChecker tells which of the books are no longer in the catalogue.
+type BookChecker interface {
+	MissingBooks(ctx context.Context, bookIDs []int) ([]int, error)
+}
+
+type ReconcileConfig struct {
+	Interval  time.Duration `default:"24h"`
+	BatchSize int           `default:"100" split_words:"true"`
+}
+
+func NewReconciler(repo repository.ReviewRepo, books BookChecker, cfg ReconcileConfig) *Reconciler {
+	return &Reconciler{
+		repo:      repo,
+		books:     books,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Reconciler removes reviews of books deleted while the book.deleted event
+// could not reach the service, e.g. before it subscribed to the event.
+type Reconciler struct {
+	repo      repository.ReviewRepo
+	books     BookChecker
+	interval  time.Duration
+	batchSize int
+}
+
+// Run reconciles on start and then every interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := r.Reconcile(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to reconcile reviews", "err", err)
+		}
+		if deleted > 0 {
+			slog.Info("Deleted reviews of deleted books", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile deletes the reviews of missing books and returns how many
+// were deleted.
+func (r *Reconciler) Reconcile(ctx context.Context) (int, error) {
+	var deleted int
+	for after := 0; ; {
+		bookIDs, err := r.repo.BookIDs(ctx, after, r.batchSize)
+		if err != nil || len(bookIDs) == 0 {
+			return deleted, err
+		}
+		after = bookIDs[len(bookIDs)-1]
+
+		missing, err := r.books.MissingBooks(ctx, bookIDs)
+		if err != nil {
+			return deleted, err
+		}
+		for _, bookID := range missing {
+			n, err := r.repo.DeleteAllByBookID(ctx, bookID)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+	}
+}
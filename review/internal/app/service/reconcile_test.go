@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+type bookSet map[int]bool
+
+func (b bookSet) MissingBooks(_ context.Context, bookIDs []int) ([]int, error) {
+	var missing []int
+	for _, id := range bookIDs {
+		if !b[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func TestReconcile(t *testing.T) {
+	repo := newMemReviewRepo(t)
+	for bookID, n := range map[int]int{1: 2, 2: 1, 3: 4, 4: 1, 5: 3} {
+		for userID := 1; userID <= n; userID++ {
+			_, err := repo.Put(context.Background(), domain.Review{UserID: userID, BookID: bookID})
+			require.NoError(t, err)
+		}
+	}
+	books := bookSet{2: true, 4: true}
+
+	reconciler := NewReconciler(repo, books, ReconcileConfig{BatchSize: 2})
+	deleted, err := reconciler.Reconcile(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 9, deleted)
+	assert.Equal(t, map[int]int{2: 1, 4: 1}, repo.bookCounts())
+}
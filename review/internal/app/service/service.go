@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 
 	"github.com/lunn06/library/review/internal/app/repository"
 	repoerrors "github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/app/screen"
 	"github.com/lunn06/library/review/internal/app/service/errors"
 	"github.com/lunn06/library/review/internal/domain"
 )
@@ -18,6 +23,12 @@ type UpdateRequest struct {
 	Score int
 }
 
+type VoteRequest struct {
+	ReviewID int
+	UserID   int
+	Helpful  bool
+}
+
 type CreateRequest struct {
 	UserID int
 	BookID int
@@ -26,25 +37,88 @@ type CreateRequest struct {
 	Score  int
 }
 
-func NewReviewService(repo repository.ReviewRepo) *ReviewService {
+func NewReviewService(
+	repo repository.ReviewRepo,
+	books BookChecker,
+	screener screen.Screener,
+	cacheCfg BookCacheConfig,
+	statsCfg StatsConfig,
+) *ReviewService {
 	return &ReviewService{
-		repo: repo,
+		repo:     repo,
+		books:    books,
+		screener: screener,
+		cache:    newBookCache(cacheCfg),
+		stats:    statsCfg,
+		prior:    &prior{ttl: statsCfg.PriorTTL},
 	}
 }
 
 type ReviewService struct {
-	repo repository.ReviewRepo
+	repo  repository.ReviewRepo
+	books BookChecker
+	// screener holds the flagged reviews for moderation, all the reviews
+	// are published without it.
+	screener screen.Screener
+	cache    *bookCache
+	stats    StatsConfig
+	prior    *prior
+}
+
+func (s *ReviewService) GetAllByBookID(ctx context.Context, bookID int, req ListRequest) (domain.ReviewPage, error) {
+	query, err := req.query()
+	if err != nil {
+		return domain.ReviewPage{}, err
+	}
+
+	return s.repo.GetAllByBookID(ctx, bookID, query)
+}
+
+func (s *ReviewService) GetAllByUserID(ctx context.Context, userID int, req ListRequest) (domain.ReviewPage, error) {
+	query, err := req.query()
+	if err != nil {
+		return domain.ReviewPage{}, err
+	}
+
+	return s.repo.GetAllByUserID(ctx, userID, query)
 }
 
-func (s *ReviewService) GetAllByBookID(ctx context.Context, bookID int) ([]domain.Review, error) {
-	return s.repo.GetAllByBookID(ctx, bookID)
+// Create fails with ErrAlreadyExists when the user has already reviewed
+// the book. The review is pending when the pre-screen flagged it.
+func (s *ReviewService) Create(ctx context.Context, req CreateRequest) (domain.Review, error) {
+	review, err := s.newReview(ctx, req)
+	if err != nil {
+		return domain.Review{}, err
+	}
+
+	review, err = s.repo.Put(ctx, review)
+	if repoerrors.IsErrAlreadyExists(err) {
+		return domain.Review{}, errors.ErrAlreadyExists{Inner: err}
+	}
+
+	return review, err
+}
+
+// Upsert creates the review of the book by the user or replaces the one
+// they have already written, it reports whether the review was created.
+func (s *ReviewService) Upsert(ctx context.Context, req CreateRequest) (domain.Review, bool, error) {
+	review, err := s.newReview(ctx, req)
+	if err != nil {
+		return domain.Review{}, false, err
+	}
+
+	return s.repo.Upsert(ctx, review)
 }
 
-func (s *ReviewService) Create(ctx context.Context, req CreateRequest) (int, error) {
+func (s *ReviewService) newReview(ctx context.Context, req CreateRequest) (domain.Review, error) {
 	score, err := domain.NewScore(req.Score)
 	if err != nil {
-		return 0, err
+		return domain.Review{}, err
 	}
+	if err = s.checkBook(ctx, req.BookID); err != nil {
+		return domain.Review{}, err
+	}
+
 	review := domain.Review{
 		UserID: req.UserID,
 		BookID: req.BookID,
@@ -52,25 +126,38 @@ func (s *ReviewService) Create(ctx context.Context, req CreateRequest) (int, err
 		Text:   req.Text,
 		Score:  score,
 	}
-
-	review, err = s.repo.Put(ctx, review)
+	review.Status, err = s.screen(ctx, review)
 	if err != nil {
-		return 0, err
+		return domain.Review{}, err
 	}
 
-	return review.ID, nil
+	return review, nil
 }
 
+// Update edits the review on behalf of the user of the context, see
+// authz.Policy.
 func (s *ReviewService) Update(ctx context.Context, req UpdateRequest) error {
 	score, err := domain.NewScore(req.Score)
 	if err != nil {
 		return err
 	}
-	review := domain.Review{
-		ID:    req.ID,
-		Title: req.Title,
-		Text:  req.Text,
-		Score: score,
+	review, err := s.repo.Get(ctx, req.ID)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil {
+		return err
+	}
+	if err = authorize(ctx, authz.ResourceReview, authz.ActionUpdate, review.UserID); err != nil {
+		return err
+	}
+	review.Title = req.Title
+	review.Text = req.Text
+	review.Score = score
+
+	review.Status, err = s.screen(ctx, review)
+	if err != nil {
+		return err
 	}
 
 	err = s.repo.Update(ctx, review)
@@ -81,15 +168,130 @@ func (s *ReviewService) Update(ctx context.Context, req UpdateRequest) error {
 	return err
 }
 
+// Get returns the review if it is published.
 func (s *ReviewService) Get(ctx context.Context, id int) (domain.Review, error) {
 	review, err := s.repo.Get(ctx, id)
 	if repoerrors.IsErrNotFound(err) {
 		return domain.Review{}, errors.ErrResourceNotFound{Inner: err}
 	}
+	if err != nil {
+		return domain.Review{}, err
+	}
+	if review.Status != domain.StatusPublished {
+		return domain.Review{}, errors.ErrResourceNotFound{Inner: fmt.Errorf("review %d is %s", id, review.Status)}
+	}
 
-	return review, err
+	return review, nil
 }
 
+// Delete removes the review on behalf of the user of the context, see
+// authz.Policy.
 func (s *ReviewService) Delete(ctx context.Context, id int) error {
-	return s.repo.Delete(ctx, id)
+	review, err := s.repo.Get(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+	if err != nil {
+		return err
+	}
+	if err = authorize(ctx, authz.ResourceReview, authz.ActionDelete, review.UserID); err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// Vote records whether the user found the review helpful, replacing their
+// previous vote. Users cannot vote for their own reviews.
+func (s *ReviewService) Vote(ctx context.Context, req VoteRequest) error {
+	review, err := s.Get(ctx, req.ReviewID)
+	if err != nil {
+		return err
+	}
+	if review.UserID == req.UserID {
+		return errors.ErrInvalidRequest{Reason: "voting for own review"}
+	}
+
+	err = s.repo.Vote(ctx, domain.Vote{
+		UserID:   req.UserID,
+		ReviewID: req.ReviewID,
+		Helpful:  req.Helpful,
+	})
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// Unvote withdraws the vote of the user for the review.
+func (s *ReviewService) Unvote(ctx context.Context, reviewID int, userID int) error {
+	err := s.repo.Unvote(ctx, userID, reviewID)
+	if repoerrors.IsErrNotFound(err) {
+		return errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return err
+}
+
+// History returns a page of the revisions of the published review, oldest
+// first, after is the number of the last revision of the previous page.
+func (s *ReviewService) History(ctx context.Context, reviewID int, after int, limit int) (domain.RevisionPage, error) {
+	if limit < 0 || after < 0 {
+		return domain.RevisionPage{}, errors.ErrInvalidRequest{Reason: "negative limit or after"}
+	}
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	if _, err := s.Get(ctx, reviewID); err != nil {
+		return domain.RevisionPage{}, err
+	}
+
+	return s.repo.Revisions(ctx, reviewID, after, limit)
+}
+
+// DeleteAllByBookID removes the reviews of a deleted book.
+func (s *ReviewService) DeleteAllByBookID(ctx context.Context, bookID int) (int, error) {
+	s.cache.Set(bookID, false)
+	return s.repo.DeleteAllByBookID(ctx, bookID)
+}
+
+// checkBook fails with ErrResourceNotFound unless the book is in the catalogue.
+func (s *ReviewService) checkBook(ctx context.Context, bookID int) error {
+	exists, ok := s.cache.Get(bookID)
+	if !ok {
+		missing, err := s.books.MissingBooks(ctx, []int{bookID})
+		if err != nil {
+			return err
+		}
+		exists = len(missing) == 0
+		s.cache.Set(bookID, exists)
+	}
+
+	if !exists {
+		return errors.ErrResourceNotFound{Inner: fmt.Errorf("book %d", bookID)}
+	}
+
+	return nil
+}
+
+// authorize fails with ErrUnauthenticated or ErrForbidden unless the user of
+// the context may do the action on the resource of the owner.
+func authorize(ctx context.Context, resource authz.Resource, action authz.Action, ownerID int) error {
+	err := authz.Authorize(ctx, resource, action, ownerID)
+	switch {
+	case stderrors.Is(err, authz.ErrUnauthenticated):
+		return errors.ErrUnauthenticated{Inner: err}
+	case err != nil:
+		return errors.ErrForbidden{Inner: err}
+	}
+
+	return nil
 }
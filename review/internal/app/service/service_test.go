@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/app/repository"
+	repoerrors "github.com/lunn06/library/review/internal/app/repository/errors"
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// memReviewRepo keeps the reviews by id, one per user and book, with their
+// votes, revisions and reports.
+type memReviewRepo struct {
+	repository.ReviewRepo
+	reviews map[int]domain.Review
+	// votes and reports are keyed by user and review
+	votes     map[[2]int]bool
+	reports   map[[2]int]domain.Report
+	revisions map[int][]domain.Revision
+	nextID    int
+}
+
+// newMemReviewRepo puts the reviews in order, so their ids count from 1.
+func newMemReviewRepo(t *testing.T, reviews ...domain.Review) *memReviewRepo {
+	t.Helper()
+
+	m := &memReviewRepo{
+		reviews:   map[int]domain.Review{},
+		votes:     map[[2]int]bool{},
+		reports:   map[[2]int]domain.Report{},
+		revisions: map[int][]domain.Revision{},
+	}
+	for _, review := range reviews {
+		_, err := m.Put(context.Background(), review)
+		require.NoError(t, err)
+	}
+
+	return m
+}
+
+// bookCounts returns the number of reviews of every book.
+func (m *memReviewRepo) bookCounts() map[int]int {
+	counts := map[int]int{}
+	for _, review := range m.reviews {
+		counts[review.BookID]++
+	}
+	return counts
+}
+
+func (m *memReviewRepo) find(userID int, bookID int) (domain.Review, bool) {
+	for _, review := range m.reviews {
+		if review.UserID == userID && review.BookID == bookID {
+			return review, true
+		}
+	}
+	return domain.Review{}, false
+}
+
+func (m *memReviewRepo) Get(_ context.Context, id int) (domain.Review, error) {
+	review, ok := m.reviews[id]
+	if !ok {
+		return domain.Review{}, repoerrors.ErrNotFound{Inner: fmt.Errorf("review %d", id)}
+	}
+	return review, nil
+}
+
+func (m *memReviewRepo) Put(_ context.Context, review domain.Review) (domain.Review, error) {
+	if _, ok := m.find(review.UserID, review.BookID); ok {
+		return domain.Review{}, repoerrors.ErrAlreadyExists{Inner: fmt.Errorf("review of book %d by user %d", review.BookID, review.UserID)}
+	}
+	m.nextID++
+	review.ID = m.nextID
+	m.reviews[review.ID] = review
+	return review, nil
+}
+
+func (m *memReviewRepo) Upsert(ctx context.Context, review domain.Review) (domain.Review, bool, error) {
+	existing, ok := m.find(review.UserID, review.BookID)
+	if !ok {
+		review, err := m.Put(ctx, review)
+		return review, true, err
+	}
+	review.ID = existing.ID
+	if err := m.Update(ctx, review); err != nil {
+		return domain.Review{}, false, err
+	}
+	return m.reviews[review.ID], false, nil
+}
+
+func (m *memReviewRepo) Update(ctx context.Context, review domain.Review) error {
+	existing, err := m.Get(ctx, review.ID)
+	if err != nil {
+		return err
+	}
+	if existing.Status.Settled() {
+		review.Status = existing.Status
+	}
+	m.reviews[review.ID] = review
+	return nil
+}
+
+func (m *memReviewRepo) DeleteAllByBookID(_ context.Context, bookID int) (int, error) {
+	var n int
+	for id, review := range m.reviews {
+		if review.BookID == bookID {
+			delete(m.reviews, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memReviewRepo) BookIDs(_ context.Context, after int, limit int) ([]int, error) {
+	var ids []int
+	for id := range m.bookCounts() {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids[:min(limit, len(ids))], nil
+}
+
+func (m *memReviewRepo) Vote(_ context.Context, vote domain.Vote) error {
+	m.votes[[2]int{vote.UserID, vote.ReviewID}] = vote.Helpful
+	return nil
+}
+
+func (m *memReviewRepo) Unvote(_ context.Context, userID int, reviewID int) error {
+	key := [2]int{userID, reviewID}
+	if _, ok := m.votes[key]; !ok {
+		return repoerrors.ErrNotFound{Inner: fmt.Errorf("vote of user %d", userID)}
+	}
+	delete(m.votes, key)
+	return nil
+}
+
+func (m *memReviewRepo) Revisions(_ context.Context, reviewID int, after int, limit int) (domain.RevisionPage, error) {
+	revisions := m.revisions[reviewID]
+	revisions = revisions[min(after, len(revisions)):]
+	var page domain.RevisionPage
+	if len(revisions) > limit {
+		revisions = revisions[:limit]
+		page.NextAfter = revisions[limit-1].Number
+	}
+	page.Revisions = revisions
+	return page, nil
+}
+
+func (m *memReviewRepo) Report(_ context.Context, report domain.Report) error {
+	key := [2]int{report.UserID, report.ReviewID}
+	if _, ok := m.reports[key]; ok {
+		return repoerrors.ErrAlreadyExists{Inner: fmt.Errorf("report %v", key)}
+	}
+	m.reports[key] = report
+	return nil
+}
+
+func (m *memReviewRepo) Moderate(_ context.Context, id int, status domain.ModerationStatus) error {
+	review := m.reviews[id]
+	review.Status = status
+	m.reviews[id] = review
+	return nil
+}
+
+type countingChecker struct {
+	bookSet
+	calls int
+}
+
+func (c *countingChecker) MissingBooks(ctx context.Context, bookIDs []int) ([]int, error) {
+	c.calls++
+	return c.bookSet.MissingBooks(ctx, bookIDs)
+}
+
+func TestCreateChecksBook(t *testing.T) {
+	repo := newMemReviewRepo(t)
+	books := &countingChecker{bookSet: bookSet{1: true}}
+	s := NewReviewService(repo, books, nil, BookCacheConfig{TTL: time.Minute, MissingTTL: time.Minute, Size: 10}, StatsConfig{})
+
+	for userID := 1; userID <= 2; userID++ {
+		_, err := s.Create(context.Background(), CreateRequest{UserID: userID, BookID: 1, Score: 8})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, books.calls, "existing book is cached")
+	assert.Equal(t, 2, repo.bookCounts()[1])
+
+	_, err := s.Create(context.Background(), CreateRequest{UserID: 1, BookID: 2, Score: 8})
+	assert.True(t, errors.IsErrResourceNotFound(err))
+	assert.Zero(t, repo.bookCounts()[2])
+
+	_, err = s.Create(context.Background(), CreateRequest{UserID: 3, BookID: 1, Score: 11})
+	assert.ErrorIs(t, err, domain.ErrInvalidScore)
+}
+
+func TestCreateConflictAndUpsert(t *testing.T) {
+	repo := newMemReviewRepo(t)
+	s := NewReviewService(repo, bookSet{1: true}, nil, BookCacheConfig{TTL: time.Minute, MissingTTL: time.Minute, Size: 10}, StatsConfig{})
+
+	review, err := s.Create(context.Background(), CreateRequest{UserID: 1, BookID: 1, Score: 8})
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusPublished, review.Status)
+
+	_, err = s.Create(context.Background(), CreateRequest{UserID: 1, BookID: 1, Score: 3})
+	assert.True(t, errors.IsErrAlreadyExists(err))
+
+	upserted, created, err := s.Upsert(context.Background(), CreateRequest{UserID: 1, BookID: 1, Score: 3})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, review.ID, upserted.ID)
+	assert.Equal(t, domain.Score(3), repo.reviews[review.ID].Score)
+
+	_, created, err = s.Upsert(context.Background(), CreateRequest{UserID: 2, BookID: 1, Score: 3})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	_, _, err = s.Upsert(context.Background(), CreateRequest{UserID: 2, BookID: 2, Score: 3})
+	assert.True(t, errors.IsErrResourceNotFound(err))
+}
+
+func TestVote(t *testing.T) {
+	repo := newMemReviewRepo(t, domain.Review{UserID: 1, BookID: 1, Status: domain.StatusPublished})
+	s := NewReviewService(repo, bookSet{}, nil, BookCacheConfig{}, StatsConfig{})
+
+	require.NoError(t, s.Vote(context.Background(), VoteRequest{ReviewID: 1, UserID: 2, Helpful: true}))
+	assert.Equal(t, map[[2]int]bool{{2, 1}: true}, repo.votes)
+
+	err := s.Vote(context.Background(), VoteRequest{ReviewID: 1, UserID: 1, Helpful: true})
+	assert.True(t, errors.IsErrInvalidRequest(err), "own review")
+
+	err = s.Vote(context.Background(), VoteRequest{ReviewID: 2, UserID: 2})
+	assert.True(t, errors.IsErrResourceNotFound(err))
+
+	require.NoError(t, s.Unvote(context.Background(), 1, 2))
+	assert.True(t, errors.IsErrResourceNotFound(s.Unvote(context.Background(), 1, 2)))
+}
+
+func TestHistory(t *testing.T) {
+	repo := newMemReviewRepo(t, domain.Review{UserID: 1, BookID: 1, Status: domain.StatusPublished})
+	for n := 1; n <= MaxPageSize+1; n++ {
+		repo.revisions[1] = append(repo.revisions[1], domain.Revision{ReviewID: 1, Number: n})
+	}
+	s := NewReviewService(repo, bookSet{}, nil, BookCacheConfig{}, StatsConfig{})
+	ctx := context.Background()
+
+	page, err := s.History(ctx, 1, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, page.Revisions, DefaultPageSize)
+	assert.Equal(t, DefaultPageSize, page.NextAfter)
+
+	page, err = s.History(ctx, 1, 1, MaxPageSize+10)
+	require.NoError(t, err)
+	assert.Len(t, page.Revisions, MaxPageSize)
+	assert.Zero(t, page.NextAfter)
+
+	_, err = s.History(ctx, 1, -1, 0)
+	assert.True(t, errors.IsErrInvalidRequest(err))
+
+	_, err = s.History(ctx, 2, 0, 0)
+	assert.True(t, errors.IsErrResourceNotFound(err))
+}
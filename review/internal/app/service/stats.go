@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lunn06/library/review/internal/app/service/errors"
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+type StatsConfig struct {
+	// PriorWeight is how many reviews of the mean score of all reviews
+	// the Bayesian mean adds to the reviews of a book.
+	PriorWeight float64       `default:"10" split_words:"true"`
+	PriorTTL    time.Duration `default:"5m" split_words:"true"`
+	MaxBooks    int           `default:"100" split_words:"true"`
+}
+
+// prior is the mean score of all reviews, recomputed once it is older
+// than the ttl.
+type prior struct {
+	mu         sync.Mutex
+	mean       float64
+	computedAt time.Time
+	ttl        time.Duration
+}
+
+func (p *prior) get(ctx context.Context, compute func(context.Context) (domain.Histogram, error)) (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.computedAt.IsZero() && time.Since(p.computedAt) < p.ttl {
+		return p.mean, nil
+	}
+
+	histogram, err := compute(ctx)
+	if err != nil {
+		return 0, err
+	}
+	p.mean, p.computedAt = histogram.Mean(), time.Now()
+
+	return p.mean, nil
+}
+
+// Stats aggregates the reviews of each of the books, books without reviews
+// get zero counts.
+func (s *ReviewService) Stats(ctx context.Context, bookIDs []int) ([]domain.Stats, error) {
+	if len(bookIDs) > s.stats.MaxBooks {
+		return nil, errors.ErrInvalidRequest{Reason: fmt.Sprintf("stats of at most %d books at once", s.stats.MaxBooks)}
+	}
+
+	priorMean, err := s.prior.get(ctx, s.repo.ScoreHistogram)
+	if err != nil {
+		return nil, err
+	}
+
+	histograms, err := s.repo.ScoreHistograms(ctx, bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]domain.Stats, len(bookIDs))
+	for i, bookID := range bookIDs {
+		histogram := histograms[bookID]
+		stats[i] = domain.Stats{
+			BookID:       bookID,
+			Count:        histogram.Count(),
+			Mean:         histogram.Mean(),
+			BayesianMean: histogram.BayesianMean(priorMean, s.stats.PriorWeight),
+			Histogram:    histogram,
+		}
+	}
+
+	return stats, nil
+}
@@ -4,12 +4,18 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/lunn06/library/review/internal/api/nats"
+	"github.com/lunn06/library/review/internal/app/screen"
+	"github.com/lunn06/library/review/internal/app/service"
 	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
 )
 
 type Config struct {
 	fx.Out
 
-	Nats     nats.Config
-	Postgres postgres.Config
+	Nats      nats.Config
+	Postgres  postgres.Config
+	Reconcile service.ReconcileConfig
+	BookCache service.BookCacheConfig
+	Stats     service.StatsConfig
+	Screen    screen.Config
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+const MaxCommentLength = 10000
+
+type Comment struct {
+	ID       int
+	ReviewID int
+	// ParentID is nil for the comments replying to the review itself.
+	ParentID  *int
+	UserID    int
+	Text      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is set once the comment is deleted, its text is gone but
+	// its replies stay.
+	DeletedAt *time.Time
+}
+
+func (c Comment) Deleted() bool {
+	return c.DeletedAt != nil
+}
+
+type CommentPage struct {
+	Comments []Comment
+	// NextAfterID is the id to continue the thread from, zero on the last
+	// page.
+	NextAfterID int
+}
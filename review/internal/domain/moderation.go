@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ModerationStatus tells who can see a review, only published reviews are
+// listed and counted in the stats.
+type ModerationStatus string
+
+const (
+	StatusPublished ModerationStatus = "published"
+	// StatusPending reviews wait for a moderator after the pre-screen
+	// flagged them.
+	StatusPending  ModerationStatus = "pending"
+	StatusHidden   ModerationStatus = "hidden"
+	StatusRejected ModerationStatus = "rejected"
+)
+
+func (ModerationStatus) Values() []string {
+	return []string{
+		string(StatusPublished),
+		string(StatusPending),
+		string(StatusHidden),
+		string(StatusRejected),
+	}
+}
+
+// Settled tells whether a moderator has taken the review down, edits do
+// not bring such reviews back.
+func (s ModerationStatus) Settled() bool {
+	return s == StatusHidden || s == StatusRejected
+}
+
+type ReportReason string
+
+const (
+	ReasonOther    ReportReason = "other"
+	ReasonSpam     ReportReason = "spam"
+	ReasonAbuse    ReportReason = "abuse"
+	ReasonOffTopic ReportReason = "off_topic"
+	ReasonSpoiler  ReportReason = "spoiler"
+)
+
+func (ReportReason) Values() []string {
+	return []string{
+		string(ReasonOther),
+		string(ReasonSpam),
+		string(ReasonAbuse),
+		string(ReasonOffTopic),
+		string(ReasonSpoiler),
+	}
+}
+
+// Report is a reader complaint about a review, the open reports put the
+// review into the moderation queue.
+type Report struct {
+	ReviewID int
+	UserID   int
+	Reason   ReportReason
+	Comment  string
+}
+
+// NormalizeText folds the case and the whitespace of a text, so copies of
+// a text differing in them are the same.
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// TextHash identifies the normalized text of a review.
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeText(text)))
+	return hex.EncodeToString(sum[:])
+}
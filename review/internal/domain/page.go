@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Sort int
+
+const (
+	SortNewest Sort = iota
+	SortOldest
+	SortHighestScore
+	SortLowestScore
+	SortMostHelpful
+)
+
+// Key is the value reviews are ordered by, ties are broken by ID.
+func (s Sort) Key(review Review) int64 {
+	switch s {
+	case SortHighestScore, SortLowestScore:
+		return int64(review.Score)
+	case SortMostHelpful:
+		return int64(review.HelpfulCount)
+	default:
+		return review.CreatedAt.UnixMicro()
+	}
+}
+
+// Descending reports whether greater keys come first.
+func (s Sort) Descending() bool {
+	return s != SortOldest && s != SortLowestScore
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last review of a page, the next page starts after it.
+type Cursor struct {
+	Sort Sort  `json:"s"`
+	Key  int64 `json:"k"`
+	ID   int   `json:"i"`
+}
+
+func CursorAfter(sort Sort, review Review) Cursor {
+	return Cursor{Sort: sort, Key: sort.Key(review), ID: review.ID}
+}
+
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.Sort < SortNewest || cursor.Sort > SortMostHelpful {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// ReviewFilter narrows a list of reviews, zero fields do not filter.
+type ReviewFilter struct {
+	MinScore      *Score
+	MaxScore      *Score
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// ReviewQuery selects a page of reviews, Cursor is nil for the first page.
+type ReviewQuery struct {
+	Filter ReviewFilter
+	Sort   Sort
+	Cursor *Cursor
+	Limit  int
+}
+
+type ReviewPage struct {
+	Reviews []Review
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	review := Review{
+		ID:        42,
+		CreatedAt: time.Date(2025, 5, 8, 16, 41, 28, 123456000, time.UTC),
+		Score:     8,
+	}
+
+	cursor := CursorAfter(SortNewest, review)
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+	assert.Equal(t, review.CreatedAt, time.UnixMicro(parsed.Key).UTC())
+
+	assert.Equal(t, int64(8), CursorAfter(SortLowestScore, review).Key)
+
+	for _, s := range []string{"", "!!!", "eyJzIjo5fQ"} {
+		_, err = ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
@@ -27,7 +27,40 @@ type Review struct {
 	UserID    int
 	BookID    int
 	CreatedAt time.Time
-	Title     string
-	Text      string
-	Score     Score
+	// UpdatedAt is the time of the last edit, CreatedAt for unedited
+	// reviews.
+	UpdatedAt      time.Time
+	Title          string
+	Text           string
+	Score          Score
+	HelpfulCount   int
+	UnhelpfulCount int
+	Status         ModerationStatus
+	// ReportCount is the number of reports not yet handled by a moderator.
+	ReportCount int
+}
+
+// Revision is the content of a review before its edit with the number.
+type Revision struct {
+	ReviewID int
+	Number   int
+	Title    string
+	Text     string
+	Score    Score
+	EditedAt time.Time
+}
+
+type RevisionPage struct {
+	Revisions []Revision
+	// NextAfter is the number to continue the history from, zero on the
+	// last page.
+	NextAfter int
+}
+
+// Vote tells whether the user found the review helpful, a user votes once
+// for a review and may change their mind.
+type Vote struct {
+	UserID   int
+	ReviewID int
+	Helpful  bool
 }
@@ -0,0 +1,50 @@
+package domain
+
+// Histogram counts the reviews with each score.
+type Histogram [MaxScore - MinScore + 1]int
+
+func (h Histogram) Count() int {
+	var count int
+	for _, n := range h {
+		count += n
+	}
+
+	return count
+}
+
+func (h Histogram) Mean() float64 {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	return float64(h.sum()) / float64(count)
+}
+
+// BayesianMean is the mean with weight reviews of priorMean added, the
+// fewer reviews there are the closer it is to priorMean.
+func (h Histogram) BayesianMean(priorMean float64, weight float64) float64 {
+	count := float64(h.Count())
+	if count+weight == 0 {
+		return 0
+	}
+
+	return (priorMean*weight + float64(h.sum())) / (count + weight)
+}
+
+func (h Histogram) sum() int {
+	var sum int
+	for i, n := range h {
+		sum += (MinScore + i) * n
+	}
+
+	return sum
+}
+
+type Stats struct {
+	BookID       int
+	Count        int
+	Mean         float64
+	BayesianMean float64
+	Histogram    Histogram
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	assert.Zero(t, h.Count())
+	assert.Zero(t, h.Mean())
+	assert.InDelta(t, 7, h.BayesianMean(7, 10), 1e-9)
+
+	h[10] = 1
+	assert.Equal(t, 1, h.Count())
+	assert.InDelta(t, 10, h.Mean(), 1e-9)
+	// a single perfect score barely moves the prior
+	assert.InDelta(t, 80.0/11, h.BayesianMean(7, 10), 1e-9)
+
+	h[0] = 2
+	h[4] = 1
+	assert.Equal(t, 4, h.Count())
+	assert.InDelta(t, 3.5, h.Mean(), 1e-9)
+	assert.InDelta(t, 3.5, h.BayesianMean(7, 0), 1e-9)
+}
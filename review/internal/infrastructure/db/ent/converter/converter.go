@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/ent"
+)
+
+func ReviewToDomain(entReview *ent.Review) domain.Review {
+	return domain.Review{
+		ID:             entReview.ID,
+		UserID:         entReview.UserID,
+		BookID:         entReview.BookID,
+		CreatedAt:      entReview.CreatedAt,
+		UpdatedAt:      entReview.UpdatedAt,
+		Title:          entReview.Title,
+		Text:           entReview.Text,
+		Score:          entReview.Score,
+		HelpfulCount:   entReview.HelpfulCount,
+		UnhelpfulCount: entReview.UnhelpfulCount,
+		Status:         entReview.Status,
+		ReportCount:    entReview.ReportCount,
+	}
+}
+
+func ReviewsToDomain(entReviews []*ent.Review) []domain.Review {
+	reviews := make([]domain.Review, len(entReviews))
+	for i, entReview := range entReviews {
+		reviews[i] = ReviewToDomain(entReview)
+	}
+
+	return reviews
+}
+
+func CommentToDomain(entComment *ent.Comment) domain.Comment {
+	return domain.Comment{
+		ID:        entComment.ID,
+		ReviewID:  entComment.ReviewID,
+		ParentID:  entComment.ParentID,
+		UserID:    entComment.UserID,
+		Text:      entComment.Text,
+		CreatedAt: entComment.CreatedAt,
+		UpdatedAt: entComment.UpdatedAt,
+		DeletedAt: entComment.DeletedAt,
+	}
+}
+
+func CommentsToDomain(entComments []*ent.Comment) []domain.Comment {
+	comments := make([]domain.Comment, len(entComments))
+	for i, entComment := range entComments {
+		comments[i] = CommentToDomain(entComment)
+	}
+
+	return comments
+}
+
+func RevisionToDomain(entRevision *ent.Revision) domain.Revision {
+	return domain.Revision{
+		ReviewID: entRevision.ReviewID,
+		Number:   entRevision.Number,
+		Title:    entRevision.Title,
+		Text:     entRevision.Text,
+		Score:    entRevision.Score,
+		EditedAt: entRevision.EditedAt,
+	}
+}
+
+func RevisionsToDomain(entRevisions []*ent.Revision) []domain.Revision {
+	revisions := make([]domain.Revision, len(entRevisions))
+	for i, entRevision := range entRevisions {
+		revisions[i] = RevisionToDomain(entRevision)
+	}
+
+	return revisions
+}
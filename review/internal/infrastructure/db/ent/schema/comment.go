@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// Comment replies to a review or to another comment of it. Deleted comments
+// keep their place in the thread.
+type Comment struct{ ent.Schema }
+
+func (Comment) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("review_id"),
+		field.Int("parent_id").Optional().Nillable(),
+		field.Int("user_id"),
+		field.String("text"),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("updated_at").Default(time.Now).UpdateDefault(time.Now),
+		field.Time("deleted_at").Optional().Nillable(),
+	}
+}
+
+func (Comment) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("review", Review.Type).
+			Ref("comments").
+			Field("review_id").
+			Unique().
+			Required(),
+		edge.To("replies", Comment.Type).
+			From("parent").
+			Field("parent_id").
+			Unique().
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+	}
+}
+
+func (Comment) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("review_id"),
+	}
+}
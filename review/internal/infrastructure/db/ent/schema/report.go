@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// Report is a reader complaint about a review, it is resolved once
+// a moderator decides on the review.
+type Report struct{ ent.Schema }
+
+func (Report) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("review_id"),
+		field.Int("user_id"),
+		field.Enum("reason").GoType(domain.ReportReason("")),
+		field.String("comment").Default(""),
+		field.Bool("resolved").Default(false),
+		field.Time("created_at").Default(time.Now).Immutable(),
+	}
+}
+
+func (Report) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("review", Review.Type).
+			Ref("reports").
+			Field("review_id").
+			Unique().
+			Required(),
+	}
+}
+
+func (Report) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "review_id").Unique(),
+	}
+}
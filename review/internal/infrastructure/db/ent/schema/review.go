@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+type Review struct{ ent.Schema }
+
+func (Review) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("user_id"),
+		field.Int("book_id"),
+		field.Time("created_at").Default(time.Now),
+		// time of the last edit of the content, see Revision, the creation
+		// for reviews never edited. The reviews written before the column
+		// existed are backfilled by postgres.Connect.
+		field.Time("updated_at").
+			Default(time.Now).
+			Annotations(entsql.Default("CURRENT_TIMESTAMP")),
+		// number of the edits
+		field.Int("revision").Default(0),
+		field.String("title"),
+		field.String("text"),
+		field.Int("score").GoType(domain.Score(0)),
+		field.Int("helpful_count").Default(0),
+		field.Int("unhelpful_count").Default(0),
+		field.Enum("status").
+			GoType(domain.ModerationStatus("")).
+			Default(string(domain.StatusPublished)),
+		field.Int("report_count").Default(0),
+		// hash of the normalized text, finds copies of it. The hashes of the
+		// reviews written before are filled in by postgres.Connect.
+		field.String("text_hash").Default(""),
+	}
+}
+
+func (Review) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("votes", Vote.Type).
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		edge.To("comments", Comment.Type).
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		edge.To("reports", Report.Type).
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		edge.To("revisions", Revision.Type).
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+	}
+}
+
+func (Review) Indexes() []ent.Index {
+	return []ent.Index{
+		// a user reviews a book once, further reviews replace it with upsert.
+		// The duplicates written before are dropped by postgres.Connect.
+		index.Fields("user_id", "book_id").
+			Unique().
+			StorageKey("review_user_id_book_id"),
+		index.Fields("user_id", "created_at"),
+		index.Fields("book_id", "created_at"),
+		index.Fields("book_id", "score"),
+		index.Fields("book_id", "helpful_count"),
+		index.Fields("status", "created_at"),
+		index.Fields("text_hash"),
+	}
+}
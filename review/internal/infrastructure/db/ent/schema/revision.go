@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// Revision keeps the content a review had before an edit, revisions are
+// never changed.
+type Revision struct{ ent.Schema }
+
+func (Revision) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("review_id").Immutable(),
+		// number of the edit, counting from 1 for each review
+		field.Int("number").Immutable(),
+		field.String("title").Immutable(),
+		field.String("text").Immutable(),
+		field.Int("score").GoType(domain.Score(0)).Immutable(),
+		field.Time("edited_at").Default(time.Now).Immutable(),
+	}
+}
+
+func (Revision) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("review", Review.Type).
+			Ref("revisions").
+			Field("review_id").
+			Unique().
+			Required().
+			Immutable(),
+	}
+}
+
+func (Revision) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("review_id", "number").Unique(),
+	}
+}
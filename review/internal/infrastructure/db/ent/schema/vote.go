@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// Vote tells whether a user found a review helpful, the counts of votes
+// are kept on the review.
+type Vote struct{ ent.Schema }
+
+func (Vote) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("user_id"),
+		field.Int("review_id"),
+		field.Bool("helpful"),
+		field.Time("created_at").Default(time.Now),
+	}
+}
+
+func (Vote) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("review", Review.Type).
+			Ref("votes").
+			Field("review_id").
+			Unique().
+			Required(),
+	}
+}
+
+func (Vote) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "review_id").Unique(),
+	}
+}
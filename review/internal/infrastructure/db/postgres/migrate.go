@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lunn06/library/review/internal/domain"
+)
+
+// migration changes the data of the reviews, which the schema migration of
+// ent can't do. Each migration is applied once, the names of the applied ones
+// are kept in the data_migrations table.
+type migration struct {
+	name string
+	up   func(ctx context.Context, tx *sql.Tx) error
+}
+
+// beforeSchema are applied before the schema is migrated, they make the data
+// fit the new constraints of the schema.
+var beforeSchema = []migration{
+	{name: "dedup_reviews", up: dedupReviews},
+}
+
+// afterSchema are applied after the schema is migrated, they fill in the
+// columns it added.
+var afterSchema = []migration{
+	{name: "backfill_text_hash", up: backfillTextHash},
+	{name: "backfill_updated_at", up: backfillUpdatedAt},
+}
+
+func migrate(ctx context.Context, db *sql.DB, migrations []migration) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS data_migrations (
+		name text PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err = apply(ctx, db, m); err != nil {
+			return fmt.Errorf("applying migration %s: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// apply runs the migration unless it was applied, the row of the migration
+// locks it for the other replicas starting at the same time.
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT DO NOTHING`, m.name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err = m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// dedupReviews keeps the latest review of each user and book, so that the
+// unique index review_user_id_book_id can be created. The duplicates were
+// written before the index existed, when no other table referred to the
+// reviews.
+func dedupReviews(ctx context.Context, tx *sql.Tx) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT to_regclass('reviews') IS NOT NULL`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM reviews r
+		USING reviews newer
+		WHERE newer.user_id = r.user_id
+			AND newer.book_id = r.book_id
+			AND (newer.created_at, newer.id) > (r.created_at, r.id)`)
+
+	return err
+}
+
+// backfillTextHash sets the text_hash of the reviews written before the
+// column existed, so that the duplicates screening finds their copies.
+func backfillTextHash(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, text FROM reviews WHERE text_hash = ''`)
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[int]string)
+	for rows.Next() {
+		var (
+			id   int
+			text string
+		)
+		if err = rows.Scan(&id, &text); err != nil {
+			return err
+		}
+		hashes[id] = domain.TextHash(text)
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for id, hash := range hashes {
+		_, err = tx.ExecContext(ctx, `UPDATE reviews SET text_hash = $1 WHERE id = $2`, hash, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillUpdatedAt sets the updated_at of the reviews never edited to
+// their created_at, the reviews written before the column existed got the
+// time of the schema migration.
+func backfillUpdatedAt(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `UPDATE reviews SET updated_at = created_at
+		WHERE revision = 0 AND updated_at <> created_at`)
+
+	return err
+}
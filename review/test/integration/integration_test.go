@@ -6,9 +6,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/nats-io/nats.go"
 	"github.com/testcontainers/testcontainers-go"
 	natscontainer "github.com/testcontainers/testcontainers-go/modules/nats"
@@ -17,7 +19,9 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	natsapi "github.com/lunn06/library/review/internal/api/nats"
+	bookpb "github.com/lunn06/library/review/internal/api/proto/book"
 	"github.com/lunn06/library/review/internal/app/repository"
+	"github.com/lunn06/library/review/internal/app/screen"
 	"github.com/lunn06/library/review/internal/app/service"
 	"github.com/lunn06/library/review/internal/config"
 	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
@@ -26,11 +30,22 @@ import (
 const reqTimeout = time.Second
 
 const (
-	reviewGetAllByBookIDSubj = "gateway.getAllByBookID"
-	reviewGetSubj            = "gateway.get"
-	reviewPutSubj            = "gateway.put"
-	reviewUpdateSubj         = "gateway.update"
-	reviewDeleteSubj         = "gateway.delete"
+	reviewGetAllByBookIDSubj = "review.getAllByBookId"
+	reviewGetSubj            = "review.get"
+	reviewPutSubj            = "review.put"
+	reviewUpsertSubj         = "review.upsert"
+	reviewUpdateSubj         = "review.update"
+	reviewDeleteSubj         = "review.delete"
+	reviewVoteSubj           = "review.vote"
+	reviewUnvoteSubj         = "review.unvote"
+	reviewReportSubj         = "review.report"
+	reviewModerateSubj       = "review.moderate"
+	reviewQueueSubj          = "review.queue"
+	reviewHistorySubj        = "review.history"
+
+	commentListSubj   = "review.comment.list"
+	commentPutSubj    = "review.comment.put"
+	commentDeleteSubj = "review.comment.delete"
 )
 
 const (
@@ -40,7 +55,10 @@ const (
 	postgresSslMode  = "disable"
 )
 
-var nc *nats.Conn
+var (
+	nc          *nats.Conn
+	postgresCfg postgres.Config
+)
 
 func TestMain(m *testing.M) {
 	natsC := natsContainer()
@@ -65,21 +83,46 @@ func TestMain(m *testing.M) {
 	cfg.Postgres.DB = postgresDb
 	cfg.Postgres.SslMode = postgresSslMode
 
+	postgresCfg = cfg.Postgres
+
 	entClient, err := postgres.Connect(cfg.Postgres)
 	if err != nil {
 		panic(err)
 	}
 
+	nc, err = nats.Connect(cfg.Nats.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	// every book is in the catalogue
+	_, err = nc.Subscribe("book.exists", func(msg *nats.Msg) {
+		data, _ := proto.Marshal(&bookpb.ExistsResponse{StatusCode: http.StatusOK})
+		_ = msg.Respond(data)
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	reviewRepo := repository.NewEntReviewRepo(entClient)
-	reviewService := service.NewReviewService(reviewRepo)
+	reviewService := service.NewReviewService(
+		reviewRepo,
+		natsapi.NewBookInfoClient(nc),
+		screen.New(screen.Config{Enabled: true, BannedWords: []string{"forbidden"}, MaxLinks: 2, DuplicateMinLength: 50}, reviewRepo),
+		service.BookCacheConfig{TTL: time.Minute, MissingTTL: time.Second, Size: 100},
+		service.StatsConfig{PriorWeight: 10, PriorTTL: time.Minute, MaxBooks: 100},
+	)
 	reviewConsumer := natsapi.NewReviewConsumer(reviewService)
 
-	nc, err = nats.Connect(cfg.Nats.URL)
-
 	if err = natsapi.RegisterReviewConsumer(nc, reviewConsumer); err != nil {
 		panic(err)
 	}
 
+	commentService := service.NewCommentService(repository.NewEntCommentRepo(entClient), reviewRepo)
+	if err = natsapi.RegisterCommentConsumer(nc, natsapi.NewCommentConsumer(commentService)); err != nil {
+		panic(err)
+	}
+
 	m.Run()
 
 	err = errors.Join(
@@ -123,12 +166,22 @@ func postgresContainer() testcontainers.Container {
 }
 
 func request(subj string, req, resp proto.Message) error {
+	return requestAs(authz.Principal{}, subj, req, resp)
+}
+
+// requestAs makes the request on behalf of the principal, as the gateway
+// does for authenticated users.
+func requestAs(p authz.Principal, subj string, req, resp proto.Message) error {
 	data, err := proto.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	resMsg, err := nc.Request(subj, data, reqTimeout)
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	authz.SetHeader(msg.Header, p)
+
+	resMsg, err := nc.RequestMsg(msg, reqTimeout)
 	if err != nil {
 		return err
 	}
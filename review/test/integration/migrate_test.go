@@ -0,0 +1,145 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/review/internal/domain"
+	"github.com/lunn06/library/review/internal/infrastructure/db/postgres"
+)
+
+// openLegacy creates a database with the reviews table as it was before the
+// data migrations, the reviews are inserted by the caller.
+func openLegacy(t *testing.T, name string) (*sql.DB, postgres.Config) {
+	ctx := context.Background()
+
+	db, err := sql.Open("postgres", dsn(postgresCfg))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %q", name))
+	require.NoError(t, err)
+
+	cfg := postgresCfg
+	cfg.DB = name
+	legacy, err := sql.Open("postgres", dsn(cfg))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = legacy.Close() })
+
+	_, err = legacy.ExecContext(ctx, `CREATE TABLE reviews (
+		id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		user_id bigint NOT NULL,
+		book_id bigint NOT NULL,
+		created_at timestamptz NOT NULL,
+		title varchar NOT NULL,
+		text varchar NOT NULL,
+		score bigint NOT NULL,
+		helpful_count bigint NOT NULL DEFAULT 0
+	)`)
+	require.NoError(t, err)
+
+	return legacy, cfg
+}
+
+func dsn(cfg postgres.Config) string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s/%s?sslmode=%s",
+		cfg.User, cfg.Password, cfg.URL, cfg.DB, cfg.SslMode,
+	)
+}
+
+func TestConnectDedupsReviews(t *testing.T) {
+	ctx := context.Background()
+	legacy, cfg := openLegacy(t, "test-dedup-reviews")
+
+	created := time.Now().Add(-time.Hour)
+	_, err := legacy.ExecContext(ctx, `INSERT INTO reviews
+		(user_id, book_id, created_at, title, text, score) VALUES
+		(1, 1, $1, 'old', 'old', 1),
+		(1, 1, $2, 'latest', 'latest', 2),
+		(1, 1, $1, 'old again', 'old again', 3),
+		(1, 2, $1, 'other book', 'other book', 4),
+		(2, 1, $1, 'other user', 'other user', 5)`,
+		created, created.Add(time.Minute),
+	)
+	require.NoError(t, err)
+
+	client, err := postgres.Connect(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := legacy.QueryContext(ctx, `SELECT title FROM reviews ORDER BY user_id, book_id`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var titles []string
+	for rows.Next() {
+		var title string
+		require.NoError(t, rows.Scan(&title))
+		titles = append(titles, title)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"latest", "other book", "other user"}, titles)
+
+	// the migrations are applied once
+	again, err := postgres.Connect(cfg)
+	require.NoError(t, err)
+	_ = again.Close()
+}
+
+func TestConnectBackfillsTextHash(t *testing.T) {
+	ctx := context.Background()
+	legacy, cfg := openLegacy(t, "test-backfill-text-hash")
+
+	_, err := legacy.ExecContext(ctx, `INSERT INTO reviews
+		(user_id, book_id, created_at, title, text, score) VALUES
+		(1, 1, now(), 'copy', 'A  Copied text', 1),
+		(2, 1, now(), 'copy', 'a copied TEXT', 1)`,
+	)
+	require.NoError(t, err)
+
+	client, err := postgres.Connect(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := legacy.QueryContext(ctx, `SELECT text_hash FROM reviews`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		require.NoError(t, rows.Scan(&hash))
+		assert.Equal(t, domain.TextHash("a copied text"), hash)
+	}
+	require.NoError(t, rows.Err())
+}
+
+func TestConnectBackfillsUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	legacy, cfg := openLegacy(t, "test-backfill-updated-at")
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	_, err := legacy.ExecContext(ctx, `INSERT INTO reviews
+		(user_id, book_id, created_at, title, text, score) VALUES
+		(1, 1, $1, 'old', 'old', 1)`,
+		created,
+	)
+	require.NoError(t, err)
+
+	client, err := postgres.Connect(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	var updated time.Time
+	err = legacy.QueryRowContext(ctx, `SELECT updated_at FROM reviews`).Scan(&updated)
+	require.NoError(t, err)
+	assert.True(t, created.Equal(updated), "updated_at %s of a review never edited", updated)
+}
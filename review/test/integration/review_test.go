@@ -4,8 +4,11 @@ package integration
 
 import (
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/lunn06/library/bookinfo/pkg/authz"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -14,7 +17,7 @@ import (
 
 func TestReviewCreate(t *testing.T) {
 	const (
-		testUserID = 999
+		testUserID = 901
 		testBookID
 		testTitle = "TestReviewCreate"
 		testText
@@ -52,7 +55,7 @@ func TestReviewCreate(t *testing.T) {
 
 func TestReviewUpdate(t *testing.T) {
 	const (
-		testUserID = 999
+		testUserID = 902
 		testBookID
 		testTitle = "TestReviewUpdate"
 		testText
@@ -85,7 +88,11 @@ func TestReviewUpdate(t *testing.T) {
 		Score: testUpdatedScore,
 	}
 	var updateResp reviewpb.EmptyResponse
-	err = request(reviewUpdateSubj, &updateReq, &updateResp)
+	err = requestAs(authz.Principal{UserID: testUserID + 1}, reviewUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(updateResp.StatusCode))
+
+	err = requestAs(authz.Principal{UserID: testUserID}, reviewUpdateSubj, &updateReq, &updateResp)
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(updateResp.StatusCode))
@@ -109,7 +116,7 @@ func TestReviewUpdate(t *testing.T) {
 
 func TestReviewDelete(t *testing.T) {
 	const (
-		testUserID = 999
+		testUserID = 903
 		testBookID
 		testTitle = "TestReviewReview"
 		testText
@@ -153,6 +160,14 @@ func TestReviewDelete(t *testing.T) {
 	var deleteResp reviewpb.EmptyResponse
 	err = request(reviewDeleteSubj, &deleteReq, &deleteResp)
 	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, int(deleteResp.StatusCode))
+
+	err = requestAs(authz.Principal{UserID: testUserID + 1}, reviewDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(deleteResp.StatusCode))
+
+	err = requestAs(authz.Principal{UserID: testUserID}, reviewDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, int(deleteResp.StatusCode))
 	//////////////
@@ -167,3 +182,505 @@ func TestReviewDelete(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, int(getResp.StatusCode))
 }
+
+func TestReviewUpsert(t *testing.T) {
+	const (
+		testUserID = 904
+		testBookID
+		testTitle = "TestReviewUpsert"
+		testText
+		testScore = 1
+
+		testReplacedTitle = "TestReplacedReviewUpsert"
+		testReplacedText
+		testReplacedScore = 2
+	)
+	// Upsert new review
+	req := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   testText,
+		Score:  testScore,
+	}
+	var resp reviewpb.UpsertResponse
+	err := request(reviewUpsertSubj, &req, &resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, int(resp.StatusCode))
+	assert.True(t, resp.Created)
+	//////////////
+
+	// Check second put conflicts
+	var putResp reviewpb.CreateResponse
+	err = request(reviewPutSubj, &req, &putResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, int(putResp.StatusCode))
+	//////////////
+
+	// Upsert existing review
+	req.Title = testReplacedTitle
+	req.Text = testReplacedText
+	req.Score = testReplacedScore
+	var replaceResp reviewpb.UpsertResponse
+	err = request(reviewUpsertSubj, &req, &replaceResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(replaceResp.StatusCode))
+	assert.False(t, replaceResp.Created)
+	assert.Equal(t, resp.ReviewId, replaceResp.ReviewId)
+	//////////////
+
+	// Check replaced review
+	getReq := reviewpb.GetRequest{
+		ReviewId: resp.ReviewId,
+	}
+	var getResp reviewpb.GetResponse
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(getResp.StatusCode))
+	assert.Equal(t, testReplacedTitle, getResp.Title)
+	assert.Equal(t, testReplacedText, getResp.Text)
+	assert.Equal(t, testReplacedScore, int(getResp.Score))
+	//////////////
+
+	// Check upserting a long text again is not taken for a duplicate
+	req.Text = strings.Repeat("TestReviewUpsert is long enough to be screened. ", 2)
+	for range 2 {
+		err = request(reviewUpsertSubj, &req, &replaceResp)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, int(replaceResp.StatusCode))
+		assert.Equal(t, reviewpb.ModerationStatus_MODERATION_STATUS_PUBLISHED, replaceResp.Status)
+	}
+}
+
+func TestReviewUpsertConcurrent(t *testing.T) {
+	const (
+		testUserID = 914
+		testBookID
+		testTitle = "TestReviewUpsertConcurrent"
+		testText
+		testScore   = 3
+		testUpserts = 5
+	)
+	// Upsert the same review at once
+	resps := make([]reviewpb.UpsertResponse, testUpserts)
+	errs := make([]error, testUpserts)
+	var wg sync.WaitGroup
+	for i := range testUpserts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := reviewpb.CreateRequest{
+				UserId: testUserID,
+				BookId: testBookID,
+				Title:  testTitle,
+				Text:   testText,
+				Score:  testScore,
+			}
+			errs[i] = request(reviewUpsertSubj, &req, &resps[i])
+		}()
+	}
+	wg.Wait()
+	//////////////
+
+	// Check a single review was created
+	var created int
+	for i := range testUpserts {
+		require.NoError(t, errs[i])
+		assert.Contains(t, []int{http.StatusOK, http.StatusCreated}, int(resps[i].StatusCode))
+		assert.Equal(t, resps[0].ReviewId, resps[i].ReviewId)
+		if resps[i].Created {
+			created++
+		}
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestReviewVote(t *testing.T) {
+	const (
+		testUserID = 905
+		testBookID
+		testVoterID = 906
+		testTitle   = "TestReviewVote"
+		testText
+		testScore = 1
+	)
+	// Put review
+	putReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   testText,
+		Score:  testScore,
+	}
+	var putResp reviewpb.CreateResponse
+	err := request(reviewPutSubj, &putReq, &putResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(putResp.StatusCode))
+	//////////////
+
+	// Vote twice and change the vote
+	for _, helpful := range []bool{true, true, false} {
+		voteReq := reviewpb.VoteRequest{
+			ReviewId: putResp.ReviewId,
+			UserId:   testVoterID,
+			Helpful:  helpful,
+		}
+		var voteResp reviewpb.EmptyResponse
+		err = request(reviewVoteSubj, &voteReq, &voteResp)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, int(voteResp.StatusCode))
+	}
+	//////////////
+
+	// Check own review vote is rejected
+	voteReq := reviewpb.VoteRequest{
+		ReviewId: putResp.ReviewId,
+		UserId:   testUserID,
+		Helpful:  true,
+	}
+	var voteResp reviewpb.EmptyResponse
+	err = request(reviewVoteSubj, &voteReq, &voteResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, int(voteResp.StatusCode))
+	//////////////
+
+	// Check counts
+	getReq := reviewpb.GetRequest{
+		ReviewId: putResp.ReviewId,
+	}
+	var getResp reviewpb.GetResponse
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(getResp.StatusCode))
+	assert.Zero(t, getResp.HelpfulCount)
+	assert.EqualValues(t, 1, getResp.UnhelpfulCount)
+	//////////////
+
+	// Unvote
+	unvoteReq := reviewpb.UnvoteRequest{
+		ReviewId: putResp.ReviewId,
+		UserId:   testVoterID,
+	}
+	var unvoteResp reviewpb.EmptyResponse
+	err = request(reviewUnvoteSubj, &unvoteReq, &unvoteResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(unvoteResp.StatusCode))
+
+	getResp = reviewpb.GetResponse{}
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Zero(t, getResp.UnhelpfulCount)
+}
+
+func TestReviewComments(t *testing.T) {
+	const (
+		testUserID = 907
+		testBookID
+		testTitle = "TestReviewComments"
+		testText
+		testScore = 1
+	)
+	// Put review
+	putReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   testText,
+		Score:  testScore,
+	}
+	var putResp reviewpb.CreateResponse
+	err := request(reviewPutSubj, &putReq, &putResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(putResp.StatusCode))
+	//////////////
+
+	// Put comment and reply
+	commentReq := reviewpb.CreateCommentRequest{
+		ReviewId: putResp.ReviewId,
+		UserId:   testUserID,
+		Text:     "TestComment",
+	}
+	var commentResp reviewpb.CreateCommentResponse
+	err = request(commentPutSubj, &commentReq, &commentResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, int(commentResp.StatusCode))
+
+	replyReq := reviewpb.CreateCommentRequest{
+		ReviewId: putResp.ReviewId,
+		ParentId: &commentResp.CommentId,
+		UserId:   testUserID + 1,
+		Text:     "TestReply",
+	}
+	var replyResp reviewpb.CreateCommentResponse
+	err = request(commentPutSubj, &replyReq, &replyResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, int(replyResp.StatusCode))
+	//////////////
+
+	// Delete comment
+	deleteReq := reviewpb.DeleteCommentRequest{
+		ReviewId:  putResp.ReviewId,
+		CommentId: commentResp.CommentId,
+	}
+	var deleteResp reviewpb.EmptyResponse
+	err = requestAs(authz.Principal{UserID: testUserID + 1}, commentDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(deleteResp.StatusCode))
+
+	moderator := authz.Principal{UserID: testUserID + 2, Roles: []authz.Role{authz.RoleModerator}}
+	err = requestAs(moderator, commentDeleteSubj, &deleteReq, &deleteResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(deleteResp.StatusCode))
+	//////////////
+
+	// Check thread pages keep the deleted comment
+	listReq := reviewpb.ListCommentsRequest{
+		ReviewId: putResp.ReviewId,
+		Limit:    1,
+	}
+	var listResp reviewpb.ListCommentsResponse
+	err = request(commentListSubj, &listReq, &listResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(listResp.StatusCode))
+	require.Len(t, listResp.Comments, 1)
+	assert.True(t, listResp.Comments[0].Deleted)
+	assert.Empty(t, listResp.Comments[0].Text)
+
+	listReq.AfterId = listResp.NextAfterId
+	listResp = reviewpb.ListCommentsResponse{}
+	err = request(commentListSubj, &listReq, &listResp)
+	require.NoError(t, err)
+
+	require.Len(t, listResp.Comments, 1)
+	assert.Equal(t, "TestReply", listResp.Comments[0].Text)
+	assert.Equal(t, commentResp.CommentId, listResp.Comments[0].GetParentId())
+	assert.Zero(t, listResp.NextAfterId)
+}
+
+func TestReviewModeration(t *testing.T) {
+	const (
+		testUserID = 908
+		testBookID
+		testReporterID = 909
+		testTitle      = "TestReviewModeration"
+		testScore      = 5
+	)
+	// Put a flagged and a clean review
+	flaggedReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   "This is a forbidden text",
+		Score:  testScore,
+	}
+	var flaggedResp reviewpb.CreateResponse
+	err := request(reviewPutSubj, &flaggedReq, &flaggedResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(flaggedResp.StatusCode))
+	assert.Equal(t, reviewpb.ModerationStatus_MODERATION_STATUS_PENDING, flaggedResp.Status)
+
+	cleanReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID + 1,
+		Title:  testTitle,
+		Text:   "This is a fine text",
+		Score:  testScore,
+	}
+	var cleanResp reviewpb.CreateResponse
+	err = request(reviewPutSubj, &cleanReq, &cleanResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, reviewpb.ModerationStatus_MODERATION_STATUS_PUBLISHED, cleanResp.Status)
+	//////////////
+
+	// Check the pending review is not shown
+	getReq := reviewpb.GetRequest{
+		ReviewId: flaggedResp.ReviewId,
+	}
+	var getResp reviewpb.GetResponse
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, int(getResp.StatusCode))
+	//////////////
+
+	// Report the clean review twice
+	for _, code := range []int{http.StatusCreated, http.StatusConflict} {
+		reportReq := reviewpb.ReportRequest{
+			ReviewId: cleanResp.ReviewId,
+			UserId:   testReporterID,
+			Reason:   reviewpb.ReportReason_REPORT_REASON_SPOILER,
+		}
+		var reportResp reviewpb.EmptyResponse
+		err = request(reviewReportSubj, &reportReq, &reportResp)
+		require.NoError(t, err)
+
+		assert.Equal(t, code, int(reportResp.StatusCode))
+	}
+	//////////////
+
+	// Check both reviews are in the queue, shown to moderators only
+	moderator := authz.Principal{UserID: testReporterID + 1, Roles: []authz.Role{authz.RoleModerator}}
+	queueReq := reviewpb.QueueRequest{
+		Limit: 100,
+	}
+	var queueResp reviewpb.QueueResponse
+	err = requestAs(authz.Principal{UserID: testReporterID}, reviewQueueSubj, &queueReq, &queueResp)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, int(queueResp.StatusCode))
+
+	queueResp = reviewpb.QueueResponse{}
+	err = requestAs(moderator, reviewQueueSubj, &queueReq, &queueResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(queueResp.StatusCode))
+	queued := map[int64]int64{}
+	for _, review := range queueResp.Reviews {
+		queued[review.Id] = review.ReportCount
+	}
+	assert.Equal(t, map[int64]int64{flaggedResp.ReviewId: 0, cleanResp.ReviewId: 1}, queued)
+	//////////////
+
+	// Publish the flagged review and hide the reported one
+	for id, status := range map[int64]reviewpb.ModerationStatus{
+		flaggedResp.ReviewId: reviewpb.ModerationStatus_MODERATION_STATUS_PUBLISHED,
+		cleanResp.ReviewId:   reviewpb.ModerationStatus_MODERATION_STATUS_HIDDEN,
+	} {
+		moderateReq := reviewpb.ModerateRequest{
+			ReviewId: id,
+			Status:   status,
+		}
+		var moderateResp reviewpb.EmptyResponse
+		err = requestAs(moderator, reviewModerateSubj, &moderateReq, &moderateResp)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, int(moderateResp.StatusCode))
+	}
+
+	getResp = reviewpb.GetResponse{}
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(getResp.StatusCode))
+
+	getReq.ReviewId = cleanResp.ReviewId
+	getResp = reviewpb.GetResponse{}
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, int(getResp.StatusCode))
+
+	queueResp = reviewpb.QueueResponse{}
+	err = requestAs(moderator, reviewQueueSubj, &queueReq, &queueResp)
+	require.NoError(t, err)
+
+	assert.Empty(t, queueResp.Reviews)
+}
+
+func TestReviewHistory(t *testing.T) {
+	const (
+		testUserID = 910
+		testBookID
+		testTitle = "TestReviewHistory"
+	)
+	// Put review
+	putReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   "first",
+		Score:  1,
+	}
+	var putResp reviewpb.CreateResponse
+	err := request(reviewPutSubj, &putReq, &putResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(putResp.StatusCode))
+	//////////////
+
+	// Edit twice, the repeated edit changes nothing
+	for _, text := range []string{"second", "second"} {
+		updateReq := reviewpb.UpdateRequest{
+			Id:    putResp.ReviewId,
+			Title: testTitle,
+			Text:  text,
+			Score: 2,
+		}
+		var updateResp reviewpb.EmptyResponse
+		err = requestAs(authz.Principal{UserID: testUserID}, reviewUpdateSubj, &updateReq, &updateResp)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, int(updateResp.StatusCode))
+	}
+
+	upsertReq := reviewpb.CreateRequest{
+		UserId: testUserID,
+		BookId: testBookID,
+		Title:  testTitle,
+		Text:   "third",
+		Score:  3,
+	}
+	var upsertResp reviewpb.UpsertResponse
+	err = request(reviewUpsertSubj, &upsertReq, &upsertResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(upsertResp.StatusCode))
+	//////////////
+
+	// Check history
+	historyReq := reviewpb.HistoryRequest{
+		ReviewId: putResp.ReviewId,
+		Limit:    1,
+	}
+	var history []*reviewpb.Revision
+	for {
+		var historyResp reviewpb.HistoryResponse
+		err = request(reviewHistorySubj, &historyReq, &historyResp)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, int(historyResp.StatusCode))
+		history = append(history, historyResp.Revisions...)
+		if historyResp.NextAfter == 0 {
+			break
+		}
+		historyReq.After = historyResp.NextAfter
+	}
+
+	require.Len(t, history, 2)
+	assert.Equal(t, int32(1), history[0].Number)
+	assert.Equal(t, "first", history[0].Text)
+	assert.Equal(t, int32(1), history[0].Score)
+	assert.Equal(t, int32(2), history[1].Number)
+	assert.Equal(t, "second", history[1].Text)
+	assert.Equal(t, int32(2), history[1].Score)
+	//////////////
+
+	// Check updated_at
+	getReq := reviewpb.GetRequest{
+		ReviewId: putResp.ReviewId,
+	}
+	var getResp reviewpb.GetResponse
+	err = request(reviewGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "third", getResp.Text)
+	assert.Equal(t, history[1].EditedAt, getResp.UpdatedAt)
+	assert.GreaterOrEqual(t, getResp.UpdatedAt, getResp.CreatedAt)
+}
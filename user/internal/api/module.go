@@ -0,0 +1,11 @@
+package api
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/user/internal/api/nats"
+)
+
+var Module = fx.Module("api",
+	nats.Module,
+)
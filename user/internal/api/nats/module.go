@@ -0,0 +1,15 @@
+package nats
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		NewConnection,
+		NewUserConsumer,
+	),
+	fx.Invoke(
+		RegisterUserConsumer,
+	),
+)
@@ -0,0 +1,41 @@
+package nats
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	userpb "github.com/lunn06/library/user/internal/api/proto/user"
+)
+
+func NewConnection(cfg Config) (*nats.Conn, error) {
+	return nats.Connect(cfg.URL)
+}
+
+func NewResponder(msg *nats.Msg) Responder {
+	return Responder{msg: msg}
+}
+
+type Responder struct {
+	msg *nats.Msg
+}
+
+func (r Responder) Respond(req proto.Message) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		slog.Error("Failed to marshal request", "err", err)
+		data, err = proto.Marshal(&userpb.EmptyResponse{
+			StatusCode: http.StatusInternalServerError,
+		})
+		if err != nil {
+			slog.Error("Failed to marshal request", "err", err)
+			_ = r.msg.Nak()
+		}
+
+		_ = r.msg.Respond(data)
+	}
+
+	_ = r.msg.Respond(data)
+}
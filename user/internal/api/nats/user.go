@@ -0,0 +1,275 @@
+package nats
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/lunn06/library/bookinfo/pkg/nats/middleware"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	userpb "github.com/lunn06/library/user/internal/api/proto/user"
+	"github.com/lunn06/library/user/internal/app/service"
+	"github.com/lunn06/library/user/internal/app/service/errors"
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+func RegisterUserConsumer(conn *nats.Conn, cons *UserConsumer) error {
+	// the logger prints the messages, which carry passwords and tokens here
+	credentials := []middleware.Middleware{
+		middleware.Recover(),
+	}
+	mws := []middleware.Middleware{
+		middleware.Recover(),
+		middleware.Logger(slog.Default()),
+	}
+	for subj, handler := range map[string]nats.MsgHandler{
+		"user.register": middleware.With(cons.Register, credentials...),
+		"user.login":    middleware.With(cons.Login, credentials...),
+		"user.refresh":  middleware.With(cons.Refresh, credentials...),
+		"user.logout":   middleware.With(cons.Logout, credentials...),
+		"user.get":      middleware.With(cons.Get, mws...),
+		"user.update":   middleware.With(cons.Update, mws...),
+	} {
+		_, err := conn.Subscribe(subj, handler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewUserConsumer(auth *service.AuthService, users *service.UserService) *UserConsumer {
+	return &UserConsumer{
+		auth:  auth,
+		users: users,
+	}
+}
+
+type UserConsumer struct {
+	auth  *service.AuthService
+	users *service.UserService
+}
+
+func (uc UserConsumer) Register(msg *nats.Msg) {
+	var (
+		req  userpb.RegisterRequest
+		resp userpb.RegisterResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on user register", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	user, err := uc.auth.Register(context.Background(), service.RegisterRequest{
+		Email:       req.Email,
+		Password:    req.Password,
+		DisplayName: req.DisplayName,
+	})
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrAlreadyExists(err) {
+		resp.StatusCode = http.StatusConflict
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.UserId = int64(user.ID)
+	resp.StatusCode = http.StatusCreated
+}
+
+func (uc UserConsumer) Login(msg *nats.Msg) {
+	var (
+		req  userpb.LoginRequest
+		resp userpb.TokenResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil && !errors.IsErrUnauthenticated(err) {
+			slog.Error("Error on user login", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	tokens, err := uc.auth.Login(context.Background(), req.Email, req.Password)
+	setTokens(&resp, tokens, err)
+}
+
+func (uc UserConsumer) Refresh(msg *nats.Msg) {
+	var (
+		req  userpb.RefreshRequest
+		resp userpb.TokenResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil && !errors.IsErrUnauthenticated(err) {
+			slog.Error("Error on token refresh", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	tokens, err := uc.auth.Refresh(context.Background(), req.RefreshToken)
+	setTokens(&resp, tokens, err)
+}
+
+func (uc UserConsumer) Logout(msg *nats.Msg) {
+	var (
+		req  userpb.LogoutRequest
+		resp userpb.EmptyResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on user logout", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	if err = uc.auth.Logout(context.Background(), req.RefreshToken); err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.StatusCode = http.StatusOK
+}
+
+// Get returns the profile of the user, with the email for the user
+// themselves.
+func (uc UserConsumer) Get(msg *nats.Msg) {
+	var (
+		req  userpb.GetRequest
+		resp userpb.GetResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on user get", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	ctx := authz.MsgContext(msg)
+	user, err := uc.users.Get(ctx, int(req.UserId))
+	if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	setProfile(ctx, &resp, user)
+}
+
+func (uc UserConsumer) Update(msg *nats.Msg) {
+	var (
+		req  userpb.UpdateRequest
+		resp userpb.GetResponse
+		err  error
+
+		responder = NewResponder(msg)
+	)
+	defer func() {
+		if err != nil {
+			slog.Error("Error on user update", "err", err)
+		}
+		responder.Respond(&resp)
+	}()
+	if err = proto.Unmarshal(msg.Data, &req); err != nil {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	}
+
+	ctx := authz.MsgContext(msg)
+	user, err := uc.users.Update(ctx, service.UpdateRequest{
+		ID:          int(req.UserId),
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarUrl,
+		Bio:         req.Bio,
+	})
+	if errors.IsErrInvalidRequest(err) {
+		resp.StatusCode = http.StatusUnprocessableEntity
+		return
+	} else if errors.IsErrResourceNotFound(err) {
+		resp.StatusCode = http.StatusNotFound
+		return
+	} else if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if errors.IsErrForbidden(err) {
+		resp.StatusCode = http.StatusForbidden
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	setProfile(ctx, &resp, user)
+}
+
+func setTokens(resp *userpb.TokenResponse, tokens domain.Tokens, err error) {
+	if errors.IsErrUnauthenticated(err) {
+		resp.StatusCode = http.StatusUnauthorized
+		return
+	} else if err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		return
+	}
+
+	resp.AccessToken = tokens.Access
+	resp.AccessExpiresAt = tokens.AccessExpiresAt.Unix()
+	resp.RefreshToken = tokens.Refresh
+	resp.RefreshExpiresAt = tokens.RefreshExpiresAt.Unix()
+	resp.StatusCode = http.StatusOK
+}
+
+func setProfile(ctx context.Context, resp *userpb.GetResponse, user domain.User) {
+	resp.Id = int64(user.ID)
+	resp.DisplayName = user.DisplayName
+	resp.AvatarUrl = user.AvatarURL
+	resp.Bio = user.Bio
+	resp.CreatedAt = user.CreatedAt.Unix()
+	if authz.FromContext(ctx).UserID == user.ID {
+		resp.Email = &user.Email
+	}
+	resp.StatusCode = http.StatusOK
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/user/internal/app/password"
+	"github.com/lunn06/library/user/internal/app/repository"
+	"github.com/lunn06/library/user/internal/app/service"
+	"github.com/lunn06/library/user/internal/app/token"
+)
+
+var Module = fx.Module("app",
+	repository.Module,
+	password.Module,
+	token.Module,
+	service.Module,
+)
@@ -0,0 +1,7 @@
+package password
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(NewHasher),
+)
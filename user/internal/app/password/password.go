@@ -0,0 +1,114 @@
+// Package password hashes passwords with argon2id, encoding the hashes in
+// the PHC string format so the parameters can change over time.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// Config has the argon2id parameters of new hashes, the defaults follow
+// the second recommended option of RFC 9106.
+type Config struct {
+	// Memory is in KiB.
+	Memory      uint32 `default:"65536"`
+	Iterations  uint32 `default:"3"`
+	Parallelism uint8  `default:"4"`
+}
+
+func NewHasher(cfg Config) *Hasher {
+	return &Hasher{params: params{
+		memory:      cfg.Memory,
+		iterations:  cfg.Iterations,
+		parallelism: cfg.Parallelism,
+	}}
+}
+
+type Hasher struct {
+	params params
+}
+
+type params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := h.params.key(password, salt, keyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.memory, h.params.iterations, h.params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify tells whether the password matches the hash, made with any
+// parameters.
+func (h *Hasher) Verify(password string, hash string) (bool, error) {
+	p, salt, key, err := decode(hash)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, p.key(password, salt, uint32(len(key)))) == 1, nil
+}
+
+// NeedsRehash tells whether the hash was made with other parameters than
+// the configured ones.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	p, _, _, err := decode(hash)
+	return err != nil || p != h.params
+}
+
+func (p params) key(password string, salt []byte, length uint32) []byte {
+	return argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, length)
+}
+
+func decode(hash string) (params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params{}, nil, nil, fmt.Errorf("%w: version %q", ErrMalformedHash, parts[2])
+	}
+
+	var p params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
+	if err != nil || p.memory == 0 || p.iterations == 0 || p.parallelism == 0 {
+		return params{}, nil, nil, fmt.Errorf("%w: parameters %q", ErrMalformedHash, parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params{}, nil, nil, fmt.Errorf("%w: salt: %w", ErrMalformedHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params{}, nil, nil, fmt.Errorf("%w: key", ErrMalformedHash)
+	}
+
+	return p, salt, key, nil
+}
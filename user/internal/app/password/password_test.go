@@ -0,0 +1,55 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cheap parameters keep the tests fast
+var testConfig = Config{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestHashVerify(t *testing.T) {
+	h := NewHasher(testConfig)
+
+	hash, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	other, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salted")
+
+	ok, err := h.Verify("correct horse", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("battery staple", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(hash))
+	stronger := NewHasher(Config{Memory: 128, Iterations: 1, Parallelism: 1})
+	assert.True(t, stronger.NeedsRehash(hash))
+	ok, err = stronger.Verify("correct horse", hash)
+	require.NoError(t, err)
+	assert.True(t, ok, "old parameters still verify")
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	h := NewHasher(testConfig)
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+	} {
+		_, err := h.Verify("password", hash)
+		assert.ErrorIs(t, err, ErrMalformedHash, hash)
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			NewEntUserRepo,
+			fx.As(new(UserRepo)),
+		),
+		fx.Annotate(
+			NewEntTokenRepo,
+			fx.As(new(TokenRepo)),
+		),
+	),
+)
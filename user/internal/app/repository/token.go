@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lunn06/library/user/internal/app/repository/errors"
+	"github.com/lunn06/library/user/internal/domain"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent/refreshtoken"
+	"github.com/lunn06/library/user/internal/infrastructure/db/postgres"
+)
+
+type TokenRepo interface {
+	Put(ctx context.Context, token domain.RefreshToken) error
+	// Rotate revokes the valid token with the hash and stores next, issued
+	// to the same user, in its place. Tokens which are unknown, expired or
+	// revoked are not found, presenting a revoked token revokes all the
+	// tokens of its user as it was likely stolen.
+	Rotate(ctx context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error)
+	// Revoke revokes the token with the hash, if any.
+	Revoke(ctx context.Context, hash string) error
+}
+
+var _ TokenRepo = (*EntTokenRepo)(nil)
+
+func NewEntTokenRepo(client *ent.Client) *EntTokenRepo {
+	return &EntTokenRepo{client: client}
+}
+
+type EntTokenRepo struct {
+	client *ent.Client
+}
+
+func (tr *EntTokenRepo) Put(ctx context.Context, token domain.RefreshToken) error {
+	return tr.client.RefreshToken.
+		Create().
+		SetUserID(token.UserID).
+		SetTokenHash(token.Hash).
+		SetExpiresAt(token.ExpiresAt).
+		Exec(ctx)
+}
+
+func (tr *EntTokenRepo) Rotate(ctx context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error) {
+	var reused bool
+	err := postgres.WithTx(ctx, tr.client, func(tx *ent.Tx) error {
+		now := time.Now()
+		current, err := tx.RefreshToken.
+			Query().
+			Where(refreshtoken.TokenHash(hash)).
+			Only(ctx)
+		if ent.IsNotFound(err) {
+			return errors.ErrNotFound{Inner: err}
+		}
+		if err != nil {
+			return err
+		}
+		if !current.ExpiresAt.After(now) {
+			return errors.ErrNotFound{Inner: fmt.Errorf("refresh token %d expired", current.ID)}
+		}
+
+		// the condition makes concurrent rotations of a token revoke it once
+		revoked, err := tx.RefreshToken.
+			Update().
+			Where(
+				refreshtoken.ID(current.ID),
+				refreshtoken.RevokedAtIsNil(),
+			).
+			SetRevokedAt(now).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if revoked == 0 {
+			reused = true
+			return tx.RefreshToken.
+				Update().
+				Where(
+					refreshtoken.UserID(current.UserID),
+					refreshtoken.RevokedAtIsNil(),
+				).
+				SetRevokedAt(now).
+				Exec(ctx)
+		}
+
+		next.UserID = current.UserID
+		return tx.RefreshToken.
+			Create().
+			SetUserID(next.UserID).
+			SetTokenHash(next.Hash).
+			SetExpiresAt(next.ExpiresAt).
+			Exec(ctx)
+	})
+	if err == nil && reused {
+		err = errors.ErrNotFound{Inner: fmt.Errorf("refresh token reused, revoked the tokens of its user")}
+	}
+	if err != nil {
+		return domain.RefreshToken{}, err
+	}
+
+	return next, nil
+}
+
+func (tr *EntTokenRepo) Revoke(ctx context.Context, hash string) error {
+	return tr.client.RefreshToken.
+		Update().
+		Where(
+			refreshtoken.TokenHash(hash),
+			refreshtoken.RevokedAtIsNil(),
+		).
+		SetRevokedAt(time.Now()).
+		Exec(ctx)
+}
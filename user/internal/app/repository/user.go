@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lunn06/library/user/internal/app/repository/errors"
+	"github.com/lunn06/library/user/internal/domain"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent/converter"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent/user"
+)
+
+type UserRepo interface {
+	// Put stores the new user, failing with ErrAlreadyExists if the email
+	// is taken.
+	Put(ctx context.Context, u domain.User, passwordHash string) (domain.User, error)
+	Get(ctx context.Context, id int) (domain.User, error)
+	// GetByEmail returns the user with the email and their password hash.
+	GetByEmail(ctx context.Context, email string) (domain.User, string, error)
+	// UpdateProfile sets the display name, the avatar and the bio of the
+	// user.
+	UpdateProfile(ctx context.Context, u domain.User) (domain.User, error)
+	SetPasswordHash(ctx context.Context, id int, passwordHash string) error
+}
+
+var _ UserRepo = (*EntUserRepo)(nil)
+
+func NewEntUserRepo(client *ent.Client) *EntUserRepo {
+	return &EntUserRepo{UserClient: client.User}
+}
+
+type EntUserRepo struct {
+	*ent.UserClient
+}
+
+func (ur *EntUserRepo) Put(ctx context.Context, u domain.User, passwordHash string) (domain.User, error) {
+	entUser, err := ur.
+		Create().
+		SetEmail(u.Email).
+		SetPasswordHash(passwordHash).
+		SetDisplayName(u.DisplayName).
+		SetNillableAvatarURL(u.AvatarURL).
+		SetBio(u.Bio).
+		SetRoles(u.Roles).
+		Save(ctx)
+	if ent.IsConstraintError(err) {
+		return domain.User{}, errors.ErrAlreadyExists{Inner: err}
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return converter.UserToDomain(entUser), nil
+}
+
+func (ur *EntUserRepo) Get(ctx context.Context, id int) (domain.User, error) {
+	entUser, err := ur.UserClient.Get(ctx, id)
+	if ent.IsNotFound(err) {
+		return domain.User{}, errors.ErrNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return converter.UserToDomain(entUser), nil
+}
+
+func (ur *EntUserRepo) GetByEmail(ctx context.Context, email string) (domain.User, string, error) {
+	entUser, err := ur.
+		Query().
+		Where(user.Email(email)).
+		Only(ctx)
+	if ent.IsNotFound(err) {
+		return domain.User{}, "", errors.ErrNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.User{}, "", err
+	}
+
+	return converter.UserToDomain(entUser), entUser.PasswordHash, nil
+}
+
+func (ur *EntUserRepo) UpdateProfile(ctx context.Context, u domain.User) (domain.User, error) {
+	entUser, err := ur.
+		UpdateOneID(u.ID).
+		SetDisplayName(u.DisplayName).
+		SetNillableAvatarURL(u.AvatarURL).
+		SetBio(u.Bio).
+		Save(ctx)
+	if ent.IsNotFound(err) {
+		return domain.User{}, errors.ErrNotFound{Inner: err}
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return converter.UserToDomain(entUser), nil
+}
+
+func (ur *EntUserRepo) SetPasswordHash(ctx context.Context, id int, passwordHash string) error {
+	err := ur.
+		UpdateOneID(id).
+		SetPasswordHash(passwordHash).
+		Exec(ctx)
+	if ent.IsNotFound(err) {
+		return errors.ErrNotFound{Inner: err}
+	}
+
+	return err
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunn06/library/user/internal/app/password"
+	"github.com/lunn06/library/user/internal/app/repository"
+	repoerrors "github.com/lunn06/library/user/internal/app/repository/errors"
+	"github.com/lunn06/library/user/internal/app/service/errors"
+	"github.com/lunn06/library/user/internal/app/token"
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+type RegisterRequest struct {
+	Email       string
+	Password    string
+	DisplayName string
+}
+
+func NewAuthService(
+	users repository.UserRepo,
+	tokens repository.TokenRepo,
+	hasher *password.Hasher,
+	issuer *token.Issuer,
+) (*AuthService, error) {
+	// logins of unknown users verify this hash, taking as long as the
+	// others
+	dummyHash, err := hasher.Hash("dummy password")
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthService{
+		users:     users,
+		tokens:    tokens,
+		hasher:    hasher,
+		issuer:    issuer,
+		dummyHash: dummyHash,
+	}, nil
+}
+
+// AuthService registers users and logs them in, issuing their tokens.
+type AuthService struct {
+	users     repository.UserRepo
+	tokens    repository.TokenRepo
+	hasher    *password.Hasher
+	issuer    *token.Issuer
+	dummyHash string
+}
+
+func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (domain.User, error) {
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err = checkPassword(req.Password); err != nil {
+		return domain.User{}, err
+	}
+	displayName, err := normalizeDisplayName(req.DisplayName)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	u, err := s.users.Put(ctx, domain.User{
+		Email:       email,
+		DisplayName: displayName,
+	}, hash)
+	if repoerrors.IsErrAlreadyExists(err) {
+		return domain.User{}, errors.ErrAlreadyExists{Inner: fmt.Errorf("email %s: %w", email, err)}
+	}
+
+	return u, err
+}
+
+// Login issues the tokens of the user with the email and the password,
+// failing with ErrUnauthenticated for wrong credentials.
+func (s *AuthService) Login(ctx context.Context, email string, pass string) (domain.Tokens, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	u, hash, err := s.users.GetByEmail(ctx, email)
+	if repoerrors.IsErrNotFound(err) {
+		_, _ = s.hasher.Verify(pass, s.dummyHash)
+		return domain.Tokens{}, errors.ErrUnauthenticated{Inner: fmt.Errorf("no user with email %s", email)}
+	}
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+
+	ok, err := s.hasher.Verify(pass, hash)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	if !ok {
+		return domain.Tokens{}, errors.ErrUnauthenticated{Inner: fmt.Errorf("wrong password of user %d", u.ID)}
+	}
+
+	if s.hasher.NeedsRehash(hash) {
+		if hash, err = s.hasher.Hash(pass); err == nil {
+			err = s.users.SetPasswordHash(ctx, u.ID, hash)
+		}
+		if err != nil {
+			slog.Error("Failed to rehash password", "user", u.ID, "err", err)
+		}
+	}
+
+	refresh, stored, err := s.issuer.Refresh()
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	stored.UserID = u.ID
+	if err = s.tokens.Put(ctx, stored); err != nil {
+		return domain.Tokens{}, err
+	}
+
+	return s.tokensOf(u, refresh, stored)
+}
+
+// Refresh exchanges the refresh token for new tokens, the token is not
+// accepted again.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (domain.Tokens, error) {
+	refresh, stored, err := s.issuer.Refresh()
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	stored, err = s.tokens.Rotate(ctx, token.Hash(refreshToken), stored)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.Tokens{}, errors.ErrUnauthenticated{Inner: err}
+	}
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+
+	// the roles may have changed since the last token
+	u, err := s.users.Get(ctx, stored.UserID)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+
+	return s.tokensOf(u, refresh, stored)
+}
+
+// Logout revokes the refresh token, the access tokens stay valid until
+// they expire.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	return s.tokens.Revoke(ctx, token.Hash(refreshToken))
+}
+
+func (s *AuthService) tokensOf(u domain.User, refresh string, stored domain.RefreshToken) (domain.Tokens, error) {
+	access, accessExpiresAt, err := s.issuer.Access(u)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+
+	return domain.Tokens{
+		Access:           access,
+		AccessExpiresAt:  accessExpiresAt,
+		Refresh:          refresh,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.ErrInvalidRequest{Reason: fmt.Sprintf("invalid email %q", email)}
+	}
+
+	return email, nil
+}
+
+func checkPassword(pass string) error {
+	switch n := utf8.RuneCountInString(pass); {
+	case n < domain.MinPasswordLength:
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("password shorter than %d characters", domain.MinPasswordLength)}
+	case n > domain.MaxPasswordLength:
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("password longer than %d characters", domain.MaxPasswordLength)}
+	}
+
+	return nil
+}
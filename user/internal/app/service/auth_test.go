@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/user/internal/app/password"
+	"github.com/lunn06/library/user/internal/app/repository"
+	repoerrors "github.com/lunn06/library/user/internal/app/repository/errors"
+	"github.com/lunn06/library/user/internal/app/service/errors"
+	"github.com/lunn06/library/user/internal/app/token"
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+type memUser struct {
+	user domain.User
+	hash string
+}
+
+type memUserRepo struct {
+	repository.UserRepo
+	users []memUser
+}
+
+func (m *memUserRepo) Put(_ context.Context, u domain.User, hash string) (domain.User, error) {
+	for _, other := range m.users {
+		if other.user.Email == u.Email {
+			return domain.User{}, repoerrors.ErrAlreadyExists{Inner: fmt.Errorf("email %s", u.Email)}
+		}
+	}
+	u.ID = len(m.users) + 1
+	m.users = append(m.users, memUser{user: u, hash: hash})
+	return u, nil
+}
+
+func (m *memUserRepo) Get(_ context.Context, id int) (domain.User, error) {
+	if id < 1 || id > len(m.users) {
+		return domain.User{}, repoerrors.ErrNotFound{Inner: fmt.Errorf("user %d", id)}
+	}
+	return m.users[id-1].user, nil
+}
+
+func (m *memUserRepo) GetByEmail(_ context.Context, email string) (domain.User, string, error) {
+	for _, u := range m.users {
+		if u.user.Email == email {
+			return u.user, u.hash, nil
+		}
+	}
+	return domain.User{}, "", repoerrors.ErrNotFound{Inner: fmt.Errorf("email %s", email)}
+}
+
+func (m *memUserRepo) UpdateProfile(ctx context.Context, u domain.User) (domain.User, error) {
+	current, err := m.Get(ctx, u.ID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	current.DisplayName, current.AvatarURL, current.Bio = u.DisplayName, u.AvatarURL, u.Bio
+	m.users[u.ID-1].user = current
+	return current, nil
+}
+
+func (m *memUserRepo) SetPasswordHash(_ context.Context, id int, hash string) error {
+	m.users[id-1].hash = hash
+	return nil
+}
+
+type memTokenRepo struct {
+	tokens  map[string]domain.RefreshToken
+	revoked map[string]bool
+}
+
+func (m memTokenRepo) Put(_ context.Context, t domain.RefreshToken) error {
+	m.tokens[t.Hash] = t
+	return nil
+}
+
+func (m memTokenRepo) Rotate(_ context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error) {
+	current, ok := m.tokens[hash]
+	if !ok || m.revoked[hash] || !current.ExpiresAt.After(time.Now()) {
+		return domain.RefreshToken{}, repoerrors.ErrNotFound{Inner: fmt.Errorf("token %s", hash)}
+	}
+	m.revoked[hash] = true
+	next.UserID = current.UserID
+	m.tokens[next.Hash] = next
+	return next, nil
+}
+
+func (m memTokenRepo) Revoke(_ context.Context, hash string) error {
+	m.revoked[hash] = true
+	return nil
+}
+
+func newTestAuthService(t *testing.T, users *memUserRepo, cfg password.Config) *AuthService {
+	issuer, err := token.NewIssuer(token.Config{Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	require.NoError(t, err)
+	tokens := memTokenRepo{tokens: map[string]domain.RefreshToken{}, revoked: map[string]bool{}}
+	s, err := NewAuthService(users, tokens, password.NewHasher(cfg), issuer)
+	require.NoError(t, err)
+	return s
+}
+
+var cheapPassword = password.Config{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestRegisterLogin(t *testing.T) {
+	users := &memUserRepo{}
+	s := newTestAuthService(t, users, cheapPassword)
+	ctx := context.Background()
+
+	u, err := s.Register(ctx, RegisterRequest{Email: " Reader@Example.com ", Password: "long enough", DisplayName: " Reader "})
+	require.NoError(t, err)
+	assert.Equal(t, "reader@example.com", u.Email)
+	assert.Equal(t, "Reader", u.DisplayName)
+
+	_, err = s.Register(ctx, RegisterRequest{Email: "reader@example.com", Password: "long enough", DisplayName: "Other"})
+	assert.True(t, errors.IsErrAlreadyExists(err))
+	for _, req := range []RegisterRequest{
+		{Email: "not an email", Password: "long enough", DisplayName: "A"},
+		{Email: "Name <a@example.com>", Password: "long enough", DisplayName: "A"},
+		{Email: "a@example.com", Password: "short", DisplayName: "A"},
+		{Email: "a@example.com", Password: "long enough", DisplayName: " "},
+	} {
+		_, err = s.Register(ctx, req)
+		assert.True(t, errors.IsErrInvalidRequest(err), req)
+	}
+
+	tokens, err := s.Login(ctx, "READER@example.com", "long enough")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.Access)
+	assert.NotEmpty(t, tokens.Refresh)
+
+	_, err = s.Login(ctx, "reader@example.com", "wrong password")
+	assert.True(t, errors.IsErrUnauthenticated(err))
+	_, err = s.Login(ctx, "nobody@example.com", "long enough")
+	assert.True(t, errors.IsErrUnauthenticated(err))
+
+	rehashing := newTestAuthService(t, users, password.Config{Memory: 128, Iterations: 1, Parallelism: 1})
+	oldHash := users.users[0].hash
+	_, err = rehashing.Login(ctx, "reader@example.com", "long enough")
+	require.NoError(t, err)
+	assert.NotEqual(t, oldHash, users.users[0].hash, "rehashed with the new parameters")
+}
+
+func TestRefreshLogout(t *testing.T) {
+	s := newTestAuthService(t, &memUserRepo{}, cheapPassword)
+	ctx := context.Background()
+
+	_, err := s.Register(ctx, RegisterRequest{Email: "reader@example.com", Password: "long enough", DisplayName: "Reader"})
+	require.NoError(t, err)
+	tokens, err := s.Login(ctx, "reader@example.com", "long enough")
+	require.NoError(t, err)
+
+	refreshed, err := s.Refresh(ctx, tokens.Refresh)
+	require.NoError(t, err)
+	assert.NotEqual(t, tokens.Refresh, refreshed.Refresh)
+
+	_, err = s.Refresh(ctx, tokens.Refresh)
+	assert.True(t, errors.IsErrUnauthenticated(err), "refresh tokens are used once")
+
+	require.NoError(t, s.Logout(ctx, refreshed.Refresh))
+	_, err = s.Refresh(ctx, refreshed.Refresh)
+	assert.True(t, errors.IsErrUnauthenticated(err))
+}
+
+func TestUpdateProfile(t *testing.T) {
+	users := &memUserRepo{}
+	_, err := users.Put(context.Background(), domain.User{Email: "reader@example.com", DisplayName: "Reader"}, "")
+	require.NoError(t, err)
+	s := NewUserService(users)
+
+	avatar := "https://example.com/avatar.png"
+	req := UpdateRequest{ID: 1, DisplayName: "Avid Reader", AvatarURL: &avatar, Bio: "Reads a lot"}
+
+	_, err = s.Update(context.Background(), req)
+	assert.True(t, errors.IsErrUnauthenticated(err))
+	_, err = s.Update(authz.NewContext(context.Background(), authz.Principal{UserID: 2}), req)
+	assert.True(t, errors.IsErrForbidden(err))
+
+	ctx := authz.NewContext(context.Background(), authz.Principal{UserID: 1})
+	u, err := s.Update(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "Avid Reader", u.DisplayName)
+	assert.Equal(t, &avatar, u.AvatarURL)
+
+	bad := "javascript:alert(1)"
+	_, err = s.Update(ctx, UpdateRequest{ID: 1, DisplayName: "Reader", AvatarURL: &bad})
+	assert.True(t, errors.IsErrInvalidRequest(err))
+}
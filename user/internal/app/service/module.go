@@ -0,0 +1,12 @@
+package service
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		NewAuthService,
+		NewUserService,
+	),
+)
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+
+	"github.com/lunn06/library/user/internal/app/repository"
+	repoerrors "github.com/lunn06/library/user/internal/app/repository/errors"
+	"github.com/lunn06/library/user/internal/app/service/errors"
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+// UpdateRequest replaces the profile of the user.
+type UpdateRequest struct {
+	ID          int
+	DisplayName string
+	AvatarURL   *string
+	Bio         string
+}
+
+func NewUserService(users repository.UserRepo) *UserService {
+	return &UserService{users: users}
+}
+
+// UserService keeps the profiles of users.
+type UserService struct {
+	users repository.UserRepo
+}
+
+func (s *UserService) Get(ctx context.Context, id int) (domain.User, error) {
+	u, err := s.users.Get(ctx, id)
+	if repoerrors.IsErrNotFound(err) {
+		return domain.User{}, errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return u, err
+}
+
+// Update changes the profile on behalf of the user of the context, see
+// authz.Policy.
+func (s *UserService) Update(ctx context.Context, req UpdateRequest) (domain.User, error) {
+	err := authz.Authorize(ctx, authz.ResourceUser, authz.ActionUpdate, req.ID)
+	switch {
+	case stderrors.Is(err, authz.ErrUnauthenticated):
+		return domain.User{}, errors.ErrUnauthenticated{Inner: err}
+	case err != nil:
+		return domain.User{}, errors.ErrForbidden{Inner: err}
+	}
+
+	displayName, err := normalizeDisplayName(req.DisplayName)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if req.AvatarURL != nil {
+		if err = checkAvatarURL(*req.AvatarURL); err != nil {
+			return domain.User{}, err
+		}
+	}
+	if utf8.RuneCountInString(req.Bio) > domain.MaxBioLength {
+		return domain.User{}, errors.ErrInvalidRequest{Reason: fmt.Sprintf("bio longer than %d characters", domain.MaxBioLength)}
+	}
+
+	u, err := s.users.UpdateProfile(ctx, domain.User{
+		ID:          req.ID,
+		DisplayName: displayName,
+		AvatarURL:   req.AvatarURL,
+		Bio:         strings.TrimSpace(req.Bio),
+	})
+	if repoerrors.IsErrNotFound(err) {
+		return domain.User{}, errors.ErrResourceNotFound{Inner: err}
+	}
+
+	return u, err
+}
+
+func normalizeDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.ErrInvalidRequest{Reason: "empty display name"}
+	case utf8.RuneCountInString(name) > domain.MaxDisplayNameLength:
+		return "", errors.ErrInvalidRequest{Reason: fmt.Sprintf("display name longer than %d characters", domain.MaxDisplayNameLength)}
+	}
+
+	return name, nil
+}
+
+func checkAvatarURL(avatarURL string) error {
+	u, err := url.Parse(avatarURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.ErrInvalidRequest{Reason: fmt.Sprintf("invalid avatar url %q", avatarURL)}
+	}
+
+	return nil
+}
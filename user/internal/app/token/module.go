@@ -0,0 +1,7 @@
+package token
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(NewIssuer),
+)
@@ -0,0 +1,86 @@
+// Package token issues the access tokens accepted by the gateway and the
+// refresh tokens exchanged for them.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+const refreshTokenLength = 32
+
+// Config must match the auth config of the gateway: Secret is its
+// GATEWAY_AUTH_SECRET and the user id and the roles go in its default
+// sub and roles claims.
+type Config struct {
+	Secret     string        `required:"true"`
+	Issuer     string        `default:"library"`
+	AccessTTL  time.Duration `default:"15m" split_words:"true"`
+	RefreshTTL time.Duration `default:"720h" split_words:"true"`
+}
+
+func NewIssuer(cfg Config) (*Issuer, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("token: no secret configured")
+	}
+
+	return &Issuer{cfg: cfg, now: time.Now}, nil
+}
+
+type Issuer struct {
+	cfg Config
+	now func() time.Time
+}
+
+// Access returns a signed access token of the user and its expiry.
+func (i *Issuer) Access(user domain.User) (string, time.Time, error) {
+	now := i.now()
+	expiresAt := now.Add(i.cfg.AccessTTL)
+
+	claims := jwt.MapClaims{
+		"sub": strconv.Itoa(user.ID),
+		"iss": i.cfg.Issuer,
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+	}
+	if len(user.Roles) > 0 {
+		claims["roles"] = user.Roles
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(i.cfg.Secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return access, expiresAt, nil
+}
+
+// Refresh returns a new refresh token and its stored form, lacking the
+// user.
+func (i *Issuer) Refresh() (string, domain.RefreshToken, error) {
+	refresh := make([]byte, refreshTokenLength)
+	if _, err := rand.Read(refresh); err != nil {
+		return "", domain.RefreshToken{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(refresh)
+
+	return encoded, domain.RefreshToken{
+		Hash:      Hash(encoded),
+		ExpiresAt: i.now().Add(i.cfg.RefreshTTL),
+	}, nil
+}
+
+// Hash returns the stored form of a refresh token. The tokens are random,
+// so a plain digest is enough.
+func Hash(refresh string) string {
+	sum := sha256.Sum256([]byte(refresh))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lunn06/library/user/internal/domain"
+)
+
+func TestAccessAndRefresh(t *testing.T) {
+	issuer, err := NewIssuer(Config{Secret: "secret", Issuer: "library", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+	issuer.now = func() time.Time { return now }
+
+	access, expiresAt, err := issuer.Access(domain.User{ID: 7, Roles: []string{"moderator"}})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), expiresAt)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.NewParser(jwt.WithTimeFunc(func() time.Time { return now })).
+		ParseWithClaims(access, claims, func(*jwt.Token) (any, error) { return []byte("secret"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims["sub"])
+	assert.Equal(t, "library", claims["iss"])
+	assert.Equal(t, []any{"moderator"}, claims["roles"])
+
+	refresh, stored, err := issuer.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, domain.RefreshToken{Hash: Hash(refresh), ExpiresAt: now.Add(time.Hour)}, stored)
+	assert.NotEqual(t, refresh, stored.Hash)
+
+	next, _, err := issuer.Refresh()
+	require.NoError(t, err)
+	assert.NotEqual(t, refresh, next)
+}
+
+func TestNewIssuerRequiresSecret(t *testing.T) {
+	_, err := NewIssuer(Config{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/user/internal/api/nats"
+	"github.com/lunn06/library/user/internal/app/password"
+	"github.com/lunn06/library/user/internal/app/token"
+	"github.com/lunn06/library/user/internal/infrastructure/db/postgres"
+)
+
+type Config struct {
+	fx.Out
+
+	Nats     nats.Config
+	Postgres postgres.Config
+	Password password.Config
+	Token    token.Config
+}
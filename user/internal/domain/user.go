@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+const (
+	MinPasswordLength    = 8
+	MaxPasswordLength    = 256
+	MaxDisplayNameLength = 64
+	MaxBioLength         = 1000
+)
+
+type User struct {
+	ID          int
+	Email       string
+	DisplayName string
+	AvatarURL   *string
+	Bio         string
+	// Roles grant the user more than their own resources, see
+	// bookinfo/pkg/authz.
+	Roles     []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RefreshToken is a stored refresh token, only the hash of the token is
+// kept.
+type RefreshToken struct {
+	UserID    int
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// Tokens are issued on login and refresh. The access token is a JWT the
+// gateway accepts, the refresh token is exchanged for new tokens once.
+type Tokens struct {
+	Access           string
+	AccessExpiresAt  time.Time
+	Refresh          string
+	RefreshExpiresAt time.Time
+}
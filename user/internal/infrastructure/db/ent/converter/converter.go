@@ -0,0 +1,19 @@
+package converter
+
+import (
+	"github.com/lunn06/library/user/internal/domain"
+	"github.com/lunn06/library/user/internal/infrastructure/db/ent"
+)
+
+func UserToDomain(entUser *ent.User) domain.User {
+	return domain.User{
+		ID:          entUser.ID,
+		Email:       entUser.Email,
+		DisplayName: entUser.DisplayName,
+		AvatarURL:   entUser.AvatarURL,
+		Bio:         entUser.Bio,
+		Roles:       entUser.Roles,
+		CreatedAt:   entUser.CreatedAt,
+		UpdatedAt:   entUser.UpdatedAt,
+	}
+}
@@ -0,0 +1,11 @@
+package db
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/user/internal/infrastructure/db/postgres"
+)
+
+var Module = fx.Options(
+	postgres.Module,
+)
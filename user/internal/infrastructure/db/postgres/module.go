@@ -0,0 +1,7 @@
+package postgres
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(Connect),
+)
@@ -0,0 +1,11 @@
+package infrastructure
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/lunn06/library/user/internal/infrastructure/db"
+)
+
+var Module = fx.Module("infrastructure",
+	db.Module,
+)
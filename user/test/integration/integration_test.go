@@ -0,0 +1,178 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/nats-io/nats.go"
+	"github.com/testcontainers/testcontainers-go"
+	natscontainer "github.com/testcontainers/testcontainers-go/modules/nats"
+	postgrescontainer "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/protobuf/proto"
+
+	natsapi "github.com/lunn06/library/user/internal/api/nats"
+	"github.com/lunn06/library/user/internal/app/password"
+	"github.com/lunn06/library/user/internal/app/repository"
+	"github.com/lunn06/library/user/internal/app/service"
+	"github.com/lunn06/library/user/internal/app/token"
+	"github.com/lunn06/library/user/internal/config"
+	"github.com/lunn06/library/user/internal/infrastructure/db/postgres"
+)
+
+const reqTimeout = time.Second
+
+const (
+	userRegisterSubj = "user.register"
+	userLoginSubj    = "user.login"
+	userRefreshSubj  = "user.refresh"
+	userLogoutSubj   = "user.logout"
+	userGetSubj      = "user.get"
+	userUpdateSubj   = "user.update"
+)
+
+const (
+	postgresUser     = "test-user-user"
+	postgresPassword = "test-user-password"
+	postgresDb       = "test-user-db"
+	postgresSslMode  = "disable"
+)
+
+const tokenSecret = "test-secret"
+
+var nc *nats.Conn
+
+func TestMain(m *testing.M) {
+	natsC := natsContainer()
+	natsEndpoint, err := natsC.Endpoint(context.Background(), "")
+	if err != nil {
+		panic(err)
+	}
+
+	postgresC := postgresContainer()
+	postgresEndpoint, err := postgresC.Endpoint(context.Background(), "")
+	if err != nil {
+		panic(err)
+	}
+
+	var cfg config.Config
+	cfg.Nats = natsapi.Config{
+		URL: fmt.Sprintf("nats://%s", natsEndpoint),
+	}
+	cfg.Postgres.URL = postgresEndpoint
+	cfg.Postgres.User = postgresUser
+	cfg.Postgres.Password = postgresPassword
+	cfg.Postgres.DB = postgresDb
+	cfg.Postgres.SslMode = postgresSslMode
+
+	entClient, err := postgres.Connect(cfg.Postgres)
+	if err != nil {
+		panic(err)
+	}
+
+	nc, err = nats.Connect(cfg.Nats.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	issuer, err := token.NewIssuer(token.Config{
+		Secret:     tokenSecret,
+		Issuer:     "library",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	if err != nil {
+		panic(err)
+	}
+	userRepo := repository.NewEntUserRepo(entClient)
+	authService, err := service.NewAuthService(
+		userRepo,
+		repository.NewEntTokenRepo(entClient),
+		password.NewHasher(password.Config{Memory: 64, Iterations: 1, Parallelism: 1}),
+		issuer,
+	)
+	if err != nil {
+		panic(err)
+	}
+	userConsumer := natsapi.NewUserConsumer(authService, service.NewUserService(userRepo))
+
+	if err = natsapi.RegisterUserConsumer(nc, userConsumer); err != nil {
+		panic(err)
+	}
+
+	m.Run()
+
+	err = errors.Join(
+		nc.Drain(),
+		natsC.Terminate(context.Background()),
+		postgresC.Terminate(context.Background()),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func natsContainer() testcontainers.Container {
+	ctx := context.Background()
+	natsC, err := natscontainer.Run(ctx, "nats:2.11-alpine3.21")
+	if err != nil {
+		panic(err)
+	}
+
+	return natsC
+}
+
+func postgresContainer() testcontainers.Container {
+	ctx := context.Background()
+	postgresC, err := postgrescontainer.Run(ctx,
+		"postgres:17-alpine3.21",
+		postgrescontainer.WithUsername(postgresUser),
+		postgrescontainer.WithPassword(postgresPassword),
+		postgrescontainer.WithDatabase(postgresDb),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	return postgresC
+}
+
+func request(subj string, req, resp proto.Message) error {
+	return requestAs(authz.Principal{}, subj, req, resp)
+}
+
+// requestAs makes the request on behalf of the principal, as the gateway
+// does for authenticated users.
+func requestAs(p authz.Principal, subj string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	authz.SetHeader(msg.Header, p)
+
+	resMsg, err := nc.RequestMsg(msg, reqTimeout)
+	if err != nil {
+		return err
+	}
+
+	err = proto.Unmarshal(resMsg.Data, resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
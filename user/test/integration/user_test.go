@@ -0,0 +1,170 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lunn06/library/bookinfo/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	userpb "github.com/lunn06/library/user/internal/api/proto/user"
+)
+
+func TestUserAuth(t *testing.T) {
+	const (
+		testEmail    = "TestUserAuth@example.com"
+		testPassword = "TestUserAuthPassword"
+	)
+	// Register, twice
+	registerReq := userpb.RegisterRequest{
+		Email:       testEmail,
+		Password:    testPassword,
+		DisplayName: "TestUserAuth",
+	}
+	var registerResp userpb.RegisterResponse
+	err := request(userRegisterSubj, &registerReq, &registerResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusCreated, int(registerResp.StatusCode))
+
+	err = request(userRegisterSubj, &registerReq, &registerResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, int(registerResp.StatusCode))
+	//////////////
+
+	// Login with a wrong and the right password
+	loginReq := userpb.LoginRequest{
+		Email:    testEmail,
+		Password: "wrong",
+	}
+	var loginResp userpb.TokenResponse
+	err = request(userLoginSubj, &loginReq, &loginResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, int(loginResp.StatusCode))
+
+	loginReq.Password = testPassword
+	err = request(userLoginSubj, &loginReq, &loginResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(loginResp.StatusCode))
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(loginResp.AccessToken, claims, func(*jwt.Token) (any, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	require.NoError(t, err)
+	assert.Equal(t, strconv.FormatInt(registerResp.UserId, 10), claims["sub"])
+	//////////////
+
+	// Refresh, the old refresh token is used up
+	refreshReq := userpb.RefreshRequest{
+		RefreshToken: loginResp.RefreshToken,
+	}
+	var refreshResp userpb.TokenResponse
+	err = request(userRefreshSubj, &refreshReq, &refreshResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(refreshResp.StatusCode))
+	assert.NotEqual(t, loginResp.RefreshToken, refreshResp.RefreshToken)
+
+	var reusedResp userpb.TokenResponse
+	err = request(userRefreshSubj, &refreshReq, &reusedResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, int(reusedResp.StatusCode))
+
+	// the reuse revoked the tokens of the user
+	refreshReq.RefreshToken = refreshResp.RefreshToken
+	err = request(userRefreshSubj, &refreshReq, &reusedResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, int(reusedResp.StatusCode))
+	//////////////
+
+	// Logout
+	err = request(userLoginSubj, &loginReq, &loginResp)
+	require.NoError(t, err)
+
+	logoutReq := userpb.LogoutRequest{
+		RefreshToken: loginResp.RefreshToken,
+	}
+	var logoutResp userpb.EmptyResponse
+	err = request(userLogoutSubj, &logoutReq, &logoutResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, int(logoutResp.StatusCode))
+
+	refreshReq.RefreshToken = loginResp.RefreshToken
+	err = request(userRefreshSubj, &refreshReq, &refreshResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, int(refreshResp.StatusCode))
+}
+
+func TestUserProfile(t *testing.T) {
+	registerReq := userpb.RegisterRequest{
+		Email:       "TestUserProfile@example.com",
+		Password:    "TestUserProfilePassword",
+		DisplayName: "TestUserProfile",
+	}
+	var registerResp userpb.RegisterResponse
+	err := request(userRegisterSubj, &registerReq, &registerResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusCreated, int(registerResp.StatusCode))
+	owner := authz.Principal{UserID: int(registerResp.UserId)}
+	//////////////
+
+	// Update by another user and by the owner
+	avatar := "https://example.com/avatar.png"
+	updateReq := userpb.UpdateRequest{
+		UserId:      registerResp.UserId,
+		DisplayName: "UpdatedTestUserProfile",
+		AvatarUrl:   &avatar,
+		Bio:         "TestBio",
+	}
+	var updateResp userpb.GetResponse
+	err = requestAs(authz.Principal{UserID: owner.UserID + 1}, userUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, int(updateResp.StatusCode))
+
+	err = requestAs(owner, userUpdateSubj, &updateReq, &updateResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(updateResp.StatusCode))
+	//////////////
+
+	// Get, the email is shown to the owner only
+	getReq := userpb.GetRequest{
+		UserId: registerResp.UserId,
+	}
+	var getResp userpb.GetResponse
+	err = request(userGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, int(getResp.StatusCode))
+	assert.Equal(t, "UpdatedTestUserProfile", getResp.DisplayName)
+	assert.Equal(t, avatar, getResp.GetAvatarUrl())
+	assert.Equal(t, "TestBio", getResp.Bio)
+	assert.Nil(t, getResp.Email)
+
+	getResp = userpb.GetResponse{}
+	err = requestAs(owner, userGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "testuserprofile@example.com", getResp.GetEmail())
+
+	getReq.UserId = registerResp.UserId + 1000
+	getResp = userpb.GetResponse{}
+	err = request(userGetSubj, &getReq, &getResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, int(getResp.StatusCode))
+}